@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -12,11 +13,22 @@ import (
 	tgHandler "remnawave-tg-shop-bot/internal/adapter/telegram/handler"
 	tgMessenger "remnawave-tg-shop-bot/internal/adapter/telegram/messenger"
 	"remnawave-tg-shop-bot/internal/app"
+	"remnawave-tg-shop-bot/internal/observability"
 	"remnawave-tg-shop-bot/internal/pkg/config"
 	"remnawave-tg-shop-bot/internal/pkg/translation"
 	pg "remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/internal/service/faq"
+	"remnawave-tg-shop-bot/internal/service/moderation"
+	"remnawave-tg-shop-bot/internal/service/outbox"
+	"remnawave-tg-shop-bot/internal/service/panelevent"
 	"remnawave-tg-shop-bot/internal/service/payment"
+	"remnawave-tg-shop-bot/internal/service/promo"
+	"remnawave-tg-shop-bot/internal/service/provisioning"
+	"remnawave-tg-shop-bot/internal/service/referral"
+	"remnawave-tg-shop-bot/internal/service/stats"
+	"remnawave-tg-shop-bot/internal/service/support"
 	syncsvc "remnawave-tg-shop-bot/internal/service/sync"
+	"remnawave-tg-shop-bot/internal/service/trial"
 )
 
 // Version is set via -ldflags.
@@ -26,6 +38,7 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
+	slog.SetDefault(slog.New(observability.NewLogHandler(slog.NewTextHandler(os.Stderr, nil))))
 	slog.Info("starting bot", "version", Version)
 
 	a, err := app.New(ctx)
@@ -41,25 +54,109 @@ func main() {
 	referralRepo := pg.NewReferralRepository(a.Pool)
 	promoRepo := pg.NewPromocodeRepository(a.Pool)
 	promoUsageRepo := pg.NewPromocodeUsageRepository(a.Pool)
+	promoBatchRepo := pg.NewPromocodeBatchRepository(a.Pool)
+	referralRewardRepo := pg.NewReferralRewardRepository(a.Pool)
+	referralDecisionRepo := pg.NewReferralDecisionRepository(a.Pool)
+	referralWithdrawalRepo := pg.NewReferralWithdrawalRepository(a.Pool)
 
-	remClient := remnawave.NewClient(config.RemnawaveUrl(), config.RemnawaveToken(), config.RemnawaveMode())
+	remClient, err := remnawave.NewClient(config.RemnawaveUrl(), config.RemnawaveToken(), config.RemnawaveMode())
+	if err != nil {
+		slog.Error("init remnawave client", "err", err)
+		return
+	}
+	// a typo in the inbounds would create users without locations, an
+	// unavailable panel is left to the health check
+	if err := remClient.ValidateInbounds(ctx, config.InboundSets()); errors.Is(err, remnawave.ErrUnavailable) {
+		slog.Warn("validate inbounds: panel unavailable", "err", err)
+	} else if err != nil {
+		slog.Error("validate inbounds", "err", err)
+		return
+	}
 	cryptoClient := crypto.NewCryptoPayClient(config.CryptoPayUrl(), config.CryptoPayToken())
 	messenger := tgMessenger.NewBotMessenger(a.Bot)
 
+	outboxDispatcher := outbox.NewDispatcher(pg.NewOutboxRepository(a.Pool), messenger)
+
+	subscriptionRepo := pg.NewSubscriptionRepository(a.Pool)
+	provisioningSvc := provisioning.NewService(pg.NewProvisioningRepository(a.Pool), subscriptionRepo, remClient, customerRepo, messenger, outboxDispatcher, tm, config.ProvisioningMaxAttempts())
+
+	referralSvc := referral.NewService(referral.RulesFromConfig(), referralRepo, referralRewardRepo, referralDecisionRepo, referralWithdrawalRepo, purchaseRepo, customerRepo, provisioningSvc, outboxDispatcher, tm)
+	if err := referral.RegisterReleaseCron(a.Cron, referralSvc); err != nil {
+		slog.Error("schedule referral rewards cron", "err", err)
+		return
+	}
+
 	paySvc := payment.NewPaymentService(tm, purchaseRepo, remClient, customerRepo, messenger,
 		[]payment.Provider{
 			payment.NewCryptoPayProvider(purchaseRepo, cryptoClient),
 			payment.NewTributeProvider(purchaseRepo),
 		},
-		referralRepo, promoRepo, promoUsageRepo, a.Cache)
+		referralSvc, promoRepo, promoUsageRepo, a.Cache, provisioningSvc, outboxDispatcher)
+
+	a.Health.Register(observability.HealthCheck{Name: "remnawave", Check: remClient.Ping})
+	for _, p := range paySvc.EnabledProviders() {
+		if pinger, ok := p.(payment.Pinger); ok {
+			a.Health.Register(observability.HealthCheck{Name: "payment:" + string(p.Type()), Check: pinger.Ping, Optional: true})
+		}
+	}
 
 	syncSvc := syncsvc.NewSyncService(remClient, customerRepo)
+	if spec := config.SyncSchedule(); spec != "" {
+		if err := syncsvc.RegisterCron(a.Cron, syncSvc, spec); err != nil {
+			slog.Error("schedule panel sync cron", "err", err)
+			return
+		}
+	}
+	promoSvc := promo.NewService(promoBatchRepo)
+	trialSvc := trial.NewService(trial.RulesFromConfig(), pg.NewTrialUsageRepository(a.Pool), customerRepo, remClient, messenger)
 
-	h := tgHandler.NewHandler(syncSvc, paySvc, tm, customerRepo, purchaseRepo, referralRepo, promoRepo, promoUsageRepo, a.Cache)
+	blockRepo := pg.NewCustomerBlockRepository(a.Pool)
+	moderationSvc := moderation.NewService(blockRepo, pg.NewAdminAuditRepository(a.Pool), remClient)
+
+	faqSvc := faq.NewService(pg.NewFAQRepository(a.Pool))
+	supportSvc := support.NewService(pg.NewSupportRepository(a.Pool), customerRepo, purchaseRepo)
+
+	statsSvc := stats.NewService(pg.NewStatsRepository(a.Pool), customerRepo, messenger, tm)
+	if err := stats.RegisterGaugesCron(a.Cron, statsSvc); err != nil {
+		slog.Error("schedule subscription gauges cron", "err", err)
+		return
+	}
+	if hour := config.StatsReportHour(); hour >= 0 {
+		if err := stats.RegisterReportCron(a.Cron, statsSvc, hour); err != nil {
+			slog.Error("schedule stats report cron", "err", err)
+			return
+		}
+	}
+
+	if secret := config.RemnawaveWebhookSecret(); secret != "" {
+		panelEventSvc := panelevent.NewService(customerRepo, subscriptionRepo, blockRepo, outboxDispatcher, tm)
+		a.Mux.Handle(config.RemnawaveWebhookPath(), remnawave.WebhookHandler(secret, panelEventSvc.Handle))
+	}
+
+	h := tgHandler.NewHandler(syncSvc, paySvc, tm, customerRepo, purchaseRepo, referralRepo, promoRepo, promoUsageRepo, a.Cache, promoSvc, referralSvc, trialSvc, moderationSvc, faqSvc, supportSvc, statsSvc)
 
 	a.InitHandlers(h)
 
 	a.Start()
 
+	go provisioningSvc.Run(ctx)
+	go outboxDispatcher.Run(ctx)
+	go reloadTranslationsOnSIGHUP(ctx, a, h)
+
 	a.Bot.Start(ctx)
 }
+
+// reloadTranslationsOnSIGHUP reloads the translation overrides on every SIGHUP.
+func reloadTranslationsOnSIGHUP(ctx context.Context, a *app.App, h *tgHandler.Handler) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			h.ReloadTranslations(ctx, a.Bot)
+		}
+	}
+}
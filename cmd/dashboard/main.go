@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"remnawave-tg-shop-bot/internal/observability"
+)
+
+// dashboard writes the Grafana dashboard generated from the bot metrics.
+func main() {
+	out := flag.String("out", "docs/grafana-dashboard.json", "output file, - for stdout")
+	flag.Parse()
+
+	b, err := observability.Dashboard()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *out == "-" {
+		_, err = os.Stdout.Write(b)
+	} else {
+		err = os.WriteFile(*out, b, 0o644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+)
+
+const usage = `usage:
+  i18n check [-dir translations]`
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "check" {
+		log.Fatal(usage)
+	}
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	dir := fs.String("dir", "translations", "directory with <lang>.yml files")
+	_ = fs.Parse(os.Args[2:])
+
+	issues, err := translation.ValidateDir(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	if len(issues) > 0 {
+		log.Fatalf("%d translation issues", len(issues))
+	}
+	log.Printf("translations in %s are valid", *dir)
+}
@@ -21,7 +21,7 @@ func main() {
 
 	if err := pg.RunMigrations(ctx, &pg.MigrationConfig{
 		Direction:      "up",
-		MigrationsPath: "./db/migrations",
+		MigrationsPath: config.MigrationsDir(),
 		Steps:          0,
 	}, pool); err != nil {
 		log.Fatalf("migrate: %v", err)
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"remnawave-tg-shop-bot/internal/app"
+	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/internal/service/promo"
+)
+
+const usage = `usage:
+  promo generate -count N -months M -uses U -tag TAG [-prefix P | -code C] [-created-by ID] [-out FILE]
+  promo export -batch ID [-out FILE]
+  promo stats -batch ID`
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+	config.InitConfig()
+	ctx := context.Background()
+
+	pool, err := app.InitDatabase(ctx, config.DatabaseURL())
+	if err != nil {
+		log.Fatalf("init db: %v", err)
+	}
+	defer pool.Close()
+
+	svc := promo.NewService(pg.NewPromocodeBatchRepository(pool))
+
+	switch os.Args[1] {
+	case "generate":
+		err = generate(ctx, svc, os.Args[2:])
+	case "export":
+		err = export(ctx, svc, os.Args[2:])
+	case "stats":
+		err = stats(ctx, svc, os.Args[2:])
+	default:
+		err = fmt.Errorf("unknown command %q\n%s", os.Args[1], usage)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func generate(ctx context.Context, svc *promo.Service, args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	var req promo.BatchRequest
+	fs.IntVar(&req.Count, "count", 1, "number of codes")
+	fs.IntVar(&req.Months, "months", 1, "subscription months (1, 3 or 6)")
+	fs.IntVar(&req.Uses, "uses", 1, "activations per code")
+	fs.StringVar(&req.Tag, "tag", "", "campaign or partner name")
+	fs.StringVar(&req.Prefix, "prefix", "", "code prefix")
+	fs.StringVar(&req.Code, "code", "", "single vanity code")
+	fs.Int64Var(&req.CreatedBy, "created-by", 0, "creator telegram id (defaults to an id from ADMIN_TELEGRAM_IDS)")
+	out := fs.String("out", "", "write CSV to file instead of stdout")
+	_ = fs.Parse(args)
+
+	if req.CreatedBy == 0 {
+		admins := config.GetAdminTelegramIds()
+		if len(admins) == 0 {
+			return fmt.Errorf("-created-by is required when ADMIN_TELEGRAM_IDS is empty")
+		}
+		req.CreatedBy = admins[0]
+	}
+
+	batch, codes, err := svc.GenerateBatch(ctx, req)
+	if err != nil {
+		return fmt.Errorf("generate batch: %w", err)
+	}
+	log.Printf("batch #%d %q created with %d codes", batch.ID, batch.Tag, len(codes))
+	return writeCSV(ctx, svc, batch.ID, *out)
+}
+
+func export(ctx context.Context, svc *promo.Service, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	id := fs.Int64("batch", 0, "batch id")
+	out := fs.String("out", "", "write CSV to file instead of stdout")
+	_ = fs.Parse(args)
+	return writeCSV(ctx, svc, *id, *out)
+}
+
+func stats(ctx context.Context, svc *promo.Service, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	id := fs.Int64("batch", 0, "batch id")
+	_ = fs.Parse(args)
+
+	batch, err := svc.Batch(ctx, *id)
+	if err != nil {
+		return err
+	}
+	st, err := svc.Stats(ctx, *id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("batch:    #%d %s\nissued:   %d\nredeemed: %d\nrevenue:  %s\n",
+		batch.ID, batch.Tag, st.Issued, st.Redeemed, promo.FormatRevenue(st.Revenue))
+	return nil
+}
+
+func writeCSV(ctx context.Context, svc *promo.Service, id int64, out string) error {
+	data, err := svc.ExportCSV(ctx, id)
+	if err != nil {
+		return fmt.Errorf("export batch: %w", err)
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(out, data, 0o600)
+}
@@ -0,0 +1,2 @@
+ALTER TABLE promocode DROP CONSTRAINT IF EXISTS promocode_uses_left_non_negative;
+ALTER TABLE promocode_usage DROP CONSTRAINT IF EXISTS promocode_usage_promocode_id_used_by_unique;
@@ -0,0 +1,13 @@
+DELETE FROM promocode_usage a
+    USING promocode_usage b
+WHERE a.promocode_id = b.promocode_id
+  AND a.used_by = b.used_by
+  AND a.id > b.id;
+
+ALTER TABLE promocode_usage
+    ADD CONSTRAINT promocode_usage_promocode_id_used_by_unique UNIQUE (promocode_id, used_by);
+
+UPDATE promocode SET uses_left = 0 WHERE uses_left < 0;
+
+ALTER TABLE promocode
+    ADD CONSTRAINT promocode_uses_left_non_negative CHECK (uses_left >= 0);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_promocode_usage_promocode_id;
+DROP INDEX IF EXISTS idx_promocode_batch_id;
+ALTER TABLE promocode DROP COLUMN IF EXISTS batch_id;
+DROP TABLE IF EXISTS promocode_batch;
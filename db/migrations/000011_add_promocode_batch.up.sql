@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS promocode_batch (
+    id            BIGSERIAL PRIMARY KEY,
+    tag           VARCHAR(64) NOT NULL,
+    prefix        VARCHAR(32),
+    months        INT         NOT NULL,
+    uses_per_code INT         NOT NULL,
+    size          INT         NOT NULL,
+    created_by    BIGINT      NOT NULL REFERENCES customer (telegram_id) ON DELETE CASCADE,
+    created_at    TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE promocode
+    ADD COLUMN IF NOT EXISTS batch_id BIGINT REFERENCES promocode_batch (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_promocode_batch_id ON promocode (batch_id);
+CREATE INDEX IF NOT EXISTS idx_promocode_usage_promocode_id ON promocode_usage (promocode_id);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS referral_reward (
+    id             BIGSERIAL PRIMARY KEY,
+    beneficiary_id BIGINT         NOT NULL REFERENCES customer (telegram_id) ON DELETE CASCADE,
+    referee_id     BIGINT         NOT NULL REFERENCES customer (telegram_id) ON DELETE CASCADE,
+    purchase_id    BIGINT         REFERENCES purchase (id) ON DELETE SET NULL,
+    level          SMALLINT       NOT NULL,
+    kind           VARCHAR(10)    NOT NULL,
+    amount         DECIMAL(20, 8) NOT NULL DEFAULT 0,
+    days           INT            NOT NULL DEFAULT 0,
+    status         VARCHAR(20)    NOT NULL DEFAULT 'held',
+    available_at   TIMESTAMPTZ    NOT NULL,
+    released_at    TIMESTAMPTZ,
+    created_at     TIMESTAMPTZ    DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT referral_reward_purchase_level_kind_unique UNIQUE (purchase_id, level, kind)
+);
+
+CREATE INDEX IF NOT EXISTS idx_referral_reward_beneficiary_id ON referral_reward (beneficiary_id);
+CREATE INDEX IF NOT EXISTS idx_referral_reward_held ON referral_reward (available_at) WHERE status = 'held';
+
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_referral_reward_review;
+DROP TABLE IF EXISTS referral_decision;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS referral_decision (
+    id          BIGSERIAL PRIMARY KEY,
+    referrer_id BIGINT      NOT NULL,
+    referee_id  BIGINT      NOT NULL,
+    purchase_id BIGINT      REFERENCES purchase (id) ON DELETE SET NULL,
+    decision    VARCHAR(20) NOT NULL,
+    reason      VARCHAR(40) NOT NULL,
+    created_at  TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_referral_decision_referrer_id ON referral_decision (referrer_id);
+CREATE INDEX IF NOT EXISTS idx_referral_reward_review ON referral_reward (beneficiary_id) WHERE status = 'review';
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS referral_withdrawal (
+    id           BIGSERIAL PRIMARY KEY,
+    customer_id  BIGINT         NOT NULL REFERENCES customer (telegram_id) ON DELETE CASCADE,
+    amount       DECIMAL(20, 8) NOT NULL,
+    details      TEXT           NOT NULL,
+    status       VARCHAR(20)    NOT NULL DEFAULT 'held',
+    admin_id     BIGINT,
+    processed_at TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ    DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_referral_withdrawal_customer_id ON referral_withdrawal (customer_id);
+CREATE INDEX IF NOT EXISTS idx_referral_withdrawal_held ON referral_withdrawal (created_at) WHERE status = 'held';
@@ -0,0 +1,13 @@
+-- No foreign key: the record must outlive the customer row, which /sync may delete.
+CREATE TABLE IF NOT EXISTS trial_usage (
+    telegram_id   BIGINT PRIMARY KEY,
+    variant       VARCHAR(50) NOT NULL,
+    trial_used_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Customers with a subscription link were not eligible for a trial before.
+INSERT INTO trial_usage (telegram_id, variant, trial_used_at)
+SELECT telegram_id, 'paid', COALESCE(created_at, CURRENT_TIMESTAMP)
+FROM customer
+WHERE subscription_link IS NOT NULL
+ON CONFLICT (telegram_id) DO NOTHING;
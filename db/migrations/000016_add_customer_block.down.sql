@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS admin_audit;
+
+ALTER TABLE customer
+    DROP COLUMN IF EXISTS block_notified,
+    DROP COLUMN IF EXISTS blocked_panel,
+    DROP COLUMN IF EXISTS blocked_until,
+    DROP COLUMN IF EXISTS blocked_reason,
+    DROP COLUMN IF EXISTS blocked_at;
@@ -0,0 +1,17 @@
+ALTER TABLE customer
+    ADD COLUMN IF NOT EXISTS blocked_at       TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS blocked_reason   TEXT,
+    ADD COLUMN IF NOT EXISTS blocked_until    TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS blocked_panel    BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS block_notified   BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS admin_audit (
+    id         BIGSERIAL PRIMARY KEY,
+    admin_id   BIGINT      NOT NULL,
+    action     VARCHAR(50) NOT NULL,
+    target_id  BIGINT      NOT NULL,
+    details    TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_audit_target_id ON admin_audit (target_id);
@@ -0,0 +1,4 @@
+-- Set when the user picked the language with /language, stops overwriting it
+-- with the Telegram client language.
+ALTER TABLE customer
+    ADD COLUMN IF NOT EXISTS language_chosen BOOLEAN NOT NULL DEFAULT FALSE;
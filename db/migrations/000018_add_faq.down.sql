@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS faq_article;
+DROP TABLE IF EXISTS faq_category;
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS faq_category (
+    id         BIGSERIAL PRIMARY KEY,
+    language   VARCHAR(10) NOT NULL,
+    title      TEXT        NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_faq_category_language ON faq_category (language);
+
+-- Articles without a category are info pages (terms of service, privacy
+-- policy) addressed by slug.
+CREATE TABLE IF NOT EXISTS faq_article (
+    id          BIGSERIAL PRIMARY KEY,
+    category_id BIGINT REFERENCES faq_category (id) ON DELETE CASCADE,
+    language    VARCHAR(10) NOT NULL,
+    slug        VARCHAR(32),
+    title       TEXT        NOT NULL,
+    body        TEXT        NOT NULL,
+    created_at  TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_faq_article_category_id ON faq_article (category_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_faq_article_slug ON faq_article (slug, language) WHERE slug IS NOT NULL;
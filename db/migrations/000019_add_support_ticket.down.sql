@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS support_canned_response;
+DROP TABLE IF EXISTS support_ticket;
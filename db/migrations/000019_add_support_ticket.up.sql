@@ -0,0 +1,19 @@
+-- A customer has a single ticket bound to their forum topic in the support
+-- group, it is reopened when they contact support again.
+CREATE TABLE IF NOT EXISTS support_ticket (
+    id          BIGSERIAL PRIMARY KEY,
+    customer_id BIGINT      NOT NULL UNIQUE REFERENCES customer (telegram_id) ON DELETE CASCADE,
+    thread_id   BIGINT      NOT NULL,
+    status      VARCHAR(10) NOT NULL DEFAULT 'open',
+    created_at  TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_support_ticket_thread_id ON support_ticket (thread_id);
+
+CREATE TABLE IF NOT EXISTS support_canned_response (
+    id         BIGSERIAL PRIMARY KEY,
+    name       VARCHAR(32) NOT NULL UNIQUE,
+    text       TEXT        NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
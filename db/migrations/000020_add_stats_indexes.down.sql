@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_promocode_usage_used_at;
+DROP INDEX IF EXISTS idx_referral_withdrawal_processed_at;
+DROP INDEX IF EXISTS idx_referral_reward_released_at;
+DROP INDEX IF EXISTS idx_referral_used_at;
+DROP INDEX IF EXISTS idx_trial_usage_used_at;
+DROP INDEX IF EXISTS idx_purchase_customer_paid;
+DROP INDEX IF EXISTS idx_purchase_paid_at;
+DROP INDEX IF EXISTS idx_customer_expire_at;
+DROP INDEX IF EXISTS idx_customer_created_at;
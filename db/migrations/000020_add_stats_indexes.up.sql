@@ -0,0 +1,10 @@
+-- Period filters of the admin statistics.
+CREATE INDEX IF NOT EXISTS idx_customer_created_at ON customer (created_at);
+CREATE INDEX IF NOT EXISTS idx_customer_expire_at ON customer (expire_at);
+CREATE INDEX IF NOT EXISTS idx_purchase_paid_at ON purchase (paid_at) WHERE status = 'paid';
+CREATE INDEX IF NOT EXISTS idx_purchase_customer_paid ON purchase (customer_id, paid_at) WHERE status = 'paid';
+CREATE INDEX IF NOT EXISTS idx_trial_usage_used_at ON trial_usage (trial_used_at);
+CREATE INDEX IF NOT EXISTS idx_referral_used_at ON referral (used_at);
+CREATE INDEX IF NOT EXISTS idx_referral_reward_released_at ON referral_reward (released_at) WHERE status = 'released';
+CREATE INDEX IF NOT EXISTS idx_referral_withdrawal_processed_at ON referral_withdrawal (processed_at) WHERE status = 'paid';
+CREATE INDEX IF NOT EXISTS idx_promocode_usage_used_at ON promocode_usage (used_at);
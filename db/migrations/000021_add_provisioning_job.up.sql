@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS provisioning_job (
+    id               BIGSERIAL PRIMARY KEY,
+    customer_id      BIGINT      NOT NULL REFERENCES customer (id) ON DELETE CASCADE,
+    telegram_id      BIGINT      NOT NULL,
+    days             INT         NOT NULL CHECK (days > 0),
+    source           VARCHAR(20) NOT NULL,
+    status           VARCHAR(20) NOT NULL DEFAULT 'pending',
+    target_expire_at TIMESTAMPTZ,
+    message_id       INT,
+    attempts         INT         NOT NULL DEFAULT 0,
+    last_error       TEXT,
+    run_at           TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at       TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    done_at          TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_provisioning_job_pending ON provisioning_job (run_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_provisioning_job_telegram_id ON provisioning_job (telegram_id);
@@ -0,0 +1,3 @@
+ALTER TABLE customer
+    DROP COLUMN IF EXISTS unreachable_at;
+DROP TABLE IF EXISTS notification_outbox;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS notification_outbox (
+    id           BIGSERIAL PRIMARY KEY,
+    chat_id      BIGINT      NOT NULL,
+    text         TEXT        NOT NULL,
+    parse_mode   VARCHAR(20) NOT NULL DEFAULT '',
+    reply_markup JSONB,
+    status       VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts     INT         NOT NULL DEFAULT 0,
+    last_error   TEXT,
+    run_at       TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at   TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    sent_at      TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_outbox_pending ON notification_outbox (run_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_notification_outbox_chat_id ON notification_outbox (chat_id) WHERE status = 'pending';
+
+-- Set when Telegram refuses messages to the customer for good, e.g. the bot was
+-- blocked. Cleared when the customer uses the bot again.
+ALTER TABLE customer
+    ADD COLUMN IF NOT EXISTS unreachable_at TIMESTAMPTZ;
@@ -0,0 +1 @@
+ALTER TABLE customer DROP COLUMN IF EXISTS missing_from_panel_at;
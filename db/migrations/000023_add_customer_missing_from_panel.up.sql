@@ -0,0 +1,5 @@
+-- Set by the panel sync when the customer has a subscription but no panel user,
+-- cleared when the user shows up again. Such customers are kept with their
+-- balance and history instead of being deleted.
+ALTER TABLE customer
+    ADD COLUMN IF NOT EXISTS missing_from_panel_at TIMESTAMPTZ;
@@ -0,0 +1,2 @@
+ALTER TABLE provisioning_job DROP COLUMN IF EXISTS subscription_id;
+DROP TABLE IF EXISTS subscription;
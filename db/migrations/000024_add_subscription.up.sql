@@ -0,0 +1,24 @@
+-- Panel users of a customer. The primary subscription has no panel_username and
+-- is the user found by telegram id; its link and expiration stay on the customer.
+-- Extra subscriptions get their own panel user named after the row.
+CREATE TABLE IF NOT EXISTS subscription (
+    id                BIGSERIAL PRIMARY KEY,
+    customer_id       BIGINT NOT NULL REFERENCES customer (id) ON DELETE CASCADE,
+    panel_uuid        UUID UNIQUE,
+    panel_username    VARCHAR(64) UNIQUE,
+    expire_at         TIMESTAMPTZ,
+    subscription_link TEXT,
+    created_at        TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_customer_id ON subscription (customer_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_subscription_primary ON subscription (customer_id) WHERE panel_username IS NULL;
+
+INSERT INTO subscription (customer_id, expire_at, subscription_link)
+SELECT id, expire_at, subscription_link
+FROM customer
+WHERE subscription_link IS NOT NULL;
+
+-- The subscription the job extends, NULL for jobs queued before subscriptions.
+ALTER TABLE provisioning_job
+    ADD COLUMN IF NOT EXISTS subscription_id BIGINT REFERENCES subscription (id) ON DELETE CASCADE;
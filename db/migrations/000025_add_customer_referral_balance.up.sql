@@ -0,0 +1,10 @@
+-- Unspent referral money in the balance, the only part that can be withdrawn.
+-- Purchases spend it before top-ups.
+ALTER TABLE customer ADD COLUMN IF NOT EXISTS referral_balance DECIMAL(20,8) NOT NULL DEFAULT 0;
+
+UPDATE customer c
+SET referral_balance = GREATEST(LEAST(c.balance,
+    COALESCE((SELECT SUM(amount) FROM referral_reward
+              WHERE beneficiary_id = c.telegram_id AND kind = 'money' AND status = 'released'), 0)
+    - COALESCE((SELECT SUM(amount) FROM referral_withdrawal
+                WHERE customer_id = c.telegram_id AND status IN ('held', 'paid')), 0)), 0);
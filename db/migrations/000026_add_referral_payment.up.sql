@@ -0,0 +1,6 @@
+-- Paid purchases whose referral rewards are not recorded yet. The row is stored
+-- with the payment and removed once the rewards are, so a failure is retried.
+CREATE TABLE IF NOT EXISTS referral_payment (
+    purchase_id BIGINT PRIMARY KEY REFERENCES purchase (id) ON DELETE CASCADE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1 @@
+ALTER TABLE provisioning_job DROP COLUMN IF EXISTS plan_months;
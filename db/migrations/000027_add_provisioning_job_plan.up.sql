@@ -0,0 +1,5 @@
+-- Months of the plan a job was bought for, the user gets the inbounds of the
+-- plan. Jobs without a plan, e.g. promocodes, leave the inbounds as they are.
+ALTER TABLE provisioning_job ADD COLUMN IF NOT EXISTS plan_months INT;
+
+UPDATE provisioning_job SET plan_months = days / 30 WHERE source = 'balance';
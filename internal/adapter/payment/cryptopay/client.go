@@ -2,16 +2,20 @@ package cryptopay
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/observability"
 )
 
 type CryptoPayApi interface {
-	CreateInvoice(invoiceReq *InvoiceRequest) (*InvoiceResponse, error)
-	GetInvoices(status, fiat, asset, invoiceIds string, offset, limit int) (*[]InvoiceResponse, error)
+	CreateInvoice(ctx context.Context, invoiceReq *InvoiceRequest) (*InvoiceResponse, error)
+	GetInvoices(ctx context.Context, status, fiat, asset, invoiceIds string, offset, limit int) (*[]InvoiceResponse, error)
 }
 
 type Client struct {
@@ -22,20 +26,69 @@ type Client struct {
 
 func NewCryptoPayClient(url string, tokn string) *Client {
 	return &Client{
-		httpClient: &http.Client{},
+		httpClient: &http.Client{Transport: observability.Transport("cryptopay", http.DefaultTransport)},
 		baseURL:    url,
 		token:      tokn,
 	}
 }
 
-func (c *Client) CreateInvoice(invoiceReq *InvoiceRequest) (*InvoiceResponse, error) {
+func (c *Client) CreateInvoice(ctx context.Context, invoiceReq *InvoiceRequest) (*InvoiceResponse, error) {
+	start := time.Now()
+	invoice, err := c.createInvoice(ctx, invoiceReq)
+	observability.ObserveDependency("cryptopay", "create_invoice", start, err)
+	return invoice, err
+}
+
+func (c *Client) GetInvoices(ctx context.Context, status, fiat, asset, invoiceIds string, offset, limit int) (*[]InvoiceResponse, error) {
+	start := time.Now()
+	invoices, err := c.getInvoices(ctx, status, fiat, asset, invoiceIds, offset, limit)
+	observability.ObserveDependency("cryptopay", "get_invoices", start, err)
+	return invoices, err
+}
+
+// Ping checks the API token with the getMe method.
+func (c *Client) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := c.getMe(ctx)
+	observability.ObserveDependency("cryptopay", "get_me", start, err)
+	return err
+}
+
+func (c *Client) getMe(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/getMe", c.baseURL), nil)
+	if err != nil {
+		return fmt.Errorf("error while creating getMe req: %w", err)
+	}
+	req.Header.Set("Crypto-Pay-API-Token", c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error while making getMe req: %w", err)
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil {
+			slog.Error("close body", "err", cerr)
+		}
+	}()
+
+	var apiResp ResponseWrapper[json.RawMessage]
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return fmt.Errorf("error while decoding getMe resp, status %d: %w", resp.StatusCode, err)
+	}
+	if !apiResp.Ok {
+		return fmt.Errorf("API getMe failed. Status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (c *Client) createInvoice(ctx context.Context, invoiceReq *InvoiceRequest) (*InvoiceResponse, error) {
 	jsonData, err := json.Marshal(invoiceReq)
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling invoice: %w", err)
 	}
 
 	endpoint := fmt.Sprintf("%s/api/createInvoice", c.baseURL)
-	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("error while creating invoice req: %w", err)
 	}
@@ -74,9 +127,9 @@ func (c *Client) CreateInvoice(invoiceReq *InvoiceRequest) (*InvoiceResponse, er
 	return &apiResp.Result, nil
 }
 
-func (c *Client) GetInvoices(status, fiat, asset, invoiceIds string, offset, limit int) (*[]InvoiceResponse, error) {
+func (c *Client) getInvoices(ctx context.Context, status, fiat, asset, invoiceIds string, offset, limit int) (*[]InvoiceResponse, error) {
 	endpoint := fmt.Sprintf("%s/api/getInvoices", c.baseURL)
-	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error while creating request: %w", err)
 	}
@@ -2,7 +2,6 @@ package remnawave
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -13,6 +12,9 @@ import (
 	remapi "github.com/Jolymmiles/remnawave-api-go/api"
 	"github.com/google/uuid"
 
+	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
+	"remnawave-tg-shop-bot/internal/observability"
+	"remnawave-tg-shop-bot/internal/pkg/breaker"
 	"remnawave-tg-shop-bot/internal/pkg/config"
 	"remnawave-tg-shop-bot/internal/pkg/contextkey"
 	"remnawave-tg-shop-bot/utils"
@@ -52,23 +54,31 @@ func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return t.base.RoundTrip(r)
 }
 
-func NewClient(baseURL, token, mode string) *Client {
-	xApiKey := config.GetXApiKey()
-	local := mode == "local"
-
+func NewClient(baseURL, token, mode string) (*Client, error) {
+	policy := PolicyFromConfig()
 	client := &http.Client{
+		Timeout: policy.Timeout,
 		Transport: &headerTransport{
-			base:    http.DefaultTransport,
-			xApiKey: xApiKey,
-			local:   local,
+			base:    observability.Transport("remnawave", http.DefaultTransport),
+			xApiKey: config.GetXApiKey(),
+			local:   mode == "local",
 		},
 	}
 
 	api, err := remapi.NewClient(baseURL, remapi.StaticToken{Token: token}, remapi.WithClient(client))
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("create remnawave client: %w", err)
 	}
-	return &Client{client: api}
+	cb := breaker.New(policy.BreakerFailures, policy.BreakerCooldown).OnChange(func(open bool) {
+		if open {
+			slog.Warn("remnawave circuit breaker opened", "cooldown", policy.BreakerCooldown)
+			observability.CircuitOpen.WithLabelValues("remnawave").Set(1)
+		} else {
+			slog.Info("remnawave circuit breaker closed")
+			observability.CircuitOpen.WithLabelValues("remnawave").Set(0)
+		}
+	})
+	return &Client{client: resilientAPI{api: meteredAPI{api: api}, policy: policy, breaker: cb}}, nil
 }
 
 func (r *Client) Ping(ctx context.Context) error {
@@ -108,41 +118,59 @@ func (r *Client) GetUsers(ctx context.Context) (*[]remapi.UserDto, error) {
 	return &users, nil
 }
 
+// CreateOrUpdateUser creates the user or extends the existing one by days. The
+// inbounds of an existing user are left alone.
 func (r *Client) CreateOrUpdateUser(ctx context.Context, telegramId int64, trafficLimit int, days int) (*remapi.UserDto, error) {
+	return r.createOrUpdateUser(ctx, telegramId, trafficLimit, days, config.InboundUUIDs(), false)
+}
+
+// CreateOrUpdateUserWithInbounds works like CreateOrUpdateUser but assigns the
+// given inbounds to the user, also to an existing one. An empty set assigns
+// all inbounds.
+func (r *Client) CreateOrUpdateUserWithInbounds(ctx context.Context, telegramId int64, trafficLimit int, days int, inbounds map[uuid.UUID]uuid.UUID) (*remapi.UserDto, error) {
+	return r.createOrUpdateUser(ctx, telegramId, trafficLimit, days, inbounds, true)
+}
+
+func (r *Client) createOrUpdateUser(ctx context.Context, telegramId int64, trafficLimit int, days int, inbounds map[uuid.UUID]uuid.UUID, reconcile bool) (*remapi.UserDto, error) {
 	resp, err := r.client.UsersControllerGetUserByTelegramId(ctx, remapi.UsersControllerGetUserByTelegramIdParams{TelegramId: strconv.FormatInt(telegramId, 10)})
 	if err != nil {
 		return nil, err
 	}
 
+	var existingUser *remapi.UserDto
 	switch v := resp.(type) {
-
 	case *remapi.UsersControllerGetUserByTelegramIdNotFound:
-		return r.createUser(ctx, telegramId, trafficLimit, days)
 	case *remapi.UsersDto:
-		var existingUser *remapi.UserDto
-		for _, panelUser := range v.GetResponse() {
-			if strings.Contains(panelUser.Username, fmt.Sprintf("_%d", telegramId)) {
-				existingUser = &panelUser
-			}
-		}
-		if existingUser == nil {
-			existingUser = &v.GetResponse()[0]
-		}
-		return r.updateUser(ctx, existingUser, trafficLimit, days)
+		existingUser = PrimaryUser(telegramId, v.GetResponse())
 	default:
-		return nil, errors.New("unknown response type")
+		return nil, &Error{Operation: "get_user_by_telegram_id", Kind: ErrInvalid, Err: fmt.Errorf("unexpected response %T", resp)}
+	}
+	if existingUser == nil {
+		return r.createUser(ctx, telegramId, trafficLimit, days, inbounds)
 	}
+
+	var active []uuid.UUID
+	if reconcile {
+		if active, err = r.inboundsToSet(ctx, existingUser, inbounds); err != nil {
+			return nil, err
+		}
+	}
+	return r.updateUserUntil(ctx, existingUser, trafficLimit, ExtendExpire(days, existingUser.ExpireAt), active)
 }
 
 func (r *Client) updateUser(ctx context.Context, existingUser *remapi.UserDto, trafficLimit int, days int) (*remapi.UserDto, error) {
+	return r.updateUserUntil(ctx, existingUser, trafficLimit, ExtendExpire(days, existingUser.ExpireAt), nil)
+}
 
-	newExpire := getNewExpire(days, existingUser.ExpireAt)
-
+// updateUserUntil sets the expiration of the user, and its inbounds unless
+// inbounds is nil.
+func (r *Client) updateUserUntil(ctx context.Context, existingUser *remapi.UserDto, trafficLimit int, newExpire time.Time, inbounds []uuid.UUID) (*remapi.UserDto, error) {
 	userUpdate := &remapi.UpdateUserRequestDto{
-		UUID:              existingUser.UUID,
-		ExpireAt:          remapi.NewOptDateTime(newExpire),
-		Status:            remapi.NewOptUpdateUserRequestDtoStatus(remapi.UpdateUserRequestDtoStatusACTIVE),
-		TrafficLimitBytes: remapi.NewOptInt(trafficLimit),
+		UUID:               existingUser.UUID,
+		ExpireAt:           remapi.NewOptDateTime(newExpire),
+		Status:             remapi.NewOptUpdateUserRequestDtoStatus(remapi.UpdateUserRequestDtoStatusACTIVE),
+		TrafficLimitBytes:  remapi.NewOptInt(trafficLimit),
+		ActiveUserInbounds: inbounds,
 	}
 
 	var username string
@@ -159,31 +187,24 @@ func (r *Client) updateUser(ctx context.Context, existingUser *remapi.UserDto, t
 		return nil, err
 	}
 	tgid, _ := existingUser.TelegramId.Get()
-	slog.Info("updated user", "telegramId", utils.MaskHalf(strconv.Itoa(tgid)), "username", utils.MaskHalf(username), "days", days)
+	slog.Info("updated user", "telegramId", utils.MaskHalf(strconv.Itoa(tgid)), "username", utils.MaskHalf(username), "expire_at", newExpire, "inbounds_changed", inbounds != nil)
 	return &updateUser.Response, nil
 }
 
-func (r *Client) createUser(ctx context.Context, telegramId int64, trafficLimit int, days int) (*remapi.UserDto, error) {
-	expireAt := time.Now().UTC().AddDate(0, 0, days)
-	username := fmt.Sprintf("%d", telegramId)
+func (r *Client) createUser(ctx context.Context, telegramId int64, trafficLimit int, days int, allowedInbounds map[uuid.UUID]uuid.UUID) (*remapi.UserDto, error) {
+	return r.createUserUntil(ctx, telegramId, "", trafficLimit, time.Now().UTC().AddDate(0, 0, days), allowedInbounds)
+}
 
-	resp, err := r.client.InboundsControllerGetInbounds(ctx)
-	if err != nil {
-		return nil, err
+// createUserUntil creates a panel user named username, the primary user of the
+// customer is named after the telegram id when username is empty.
+func (r *Client) createUserUntil(ctx context.Context, telegramId int64, username string, trafficLimit int, expireAt time.Time, allowedInbounds map[uuid.UUID]uuid.UUID) (*remapi.UserDto, error) {
+	if username == "" {
+		username = fmt.Sprintf("%d", telegramId)
 	}
 
-	inbounds := resp.GetResponse()
-	inboundsId := make([]uuid.UUID, 0, len(config.InboundUUIDs()))
-	for _, inbound := range inbounds {
-		if config.InboundUUIDs() != nil && len(config.InboundUUIDs()) > 0 {
-			if _, isExist := config.InboundUUIDs()[inbound.UUID]; !isExist {
-				continue
-			} else {
-				inboundsId = append(inboundsId, inbound.UUID)
-			}
-		} else {
-			inboundsId = append(inboundsId, inbound.UUID)
-		}
+	inboundsId, err := r.resolveInbounds(ctx, allowedInbounds)
+	if err != nil {
+		return nil, err
 	}
 
 	createUserRequestDto := remapi.CreateUserRequestDto{
@@ -206,29 +227,131 @@ func (r *Client) createUser(ctx context.Context, telegramId int64, trafficLimit
 	if err != nil {
 		return nil, err
 	}
-	slog.Info("created user", "telegramId", utils.MaskHalf(strconv.FormatInt(telegramId, 10)), "username", utils.MaskHalf(tgUsername), "days", days)
+	slog.Info("created user", "telegramId", utils.MaskHalf(strconv.FormatInt(telegramId, 10)), "username", utils.MaskHalf(tgUsername), "expire_at", expireAt)
 	return &userCreate.Response, nil
 }
 
+// SubscriptionRef identifies the panel user of a subscription. The user is
+// looked up by UUID, then by Username, and an empty Username stands for the
+// primary user of the customer.
+type SubscriptionRef struct {
+	TelegramID int64
+	UUID       *uuid.UUID
+	Username   string
+}
+
+// SetSubscriptionExpire creates the panel user of the subscription or updates
+// the existing one with the given expiration and inbounds. Nil inbounds keep
+// those of the existing user and give a new one INBOUND_UUIDS. Unlike
+// CreateOrUpdateUser it sets an absolute date, so repeating the call doesn't
+// extend the user again.
+func (r *Client) SetSubscriptionExpire(ctx context.Context, ref SubscriptionRef, trafficLimit int, expireAt time.Time, inbounds map[uuid.UUID]uuid.UUID) (*remapi.UserDto, error) {
+	user, err := r.GetSubscriptionUser(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		if inbounds == nil {
+			inbounds = config.InboundUUIDs()
+		}
+		return r.createUserUntil(ctx, ref.TelegramID, ref.Username, trafficLimit, expireAt, inbounds)
+	}
+	if inbounds == nil {
+		return r.updateUserUntil(ctx, user, trafficLimit, expireAt, nil)
+	}
+	active, err := r.inboundsToSet(ctx, user, inbounds)
+	if err != nil {
+		return nil, err
+	}
+	return r.updateUserUntil(ctx, user, trafficLimit, expireAt, active)
+}
+
+// SetUserEnabled switches the status of every panel account of the user, the
+// extra subscriptions included. Users without an account are ignored.
+func (r *Client) SetUserEnabled(ctx context.Context, telegramId int64, enabled bool) error {
+	users, err := r.usersByTelegramID(ctx, telegramId)
+	if err != nil {
+		return err
+	}
+	status := remapi.UpdateUserRequestDtoStatusDISABLED
+	if enabled {
+		status = remapi.UpdateUserRequestDtoStatusACTIVE
+	}
+	for _, user := range users {
+		_, err = r.client.UsersControllerUpdateUser(ctx, &remapi.UpdateUserRequestDto{
+			UUID:   user.UUID,
+			Status: remapi.NewOptUpdateUserRequestDtoStatus(status),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	slog.Info("changed user status", "telegramId", utils.MaskHalfInt64(telegramId), "status", status, "users", len(users))
+	return nil
+}
+
+// GetUserByTelegramID returns the primary panel user of the customer, nil when
+// there is none.
 func (r *Client) GetUserByTelegramID(ctx context.Context, telegramId int64) (*remapi.UserDto, error) {
-	resp, err := r.client.UsersControllerGetUserByTelegramId(ctx, remapi.UsersControllerGetUserByTelegramIdParams{TelegramId: strconv.FormatInt(telegramId, 10)})
+	return r.GetSubscriptionUser(ctx, SubscriptionRef{TelegramID: telegramId})
+}
+
+// GetSubscriptionUser returns the panel user of the subscription, nil when
+// there is none.
+func (r *Client) GetSubscriptionUser(ctx context.Context, ref SubscriptionRef) (*remapi.UserDto, error) {
+	users, err := r.usersByTelegramID(ctx, ref.TelegramID)
 	if err != nil {
 		return nil, err
 	}
-	switch v := resp.(type) {
-	case *remapi.UsersDto:
-		if len(v.GetResponse()) == 0 {
-			return nil, nil
+	if ref.UUID != nil {
+		for i := range users {
+			if users[i].UUID == *ref.UUID {
+				return &users[i], nil
+			}
 		}
-		for _, u := range v.GetResponse() {
-			if strings.Contains(u.Username, fmt.Sprintf("_%d", telegramId)) {
-				return &u, nil
+	}
+	if ref.Username != "" {
+		for i := range users {
+			if users[i].Username == ref.Username {
+				return &users[i], nil
 			}
 		}
-		return &v.GetResponse()[0], nil
-	default:
 		return nil, nil
 	}
+	return PrimaryUser(ref.TelegramID, users), nil
+}
+
+// usersByTelegramID returns all panel users of the customer.
+func (r *Client) usersByTelegramID(ctx context.Context, telegramId int64) ([]remapi.UserDto, error) {
+	resp, err := r.client.UsersControllerGetUserByTelegramId(ctx, remapi.UsersControllerGetUserByTelegramIdParams{TelegramId: strconv.FormatInt(telegramId, 10)})
+	if err != nil {
+		return nil, err
+	}
+	v, ok := resp.(*remapi.UsersDto)
+	if !ok {
+		return nil, nil
+	}
+	return v.GetResponse(), nil
+}
+
+// PrimaryUser picks the primary user among the panel users of the customer:
+// the one named "..._<telegramId>" or else the oldest one. Users of extra
+// subscriptions are skipped.
+func PrimaryUser(telegramId int64, users []remapi.UserDto) *remapi.UserDto {
+	var oldest *remapi.UserDto
+	for i := range users {
+		u := &users[i]
+		if domaincustomer.IsSubscriptionUsername(telegramId, u.Username) {
+			continue
+		}
+		if strings.Contains(u.Username, fmt.Sprintf("_%d", telegramId)) {
+			return u
+		}
+		if oldest == nil || u.CreatedAt.Before(oldest.CreatedAt) {
+			oldest = u
+		}
+	}
+	return oldest
 }
 
 func (r *Client) GetUserDailyUsage(ctx context.Context, uuid string, start, end time.Time) (float64, error) {
@@ -248,7 +371,10 @@ func (r *Client) GetUserDailyUsage(ctx context.Context, uuid string, start, end
 	}
 }
 
-func getNewExpire(daysToAdd int, currentExpire time.Time) time.Time {
+// ExtendExpire returns the expiration of a subscription expiring at
+// currentExpire extended by daysToAdd. Expired subscriptions are extended
+// from now.
+func ExtendExpire(daysToAdd int, currentExpire time.Time) time.Time {
 	if currentExpire.IsZero() {
 		return time.Now().UTC().AddDate(0, 0, daysToAdd)
 	}
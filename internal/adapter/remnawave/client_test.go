@@ -2,6 +2,7 @@ package remnawave
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,22 +13,36 @@ import (
 )
 
 type stubAPI struct {
+	inbounds  []uuid.UUID
+	users     []remapi.UserDto
 	createReq *remapi.CreateUserRequestDto
 	updateReq *remapi.UpdateUserRequestDto
+	updated   []uuid.UUID
 }
 
 func (s *stubAPI) UsersControllerGetAllUsers(ctx context.Context, params remapi.UsersControllerGetAllUsersParams, options ...remapi.RequestOption) (*remapi.GetAllUsersResponseDto, error) {
 	return nil, nil
 }
 func (s *stubAPI) UsersControllerGetUserByTelegramId(ctx context.Context, params remapi.UsersControllerGetUserByTelegramIdParams, options ...remapi.RequestOption) (remapi.UsersControllerGetUserByTelegramIdRes, error) {
+	if s.users != nil {
+		return &remapi.UsersDto{Response: s.users}, nil
+	}
 	return nil, nil
 }
 func (s *stubAPI) UsersControllerUpdateUser(ctx context.Context, req *remapi.UpdateUserRequestDto, options ...remapi.RequestOption) (*remapi.UserResponseDto, error) {
 	s.updateReq = req
+	s.updated = append(s.updated, req.UUID)
 	return &remapi.UserResponseDto{Response: remapi.UserDto{}}, nil
 }
 func (s *stubAPI) InboundsControllerGetInbounds(ctx context.Context, options ...remapi.RequestOption) (*remapi.GetInboundsResponseDto, error) {
-	return &remapi.GetInboundsResponseDto{Response: []remapi.GetInboundsResponseDtoResponseItem{{UUID: uuid.New()}}}, nil
+	if s.inbounds == nil {
+		return &remapi.GetInboundsResponseDto{Response: []remapi.GetInboundsResponseDtoResponseItem{{UUID: uuid.New()}}}, nil
+	}
+	items := make([]remapi.GetInboundsResponseDtoResponseItem, 0, len(s.inbounds))
+	for _, id := range s.inbounds {
+		items = append(items, remapi.GetInboundsResponseDtoResponseItem{UUID: id})
+	}
+	return &remapi.GetInboundsResponseDto{Response: items}, nil
 }
 func (s *stubAPI) UsersControllerCreateUser(ctx context.Context, req *remapi.CreateUserRequestDto, options ...remapi.RequestOption) (*remapi.UserResponseDto, error) {
 	s.createReq = req
@@ -41,7 +56,7 @@ func TestCreateUserDescription(t *testing.T) {
 	api := &stubAPI{}
 	c := &Client{client: api}
 	ctx := context.WithValue(context.Background(), contextkey.Username, "user")
-	if _, err := c.createUser(ctx, 1, 1, 1); err != nil {
+	if _, err := c.createUser(ctx, 1, 1, 1, nil); err != nil {
 		t.Fatalf("createUser: %v", err)
 	}
 	if !api.createReq.Description.IsSet() {
@@ -52,7 +67,7 @@ func TestCreateUserDescription(t *testing.T) {
 	}
 
 	api.createReq = nil
-	if _, err := c.createUser(context.Background(), 1, 1, 1); err != nil {
+	if _, err := c.createUser(context.Background(), 1, 1, 1, nil); err != nil {
 		t.Fatalf("createUser: %v", err)
 	}
 	if api.createReq.Description.IsSet() {
@@ -87,3 +102,100 @@ func TestUpdateUserDescription(t *testing.T) {
 		t.Fatal("description should not change")
 	}
 }
+
+func TestGetSubscriptionUser(t *testing.T) {
+	now := time.Now()
+	extra := remapi.UserDto{UUID: uuid.New(), Username: "10_sub7", CreatedAt: now.Add(-2 * time.Hour)}
+	older := remapi.UserDto{UUID: uuid.New(), Username: "old10", CreatedAt: now.Add(-time.Hour)}
+	newer := remapi.UserDto{UUID: uuid.New(), Username: "10", CreatedAt: now}
+	api := &stubAPI{users: []remapi.UserDto{newer, extra, older}}
+	c := &Client{client: api}
+	ctx := context.Background()
+
+	primary, err := c.GetUserByTelegramID(ctx, 10)
+	if err != nil || primary == nil || primary.UUID != older.UUID {
+		t.Fatalf("primary %v, err %v", primary, err)
+	}
+	byName, _ := c.GetSubscriptionUser(ctx, SubscriptionRef{TelegramID: 10, Username: "10_sub7"})
+	if byName == nil || byName.UUID != extra.UUID {
+		t.Fatalf("by username %v", byName)
+	}
+	byUUID, _ := c.GetSubscriptionUser(ctx, SubscriptionRef{TelegramID: 10, UUID: &newer.UUID, Username: "10_sub7"})
+	if byUUID == nil || byUUID.UUID != newer.UUID {
+		t.Fatalf("by uuid %v", byUUID)
+	}
+	missing, _ := c.GetSubscriptionUser(ctx, SubscriptionRef{TelegramID: 10, Username: "10_sub8"})
+	if missing != nil {
+		t.Fatalf("unknown subscription resolved to %v", missing)
+	}
+
+	if _, err := c.SetSubscriptionExpire(ctx, SubscriptionRef{TelegramID: 10, Username: "10_sub8"}, 1, now, nil); err != nil {
+		t.Fatal(err)
+	}
+	if api.createReq == nil || api.createReq.Username != "10_sub8" {
+		t.Fatalf("subscription user not created: %+v", api.createReq)
+	}
+}
+
+func TestSetSubscriptionExpireReconcilesInbounds(t *testing.T) {
+	basic, premium := uuid.New(), uuid.New()
+	user := remapi.UserDto{UUID: uuid.New(), Username: "10", ActiveUserInbounds: []remapi.UserDtoActiveUserInboundsItem{{UUID: basic}}}
+	api := &stubAPI{inbounds: []uuid.UUID{basic, premium}, users: []remapi.UserDto{user}}
+	c := &Client{client: api}
+	ref := SubscriptionRef{TelegramID: 10}
+
+	if _, err := c.SetSubscriptionExpire(context.Background(), ref, 1, time.Now(), map[uuid.UUID]uuid.UUID{basic: basic}); err != nil {
+		t.Fatal(err)
+	}
+	if api.updateReq.ActiveUserInbounds != nil {
+		t.Errorf("unchanged inbounds sent: %v", api.updateReq.ActiveUserInbounds)
+	}
+
+	if _, err := c.SetSubscriptionExpire(context.Background(), ref, 1, time.Now(), map[uuid.UUID]uuid.UUID{basic: basic, premium: premium}); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.updateReq.ActiveUserInbounds) != 2 {
+		t.Errorf("inbounds of the plan not set: %v", api.updateReq.ActiveUserInbounds)
+	}
+
+	// a job without a plan, e.g. a promocode, keeps the inbounds
+	if _, err := c.SetSubscriptionExpire(context.Background(), ref, 1, time.Now(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if api.updateReq.ActiveUserInbounds != nil {
+		t.Errorf("inbounds changed without a plan: %v", api.updateReq.ActiveUserInbounds)
+	}
+}
+
+func TestValidateInbounds(t *testing.T) {
+	known, typo := uuid.New(), uuid.New()
+	c := &Client{client: &stubAPI{inbounds: []uuid.UUID{known}}}
+
+	if err := c.ValidateInbounds(context.Background(), map[string]map[uuid.UUID]uuid.UUID{
+		"INBOUND_UUIDS": {known: known}, "TRIAL_INBOUND_UUIDS": {},
+	}); err != nil {
+		t.Fatalf("valid inbounds rejected: %v", err)
+	}
+	err := c.ValidateInbounds(context.Background(), map[string]map[uuid.UUID]uuid.UUID{
+		"INBOUND_UUIDS": {known: known}, "INBOUND_UUIDS_6": {typo: typo},
+	})
+	if err == nil || !strings.Contains(err.Error(), "INBOUND_UUIDS_6: "+typo.String()) {
+		t.Fatalf("unknown inbound not reported: %v", err)
+	}
+}
+
+func TestSetUserEnabledSwitchesAllSubscriptions(t *testing.T) {
+	primary, extra := uuid.New(), uuid.New()
+	api := &stubAPI{users: []remapi.UserDto{{UUID: primary, Username: "10"}, {UUID: extra, Username: "10_sub4"}}}
+	c := &Client{client: api}
+
+	if err := c.SetUserEnabled(context.Background(), 10, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.updated) != 2 || api.updated[0] != primary || api.updated[1] != extra {
+		t.Fatalf("updated %v", api.updated)
+	}
+	if status, _ := api.updateReq.Status.Get(); status != remapi.UpdateUserRequestDtoStatusDISABLED {
+		t.Errorf("status %v", status)
+	}
+}
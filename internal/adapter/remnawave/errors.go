@@ -0,0 +1,68 @@
+package remnawave
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/ogen-go/ogen/validate"
+)
+
+// Kinds of Error, match them with errors.Is.
+var (
+	// ErrUnavailable covers network errors, timeouts, 429 and 5xx responses and
+	// calls failed fast by the open circuit breaker. It's worth retrying later.
+	ErrUnavailable = errors.New("panel unavailable")
+	ErrNotFound    = errors.New("not found")
+	// ErrAuth means the token or X_API_KEY was rejected.
+	ErrAuth = errors.New("unauthorized")
+	// ErrInvalid covers other 4xx responses and responses the client can't decode.
+	ErrInvalid = errors.New("invalid request or response")
+)
+
+// Error is a failed panel call.
+type Error struct {
+	Operation string
+	Kind      error
+	// StatusCode is set when the panel answered with an unexpected status.
+	StatusCode int
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("remnawave %s: %v: %v", e.Operation, e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+func (e *Error) Is(target error) bool { return target == e.Kind }
+
+// classify wraps err of the operation into Error.
+func classify(operation string, err error) error {
+	var apiErr *Error
+	if err == nil || errors.As(err, &apiErr) {
+		return err
+	}
+	e := &Error{Operation: operation, Kind: ErrInvalid, Err: err}
+
+	var statusErr *validate.UnexpectedStatusCodeError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &statusErr):
+		e.StatusCode = statusErr.StatusCode
+		switch code := statusErr.StatusCode; {
+		case code == http.StatusUnauthorized || code == http.StatusForbidden:
+			e.Kind = ErrAuth
+		case code == http.StatusNotFound:
+			e.Kind = ErrNotFound
+		case code == http.StatusTooManyRequests || code >= http.StatusInternalServerError:
+			e.Kind = ErrUnavailable
+		}
+	case errors.As(err, &netErr), errors.Is(err, context.DeadlineExceeded), errors.Is(err, io.ErrUnexpectedEOF):
+		e.Kind = ErrUnavailable
+	}
+	return e
+}
@@ -0,0 +1,89 @@
+package remnawave
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	remapi "github.com/Jolymmiles/remnawave-api-go/api"
+	"github.com/google/uuid"
+)
+
+// resolveInbounds returns the panel inbounds among allowed, all of them when
+// allowed is empty.
+func (r *Client) resolveInbounds(ctx context.Context, allowed map[uuid.UUID]uuid.UUID) ([]uuid.UUID, error) {
+	resp, err := r.client.InboundsControllerGetInbounds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uuid.UUID, 0, len(allowed))
+	for _, inbound := range resp.GetResponse() {
+		if _, ok := allowed[inbound.UUID]; ok || len(allowed) == 0 {
+			ids = append(ids, inbound.UUID)
+		}
+	}
+	return ids, nil
+}
+
+// inboundsToSet returns the inbounds the user must get to match allowed, nil
+// when the user already has them.
+func (r *Client) inboundsToSet(ctx context.Context, user *remapi.UserDto, allowed map[uuid.UUID]uuid.UUID) ([]uuid.UUID, error) {
+	want, err := r.resolveInbounds(ctx, allowed)
+	if err != nil {
+		return nil, err
+	}
+	have := make([]uuid.UUID, 0, len(user.ActiveUserInbounds))
+	for _, inbound := range user.ActiveUserInbounds {
+		have = append(have, inbound.UUID)
+	}
+	if sameInbounds(have, want) {
+		return nil, nil
+	}
+	return want, nil
+}
+
+func sameInbounds(a, b []uuid.UUID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sortUUIDs(a)
+	sortUUIDs(b)
+	return slices.Equal(a, b)
+}
+
+func sortUUIDs(ids []uuid.UUID) {
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+}
+
+// ValidateInbounds checks that the panel has every inbound of the sets, which
+// are keyed by the .env variable they come from.
+func (r *Client) ValidateInbounds(ctx context.Context, sets map[string]map[uuid.UUID]uuid.UUID) error {
+	if !slices.ContainsFunc(slices.Collect(maps.Values(sets)), func(set map[uuid.UUID]uuid.UUID) bool { return len(set) > 0 }) {
+		return nil
+	}
+	known, err := r.resolveInbounds(ctx, nil)
+	if err != nil {
+		return err
+	}
+	var problems []string
+	for key, set := range sets {
+		var unknown []string
+		for id := range set {
+			if !slices.Contains(known, id) {
+				unknown = append(unknown, id.String())
+			}
+		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			problems = append(problems, fmt.Sprintf("%s: %s", key, strings.Join(unknown, ", ")))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("unknown inbounds in %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
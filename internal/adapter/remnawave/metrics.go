@@ -0,0 +1,58 @@
+package remnawave
+
+import (
+	"context"
+	"time"
+
+	remapi "github.com/Jolymmiles/remnawave-api-go/api"
+
+	"remnawave-tg-shop-bot/internal/observability"
+)
+
+// meteredAPI records the latency and errors of every Remnawave call.
+type meteredAPI struct {
+	api remAPI
+}
+
+func call[T any](operation string, f func() (T, error)) (T, error) {
+	start := time.Now()
+	res, err := f()
+	observability.ObserveDependency("remnawave", operation, start, err)
+	return res, err
+}
+
+func (m meteredAPI) UsersControllerGetAllUsers(ctx context.Context, params remapi.UsersControllerGetAllUsersParams, options ...remapi.RequestOption) (*remapi.GetAllUsersResponseDto, error) {
+	return call("get_users", func() (*remapi.GetAllUsersResponseDto, error) {
+		return m.api.UsersControllerGetAllUsers(ctx, params, options...)
+	})
+}
+
+func (m meteredAPI) UsersControllerGetUserByTelegramId(ctx context.Context, params remapi.UsersControllerGetUserByTelegramIdParams, options ...remapi.RequestOption) (remapi.UsersControllerGetUserByTelegramIdRes, error) {
+	return call("get_user_by_telegram_id", func() (remapi.UsersControllerGetUserByTelegramIdRes, error) {
+		return m.api.UsersControllerGetUserByTelegramId(ctx, params, options...)
+	})
+}
+
+func (m meteredAPI) UsersControllerUpdateUser(ctx context.Context, request *remapi.UpdateUserRequestDto, options ...remapi.RequestOption) (*remapi.UserResponseDto, error) {
+	return call("update_user", func() (*remapi.UserResponseDto, error) {
+		return m.api.UsersControllerUpdateUser(ctx, request, options...)
+	})
+}
+
+func (m meteredAPI) InboundsControllerGetInbounds(ctx context.Context, options ...remapi.RequestOption) (*remapi.GetInboundsResponseDto, error) {
+	return call("get_inbounds", func() (*remapi.GetInboundsResponseDto, error) {
+		return m.api.InboundsControllerGetInbounds(ctx, options...)
+	})
+}
+
+func (m meteredAPI) UsersControllerCreateUser(ctx context.Context, request *remapi.CreateUserRequestDto, options ...remapi.RequestOption) (*remapi.UserResponseDto, error) {
+	return call("create_user", func() (*remapi.UserResponseDto, error) {
+		return m.api.UsersControllerCreateUser(ctx, request, options...)
+	})
+}
+
+func (m meteredAPI) UsersStatsControllerGetUserUsageByRange(ctx context.Context, params remapi.UsersStatsControllerGetUserUsageByRangeParams, options ...remapi.RequestOption) (remapi.UsersStatsControllerGetUserUsageByRangeRes, error) {
+	return call("get_user_usage", func() (remapi.UsersStatsControllerGetUserUsageByRangeRes, error) {
+		return m.api.UsersStatsControllerGetUserUsageByRange(ctx, params, options...)
+	})
+}
@@ -0,0 +1,149 @@
+package remnawave
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	remapi "github.com/Jolymmiles/remnawave-api-go/api"
+
+	"remnawave-tg-shop-bot/internal/pkg/breaker"
+	"remnawave-tg-shop-bot/internal/pkg/config"
+)
+
+// Policy bounds the calls to the panel.
+type Policy struct {
+	// Timeout limits every attempt.
+	Timeout time.Duration
+	// Retries is the number of extra attempts of idempotent calls failed with ErrUnavailable.
+	Retries int
+	// Backoff is the base delay between attempts, doubled on every retry and jittered.
+	Backoff time.Duration
+	// BreakerFailures consecutive ErrUnavailable failures open the circuit
+	// breaker for BreakerCooldown. Zero disables the breaker.
+	BreakerFailures int
+	BreakerCooldown time.Duration
+}
+
+func PolicyFromConfig() Policy {
+	return Policy{
+		Timeout:         config.RemnawaveTimeout(),
+		Retries:         config.RemnawaveRetries(),
+		Backoff:         200 * time.Millisecond,
+		BreakerFailures: config.RemnawaveBreakerFailures(),
+		BreakerCooldown: config.RemnawaveBreakerCooldown(),
+	}
+}
+
+// resilientAPI applies the policy to the calls and types their errors.
+type resilientAPI struct {
+	api     remAPI
+	policy  Policy
+	breaker *breaker.Breaker
+}
+
+// attempt runs f under the policy. Only idempotent calls are retried.
+func attempt[T any](ctx context.Context, r resilientAPI, operation string, idempotent bool, f func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	attempts := 1
+	if idempotent {
+		attempts += max(r.policy.Retries, 0)
+	}
+
+	var err error
+	for i := range attempts {
+		if i > 0 && !sleep(ctx, backoff(r.policy.Backoff, i)) {
+			break
+		}
+		if berr := r.breaker.Allow(); berr != nil {
+			return zero, &Error{Operation: operation, Kind: ErrUnavailable, Err: berr}
+		}
+
+		callCtx, cancel := ctx, context.CancelFunc(func() {})
+		if r.policy.Timeout > 0 {
+			callCtx, cancel = context.WithTimeout(ctx, r.policy.Timeout)
+		}
+		var res T
+		res, err = f(callCtx)
+		cancel()
+
+		if err == nil {
+			r.breaker.Success()
+			return res, nil
+		}
+		if ctx.Err() != nil {
+			// the caller gave up, the panel may be fine
+			r.breaker.Cancel()
+			return zero, classify(operation, err)
+		}
+		err = classify(operation, err)
+		if !errors.Is(err, ErrUnavailable) {
+			// the panel answered
+			r.breaker.Success()
+			return zero, err
+		}
+		r.breaker.Failure()
+	}
+	return zero, err
+}
+
+// backoff returns the delay before the retry: base doubled per retry, half of
+// it random.
+func backoff(base time.Duration, retry int) time.Duration {
+	d := base << (retry - 1)
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+func (r resilientAPI) UsersControllerGetAllUsers(ctx context.Context, params remapi.UsersControllerGetAllUsersParams, options ...remapi.RequestOption) (*remapi.GetAllUsersResponseDto, error) {
+	return attempt(ctx, r, "get_users", true, func(ctx context.Context) (*remapi.GetAllUsersResponseDto, error) {
+		return r.api.UsersControllerGetAllUsers(ctx, params, options...)
+	})
+}
+
+func (r resilientAPI) UsersControllerGetUserByTelegramId(ctx context.Context, params remapi.UsersControllerGetUserByTelegramIdParams, options ...remapi.RequestOption) (remapi.UsersControllerGetUserByTelegramIdRes, error) {
+	return attempt(ctx, r, "get_user_by_telegram_id", true, func(ctx context.Context) (remapi.UsersControllerGetUserByTelegramIdRes, error) {
+		return r.api.UsersControllerGetUserByTelegramId(ctx, params, options...)
+	})
+}
+
+// UsersControllerUpdateUser is retried, the request holds the absolute expiration.
+func (r resilientAPI) UsersControllerUpdateUser(ctx context.Context, request *remapi.UpdateUserRequestDto, options ...remapi.RequestOption) (*remapi.UserResponseDto, error) {
+	return attempt(ctx, r, "update_user", true, func(ctx context.Context) (*remapi.UserResponseDto, error) {
+		return r.api.UsersControllerUpdateUser(ctx, request, options...)
+	})
+}
+
+func (r resilientAPI) InboundsControllerGetInbounds(ctx context.Context, options ...remapi.RequestOption) (*remapi.GetInboundsResponseDto, error) {
+	return attempt(ctx, r, "get_inbounds", true, func(ctx context.Context) (*remapi.GetInboundsResponseDto, error) {
+		return r.api.InboundsControllerGetInbounds(ctx, options...)
+	})
+}
+
+// UsersControllerCreateUser is not retried, the user may have been created
+// by an attempt that timed out.
+func (r resilientAPI) UsersControllerCreateUser(ctx context.Context, request *remapi.CreateUserRequestDto, options ...remapi.RequestOption) (*remapi.UserResponseDto, error) {
+	return attempt(ctx, r, "create_user", false, func(ctx context.Context) (*remapi.UserResponseDto, error) {
+		return r.api.UsersControllerCreateUser(ctx, request, options...)
+	})
+}
+
+func (r resilientAPI) UsersStatsControllerGetUserUsageByRange(ctx context.Context, params remapi.UsersStatsControllerGetUserUsageByRangeParams, options ...remapi.RequestOption) (remapi.UsersStatsControllerGetUserUsageByRangeRes, error) {
+	return attempt(ctx, r, "get_user_usage", true, func(ctx context.Context) (remapi.UsersStatsControllerGetUserUsageByRangeRes, error) {
+		return r.api.UsersStatsControllerGetUserUsageByRange(ctx, params, options...)
+	})
+}
@@ -0,0 +1,113 @@
+package remnawave
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	remapi "github.com/Jolymmiles/remnawave-api-go/api"
+	"github.com/ogen-go/ogen/validate"
+
+	"remnawave-tg-shop-bot/internal/pkg/breaker"
+)
+
+// flakyAPI fails the calls with errs in order, then succeeds.
+type flakyAPI struct {
+	stubAPI
+	errs  []error
+	calls int
+}
+
+func (f *flakyAPI) next() error {
+	f.calls++
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+func (f *flakyAPI) UsersControllerGetUserByTelegramId(ctx context.Context, params remapi.UsersControllerGetUserByTelegramIdParams, options ...remapi.RequestOption) (remapi.UsersControllerGetUserByTelegramIdRes, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return &remapi.UsersDto{}, nil
+}
+
+func (f *flakyAPI) UsersControllerCreateUser(ctx context.Context, req *remapi.CreateUserRequestDto, options ...remapi.RequestOption) (*remapi.UserResponseDto, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return &remapi.UserResponseDto{}, nil
+}
+
+func resilient(api remAPI, retries, breakerFailures int) resilientAPI {
+	return resilientAPI{
+		api:     api,
+		policy:  Policy{Timeout: time.Second, Retries: retries},
+		breaker: breaker.New(breakerFailures, time.Minute),
+	}
+}
+
+func TestRetryTransientErrors(t *testing.T) {
+	api := &flakyAPI{errs: []error{validate.UnexpectedStatusCode(http.StatusBadGateway), context.DeadlineExceeded}}
+	r := resilient(api, 2, 0)
+	if _, err := r.UsersControllerGetUserByTelegramId(context.Background(), remapi.UsersControllerGetUserByTelegramIdParams{}); err != nil {
+		t.Fatalf("not retried: %v", err)
+	}
+	if api.calls != 3 {
+		t.Errorf("%d calls", api.calls)
+	}
+
+	api = &flakyAPI{errs: []error{validate.UnexpectedStatusCode(http.StatusBadGateway)}}
+	r = resilient(api, 2, 0)
+	_, err := r.UsersControllerCreateUser(context.Background(), &remapi.CreateUserRequestDto{})
+	if !errors.Is(err, ErrUnavailable) || api.calls != 1 {
+		t.Errorf("create user retried or untyped: %v after %d calls", err, api.calls)
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	for code, kind := range map[int]error{
+		http.StatusUnauthorized:       ErrAuth,
+		http.StatusNotFound:           ErrNotFound,
+		http.StatusBadRequest:         ErrInvalid,
+		http.StatusTooManyRequests:    ErrUnavailable,
+		http.StatusServiceUnavailable: ErrUnavailable,
+	} {
+		err := classify("op", validate.UnexpectedStatusCode(code))
+		if !errors.Is(err, kind) {
+			t.Errorf("%d classified as %v", code, err)
+		}
+		var apiErr *Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != code {
+			t.Errorf("%d status lost: %v", code, err)
+		}
+	}
+
+	api := &flakyAPI{errs: []error{validate.UnexpectedStatusCode(http.StatusUnauthorized)}}
+	_, err := resilient(api, 2, 0).UsersControllerGetUserByTelegramId(context.Background(), remapi.UsersControllerGetUserByTelegramIdParams{})
+	if !errors.Is(err, ErrAuth) || api.calls != 1 {
+		t.Errorf("auth error retried: %v after %d calls", err, api.calls)
+	}
+}
+
+func TestBreakerFailsFast(t *testing.T) {
+	unavailable := validate.UnexpectedStatusCode(http.StatusServiceUnavailable)
+	api := &flakyAPI{errs: []error{unavailable, unavailable, unavailable}}
+	r := resilient(api, 0, 2)
+	params := remapi.UsersControllerGetUserByTelegramIdParams{}
+	for range 2 {
+		_, _ = r.UsersControllerGetUserByTelegramId(context.Background(), params)
+	}
+	_, err := r.UsersControllerGetUserByTelegramId(context.Background(), params)
+	if !errors.Is(err, ErrUnavailable) || !errors.Is(err, breaker.ErrOpen) {
+		t.Fatalf("breaker didn't open: %v", err)
+	}
+	if api.calls != 2 {
+		t.Errorf("panel called %d times", api.calls)
+	}
+}
@@ -0,0 +1,139 @@
+package remnawave
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Events of the panel webhooks about users.
+const (
+	EventUserCreated  = "user.created"
+	EventUserModified = "user.modified"
+	EventUserDeleted  = "user.deleted"
+	EventUserRevoked  = "user.revoked"
+	EventUserDisabled = "user.disabled"
+	EventUserEnabled  = "user.enabled"
+	EventUserLimited  = "user.limited"
+	EventUserExpired  = "user.expired"
+	// EventUserTrafficReset is sent when the used traffic is reset by the strategy or an admin.
+	EventUserTrafficReset = "user.traffic_reset"
+)
+
+// SignatureHeader holds the hex HMAC-SHA256 of the webhook body keyed with the
+// webhook secret of the panel.
+const SignatureHeader = "X-Remnawave-Signature"
+
+// maxWebhookAge is how far the send time of a webhook may be from now, an
+// older webhook is a replay.
+const maxWebhookAge = 5 * time.Minute
+
+// maxWebhookBody limits the webhook body, a user event is a few kilobytes.
+const maxWebhookBody = 1 << 20
+
+// WebhookEvent is a panel webhook. Only the user fields the bot keeps are decoded.
+type WebhookEvent struct {
+	Event string      `json:"event"`
+	Data  WebhookUser `json:"data"`
+	// Timestamp is the send time. It is signed with the body, so a webhook
+	// without it can't be told from a replay.
+	Timestamp json.RawMessage `json:"timestamp"`
+}
+
+type WebhookUser struct {
+	UUID            string    `json:"uuid"`
+	Username        string    `json:"username"`
+	Status          string    `json:"status"`
+	TelegramID      *int64    `json:"telegramId"`
+	ExpireAt        time.Time `json:"expireAt"`
+	SubscriptionURL string    `json:"subscriptionUrl"`
+}
+
+// VerifyWebhookSignature reports whether signature is the signature of body.
+func VerifyWebhookSignature(secret string, body []byte, signature string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// WebhookHandler verifies the panel webhooks and passes them to handle. A
+// failed handle answers 500, so that the panel can deliver the event again.
+func WebhookHandler(secret string, handle func(ctx context.Context, event *WebhookEvent) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+		if err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+
+		signature := r.Header.Get(SignatureHeader)
+		if signature == "" {
+			http.Error(w, "missing signature", http.StatusUnauthorized)
+			return
+		}
+		if !VerifyWebhookSignature(secret, body, signature) {
+			slog.Warn("remnawave webhook: bad signature", "remote", r.RemoteAddr)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		var event WebhookEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			slog.Error("remnawave webhook: unmarshal error", "err", err)
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+
+		sent, ok := parseWebhookTime(string(event.Timestamp))
+		if !ok {
+			slog.Warn("remnawave webhook: missing timestamp", "event", event.Event, "remote", r.RemoteAddr)
+			http.Error(w, "missing timestamp", http.StatusUnauthorized)
+			return
+		}
+		if time.Since(sent) > maxWebhookAge || time.Until(sent) > maxWebhookAge {
+			slog.Warn("remnawave webhook: stale timestamp", "event", event.Event, "sent", sent, "remote", r.RemoteAddr)
+			http.Error(w, "stale timestamp", http.StatusUnauthorized)
+			return
+		}
+
+		if err := handle(r.Context(), &event); err != nil {
+			slog.Error("remnawave webhook: handle event", "event", event.Event, "err", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// parseWebhookTime accepts an RFC 3339 time, quoted or not, or unix seconds
+// or milliseconds.
+func parseWebhookTime(s string) (time.Time, bool) {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+	if s == "" {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, true
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if n > 1e12 {
+		return time.UnixMilli(n), true
+	}
+	return time.Unix(n, 0), true
+}
@@ -22,6 +22,9 @@ const (
 	CallbackPromoUnfreeze           = "promo_unfreeze"
 	CallbackPromoConfirmationDelete = "promo_confirm_delete"
 	CallbackPromoDelete             = "promo_delete"
+	CallbackPromoBatches            = "promo_batches"
+	CallbackPromoBatchView          = "promo_batch_view"
+	CallbackPromoBatchExport        = "promo_batch_export"
 	CallbackOther                   = "other"
 	CallbackFAQ                     = "faq"
 	CallbackTrafficLimit            = "traffic_limit"
@@ -31,4 +34,13 @@ const (
 	CallbackShortList               = "short_list"
 	CallbackLocations               = "locations"
 	CallbackRegenKey                = "regen_key"
+	CallbackReviewApprove           = "review_approve"
+	CallbackReviewReject            = "review_reject"
+	CallbackPayout                  = "payout"
+	CallbackChannelCheck            = "channel_check"
+	CallbackWithdrawalPaid          = "withdrawal_paid"
+	CallbackWithdrawalReject        = "withdrawal_reject"
+	CallbackLanguage                = "language"
+	CallbackSupport                 = "support"
+	CallbackStats                   = "stats"
 )
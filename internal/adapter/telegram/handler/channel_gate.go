@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/adapter/telegram/messenger"
+	"remnawave-tg-shop-bot/internal/pkg/cache"
+	"remnawave-tg-shop-bot/internal/pkg/config"
+)
+
+// gatedUpdateTTL is how long an update stopped by the channel gate waits for
+// the user to join.
+const gatedUpdateTTL = 30 * time.Minute
+
+func newGatedUpdates() *cache.Cache[*models.Update] {
+	return cache.NewCache[*models.Update](context.Background(), gatedUpdateTTL)
+}
+
+func newChannelMembers() *cache.Cache[bool] {
+	return cache.NewCache[bool](context.Background(), config.ChannelGateCacheTTL())
+}
+
+// ChannelGateMiddleware requires membership in CHANNEL_ID before the handler
+// runs. Depending on CHANNEL_GATE it guards trial activation or every update.
+// Non-members get a "Subscribe & check" screen and the update is replayed by
+// ChannelCheckCallbackHandler once they join.
+func (h *Handler) ChannelGateMiddleware(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		userID, langCode := updateSender(ctx, update)
+		if userID == 0 || !channelGateApplies(update) || config.IsAdmin(userID) {
+			next(ctx, b, update)
+			return
+		}
+
+		member, err := h.isChannelMember(ctx, b, userID, false)
+		if err != nil {
+			// the bot may have lost access to the channel, don't lock users out
+			slog.Error("error checking channel membership", "err", err)
+			next(ctx, b, update)
+			return
+		}
+		if member {
+			next(ctx, b, update)
+			return
+		}
+
+		h.gatedUpdates.Set(userID, update)
+		h.sendChannelGate(ctx, b, update, langCode)
+	}
+}
+
+// ChannelCheckCallbackHandler re-checks membership and resumes the action that
+// was stopped by the gate, or opens the account menu when there is none or it
+// is older than gatedUpdateTTL.
+func (h *Handler) ChannelCheckCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	userID := update.CallbackQuery.From.ID
+	langCode := userLanguage(ctx, update)
+
+	member, err := h.isChannelMember(ctx, b, userID, true)
+	if err != nil {
+		slog.Error("error checking channel membership", "err", err)
+		return
+	}
+	if !member {
+		_, err = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+			Text:            h.translation.GetText(langCode, "channel_gate_not_joined"),
+			ShowAlert:       true,
+		})
+		if err != nil {
+			slog.Error("Error answering channel check", "err", err)
+		}
+		return
+	}
+
+	_, err = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID})
+	if err != nil {
+		slog.Error("Error answering channel check", "err", err)
+	}
+
+	pending, _ := h.gatedUpdates.Take(userID)
+	if pending == nil {
+		callback := *update.CallbackQuery
+		callback.Data = CallbackStart
+		pending = &models.Update{ID: update.ID, CallbackQuery: &callback}
+	}
+	b.ProcessUpdate(ctx, pending)
+}
+
+func (h *Handler) sendChannelGate(ctx context.Context, b *bot.Bot, update *models.Update, langCode string) {
+	var kb [][]models.InlineKeyboardButton
+	if config.ChannelURL() != "" {
+		kb = append(kb, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "channel_gate_subscribe_button"), URL: config.ChannelURL()}})
+	}
+	kb = append(kb, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "channel_gate_check_button"), CallbackData: CallbackChannelCheck}})
+	text := h.translation.GetText(langCode, "channel_gate_text")
+
+	if chatID, msgID, ok := callbackChatMessage(update); ok {
+		_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:      chatID,
+			MessageID:   msgID,
+			ParseMode:   models.ParseModeHTML,
+			Text:        text,
+			ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: kb},
+		})
+		if err != nil {
+			slog.Error("Error sending channel gate", "err", err)
+		}
+		return
+	}
+	if update.Message == nil {
+		return
+	}
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      update.Message.Chat.ID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        text,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: kb},
+	})
+	if err != nil {
+		slog.Error("Error sending channel gate", "err", err)
+	}
+}
+
+// isChannelMember caches positive answers for CHANNEL_GATE_CACHE_SECONDS.
+// fresh skips the cache, it is used when the user asks to check again.
+func (h *Handler) isChannelMember(ctx context.Context, b *bot.Bot, userID int64, fresh bool) (bool, error) {
+	if !fresh {
+		if _, ok := h.channelMembers.Get(userID); ok {
+			return true, nil
+		}
+	}
+
+	member, err := messenger.NewBotMessenger(b).IsChatMember(ctx, config.ChannelID(), userID)
+	if err != nil {
+		return false, err
+	}
+	if member {
+		h.channelMembers.Set(userID, true)
+	} else {
+		h.channelMembers.Delete(userID)
+	}
+	return member, nil
+}
+
+func channelGateApplies(update *models.Update) bool {
+	switch config.ChannelGate() {
+	case "all":
+		return update.CallbackQuery == nil || !strings.HasPrefix(update.CallbackQuery.Data, CallbackChannelCheck)
+	case "trial":
+		return update.CallbackQuery != nil &&
+			(strings.HasPrefix(update.CallbackQuery.Data, CallbackTrial) || strings.HasPrefix(update.CallbackQuery.Data, CallbackActivateTrial))
+	default:
+		return false
+	}
+}
+
+// updateSender returns the user of the update and the language to answer in,
+// see userLanguage.
+func updateSender(ctx context.Context, update *models.Update) (int64, string) {
+	switch {
+	case update.Message != nil && update.Message.From != nil:
+		return update.Message.From.ID, userLanguage(ctx, update)
+	case update.CallbackQuery != nil:
+		return update.CallbackQuery.From.ID, userLanguage(ctx, update)
+	default:
+		return 0, ""
+	}
+}
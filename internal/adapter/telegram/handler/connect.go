@@ -2,7 +2,6 @@ package handler
 
 import (
 	"context"
-	"fmt"
 	"remnawave-tg-shop-bot/internal/pkg/config"
 	"strings"
 	"time"
@@ -13,6 +12,7 @@ import (
 
 	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
 	"remnawave-tg-shop-bot/internal/pkg/translation"
+	pg "remnawave-tg-shop-bot/internal/repository/pg"
 	"remnawave-tg-shop-bot/utils"
 )
 
@@ -27,12 +27,17 @@ func (h *Handler) ConnectCommandHandler(ctx context.Context, b *bot.Bot, update
 		return
 	}
 
-	langCode := update.Message.From.LanguageCode
+	langCode := userLanguage(ctx, update)
+
+	text := buildConnectText(customer, langCode)
+	if subs := h.subscriptions(ctx, customer); len(subs) > 1 {
+		text = h.buildSubscriptionsText(subs, langCode)
+	}
 
 	isDisabled := true
 	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:    update.Message.Chat.ID,
-		Text:      buildConnectText(customer, langCode),
+		Text:      text,
 		ParseMode: models.ParseModeHTML,
 		LinkPreviewOptions: &models.LinkPreviewOptions{
 			IsDisabled: &isDisabled,
@@ -66,10 +71,21 @@ func (h *Handler) ConnectCallbackHandler(ctx context.Context, b *bot.Bot, update
 		return
 	}
 
-	langCode := update.CallbackQuery.From.LanguageCode
+	langCode := userLanguage(ctx, update)
 
+	text := buildConnectText(customer, langCode)
 	var markup [][]models.InlineKeyboardButton
-	if config.IsWepAppLinkEnabled() {
+	if subs := h.subscriptions(ctx, customer); len(subs) > 1 {
+		text = h.buildSubscriptionsText(subs, langCode)
+		if config.IsWepAppLinkEnabled() {
+			for i := range subs {
+				if active(&subs[i]) && subs[i].SubscriptionLink != nil {
+					markup = append(markup, []models.InlineKeyboardButton{{Text: h.subscriptionButtonText(langCode, i+1, &subs[i]),
+						WebApp: &models.WebAppInfo{URL: *subs[i].SubscriptionLink}}})
+				}
+			}
+		}
+	} else if config.IsWepAppLinkEnabled() {
 		if customer.SubscriptionLink != nil && customer.ExpireAt.After(time.Now()) {
 			markup = append(markup, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "connect_button"),
 				WebApp: &models.WebAppInfo{
@@ -84,7 +100,7 @@ func (h *Handler) ConnectCallbackHandler(ctx context.Context, b *bot.Bot, update
 		ChatID:    chatID,
 		MessageID: msgID,
 		ParseMode: models.ParseModeHTML,
-		Text:      buildConnectText(customer, langCode),
+		Text:      text,
 		LinkPreviewOptions: &models.LinkPreviewOptions{
 			IsDisabled: &isDisabled,
 		},
@@ -109,14 +125,12 @@ func buildConnectText(customer *domaincustomer.Customer, langCode string) string
 		if currentTime.Before(*customer.ExpireAt) {
 			formattedDate := customer.ExpireAt.Format("02.01.2006 15:04")
 
-			subscriptionActiveText := tm.GetText(langCode, "subscription_active")
-			info.WriteString(fmt.Sprintf(subscriptionActiveText, formattedDate))
+			info.WriteString(tm.Format(langCode, "subscription_active", translation.Args{"expire": formattedDate}))
 
 			if customer.SubscriptionLink != nil && *customer.SubscriptionLink != "" {
 				if config.IsWepAppLinkEnabled() {
 				} else {
-					subscriptionLinkText := tm.GetText(langCode, "subscription_link")
-					info.WriteString(fmt.Sprintf(subscriptionLinkText, *customer.SubscriptionLink))
+					info.WriteString(tm.Format(langCode, "subscription_link", translation.Args{"link": *customer.SubscriptionLink}))
 				}
 			}
 		} else {
@@ -130,3 +144,27 @@ func buildConnectText(customer *domaincustomer.Customer, langCode string) string
 
 	return info.String()
 }
+
+// buildSubscriptionsText lists the subscriptions of a customer with more than
+// one, the links are shown as buttons when they open in the web app.
+func (h *Handler) buildSubscriptionsText(subs []pg.Subscription, langCode string) string {
+	var info strings.Builder
+	for i := range subs {
+		if i > 0 {
+			info.WriteString("\n\n")
+		}
+		sub := &subs[i]
+		if !active(sub) {
+			info.WriteString(h.translation.Format(langCode, "subscription_list_item_inactive", translation.Args{"number": i + 1}))
+			continue
+		}
+		info.WriteString(h.translation.Format(langCode, "subscription_list_item", translation.Args{
+			"number": i + 1,
+			"expire": sub.ExpireAt.Format("02.01.2006 15:04"),
+		}))
+		if sub.SubscriptionLink != nil && *sub.SubscriptionLink != "" && !config.IsWepAppLinkEnabled() {
+			info.WriteString(h.translation.Format(langCode, "subscription_list_link", translation.Args{"link": *sub.SubscriptionLink}))
+		}
+	}
+	return info.String()
+}
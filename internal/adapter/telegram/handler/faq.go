@@ -0,0 +1,361 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	"remnawave-tg-shop-bot/internal/service/faq"
+)
+
+// FAQ callbacks share the CallbackFAQ prefix: "faq" and "faq:p:<page>" list
+// the categories, "faq:c:<id>:<page>" the articles of a category,
+// "faq:a:<id>" shows an article or info page and "faq:s" starts a search.
+const (
+	faqPage     = "p"
+	faqCategory = "c"
+	faqArticle  = "a"
+	faqSearch   = "s"
+)
+
+// tosSlug is the info page that replaces the TOS_URL button.
+const tosSlug = "tos"
+
+func (h *Handler) FAQCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	lang := userLanguage(ctx, update)
+	isAdmin := config.IsAdmin(update.CallbackQuery.From.ID)
+	parts := strings.Split(update.CallbackQuery.Data, ":")
+	arg := func(i int) int64 {
+		if i >= len(parts) {
+			return 0
+		}
+		v, _ := strconv.ParseInt(parts[i], 10, 64)
+		return v
+	}
+
+	var (
+		text string
+		kb   [][]models.InlineKeyboardButton
+		err  error
+	)
+	switch {
+	case len(parts) > 1 && parts[1] == faqCategory:
+		text, kb, err = h.faqCategoryView(ctx, lang, arg(2), int(arg(3)), isAdmin)
+	case len(parts) > 1 && parts[1] == faqArticle:
+		text, kb, err = h.faqArticleView(ctx, lang, arg(2), isAdmin)
+	case len(parts) > 1 && parts[1] == faqSearch:
+		h.expectFAQSearch(update.CallbackQuery.From.ID)
+		text = h.translation.GetText(lang, "faq_search_prompt")
+		kb = [][]models.InlineKeyboardButton{{{Text: h.translation.GetText(lang, "back_button"), CallbackData: CallbackFAQ}}}
+	default:
+		text, kb, err = h.faqMenuView(ctx, lang, int(arg(2)), isAdmin)
+	}
+	if err != nil {
+		slog.Error("load faq", "data", update.CallbackQuery.Data, "err", err)
+		return
+	}
+
+	chatID, msgID, ok := callbackChatMessage(update)
+	if !ok {
+		slog.Error("callback message missing")
+		return
+	}
+	_, err = SafeEditMessageText(ctx, b, update.CallbackQuery.Message.Message, &bot.EditMessageTextParams{
+		ChatID:      chatID,
+		MessageID:   msgID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        text,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: kb},
+	})
+	if err != nil {
+		slog.Error("Error editing faq", "err", err)
+	}
+}
+
+func (h *Handler) faqMenuView(ctx context.Context, lang string, page int, isAdmin bool) (string, [][]models.InlineKeyboardButton, error) {
+	categories, err := h.faqService.Categories(ctx, lang)
+	if err != nil {
+		return "", nil, err
+	}
+	back := []models.InlineKeyboardButton{{Text: h.translation.GetText(lang, "back_button"), CallbackData: CallbackOther}}
+	if len(categories) == 0 {
+		return h.translation.GetText(lang, "faq_empty_text"), [][]models.InlineKeyboardButton{back}, nil
+	}
+
+	items, page, pages := faq.Paginate(categories, page)
+	var kb [][]models.InlineKeyboardButton
+	for _, c := range items {
+		kb = append(kb, []models.InlineKeyboardButton{{
+			Text:         faqLabel(c.ID, c.Title, isAdmin),
+			CallbackData: fmt.Sprintf("%s:%s:%d:0", CallbackFAQ, faqCategory, c.ID),
+		}})
+	}
+	if nav := faqPager(CallbackFAQ+":"+faqPage+":", page, pages); nav != nil {
+		kb = append(kb, nav)
+	}
+	kb = append(kb, []models.InlineKeyboardButton{{Text: h.translation.GetText(lang, "faq_search_button"), CallbackData: CallbackFAQ + ":" + faqSearch}})
+	kb = append(kb, back)
+	return h.translation.GetText(lang, "faq_menu_text"), kb, nil
+}
+
+func (h *Handler) faqCategoryView(ctx context.Context, lang string, id int64, page int, isAdmin bool) (string, [][]models.InlineKeyboardButton, error) {
+	category, err := h.faqService.Category(ctx, id)
+	if err != nil {
+		return "", nil, err
+	}
+	if category == nil {
+		return h.faqMenuView(ctx, lang, 0, isAdmin)
+	}
+	articles, err := h.faqService.Articles(ctx, id)
+	if err != nil {
+		return "", nil, err
+	}
+
+	items, page, pages := faq.Paginate(articles, page)
+	var kb [][]models.InlineKeyboardButton
+	for _, a := range items {
+		kb = append(kb, []models.InlineKeyboardButton{{
+			Text:         faqLabel(a.ID, a.Title, isAdmin),
+			CallbackData: fmt.Sprintf("%s:%s:%d", CallbackFAQ, faqArticle, a.ID),
+		}})
+	}
+	if nav := faqPager(fmt.Sprintf("%s:%s:%d:", CallbackFAQ, faqCategory, id), page, pages); nav != nil {
+		kb = append(kb, nav)
+	}
+	kb = append(kb, []models.InlineKeyboardButton{{Text: h.translation.GetText(lang, "back_button"), CallbackData: CallbackFAQ}})
+
+	text := h.translation.Format(lang, "faq_category_text", translation.Args{"title": category.Title})
+	if isAdmin {
+		text += fmt.Sprintf("\n\n<i>#%d</i>", category.ID)
+	}
+	return text, kb, nil
+}
+
+func (h *Handler) faqArticleView(ctx context.Context, lang string, id int64, isAdmin bool) (string, [][]models.InlineKeyboardButton, error) {
+	article, err := h.faqService.Article(ctx, id)
+	if err != nil {
+		return "", nil, err
+	}
+	if article == nil {
+		return h.faqMenuView(ctx, lang, 0, isAdmin)
+	}
+
+	back := CallbackOther
+	if article.CategoryID != nil {
+		back = fmt.Sprintf("%s:%s:%d:0", CallbackFAQ, faqCategory, *article.CategoryID)
+	}
+	kb := [][]models.InlineKeyboardButton{{{Text: h.translation.GetText(lang, "back_button"), CallbackData: back}}}
+
+	text := "<b>" + html.EscapeString(article.Title) + "</b>\n\n" + article.Body
+	if isAdmin {
+		text += fmt.Sprintf("\n\n<i>#%d</i>", article.ID)
+	}
+	return text, kb, nil
+}
+
+// faqLabel prefixes the button text with the entry id for admins, who need it
+// for the edit commands.
+func faqLabel(id int64, title string, isAdmin bool) string {
+	if isAdmin {
+		return fmt.Sprintf("#%d %s", id, title)
+	}
+	return title
+}
+
+// faqPager returns the previous and next page buttons, nil for a single page.
+func faqPager(prefix string, page, pages int) []models.InlineKeyboardButton {
+	if pages < 2 {
+		return nil
+	}
+	var row []models.InlineKeyboardButton
+	if page > 0 {
+		row = append(row, models.InlineKeyboardButton{Text: "◀️", CallbackData: prefix + strconv.Itoa(page-1)})
+	}
+	row = append(row, models.InlineKeyboardButton{Text: fmt.Sprintf("%d/%d", page+1, pages), CallbackData: prefix + strconv.Itoa(page)})
+	if page < pages-1 {
+		row = append(row, models.InlineKeyboardButton{Text: "▶️", CallbackData: prefix + strconv.Itoa(page+1)})
+	}
+	return row
+}
+
+// FAQSearchMessageHandler answers the keyword typed after the search button.
+func (h *Handler) FAQSearchMessageHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if !h.consumeFAQSearch(update.Message.Chat.ID) {
+		return
+	}
+	lang := userLanguage(ctx, update)
+	query := strings.TrimSpace(update.Message.Text)
+
+	articles, err := h.faqService.Search(ctx, lang, query)
+	if err != nil {
+		slog.Error("search faq", "err", err)
+		return
+	}
+	text := h.translation.Format(lang, "faq_search_results", translation.Args{"query": query})
+	if len(articles) == 0 {
+		text = h.translation.Format(lang, "faq_search_empty", translation.Args{"query": query})
+	}
+	var kb [][]models.InlineKeyboardButton
+	for _, a := range articles {
+		kb = append(kb, []models.InlineKeyboardButton{{Text: a.Title, CallbackData: fmt.Sprintf("%s:%s:%d", CallbackFAQ, faqArticle, a.ID)}})
+	}
+	kb = append(kb, []models.InlineKeyboardButton{{Text: h.translation.GetText(lang, "faq_search_button"), CallbackData: CallbackFAQ + ":" + faqSearch}})
+	kb = append(kb, []models.InlineKeyboardButton{{Text: h.translation.GetText(lang, "back_button"), CallbackData: CallbackFAQ}})
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      update.Message.Chat.ID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        text,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: kb},
+	})
+	if err != nil {
+		slog.Error("Error sending faq search results", "err", err)
+	}
+}
+
+// infoPageButtons returns a button for every info page of lang. Without a
+// "tos" page the TOS_URL link is shown instead.
+func (h *Handler) infoPageButtons(ctx context.Context, lang string) [][]models.InlineKeyboardButton {
+	pages, err := h.faqService.Pages(ctx, lang)
+	if err != nil {
+		slog.Error("load info pages", "err", err)
+	}
+	var kb [][]models.InlineKeyboardButton
+	hasTos := false
+	for _, p := range pages {
+		hasTos = hasTos || *p.Slug == tosSlug
+		kb = append(kb, []models.InlineKeyboardButton{{Text: p.Title, CallbackData: fmt.Sprintf("%s:%s:%d", CallbackFAQ, faqArticle, p.ID)}})
+	}
+	if !hasTos && config.TosURL() != "" {
+		kb = append(kb, []models.InlineKeyboardButton{{Text: h.translation.GetText(lang, "tos_button"), URL: config.TosURL()}})
+	}
+	return kb
+}
+
+// FAQCategoryCommandHandler adds a category: /faq_category <lang> <title>
+func (h *Handler) FAQCategoryCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	h.faqCommand(ctx, b, update, func(lang, args string) (string, error) {
+		categoryLang, title, err := faq.ParseCategory(args)
+		if err != nil {
+			return "", err
+		}
+		c, err := h.faqService.AddCategory(ctx, categoryLang, title)
+		if err != nil {
+			return "", err
+		}
+		return h.translation.Format(lang, "faq_category_added", translation.Args{"id": c.ID}), nil
+	})
+}
+
+// FAQAddCommandHandler adds an article: /faq_add <category_id> <title>, the
+// text follows on the next lines.
+func (h *Handler) FAQAddCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	h.faqCommand(ctx, b, update, func(lang, args string) (string, error) {
+		req, err := faq.ParseArticle(args)
+		if err != nil {
+			return "", err
+		}
+		a, err := h.faqService.AddArticle(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		return h.translation.Format(lang, "faq_article_saved", translation.Args{"id": a.ID}), nil
+	})
+}
+
+// FAQEditCommandHandler replaces an article or info page: /faq_edit <id> <title>,
+// the text follows on the next lines.
+func (h *Handler) FAQEditCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	h.faqCommand(ctx, b, update, func(lang, args string) (string, error) {
+		req, err := faq.ParseArticle(args)
+		if err != nil {
+			return "", err
+		}
+		if err := h.faqService.EditArticle(ctx, req); err != nil {
+			return "", err
+		}
+		return h.translation.Format(lang, "faq_article_saved", translation.Args{"id": req.ID}), nil
+	})
+}
+
+// FAQPageCommandHandler creates or replaces an info page:
+// /faq_page <slug> <lang> <title>, the text follows on the next lines.
+func (h *Handler) FAQPageCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	h.faqCommand(ctx, b, update, func(lang, args string) (string, error) {
+		req, err := faq.ParsePage(args)
+		if err != nil {
+			return "", err
+		}
+		a, err := h.faqService.SavePage(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		return h.translation.Format(lang, "faq_article_saved", translation.Args{"id": a.ID}), nil
+	})
+}
+
+// FAQDeleteCommandHandler removes an article or info page: /faq_delete <id>
+func (h *Handler) FAQDeleteCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	h.faqCommand(ctx, b, update, func(lang, args string) (string, error) {
+		id, err := strconv.ParseInt(strings.TrimSpace(args), 10, 64)
+		if err != nil {
+			return "", faq.ErrFormat
+		}
+		if err := h.faqService.DeleteArticle(ctx, id); err != nil {
+			return "", err
+		}
+		return h.translation.GetText(lang, "faq_deleted"), nil
+	})
+}
+
+// FAQDeleteCategoryCommandHandler removes a category with its articles:
+// /faq_delete_category <id>
+func (h *Handler) FAQDeleteCategoryCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	h.faqCommand(ctx, b, update, func(lang, args string) (string, error) {
+		id, err := strconv.ParseInt(strings.TrimSpace(args), 10, 64)
+		if err != nil {
+			return "", faq.ErrFormat
+		}
+		if err := h.faqService.DeleteCategory(ctx, id); err != nil {
+			return "", err
+		}
+		return h.translation.GetText(lang, "faq_deleted"), nil
+	})
+}
+
+// faqCommand runs an admin FAQ command and replies with its result or error.
+func (h *Handler) faqCommand(ctx context.Context, b *bot.Bot, update *models.Update, run func(lang, args string) (string, error)) {
+	if !config.IsAdmin(update.Message.From.ID) {
+		return
+	}
+	lang := userLanguage(ctx, update)
+	_, args, _ := strings.Cut(update.Message.Text, " ")
+
+	text, err := run(lang, args)
+	switch {
+	case err == nil:
+		slog.Info("faq changed", "admin", update.Message.From.ID, "command", strings.Fields(update.Message.Text)[0])
+	case errors.Is(err, faq.ErrFormat):
+		text = h.translation.GetText(lang, "faq_usage")
+	case errors.Is(err, faq.ErrTooLong):
+		text = h.translation.GetText(lang, "faq_too_long")
+	case errors.Is(err, faq.ErrNotFound):
+		text = h.translation.GetText(lang, "faq_not_found")
+	default:
+		slog.Error("change faq", "err", err)
+		text = h.translation.GetText(lang, "faq_error")
+	}
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{ChatID: update.Message.Chat.ID, ParseMode: models.ParseModeHTML, Text: text})
+	if err != nil {
+		slog.Error("Error sending faq command result", "err", err)
+	}
+}
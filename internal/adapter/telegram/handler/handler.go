@@ -4,12 +4,23 @@ import (
 	"sync"
 	"time"
 
+	"github.com/go-telegram/bot/models"
+
 	"remnawave-tg-shop-bot/internal/pkg/cache"
+	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/pkg/ratelimit"
 	"remnawave-tg-shop-bot/internal/pkg/translation"
 	pg "remnawave-tg-shop-bot/internal/repository/pg"
 	custrepo "remnawave-tg-shop-bot/internal/service/customer"
+	"remnawave-tg-shop-bot/internal/service/faq"
+	"remnawave-tg-shop-bot/internal/service/moderation"
 	"remnawave-tg-shop-bot/internal/service/payment"
+	"remnawave-tg-shop-bot/internal/service/promo"
+	"remnawave-tg-shop-bot/internal/service/referral"
+	"remnawave-tg-shop-bot/internal/service/stats"
+	"remnawave-tg-shop-bot/internal/service/support"
 	syncsvc "remnawave-tg-shop-bot/internal/service/sync"
+	"remnawave-tg-shop-bot/internal/service/trial"
 )
 
 type Handler struct {
@@ -21,11 +32,26 @@ type Handler struct {
 	referralRepository       *pg.ReferralRepository
 	promocodeRepository      *pg.PromocodeRepository
 	promocodeUsageRepository *pg.PromocodeUsageRepository
-	cache                    *cache.Cache
+	cache                    *cache.Cache[int]
+	promoService             *promo.Service
+	referralService          *referral.Service
+	trialService             *trial.Service
+	moderationService        *moderation.Service
+	faqService               *faq.Service
+	supportService           *support.Service
+	statsService             *stats.Service
 	awaitingPromo            map[int64]bool
 	promoMu                  sync.RWMutex
+	awaitingWithdrawal       map[int64]bool
+	withdrawalMu             sync.RWMutex
+	awaitingFAQSearch        map[int64]bool
+	faqSearchMu              sync.RWMutex
 	shortLinks               map[int64][]ShortLink
 	shortMu                  sync.RWMutex
+	channelMembers           *cache.Cache[bool]
+	gatedUpdates             *cache.Cache[*models.Update]
+	cheapLimiter             *ratelimit.Limiter
+	expensiveLimiter         *ratelimit.Limiter
 }
 
 type ShortLink struct {
@@ -42,7 +68,16 @@ func NewHandler(
 	referralRepository *pg.ReferralRepository,
 	promocodeRepository *pg.PromocodeRepository,
 	promocodeUsageRepository *pg.PromocodeUsageRepository,
-	cache *cache.Cache) *Handler {
+	cache *cache.Cache[int],
+	promoService *promo.Service,
+	referralService *referral.Service,
+	trialService *trial.Service,
+	moderationService *moderation.Service,
+	faqService *faq.Service,
+	supportService *support.Service,
+	statsService *stats.Service) *Handler {
+	cheapRate, cheapBurst := config.RateLimitCheap()
+	expensiveRate, expensiveBurst := config.RateLimitExpensive()
 	return &Handler{
 		syncService:              syncService,
 		paymentService:           paymentService,
@@ -53,8 +88,21 @@ func NewHandler(
 		promocodeRepository:      promocodeRepository,
 		promocodeUsageRepository: promocodeUsageRepository,
 		cache:                    cache,
+		promoService:             promoService,
+		referralService:          referralService,
+		trialService:             trialService,
+		moderationService:        moderationService,
+		faqService:               faqService,
+		supportService:           supportService,
+		statsService:             statsService,
 		awaitingPromo:            make(map[int64]bool),
+		awaitingWithdrawal:       make(map[int64]bool),
+		awaitingFAQSearch:        make(map[int64]bool),
 		shortLinks:               make(map[int64][]ShortLink),
+		channelMembers:           newChannelMembers(),
+		gatedUpdates:             newGatedUpdates(),
+		cheapLimiter:             ratelimit.New(cheapRate, cheapBurst),
+		expensiveLimiter:         ratelimit.New(expensiveRate, expensiveBurst),
 	}
 }
 
@@ -79,3 +127,47 @@ func (h *Handler) IsAwaitingPromo(id int64) bool {
 	defer h.promoMu.RUnlock()
 	return h.awaitingPromo[id]
 }
+
+func (h *Handler) expectWithdrawal(id int64) {
+	h.withdrawalMu.Lock()
+	h.awaitingWithdrawal[id] = true
+	h.withdrawalMu.Unlock()
+}
+
+func (h *Handler) consumeWithdrawal(id int64) bool {
+	h.withdrawalMu.Lock()
+	defer h.withdrawalMu.Unlock()
+	if h.awaitingWithdrawal[id] {
+		delete(h.awaitingWithdrawal, id)
+		return true
+	}
+	return false
+}
+
+func (h *Handler) IsAwaitingWithdrawal(id int64) bool {
+	h.withdrawalMu.RLock()
+	defer h.withdrawalMu.RUnlock()
+	return h.awaitingWithdrawal[id]
+}
+
+func (h *Handler) expectFAQSearch(id int64) {
+	h.faqSearchMu.Lock()
+	h.awaitingFAQSearch[id] = true
+	h.faqSearchMu.Unlock()
+}
+
+func (h *Handler) consumeFAQSearch(id int64) bool {
+	h.faqSearchMu.Lock()
+	defer h.faqSearchMu.Unlock()
+	if h.awaitingFAQSearch[id] {
+		delete(h.awaitingFAQSearch, id)
+		return true
+	}
+	return false
+}
+
+func (h *Handler) IsAwaitingFAQSearch(id int64) bool {
+	h.faqSearchMu.RLock()
+	defer h.faqSearchMu.RUnlock()
+	return h.awaitingFAQSearch[id]
+}
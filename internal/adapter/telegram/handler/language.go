@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+)
+
+// languageAuto resets the choice so the Telegram client language is used again.
+const languageAuto = "auto"
+
+// LanguageCommandHandler shows the language settings: /language
+func (h *Handler) LanguageCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	lang := userLanguage(ctx, update)
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      update.Message.Chat.ID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        h.translation.Format(lang, "language_menu_text", translation.Args{"language": h.translation.GetText(lang, "language_name")}),
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: h.languageKeyboard(lang)},
+	})
+	if err != nil {
+		slog.Error("Error sending language menu", "err", err)
+	}
+}
+
+// LanguageCallbackHandler shows the language settings for "language" and
+// stores the choice for "language:<code>" or "language:auto".
+func (h *Handler) LanguageCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	lang := userLanguage(ctx, update)
+	if _, choice, ok := strings.Cut(update.CallbackQuery.Data, ":"); ok {
+		chosen, err := h.setLanguage(ctx, update.CallbackQuery.From, choice)
+		if err != nil {
+			slog.Error("set customer language", "err", err)
+			return
+		}
+		lang = chosen
+	}
+
+	chatID, msgID, ok := callbackChatMessage(update)
+	if !ok {
+		slog.Error("callback message missing")
+		return
+	}
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      chatID,
+		MessageID:   msgID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        h.translation.Format(lang, "language_menu_text", translation.Args{"language": h.translation.GetText(lang, "language_name")}),
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: h.languageKeyboard(lang)},
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.Error("Error editing language menu", "err", err)
+	}
+}
+
+// setLanguage stores the choice of the user and returns the language to use from now on.
+func (h *Handler) setLanguage(ctx context.Context, user models.User, choice string) (string, error) {
+	customer, err := h.customerRepository.FindByTelegramId(ctx, user.ID)
+	if err != nil {
+		return "", err
+	}
+	if customer == nil {
+		return "", fmt.Errorf("customer %d not found", user.ID)
+	}
+
+	updates := map[string]interface{}{"language": choice, "language_chosen": true}
+	if choice == languageAuto || !h.translation.Has(choice) {
+		updates = map[string]interface{}{"language": user.LanguageCode, "language_chosen": false}
+	}
+	if err := h.customerRepository.UpdateFields(ctx, customer.ID, updates); err != nil {
+		return "", err
+	}
+	return updates["language"].(string), nil
+}
+
+func (h *Handler) languageKeyboard(lang string) [][]models.InlineKeyboardButton {
+	var kb [][]models.InlineKeyboardButton
+	for _, code := range h.translation.Languages() {
+		name := h.translation.GetText(code, "language_name")
+		if code == lang {
+			name = "✅ " + name
+		}
+		kb = append(kb, []models.InlineKeyboardButton{{Text: name, CallbackData: fmt.Sprintf("%s:%s", CallbackLanguage, code)}})
+	}
+	kb = append(kb, []models.InlineKeyboardButton{{Text: h.translation.GetText(lang, "language_auto_button"), CallbackData: fmt.Sprintf("%s:%s", CallbackLanguage, languageAuto)}})
+	kb = append(kb, []models.InlineKeyboardButton{{Text: h.translation.GetText(lang, "back_button"), CallbackData: CallbackOther}})
+	return kb
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/go-telegram/bot/models"
+)
+
+// metricCommands and metricCallbacks get their own handler label, the update
+// data comes from clients so anything else shares the "other" label.
+var (
+	metricCommands = nameSet("start", "menu", "help", "promo", "connect", "language", "sync", "referral_review", "withdrawals",
+		"block", "ban", "unblock", "faq_category", "faq_add", "faq_edit", "faq_page", "faq_delete", "faq_delete_category",
+		"stats", "reload_translations", "promo_batch")
+	metricCallbacks = nameSet(CallbackBuy, CallbackSell, CallbackStart, CallbackConnect, CallbackPayment, CallbackBalance,
+		CallbackTopup, CallbackTopupMethod, CallbackPayFromBal, CallbackTrial, CallbackActivateTrial, CallbackReferral,
+		CallbackReferralStats, CallbackPromoCodes, CallbackPromoCreate, CallbackPromoEnter, CallbackPromoList,
+		CallbackPromoFreeze, CallbackPromoUnfreeze, CallbackPromoConfirmationDelete, CallbackPromoDelete,
+		CallbackPromoBatches, CallbackPromoBatchView, CallbackPromoBatchExport, CallbackOther, CallbackFAQ,
+		CallbackTrafficLimit, CallbackKeys, CallbackQR, CallbackShortLink, CallbackShortList, CallbackLocations,
+		CallbackRegenKey, CallbackReviewApprove, CallbackReviewReject, CallbackPayout, CallbackChannelCheck,
+		CallbackWithdrawalPaid, CallbackWithdrawalReject, CallbackLanguage, CallbackSupport, CallbackStats)
+)
+
+func nameSet(names ...string) map[string]struct{} {
+	m := make(map[string]struct{}, len(names))
+	for _, n := range names {
+		m[n] = struct{}{}
+	}
+	return m
+}
+
+// UpdateLabel names the handler of the update in the handler duration metric.
+func UpdateLabel(update *models.Update) string {
+	switch {
+	case update.CallbackQuery != nil:
+		name := update.CallbackQuery.Data
+		if i := strings.IndexAny(name, ":?"); i >= 0 {
+			name = name[:i]
+		}
+		if _, ok := metricCallbacks[name]; ok {
+			return "callback:" + name
+		}
+		return "callback:other"
+	case update.PreCheckoutQuery != nil:
+		return "pre_checkout"
+	case update.Message != nil:
+		if IsSupportGroupMessage(update) {
+			return "support_group"
+		}
+		if update.Message.SuccessfulPayment != nil {
+			return "successful_payment"
+		}
+		command, _, ok := parseGroupCommand(update.Message.Text)
+		if !ok {
+			return "message"
+		}
+		if _, known := metricCommands[command]; known {
+			return "command:" + command
+		}
+		return "command:other"
+	default:
+		return "other"
+	}
+}
@@ -8,8 +8,12 @@ import (
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
+	"remnawave-tg-shop-bot/internal/pkg/contextkey"
 )
 
+// CreateCustomerIfNotExistMiddleware creates the customer on the first update
+// and keeps their language in sync with the Telegram client unless they chose
+// one with /language. The resolved language is passed on in the context.
 func (h *Handler) CreateCustomerIfNotExistMiddleware(next bot.HandlerFunc) bot.HandlerFunc {
 	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
 		var telegramId int64
@@ -38,18 +42,27 @@ func (h *Handler) CreateCustomerIfNotExistMiddleware(next bot.HandlerFunc) bot.H
 				return
 			}
 		} else {
-			updates := map[string]interface{}{
-				"language": langCode,
+			updates := map[string]interface{}{}
+			if existingCustomer.LanguageChosen {
+				langCode = existingCustomer.Language
+			} else {
+				updates["language"] = langCode
+			}
+			if existingCustomer.UnreachableAt != nil {
+				// the customer is back, notifications reach them again
+				updates["unreachable_at"] = nil
 			}
 
-			err = h.customerRepository.UpdateFields(ctx, existingCustomer.ID, updates)
-			if err != nil {
-				slog.Error("Error updating customer", "err", err)
-				return
+			if len(updates) > 0 {
+				err = h.customerRepository.UpdateFields(ctx, existingCustomer.ID, updates)
+				if err != nil {
+					slog.Error("Error updating customer", "err", err)
+					return
+				}
 			}
 		}
 
-		next(ctx, b, update)
+		next(contextkey.WithLanguage(ctx, langCode), b, update)
 	}
 }
 
@@ -58,9 +71,9 @@ func LogUpdateMiddleware(next bot.HandlerFunc) bot.HandlerFunc {
 	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
 		switch {
 		case update.Message != nil:
-			slog.Info("update", "type", "message", "chat", update.Message.Chat.ID)
+			slog.InfoContext(ctx, "update", "type", "message", "chat", update.Message.Chat.ID)
 		case update.CallbackQuery != nil:
-			slog.Info("update", "type", "callback", "from", update.CallbackQuery.From.ID)
+			slog.InfoContext(ctx, "update", "type", "callback", "from", update.CallbackQuery.From.ID)
 		}
 		next(ctx, b, update)
 	}
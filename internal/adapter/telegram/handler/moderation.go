@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	"remnawave-tg-shop-bot/internal/service/moderation"
+)
+
+// BlockedUserMiddleware stops updates from blocked users. The first update
+// after a block is answered with a notice, the rest are dropped silently.
+func (h *Handler) BlockedUserMiddleware(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		userID, lang := updateSender(ctx, update)
+		if userID == 0 || config.IsAdmin(userID) {
+			next(ctx, b, update)
+			return
+		}
+		block, notify, err := h.moderationService.Check(ctx, userID)
+		if err != nil {
+			slog.Error("check customer block", "err", err)
+			return
+		}
+		if block == nil {
+			next(ctx, b, update)
+			return
+		}
+
+		if update.CallbackQuery != nil {
+			_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID})
+		}
+		if !notify {
+			return
+		}
+		reason := block.Reason
+		if reason == "" {
+			reason = h.translation.GetText(lang, "blocked_no_reason")
+		}
+		text := h.translation.Format(lang, "blocked_notice", translation.Args{"reason": translation.Raw(reason)})
+		if block.Until != nil {
+			text = h.translation.Format(lang, "blocked_notice_until", translation.Args{
+				"reason": translation.Raw(reason),
+				"until":  block.Until.Format("02.01.2006 15:04"),
+			})
+		}
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{ChatID: userID, Text: text})
+		if err != nil {
+			slog.Error("Error sending blocked notice", "err", err)
+		}
+	}
+}
+
+// BlockCommandHandler blocks a user in the bot: /block <telegram_id> [30m|12h|7d] [reason]
+func (h *Handler) BlockCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	h.blockCommand(ctx, b, update, false)
+}
+
+// BanCommandHandler blocks a user and disables their Remnawave user:
+// /ban <telegram_id> [30m|12h|7d] [reason]
+func (h *Handler) BanCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	h.blockCommand(ctx, b, update, true)
+}
+
+func (h *Handler) blockCommand(ctx context.Context, b *bot.Bot, update *models.Update, disablePanel bool) {
+	if !config.IsAdmin(update.Message.From.ID) {
+		return
+	}
+	lang := userLanguage(ctx, update)
+	_, args, _ := strings.Cut(update.Message.Text, " ")
+
+	req, err := moderation.ParseBlock(args)
+	if err == nil {
+		_, err = h.moderationService.Block(ctx, update.Message.From.ID, req, disablePanel)
+	}
+
+	var text string
+	switch {
+	case err == nil && disablePanel:
+		text = h.translation.Format(lang, "ban_done", translation.Args{"id": req.TelegramID})
+	case err == nil:
+		text = h.translation.Format(lang, "block_done", translation.Args{"id": req.TelegramID})
+	case errors.Is(err, moderation.ErrFormat), errors.Is(err, moderation.ErrReason):
+		text = h.translation.GetText(lang, "block_usage")
+	case errors.Is(err, moderation.ErrCustomerNotFound):
+		text = h.translation.GetText(lang, "block_customer_not_found")
+	case errors.Is(err, moderation.ErrAdminTarget):
+		text = h.translation.GetText(lang, "block_admin_target")
+	default:
+		slog.Error("block customer", "err", err)
+		text = h.translation.GetText(lang, "block_error")
+	}
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{ChatID: update.Message.Chat.ID, Text: text})
+	if err != nil {
+		slog.Error("Error sending block result", "err", err)
+	}
+}
+
+// UnblockCommandHandler lifts a block: /unblock <telegram_id>
+func (h *Handler) UnblockCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if !config.IsAdmin(update.Message.From.ID) {
+		return
+	}
+	lang := userLanguage(ctx, update)
+	_, args, _ := strings.Cut(update.Message.Text, " ")
+
+	telegramID, err := strconv.ParseInt(strings.TrimSpace(args), 10, 64)
+	if err == nil {
+		err = h.moderationService.Unblock(ctx, update.Message.From.ID, telegramID)
+	} else {
+		err = moderation.ErrFormat
+	}
+
+	var text string
+	switch {
+	case err == nil:
+		text = h.translation.Format(lang, "unblock_done", translation.Args{"id": telegramID})
+	case errors.Is(err, moderation.ErrFormat):
+		text = h.translation.GetText(lang, "unblock_usage")
+	case errors.Is(err, moderation.ErrNotBlocked):
+		text = h.translation.GetText(lang, "unblock_not_blocked")
+	case errors.Is(err, moderation.ErrPanelEnable):
+		text = h.translation.Format(lang, "unblock_panel_failed", translation.Args{"id": telegramID})
+	default:
+		slog.Error("unblock customer", "err", err)
+		text = h.translation.GetText(lang, "block_error")
+	}
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{ChatID: update.Message.Chat.ID, Text: text})
+	if err != nil {
+		slog.Error("Error sending unblock result", "err", err)
+	}
+}
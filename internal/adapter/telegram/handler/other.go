@@ -17,11 +17,12 @@ import (
 	"github.com/go-telegram/bot/models"
 
 	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
 	"remnawave-tg-shop-bot/internal/ui"
 )
 
 func (h *Handler) OtherCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	lang := update.CallbackQuery.From.LanguageCode
+	lang := userLanguage(ctx, update)
 	kb := [][]models.InlineKeyboardButton{
 		{
 			{Text: h.translation.GetText(lang, "faq_button"), CallbackData: CallbackFAQ},
@@ -34,7 +35,9 @@ func (h *Handler) OtherCallbackHandler(ctx context.Context, b *bot.Bot, update *
 		{{Text: h.translation.GetText(lang, "short_button"), CallbackData: CallbackShortLink}},
 		{{Text: h.translation.GetText(lang, "locations_button"), CallbackData: CallbackLocations}},
 		{{Text: h.translation.GetText(lang, "regen_key_button"), CallbackData: CallbackRegenKey}},
+		{{Text: h.translation.GetText(lang, "language_button"), CallbackData: CallbackLanguage}},
 	}
+	kb = append(kb, h.infoPageButtons(ctx, lang)...)
 	if config.ServerStatusURL() != "" {
 		kb = append(kb, []models.InlineKeyboardButton{{Text: h.translation.GetText(lang, "server_status_button"), URL: config.ServerStatusURL()}})
 	}
@@ -74,24 +77,23 @@ func (h *Handler) OtherCallbackHandler(ctx context.Context, b *bot.Bot, update *
 	}
 }
 
-func (h *Handler) FAQCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	h.simpleBack(ctx, b, update, h.translation.GetText(update.CallbackQuery.From.LanguageCode, "coming_soon_text"))
-}
-
 func (h *Handler) TrafficLimitCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	h.simpleBack(ctx, b, update, h.translation.GetText(update.CallbackQuery.From.LanguageCode, "coming_soon_text"))
+	lang := userLanguage(ctx, update)
+	h.simpleBack(ctx, b, update, h.translation.GetText(lang, "coming_soon_text"))
 }
 
 func (h *Handler) LocationsCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	h.simpleBack(ctx, b, update, h.translation.GetText(update.CallbackQuery.From.LanguageCode, "coming_soon_text"))
+	lang := userLanguage(ctx, update)
+	h.simpleBack(ctx, b, update, h.translation.GetText(lang, "coming_soon_text"))
 }
 
 func (h *Handler) RegenKeyCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	h.simpleBack(ctx, b, update, h.translation.GetText(update.CallbackQuery.From.LanguageCode, "coming_soon_text"))
+	lang := userLanguage(ctx, update)
+	h.simpleBack(ctx, b, update, h.translation.GetText(lang, "coming_soon_text"))
 }
 
 func (h *Handler) simpleBack(ctx context.Context, b *bot.Bot, update *models.Update, text string) {
-	lang := update.CallbackQuery.From.LanguageCode
+	lang := userLanguage(ctx, update)
 	kb := [][]models.InlineKeyboardButton{{{Text: h.translation.GetText(lang, "back_button"), CallbackData: CallbackOther}}}
 
 	chatID, msgID, ok := callbackChatMessage(update)
@@ -113,7 +115,7 @@ func (h *Handler) simpleBack(ctx context.Context, b *bot.Bot, update *models.Upd
 }
 
 func (h *Handler) KeysCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	lang := update.CallbackQuery.From.LanguageCode
+	lang := userLanguage(ctx, update)
 	customer, err := h.findOrCreateCustomer(ctx, update.CallbackQuery.From.ID, lang)
 	if err != nil || customer.SubscriptionLink == nil {
 		slog.Error("find customer", "err", err)
@@ -151,7 +153,7 @@ func (h *Handler) KeysCallbackHandler(ctx context.Context, b *bot.Bot, update *m
 }
 
 func (h *Handler) QRCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	lang := update.CallbackQuery.From.LanguageCode
+	lang := userLanguage(ctx, update)
 	customer, err := h.findOrCreateCustomer(ctx, update.CallbackQuery.From.ID, lang)
 	if err != nil || customer.SubscriptionLink == nil {
 		slog.Error("find customer", "err", err)
@@ -179,7 +181,7 @@ func (h *Handler) QRCallbackHandler(ctx context.Context, b *bot.Bot, update *mod
 	_, err = b.SendPhoto(ctx, &bot.SendPhotoParams{
 		ChatID:      chatID,
 		Photo:       &models.InputFileUpload{Filename: "qr.png", Data: bytes.NewReader(data)},
-		Caption:     fmt.Sprintf(h.translation.GetText(lang, "qr_text"), *customer.SubscriptionLink),
+		Caption:     h.translation.Format(lang, "qr_text", translation.Args{"link": *customer.SubscriptionLink}),
 		ParseMode:   models.ParseModeHTML,
 		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: kb},
 	})
@@ -189,7 +191,7 @@ func (h *Handler) QRCallbackHandler(ctx context.Context, b *bot.Bot, update *mod
 }
 
 func (h *Handler) ShortLinkCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	lang := update.CallbackQuery.From.LanguageCode
+	lang := userLanguage(ctx, update)
 	customer, err := h.findOrCreateCustomer(ctx, update.CallbackQuery.From.ID, lang)
 	if err != nil || customer.SubscriptionLink == nil {
 		slog.Error("find customer", "err", err)
@@ -243,7 +245,7 @@ func (h *Handler) ShortLinkCallbackHandler(ctx context.Context, b *bot.Bot, upda
 		ChatID:      chatID,
 		MessageID:   msgID,
 		ParseMode:   models.ParseModeHTML,
-		Text:        fmt.Sprintf(h.translation.GetText(lang, "short_created_text"), shortURL),
+		Text:        h.translation.Format(lang, "short_created_text", translation.Args{"link": shortURL}),
 		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: kb},
 	})
 	if err != nil {
@@ -252,14 +254,13 @@ func (h *Handler) ShortLinkCallbackHandler(ctx context.Context, b *bot.Bot, upda
 }
 
 func (h *Handler) ShortListCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	lang := update.CallbackQuery.From.LanguageCode
+	lang := userLanguage(ctx, update)
 	h.shortMu.RLock()
 	list := h.shortLinks[update.CallbackQuery.From.ID]
 	h.shortMu.RUnlock()
 	var text string
 	if len(list) == 0 {
-		text = h.translation.GetText(lang, "short_list_text")
-		text = fmt.Sprintf(text, "-")
+		text = h.translation.Format(lang, "short_list_text", translation.Args{"links": "-"})
 	} else {
 		var bld strings.Builder
 		for i, l := range list {
@@ -269,7 +270,7 @@ func (h *Handler) ShortListCallbackHandler(ctx context.Context, b *bot.Bot, upda
 			}
 			fmt.Fprintf(&bld, "%d. %s\n   – %s\n", i+1, l.URL, status)
 		}
-		text = fmt.Sprintf(h.translation.GetText(lang, "short_list_text"), bld.String())
+		text = h.translation.Format(lang, "short_list_text", translation.Args{"links": bld.String()})
 	}
 	kb := [][]models.InlineKeyboardButton{{{Text: h.translation.GetText(lang, "back_button"), CallbackData: CallbackOther}}}
 	chatID, msgID, ok := callbackChatMessage(update)
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/adapter/remnawave"
+)
+
+// answerPanelUnavailable tells the user to come back later when err is caused
+// by the panel being unreachable, and reports whether it did.
+func (h *Handler) answerPanelUnavailable(ctx context.Context, b *bot.Bot, update *models.Update, err error) bool {
+	if !errors.Is(err, remnawave.ErrUnavailable) {
+		return false
+	}
+	text := h.translation.GetText(userLanguage(ctx, update), "panel_unavailable")
+	switch {
+	case update.CallbackQuery != nil:
+		_, err = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+			Text:            text,
+			ShowAlert:       true,
+		})
+	case update.Message != nil:
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{ChatID: update.Message.Chat.ID, Text: text})
+	default:
+		return true
+	}
+	if err != nil {
+		slog.Error("Error sending panel unavailable", "err", err)
+	}
+	return true
+}
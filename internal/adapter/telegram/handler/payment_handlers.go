@@ -15,6 +15,7 @@ import (
 
 	"remnawave-tg-shop-bot/internal/pkg/config"
 	"remnawave-tg-shop-bot/internal/pkg/contextkey"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
 )
 
 func (h *Handler) BuyCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
@@ -23,28 +24,46 @@ func (h *Handler) BuyCallbackHandler(ctx context.Context, b *bot.Bot, update *mo
 		slog.Error("callback message missing")
 		return
 	}
-	langCode := update.CallbackQuery.From.LanguageCode
+	langCode := userLanguage(ctx, update)
+	sub, picked := parseCallbackData(update.CallbackQuery.Data)["sub"]
+
+	customer, _ := h.customerRepository.FindByTelegramId(ctx, chatID)
+	if customer != nil && !picked {
+		if picker := h.subscriptionPicker(langCode, h.subscriptions(ctx, customer)); picker != nil {
+			_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+				ChatID:      chatID,
+				MessageID:   msgID,
+				ParseMode:   models.ParseModeHTML,
+				ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: picker},
+				Text:        h.translation.GetText(langCode, "subscription_pick_text"),
+			})
+			if err != nil {
+				slog.Error("Error sending subscription picker", "err", err)
+			}
+			return
+		}
+	}
 
 	var priceButtons []models.InlineKeyboardButton
 
 	if config.Price1() > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
 			Text:         h.translation.GetText(langCode, "month_1"),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d", CallbackSell, 1, config.Price1()),
+			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d%s", CallbackSell, 1, config.Price1(), subscriptionQuery(sub)),
 		})
 	}
 
 	if config.Price3() > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
 			Text:         h.translation.GetText(langCode, "month_3"),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d", CallbackSell, 3, config.Price3()),
+			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d%s", CallbackSell, 3, config.Price3(), subscriptionQuery(sub)),
 		})
 	}
 
 	if config.Price6() > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
 			Text:         h.translation.GetText(langCode, "month_6"),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d", CallbackSell, 6, config.Price6()),
+			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d%s", CallbackSell, 6, config.Price6(), subscriptionQuery(sub)),
 		})
 	}
 
@@ -57,11 +76,16 @@ func (h *Handler) BuyCallbackHandler(ctx context.Context, b *bot.Bot, update *mo
 		keyboard = append(keyboard, priceButtons)
 	}
 
-	keyboard = append(keyboard, []models.InlineKeyboardButton{
-		{Text: h.translation.GetText(langCode, "back_to_account_button"), CallbackData: CallbackStart},
-	})
+	if picked {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{
+			{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBuy},
+		})
+	} else {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{
+			{Text: h.translation.GetText(langCode, "back_to_account_button"), CallbackData: CallbackStart},
+		})
+	}
 
-	customer, _ := h.customerRepository.FindByTelegramId(ctx, chatID)
 	bal := 0
 	if customer != nil {
 		bal = int(customer.Balance)
@@ -73,7 +97,12 @@ func (h *Handler) BuyCallbackHandler(ctx context.Context, b *bot.Bot, update *mo
 		ReplyMarkup: models.InlineKeyboardMarkup{
 			InlineKeyboard: keyboard,
 		},
-		Text: fmt.Sprintf(h.translation.GetText(langCode, "choose_plan_text"), bal, config.Price1(), config.Price3(), config.Price6()),
+		Text: h.translation.Format(langCode, "choose_plan_text", translation.Args{
+			"balance": bal,
+			"price1":  config.Price1(),
+			"price3":  config.Price3(),
+			"price6":  config.Price6(),
+		}),
 	})
 
 	if err != nil {
@@ -88,15 +117,20 @@ func (h *Handler) SellCallbackHandler(ctx context.Context, b *bot.Bot, update *m
 		return
 	}
 	callbackQuery := parseCallbackData(update.CallbackQuery.Data)
-	langCode := update.CallbackQuery.From.LanguageCode
+	langCode := userLanguage(ctx, update)
 	month := callbackQuery["month"]
+	sub := subscriptionQuery(callbackQuery["sub"])
 
 	keyboard := [][]models.InlineKeyboardButton{
-		{{Text: h.translation.GetText(langCode, "buy_sub_balance_button"), CallbackData: fmt.Sprintf("%s?month=%s", CallbackPayFromBal, month)}},
+		{{Text: h.translation.GetText(langCode, "buy_sub_balance_button"), CallbackData: fmt.Sprintf("%s?month=%s%s", CallbackPayFromBal, month, sub)}},
 	}
 
+	back := CallbackBuy
+	if sub != "" {
+		back = fmt.Sprintf("%s?sub=%s", CallbackBuy, callbackQuery["sub"])
+	}
 	keyboard = append(keyboard, []models.InlineKeyboardButton{
-		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBuy},
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: back},
 	})
 
 	_, err := b.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
@@ -156,7 +190,7 @@ func (h *Handler) PaymentCallbackHandler(ctx context.Context, b *bot.Bot, update
 		return
 	}
 
-	langCode := update.CallbackQuery.From.LanguageCode
+	langCode := userLanguage(ctx, update)
 
 	message, err := b.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
 		ChatID:    chatID,
@@ -209,13 +243,13 @@ func (h *Handler) BalanceCallbackHandler(ctx context.Context, b *bot.Bot, update
 		slog.Error("callback message missing")
 		return
 	}
-	lang := update.CallbackQuery.From.LanguageCode
+	lang := userLanguage(ctx, update)
 	customer, _ := h.customerRepository.FindByTelegramId(ctx, chatID)
 	if customer == nil {
 		return
 	}
 
-	text := fmt.Sprintf(h.translation.GetText(lang, "balance_menu_text"), int(customer.Balance))
+	text := h.translation.Format(lang, "balance_menu_text", translation.Args{"balance": int(customer.Balance)})
 
 	keyboard := [][]models.InlineKeyboardButton{
 		{{Text: h.translation.GetText(lang, "topup_button"), CallbackData: CallbackTopup}},
@@ -241,7 +275,7 @@ func (h *Handler) TopupCallbackHandler(ctx context.Context, b *bot.Bot, update *
 		slog.Error("callback message missing")
 		return
 	}
-	lang := update.CallbackQuery.From.LanguageCode
+	lang := userLanguage(ctx, update)
 	customer, _ := h.customerRepository.FindByTelegramId(ctx, chatID)
 	if customer == nil {
 		return
@@ -264,7 +298,7 @@ func (h *Handler) TopupCallbackHandler(ctx context.Context, b *bot.Bot, update *
 		},
 		{{Text: h.translation.GetText(lang, "back_button"), CallbackData: CallbackBalance}},
 	}
-	text := fmt.Sprintf(h.translation.GetText(lang, "topup_intro_text"), int(customer.Balance))
+	text := h.translation.Format(lang, "topup_intro_text", translation.Args{"balance": int(customer.Balance)})
 	params := &bot.EditMessageTextParams{
 		ChatID:      chatID,
 		MessageID:   msgID,
@@ -289,7 +323,7 @@ func (h *Handler) TopupMethodCallbackHandler(ctx context.Context, b *bot.Bot, up
 	}
 	data := parseCallbackData(update.CallbackQuery.Data)
 	amount := data["amount"]
-	lang := update.CallbackQuery.From.LanguageCode
+	lang := userLanguage(ctx, update)
 
 	var keyboard [][]models.InlineKeyboardButton
 	for _, p := range h.paymentService.EnabledProviders() {
@@ -322,6 +356,11 @@ func (h *Handler) PayFromBalanceCallbackHandler(ctx context.Context, b *bot.Bot,
 	}
 	data := parseCallbackData(update.CallbackQuery.Data)
 	month, _ := strconv.Atoi(data["month"])
+	target, err := subscriptionTarget(data["sub"])
+	if err != nil {
+		slog.Error("error pay from balance", "err", err)
+		return
+	}
 
 	ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
@@ -329,7 +368,8 @@ func (h *Handler) PayFromBalanceCallbackHandler(ctx context.Context, b *bot.Bot,
 	if err != nil || customer == nil {
 		return
 	}
-	if err := h.paymentService.PurchaseFromBalance(ctxTimeout, customer, month); err != nil {
+	if err := h.paymentService.PurchaseFromBalance(ctxTimeout, customer, target, month); err != nil {
 		slog.Error("error pay from balance", "err", err)
+		h.answerPanelUnavailable(ctx, b, update, err)
 	}
 }
@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	pg "remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/internal/service/promo"
+)
+
+const promoBatchListLimit = 10
+
+// PromoBatchCommandHandler generates a batch of promo codes:
+// /promo_batch count=100 months=1 uses=1 tag=partner [prefix=PARTNER|code=VANITY]
+func (h *Handler) PromoBatchCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if !config.IsAdmin(update.Message.From.ID) {
+		return
+	}
+	lang := userLanguage(ctx, update)
+	chatID := update.Message.Chat.ID
+
+	req, err := promo.ParseBatchArgs(strings.Fields(update.Message.Text)[1:])
+	if err != nil {
+		h.sendPromoBatchUsage(ctx, b, chatID, lang, err)
+		return
+	}
+	req.CreatedBy = update.Message.From.ID
+
+	batch, codes, err := h.promoService.GenerateBatch(ctx, req)
+	if errors.Is(err, pg.ErrPromocodeExists) {
+		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{ChatID: chatID, Text: h.translation.GetText(lang, "promo_batch_code_taken")})
+		return
+	}
+	if err != nil {
+		slog.Error("generate promocode batch", "err", err)
+		h.sendPromoBatchUsage(ctx, b, chatID, lang, err)
+		return
+	}
+	slog.Info("promocode batch created", "batch", batch.ID, "tag", batch.Tag, "size", len(codes))
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   h.translation.Format(lang, "promo_batch_created", translation.Args{"id": batch.ID, "tag": translation.Raw(batch.Tag), "count": len(codes)}),
+	})
+	if err != nil {
+		slog.Error("Error sending promo_batch_created msg", "err", err)
+	}
+	h.sendPromoBatchCSV(ctx, b, chatID, lang, batch)
+}
+
+func (h *Handler) sendPromoBatchUsage(ctx context.Context, b *bot.Bot, chatID int64, lang string, cause error) {
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   h.translation.Format(lang, "promo_batch_usage", translation.Args{"error": translation.Raw(cause.Error())}),
+	})
+	if err != nil {
+		slog.Error("Error sending promo_batch_usage msg", "err", err)
+	}
+}
+
+func (h *Handler) PromoBatchesCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if !config.IsAdmin(update.CallbackQuery.From.ID) {
+		return
+	}
+	langCode := userLanguage(ctx, update)
+	chatID, msgID, ok := callbackChatMessage(update)
+	if !ok {
+		slog.Error("callback message missing")
+		return
+	}
+
+	batches, err := h.promoService.RecentBatches(ctx, promoBatchListLimit)
+	if err != nil {
+		slog.Error("error getting promocode batches", "err", err)
+		return
+	}
+
+	var textBuilder strings.Builder
+	textBuilder.WriteString(h.translation.GetText(langCode, "promo_batches_list_intro"))
+	if len(batches) == 0 {
+		textBuilder.WriteString("\n\n-")
+	}
+	var kb [][]models.InlineKeyboardButton
+	for _, batch := range batches {
+		label := fmt.Sprintf("#%d %s — %d × %d", batch.ID, batch.Tag, batch.Size, batch.Months)
+		kb = append(kb, []models.InlineKeyboardButton{
+			{Text: label, CallbackData: fmt.Sprintf("%s:%d", CallbackPromoBatchView, batch.ID)},
+		})
+	}
+	kb = append(kb, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackPromoCodes}})
+
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      chatID,
+		MessageID:   msgID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        textBuilder.String(),
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: kb},
+	})
+	if err != nil {
+		slog.Error("Error sending promocode batches", "err", err)
+	}
+}
+
+func (h *Handler) PromoBatchViewCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if !config.IsAdmin(update.CallbackQuery.From.ID) {
+		return
+	}
+	langCode := userLanguage(ctx, update)
+	chatID, msgID, ok := callbackChatMessage(update)
+	if !ok {
+		slog.Error("callback message missing")
+		return
+	}
+	id, ok := parseIDCallback(update.CallbackQuery.Data)
+	if !ok {
+		return
+	}
+
+	batch, err := h.promoService.Batch(ctx, id)
+	if err != nil {
+		slog.Error("failed to get promocode batch", "err", err)
+		return
+	}
+	stats, err := h.promoService.Stats(ctx, id)
+	if err != nil {
+		slog.Error("failed to get promocode batch stats", "err", err)
+		return
+	}
+
+	text := h.translation.Format(langCode, "promo_batch_stats", translation.Args{
+		"id":       batch.ID,
+		"tag":      batch.Tag,
+		"months":   batch.Months,
+		"uses":     batch.UsesPerCode,
+		"issued":   stats.Issued,
+		"redeemed": stats.Redeemed,
+		"revenue":  promo.FormatRevenue(stats.Revenue),
+	})
+
+	kb := [][]models.InlineKeyboardButton{
+		{{Text: h.translation.GetText(langCode, "promo_batch_export_button"), CallbackData: fmt.Sprintf("%s:%d", CallbackPromoBatchExport, batch.ID)}},
+		{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackPromoBatches}},
+	}
+
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      chatID,
+		MessageID:   msgID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        text,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: kb},
+	})
+	if err != nil {
+		slog.Error("Error sending promocode batch stats", "err", err)
+	}
+}
+
+func (h *Handler) PromoBatchExportCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if !config.IsAdmin(update.CallbackQuery.From.ID) {
+		return
+	}
+	chatID, _, ok := callbackChatMessage(update)
+	if !ok {
+		slog.Error("callback message missing")
+		return
+	}
+	id, ok := parseIDCallback(update.CallbackQuery.Data)
+	if !ok {
+		return
+	}
+	batch, err := h.promoService.Batch(ctx, id)
+	if err != nil {
+		slog.Error("failed to get promocode batch", "err", err)
+		return
+	}
+	h.sendPromoBatchCSV(ctx, b, chatID, userLanguage(ctx, update), batch)
+}
+
+func (h *Handler) sendPromoBatchCSV(ctx context.Context, b *bot.Bot, chatID int64, lang string, batch *pg.PromocodeBatch) {
+	data, err := h.promoService.ExportCSV(ctx, batch.ID)
+	if err != nil {
+		slog.Error("export promocode batch", "err", err)
+		return
+	}
+	_, err = b.SendDocument(ctx, &bot.SendDocumentParams{
+		ChatID:   chatID,
+		Document: &models.InputFileUpload{Filename: fmt.Sprintf("promo_batch_%d_%s.csv", batch.ID, batch.Tag), Data: bytes.NewReader(data)},
+		Caption:  h.translation.Format(lang, "promo_batch_export_caption", translation.Args{"id": batch.ID, "tag": translation.Raw(batch.Tag)}),
+	})
+	if err != nil {
+		slog.Error("send promocode batch csv", "err", err)
+	}
+}
+
+// parseIDCallback extracts the numeric id from "<prefix>:<id>" callback data.
+func parseIDCallback(data string) (int64, bool) {
+	parts := strings.Split(data, ":")
+	if len(parts) != 2 {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/observability"
+	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// Budget selects the per-user rate limit applied to a handler.
+type Budget string
+
+const (
+	// BudgetCheap covers handlers that only touch the database.
+	BudgetCheap Budget = "cheap"
+	// BudgetExpensive covers handlers calling Remnawave, payment providers or other external APIs.
+	BudgetExpensive Budget = "expensive"
+)
+
+// RateLimit drops updates of users who spent their budget. It is placed
+// before CreateCustomerIfNotExistMiddleware so that throttled updates cost
+// no database writes. Callbacks get a "slow down" toast, messages are ignored.
+func (h *Handler) RateLimit(budget Budget) bot.Middleware {
+	limiter := h.cheapLimiter
+	if budget == BudgetExpensive {
+		limiter = h.expensiveLimiter
+	}
+	return func(next bot.HandlerFunc) bot.HandlerFunc {
+		return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+			userID, lang := updateSender(ctx, update)
+			if userID == 0 || config.IsAdmin(userID) || limiter.Allow(userID) {
+				next(ctx, b, update)
+				return
+			}
+			observability.ThrottledUpdates.WithLabelValues(string(budget)).Inc()
+			slog.Debug("update throttled", "budget", budget, "user", utils.MaskHalfInt64(userID))
+			if update.CallbackQuery == nil {
+				return
+			}
+			_, err := b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+				CallbackQueryID: update.CallbackQuery.ID,
+				Text:            h.translation.GetText(lang, "rate_limited"),
+			})
+			if err != nil {
+				slog.Error("Error answering throttled callback", "err", err)
+			}
+		}
+	}
+}
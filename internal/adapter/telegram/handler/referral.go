@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -11,11 +12,15 @@ import (
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 
+	"remnawave-tg-shop-bot/internal/adapter/remnawave"
 	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	pg "remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/internal/service/referral"
 )
 
 func (h *Handler) ReferralCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	langCode := update.CallbackQuery.From.LanguageCode
+	langCode := userLanguage(ctx, update)
 	chatID, msgID, ok := callbackChatMessage(update)
 	if !ok {
 		slog.Error("callback message missing")
@@ -55,7 +60,7 @@ func (h *Handler) ReferralCallbackHandler(ctx context.Context, b *bot.Bot, updat
 }
 
 func (h *Handler) PromoCreateCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	langCode := update.CallbackQuery.From.LanguageCode
+	langCode := userLanguage(ctx, update)
 	chatID, msgID, ok := callbackChatMessage(update)
 	if !ok {
 		slog.Error("callback message missing")
@@ -112,7 +117,7 @@ func (h *Handler) PromoCreateCallbackHandler(ctx context.Context, b *bot.Bot, up
 		ChatID:      chatID,
 		MessageID:   msgID,
 		ParseMode:   models.ParseModeHTML,
-		Text:        fmt.Sprintf(h.translation.GetText(langCode, "promocode_created"), code),
+		Text:        h.translation.Format(langCode, "promocode_created", translation.Args{"code": code}),
 		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: kb},
 	})
 	if err != nil {
@@ -123,7 +128,7 @@ func (h *Handler) PromoCreateCallbackHandler(ctx context.Context, b *bot.Bot, up
 }
 
 func (h *Handler) PromoEnterCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	langCode := update.CallbackQuery.From.LanguageCode
+	langCode := userLanguage(ctx, update)
 
 	chatID, msgID, ok := callbackChatMessage(update)
 	if !ok {
@@ -159,7 +164,7 @@ func (h *Handler) PromoEnterCallbackHandler(ctx context.Context, b *bot.Bot, upd
 }
 
 func (h *Handler) ReferralStatsCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	langCode := update.CallbackQuery.From.LanguageCode
+	langCode := userLanguage(ctx, update)
 	chatID, msgID, ok := callbackChatMessage(update)
 	if !ok {
 		slog.Error("callback message missing")
@@ -171,34 +176,36 @@ func (h *Handler) ReferralStatsCallbackHandler(ctx context.Context, b *bot.Bot,
 		return
 	}
 
-	refs, err := h.referralRepository.FindByReferrer(ctx, customer.TelegramID)
+	stats, err := h.referralService.Stats(ctx, customer.TelegramID)
 	if err != nil {
-		slog.Error("error loading referrals", "err", err)
+		slog.Error("error loading referral stats", "err", err)
 		return
 	}
 
-	invited := len(refs)
-	subscribed := 0
-	for _, r := range refs {
-		if r.BonusGranted {
-			subscribed++
-		}
-	}
-
-	bonusTotal := subscribed * config.GetReferralBonus()
-
 	refLink := fmt.Sprintf("https://telegram.me/share/url?url=https://t.me/%s?start=ref_%d", update.CallbackQuery.From.Username, customer.TelegramID)
 
-	text := fmt.Sprintf(h.translation.GetText(langCode, "referral_system_text"), invited, subscribed, bonusTotal, refLink, config.GetReferralBonus())
+	text := h.translation.Format(langCode, "referral_system_text", translation.Args{
+		"invited":  stats.Invited,
+		"rewarded": stats.Rewarded,
+		"earned":   translation.Raw(h.referralAmountText(langCode, stats.Rewards.ReleasedMoney, stats.Rewards.ReleasedDays)),
+		"held":     translation.Raw(h.referralAmountText(langCode, stats.Rewards.HeldMoney, stats.Rewards.HeldDays)),
+		"link":     refLink,
+		"rules":    translation.Raw(h.referralRulesText(langCode, h.referralService.Rules())),
+	})
 
 	kb := [][]models.InlineKeyboardButton{
 		{
 			{Text: h.translation.GetText(langCode, "share_referral_button"), URL: refLink},
 		},
-		{
-			{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackReferral},
-		},
 	}
+	if h.referralService.Rules().MinWithdrawal > 0 {
+		kb = append(kb, []models.InlineKeyboardButton{
+			{Text: h.translation.GetText(langCode, "referral_withdraw_button"), CallbackData: CallbackPayout},
+		})
+	}
+	kb = append(kb, []models.InlineKeyboardButton{
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackReferral},
+	})
 
 	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
 		ChatID:      chatID,
@@ -213,13 +220,13 @@ func (h *Handler) ReferralStatsCallbackHandler(ctx context.Context, b *bot.Bot,
 }
 
 func (h *Handler) PromoCodesCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	langCode := update.CallbackQuery.From.LanguageCode
+	langCode := userLanguage(ctx, update)
 	chatID, msgID, ok := callbackChatMessage(update)
 	if !ok {
 		slog.Error("callback message missing")
 		return
 	}
-	_, err := h.findOrCreateCustomer(ctx, update.CallbackQuery.From.ID, langCode)
+	customer, err := h.findOrCreateCustomer(ctx, update.CallbackQuery.From.ID, langCode)
 	if err != nil {
 		slog.Error("find or create customer", "err", err)
 		return
@@ -232,10 +239,15 @@ func (h *Handler) PromoCodesCallbackHandler(ctx context.Context, b *bot.Bot, upd
 		{
 			{Text: h.translation.GetText(langCode, "promo_list_button"), CallbackData: CallbackPromoList},
 		},
-		{
-			{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackReferral},
-		},
 	}
+	if config.IsAdmin(customer.TelegramID) {
+		kb = append(kb, []models.InlineKeyboardButton{
+			{Text: h.translation.GetText(langCode, "promo_batches_button"), CallbackData: CallbackPromoBatches},
+		})
+	}
+	kb = append(kb, []models.InlineKeyboardButton{
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackReferral},
+	})
 
 	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
 		ChatID:      chatID,
@@ -250,7 +262,7 @@ func (h *Handler) PromoCodesCallbackHandler(ctx context.Context, b *bot.Bot, upd
 }
 
 func (h *Handler) PromoListCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	langCode := update.CallbackQuery.From.LanguageCode
+	langCode := userLanguage(ctx, update)
 	chatID, msgID, ok := callbackChatMessage(update)
 	if !ok {
 		slog.Error("callback message missing")
@@ -313,7 +325,7 @@ func (h *Handler) PromoCodeMessageHandler(ctx context.Context, b *bot.Bot, updat
 	if !h.consumePromo(update.Message.Chat.ID) {
 		return
 	}
-	lang := update.Message.From.LanguageCode
+	lang := userLanguage(ctx, update)
 
 	customer, err := h.findOrCreateCustomer(ctx, update.Message.Chat.ID, lang)
 	if err != nil {
@@ -322,19 +334,10 @@ func (h *Handler) PromoCodeMessageHandler(ctx context.Context, b *bot.Bot, updat
 	}
 
 	code := strings.TrimSpace(update.Message.Text)
+	// the result is sent by the provisioning worker
 	if err := h.paymentService.ApplyPromocode(ctx, customer, code); err != nil {
-		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{ChatID: update.Message.Chat.ID, Text: h.translation.GetText(lang, "promo_invalid")})
-		return
+		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{ChatID: update.Message.Chat.ID, Text: h.promoErrorText(lang, err)})
 	}
-
-	until := ""
-	if customer.ExpireAt != nil {
-		until = customer.ExpireAt.Format("02.01.2006 15:04")
-	}
-
-	_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: update.Message.Chat.ID,
-		Text:   fmt.Sprintf(h.translation.GetText(lang, "promo_applied"), until)})
 }
 
 func (h *Handler) PromoFreezeCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
@@ -362,7 +365,7 @@ func (h *Handler) PromoUnfreezeCallbackHandler(ctx context.Context, b *bot.Bot,
 }
 
 func (h *Handler) PromoDeleteConfirmationCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	langCode := update.CallbackQuery.From.LanguageCode
+	langCode := userLanguage(ctx, update)
 
 	chatID, msgID, ok := callbackChatMessage(update)
 	if !ok {
@@ -435,3 +438,66 @@ func (h *Handler) PromoDeleteCallbackHandler(ctx context.Context, b *bot.Bot, up
 	// Optionally mark deleted: we set active=false
 	h.PromoListCallbackHandler(ctx, b, update)
 }
+
+// promoErrorText maps a promocode redemption error to a user facing message.
+func (h *Handler) promoErrorText(lang string, err error) string {
+	if errors.Is(err, pg.ErrPromocodeAlreadyUsed) {
+		return h.translation.GetText(lang, "promo_already_used")
+	}
+	if errors.Is(err, remnawave.ErrUnavailable) {
+		return h.translation.GetText(lang, "panel_unavailable")
+	}
+	return h.translation.GetText(lang, "promo_invalid")
+}
+
+// referralAmountText renders a reward total, e.g. "300 rubles + 7 days".
+func (h *Handler) referralAmountText(lang string, money float64, days int) string {
+	var parts []string
+	if money > 0 || days == 0 {
+		parts = append(parts, h.translation.Format(lang, "referral_amount_money", translation.Args{"amount": referral.FormatAmount(money)}))
+	}
+	if days > 0 {
+		parts = append(parts, h.translation.Format(lang, "referral_amount_days", translation.Args{"days": days}))
+	}
+	return strings.Join(parts, " + ")
+}
+
+// referralRulesText describes the configured referral program.
+func (h *Handler) referralRulesText(lang string, rules referral.Rules) string {
+	var lines []string
+	switch rules.Mode {
+	case referral.ModePercent:
+		lines = append(lines, h.translation.Format(lang, "referral_rule_percent", translation.Args{"percent": rules.Percent}))
+	case referral.ModeDays:
+		lines = append(lines, h.translation.Format(lang, "referral_rule_days", translation.Args{"days": rules.Days}))
+	default:
+		lines = append(lines, h.translation.Format(lang, "referral_rule_fixed", translation.Args{"bonus": rules.Bonus}))
+	}
+	switch rules.Payments {
+	case 0:
+		lines = append(lines, h.translation.GetText(lang, "referral_rule_payments_all"))
+	case 1:
+		lines = append(lines, h.translation.GetText(lang, "referral_rule_payments_first"))
+	default:
+		lines = append(lines, h.translation.Format(lang, "referral_rule_payments_n", translation.Args{"payments": rules.Payments}))
+	}
+	if rules.RefereeBonus > 0 {
+		lines = append(lines, h.translation.Format(lang, "referral_rule_referee_bonus", translation.Args{"bonus": rules.RefereeBonus}))
+	}
+	if rules.RefereeDays > 0 {
+		lines = append(lines, h.translation.Format(lang, "referral_rule_referee_days", translation.Args{"days": rules.RefereeDays}))
+	}
+	if rules.SecondLevelPercent > 0 {
+		lines = append(lines, h.translation.Format(lang, "referral_rule_second_level", translation.Args{"percent": rules.SecondLevelPercent}))
+	}
+	if days := int(rules.Hold.Hours() / 24); days > 0 {
+		lines = append(lines, h.translation.Format(lang, "referral_rule_hold", translation.Args{"days": days}))
+	}
+	if rules.MinPayment > 0 {
+		lines = append(lines, h.translation.Format(lang, "referral_rule_min_payment", translation.Args{"amount": rules.MinPayment}))
+	}
+	if rules.MinWithdrawal > 0 {
+		lines = append(lines, h.translation.Format(lang, "referral_rule_withdrawal", translation.Args{"amount": rules.MinWithdrawal}))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	"remnawave-tg-shop-bot/internal/service/referral"
+)
+
+// ReferralReviewCommandHandler lists referrers whose rewards were sent to review
+// by the daily cap, with approve and reject buttons.
+func (h *Handler) ReferralReviewCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if !config.IsAdmin(update.Message.From.ID) {
+		return
+	}
+	lang := userLanguage(ctx, update)
+
+	queue, err := h.referralService.ReviewQueue(ctx)
+	if err != nil {
+		slog.Error("error getting referral review queue", "err", err)
+		return
+	}
+	if len(queue) == 0 {
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{ChatID: update.Message.Chat.ID, Text: h.translation.GetText(lang, "referral_review_empty")})
+		if err != nil {
+			slog.Error("Error sending referral_review_empty msg", "err", err)
+		}
+		return
+	}
+
+	var textBuilder strings.Builder
+	textBuilder.WriteString(h.translation.GetText(lang, "referral_review_intro"))
+	var kb [][]models.InlineKeyboardButton
+	for _, item := range queue {
+		id := strconv.FormatInt(item.BeneficiaryID, 10)
+		textBuilder.WriteString("\n")
+		textBuilder.WriteString(h.translation.Format(lang, "referral_review_item", translation.Args{
+			"referrer": translation.Raw(id),
+			"rewards":  item.Rewards,
+			"amount":   translation.Raw(referral.FormatAmount(item.Money)),
+			"days":     item.Days,
+			"since":    translation.Raw(item.Since.Format("02.01.2006 15:04")),
+		}))
+		kb = append(kb, []models.InlineKeyboardButton{
+			{Text: h.translation.Format(lang, "referral_review_approve_button", translation.Args{"referrer": translation.Raw(id)}), CallbackData: fmt.Sprintf("%s:%s", CallbackReviewApprove, id)},
+			{Text: h.translation.Format(lang, "referral_review_reject_button", translation.Args{"referrer": translation.Raw(id)}), CallbackData: fmt.Sprintf("%s:%s", CallbackReviewReject, id)},
+		})
+	}
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      update.Message.Chat.ID,
+		Text:        textBuilder.String(),
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: kb},
+	})
+	if err != nil {
+		slog.Error("Error sending referral review queue", "err", err)
+	}
+}
+
+func (h *Handler) ReviewApproveCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	h.resolveReferralReview(ctx, b, update, h.referralService.ApproveReview, func(lang string, args translation.Args) string {
+		return h.translation.Format(lang, "referral_review_approved", args)
+	})
+}
+
+func (h *Handler) ReviewRejectCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	h.resolveReferralReview(ctx, b, update, h.referralService.RejectReview, func(lang string, args translation.Args) string {
+		return h.translation.Format(lang, "referral_review_rejected", args)
+	})
+}
+
+func (h *Handler) resolveReferralReview(ctx context.Context, b *bot.Bot, update *models.Update,
+	resolve func(context.Context, int64) (int, error), result func(string, translation.Args) string) {
+	if !config.IsAdmin(update.CallbackQuery.From.ID) {
+		return
+	}
+	chatID, msgID, ok := callbackChatMessage(update)
+	if !ok {
+		slog.Error("callback message missing")
+		return
+	}
+	referrerID, ok := parseIDCallback(update.CallbackQuery.Data)
+	if !ok {
+		return
+	}
+
+	count, err := resolve(ctx, referrerID)
+	if err != nil {
+		slog.Error("error resolving referral review", "err", err)
+		return
+	}
+	slog.Info("referral review resolved", "admin", update.CallbackQuery.From.ID, "rewards", count)
+
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    chatID,
+		MessageID: msgID,
+		Text: result(userLanguage(ctx, update), translation.Args{
+			"rewards":  count,
+			"referrer": referrerID,
+		}),
+	})
+	if err != nil {
+		slog.Error("Error sending referral review result", "err", err)
+	}
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -12,15 +13,17 @@ import (
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 
+	"remnawave-tg-shop-bot/internal/adapter/remnawave"
 	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
 	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
 	"remnawave-tg-shop-bot/utils"
 )
 
 func (h *Handler) StartCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	ctxWithTime, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	langCode := update.Message.From.LanguageCode
+	langCode := userLanguage(ctx, update)
 	existingCustomer, err := h.customerRepository.FindByTelegramId(ctx, update.Message.Chat.ID)
 	if err != nil {
 		slog.Error("error finding customer by telegram id", "err", err)
@@ -38,34 +41,22 @@ func (h *Handler) StartCommandHandler(ctx context.Context, b *bot.Bot, update *m
 			return
 		}
 
-		parts := strings.Fields(update.Message.Text)
-		if len(parts) > 1 && strings.HasPrefix(parts[1], "ref_") {
-			code := strings.TrimPrefix(parts[1], "ref_")
-			referrerId, err := strconv.ParseInt(code, 10, 64)
+		h.registerReferral(ctx, update.Message.Text, existingCustomer.TelegramID, true)
+	} else {
+		if existingCustomer.LanguageChosen {
+			langCode = existingCustomer.Language
+		} else {
+			updates := map[string]interface{}{
+				"language": langCode,
+			}
+
+			err = h.customerRepository.UpdateFields(ctx, existingCustomer.ID, updates)
 			if err != nil {
-				slog.Error("error parsing referrer id", "err", err)
+				slog.Error("Error updating customer", "err", err)
 				return
 			}
-			_, err = h.customerRepository.FindByTelegramId(ctx, referrerId)
-			if err == nil {
-				_, err := h.referralRepository.Create(ctx, referrerId, existingCustomer.TelegramID)
-				if err != nil {
-					slog.Error("error creating referral", "err", err)
-					return
-				}
-				slog.Info("referral created", "referrerId", utils.MaskHalfInt64(referrerId), "refereeId", utils.MaskHalfInt64(existingCustomer.TelegramID))
-			}
-		}
-	} else {
-		updates := map[string]interface{}{
-			"language": langCode,
-		}
-
-		err = h.customerRepository.UpdateFields(ctx, existingCustomer.ID, updates)
-		if err != nil {
-			slog.Error("Error updating customer", "err", err)
-			return
 		}
+		h.registerReferral(ctx, update.Message.Text, existingCustomer.TelegramID, false)
 	}
 
 	startKb := [][]models.InlineKeyboardButton{{{Text: h.translation.GetText(langCode, "account_button"), CallbackData: CallbackStart}}}
@@ -94,7 +85,7 @@ func (h *Handler) StartCommandHandler(ctx context.Context, b *bot.Bot, update *m
 		return
 	}
 
-	text := fmt.Sprintf(h.translation.GetText(langCode, "start_menu_text"), update.Message.From.FirstName)
+	text := h.translation.Format(langCode, "start_menu_text", translation.Args{"name": update.Message.From.FirstName})
 	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:      update.Message.Chat.ID,
 		ParseMode:   models.ParseModeHTML,
@@ -106,12 +97,34 @@ func (h *Handler) StartCommandHandler(ctx context.Context, b *bot.Bot, update *m
 	}
 }
 
+// registerReferral links the customer to the referrer from a "/start ref_<id>"
+// payload. Attempts by existing customers are rejected and logged.
+func (h *Handler) registerReferral(ctx context.Context, text string, refereeID int64, newCustomer bool) {
+	parts := strings.Fields(text)
+	if len(parts) < 2 || !strings.HasPrefix(parts[1], "ref_") {
+		return
+	}
+	referrerID, err := strconv.ParseInt(strings.TrimPrefix(parts[1], "ref_"), 10, 64)
+	if err != nil {
+		slog.Error("error parsing referrer id", "err", err)
+		return
+	}
+	created, err := h.referralService.Register(ctx, referrerID, refereeID, newCustomer)
+	if err != nil {
+		slog.Error("error creating referral", "err", err)
+		return
+	}
+	if created {
+		slog.Info("referral created", "referrerId", utils.MaskHalfInt64(referrerID), "refereeId", utils.MaskHalfInt64(refereeID))
+	}
+}
+
 func (h *Handler) StartCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	ctxWithTime, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	callback := update.CallbackQuery
-	langCode := callback.From.LanguageCode
+	langCode := userLanguage(ctx, update)
 
 	existingCustomer, err := h.customerRepository.FindByTelegramId(ctxWithTime, callback.From.ID)
 	if err != nil {
@@ -119,9 +132,9 @@ func (h *Handler) StartCallbackHandler(ctx context.Context, b *bot.Bot, update *
 		return
 	}
 
-	inlineKeyboard := h.buildStartKeyboard(existingCustomer, langCode)
+	inlineKeyboard := h.buildStartKeyboard(ctxWithTime, existingCustomer, langCode)
 
-	text := fmt.Sprintf(h.translation.GetText(langCode, "account_menu_text"), callback.From.FirstName) + "\n\n" + h.buildAccountInfo(ctxWithTime, existingCustomer, langCode)
+	text := h.translation.Format(langCode, "account_menu_text", translation.Args{"name": callback.From.FirstName}) + "\n\n" + h.buildAccountInfo(ctxWithTime, existingCustomer, langCode)
 	_, err = b.EditMessageText(ctxWithTime, &bot.EditMessageTextParams{
 		ChatID:      callback.Message.Message.Chat.ID,
 		MessageID:   callback.Message.Message.ID,
@@ -134,6 +147,15 @@ func (h *Handler) StartCallbackHandler(ctx context.Context, b *bot.Bot, update *
 	}
 }
 
+func (h *Handler) trialUsed(ctx context.Context, telegramID int64) bool {
+	used, err := h.trialService.Used(ctx, telegramID)
+	if err != nil {
+		slog.Error("error checking trial usage", "err", err)
+		return true
+	}
+	return used
+}
+
 func (h *Handler) resolveConnectButton(lang string) []models.InlineKeyboardButton {
 	var inlineKeyboard []models.InlineKeyboardButton
 
@@ -151,10 +173,10 @@ func (h *Handler) resolveConnectButton(lang string) []models.InlineKeyboardButto
 	return inlineKeyboard
 }
 
-func (h *Handler) buildStartKeyboard(existingCustomer *domaincustomer.Customer, langCode string) [][]models.InlineKeyboardButton {
+func (h *Handler) buildStartKeyboard(ctx context.Context, existingCustomer *domaincustomer.Customer, langCode string) [][]models.InlineKeyboardButton {
 	var kb [][]models.InlineKeyboardButton
 
-	if existingCustomer.SubscriptionLink == nil && config.TrialDays() > 0 {
+	if existingCustomer.SubscriptionLink == nil && h.trialService.Rules().Days > 0 && !h.trialUsed(ctx, existingCustomer.TelegramID) {
 		kb = append(kb, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "trial_button"), CallbackData: CallbackTrial}})
 	}
 
@@ -169,7 +191,9 @@ func (h *Handler) buildStartKeyboard(existingCustomer *domaincustomer.Customer,
 	kb = append(kb, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "other_button"), CallbackData: CallbackOther}})
 
 	var row []models.InlineKeyboardButton
-	if config.SupportURL() != "" {
+	if config.SupportChatID() != 0 {
+		row = append(row, models.InlineKeyboardButton{Text: h.translation.GetText(langCode, "support_button"), CallbackData: CallbackSupport})
+	} else if config.SupportURL() != "" {
 		row = append(row, models.InlineKeyboardButton{Text: h.translation.GetText(langCode, "support_button"), URL: config.SupportURL()})
 	}
 	if config.ChannelURL() != "" {
@@ -183,8 +207,13 @@ func (h *Handler) buildStartKeyboard(existingCustomer *domaincustomer.Customer,
 }
 
 func (h *Handler) buildAccountInfo(ctx context.Context, customer *domaincustomer.Customer, lang string) string {
-	user, _ := h.paymentService.GetUser(ctx, customer.TelegramID)
+	user, err := h.paymentService.GetUser(ctx, customer.TelegramID)
 	var info strings.Builder
+	if errors.Is(err, remnawave.ErrUnavailable) {
+		info.WriteString(h.translation.Format(lang, "balance_info", translation.Args{"balance": int(customer.Balance)}))
+		info.WriteString("\n\n" + h.translation.GetText(lang, "panel_unavailable"))
+		return info.String()
+	}
 	if user != nil {
 		if user.ExpireAt.After(time.Now()) {
 			info.WriteString(h.translation.GetText(lang, "subscription_active_hint"))
@@ -208,18 +237,33 @@ func (h *Handler) buildAccountInfo(ctx context.Context, customer *domaincustomer
 			limit = float64(v)
 		}
 		info.WriteString(h.translation.GetText(lang, "account_info_header"))
-		info.WriteString(fmt.Sprintf(h.translation.GetText(lang, "account_info_balance"), customer.Balance))
-		info.WriteString(fmt.Sprintf(h.translation.GetText(lang, "account_info_expire"), expire))
-		info.WriteString(fmt.Sprintf(h.translation.GetText(lang, "account_info_status"), status))
-		info.WriteString(fmt.Sprintf(h.translation.GetText(lang, "account_info_last_client"), lastClient))
+		info.WriteString(h.translation.Format(lang, "account_info_balance", translation.Args{"balance": fmt.Sprintf("%.0f", customer.Balance)}))
+		info.WriteString(h.translation.Format(lang, "account_info_expire", translation.Args{"expire": expire}))
+		info.WriteString(h.translation.Format(lang, "account_info_status", translation.Args{"status": status}))
+		info.WriteString(h.translation.Format(lang, "account_info_last_client", translation.Args{"client": lastClient}))
 		info.WriteString(h.translation.GetText(lang, "traffic_info_header"))
-		info.WriteString(fmt.Sprintf(h.translation.GetText(lang, "traffic_limit"), utils.FormatGB(usage), utils.FormatGB(limit)))
-		info.WriteString(fmt.Sprintf(h.translation.GetText(lang, "traffic_total_used"), utils.FormatGB(user.LifetimeUsedTrafficBytes)))
+		info.WriteString(h.translation.Format(lang, "traffic_limit", translation.Args{"used": utils.FormatGB(usage), "limit": utils.FormatGB(limit)}))
+		info.WriteString(h.translation.Format(lang, "traffic_total_used", translation.Args{"used": utils.FormatGB(user.LifetimeUsedTrafficBytes)}))
 		untilReset := time.Until(start.Add(24 * time.Hour))
-		info.WriteString(fmt.Sprintf(h.translation.GetText(lang, "traffic_time_to_reset"), untilReset.Truncate(time.Second)))
+		info.WriteString(h.translation.Format(lang, "traffic_time_to_reset", translation.Args{"duration": untilReset.Truncate(time.Second)}))
 
 	} else {
-		info.WriteString(fmt.Sprintf(h.translation.GetText(lang, "balance_info"), int(customer.Balance)))
+		info.WriteString(h.translation.Format(lang, "balance_info", translation.Args{"balance": int(customer.Balance)}))
+	}
+
+	if subs := h.subscriptions(ctx, customer); len(subs) > 1 {
+		info.WriteString(h.translation.GetText(lang, "account_info_subscriptions"))
+		for i := range subs {
+			sub := &subs[i]
+			if !active(sub) {
+				info.WriteString(h.translation.Format(lang, "account_info_subscription_inactive", translation.Args{"number": i + 1}))
+				continue
+			}
+			info.WriteString(h.translation.Format(lang, "account_info_subscription", translation.Args{
+				"number": i + 1,
+				"expire": sub.ExpireAt.Format("02.01.2006 15:04"),
+			}))
+		}
 	}
 	return info.String()
 }
@@ -228,15 +272,15 @@ func (h *Handler) MenuCommandHandler(ctx context.Context, b *bot.Bot, update *mo
 	ctxWithTime, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	lang := update.Message.From.LanguageCode
+	lang := userLanguage(ctx, update)
 	customer, err := h.findOrCreateCustomer(ctxWithTime, update.Message.Chat.ID, lang)
 	if err != nil {
 		slog.Error("find or create customer", "err", err)
 		return
 	}
 
-	kb := h.buildStartKeyboard(customer, lang)
-	text := fmt.Sprintf(h.translation.GetText(lang, "account_menu_text"), update.Message.From.FirstName) + "\n\n" + h.buildAccountInfo(ctxWithTime, customer, lang)
+	kb := h.buildStartKeyboard(ctxWithTime, customer, lang)
+	text := h.translation.Format(lang, "account_menu_text", translation.Args{"name": update.Message.From.FirstName}) + "\n\n" + h.buildAccountInfo(ctxWithTime, customer, lang)
 
 	m, err := b.SendMessage(ctxWithTime, &bot.SendMessageParams{
 		ChatID:      update.Message.Chat.ID,
@@ -263,7 +307,7 @@ func (h *Handler) HelpCommandHandler(ctx context.Context, b *bot.Bot, update *mo
 }
 
 func (h *Handler) PromoCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	lang := update.Message.From.LanguageCode
+	lang := userLanguage(ctx, update)
 	customer, err := h.findOrCreateCustomer(ctx, update.Message.Chat.ID, lang)
 	if err != nil {
 		slog.Error("find or create customer", "err", err)
@@ -274,14 +318,8 @@ func (h *Handler) PromoCommandHandler(ctx context.Context, b *bot.Bot, update *m
 	if len(parts) > 1 {
 		code := parts[1]
 		if err := h.paymentService.ApplyPromocode(ctx, customer, code); err != nil {
-			_, _ = b.SendMessage(ctx, &bot.SendMessageParams{ChatID: update.Message.Chat.ID, Text: h.translation.GetText(lang, "promo_invalid")})
-			return
-		}
-		until := ""
-		if customer.ExpireAt != nil {
-			until = customer.ExpireAt.Format("02.01.2006 15:04")
+			_, _ = b.SendMessage(ctx, &bot.SendMessageParams{ChatID: update.Message.Chat.ID, Text: h.promoErrorText(lang, err)})
 		}
-		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{ChatID: update.Message.Chat.ID, Text: fmt.Sprintf(h.translation.GetText(lang, "promo_applied"), until)})
 		return
 	}
 
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	"remnawave-tg-shop-bot/internal/service/stats"
+)
+
+// StatsCommandHandler shows today's statistics with buttons switching the period.
+func (h *Handler) StatsCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if !config.IsAdmin(update.Message.From.ID) {
+		return
+	}
+	lang := userLanguage(ctx, update)
+	text, kb, err := h.statsView(ctx, lang, stats.PeriodDay)
+	if err != nil {
+		slog.Error("build stats report", "err", err)
+		return
+	}
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      update.Message.Chat.ID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        text,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: kb},
+	})
+	if err != nil {
+		slog.Error("Error sending stats", "err", err)
+	}
+}
+
+func (h *Handler) StatsCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if !config.IsAdmin(update.CallbackQuery.From.ID) {
+		return
+	}
+	lang := userLanguage(ctx, update)
+	period, ok := stats.ParsePeriod(strings.TrimPrefix(update.CallbackQuery.Data, CallbackStats+":"))
+	if !ok {
+		period = stats.PeriodDay
+	}
+	text, kb, err := h.statsView(ctx, lang, period)
+	if err != nil {
+		slog.Error("build stats report", "period", period, "err", err)
+		return
+	}
+
+	chatID, msgID, ok := callbackChatMessage(update)
+	if !ok {
+		slog.Error("callback message missing")
+		return
+	}
+	_, err = SafeEditMessageText(ctx, b, update.CallbackQuery.Message.Message, &bot.EditMessageTextParams{
+		ChatID:      chatID,
+		MessageID:   msgID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        text,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: kb},
+	})
+	if err != nil {
+		slog.Error("Error editing stats", "err", err)
+	}
+}
+
+func (h *Handler) statsView(ctx context.Context, lang string, period stats.Period) (string, [][]models.InlineKeyboardButton, error) {
+	report, err := h.statsService.Report(ctx, period)
+	if err != nil {
+		return "", nil, err
+	}
+	periodName := h.statsPeriodName(lang, period)
+	from, to := report.From.Format("02.01.2006 15:04"), report.To.Format("02.01.2006 15:04")
+	title := h.translation.Format(lang, "stats_title", translation.Args{"period": periodName, "from": from, "to": to})
+	text := title + "\n\n" + h.statsService.Format(lang, report)
+
+	var row []models.InlineKeyboardButton
+	for _, p := range stats.Periods {
+		label := h.statsPeriodName(lang, p)
+		if p == period {
+			label = "• " + label
+		}
+		row = append(row, models.InlineKeyboardButton{Text: label, CallbackData: fmt.Sprintf("%s:%s", CallbackStats, p)})
+	}
+	return text, [][]models.InlineKeyboardButton{row}, nil
+}
+
+func (h *Handler) statsPeriodName(lang string, period stats.Period) string {
+	switch period {
+	case stats.PeriodWeek:
+		return h.translation.GetText(lang, "stats_period_week")
+	case stats.PeriodMonth:
+		return h.translation.GetText(lang, "stats_period_month")
+	case stats.PeriodAll:
+		return h.translation.GetText(lang, "stats_period_all")
+	default:
+		return h.translation.GetText(lang, "stats_period_day")
+	}
+}
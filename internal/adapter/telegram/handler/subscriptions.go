@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+
+	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
+	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	pg "remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/internal/service/provisioning"
+)
+
+// subscriptionNew is the sub callback parameter of a new subscription.
+const subscriptionNew = "new"
+
+// subscriptionTarget parses the sub callback parameter of the purchase flow.
+// A missing parameter or 0 is the primary subscription.
+func subscriptionTarget(value string) (provisioning.Target, error) {
+	switch value {
+	case "":
+		return provisioning.Target{}, nil
+	case subscriptionNew:
+		return provisioning.Target{New: true}, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return provisioning.Target{}, fmt.Errorf("invalid subscription %q", value)
+	}
+	return provisioning.Target{SubscriptionID: id}, nil
+}
+
+// subscriptionQuery passes the picked subscription on to the next callback.
+func subscriptionQuery(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "&sub=" + value
+}
+
+// subscriptions lists the subscriptions of the customer for the menus, nil
+// unless customers may have more than one.
+func (h *Handler) subscriptions(ctx context.Context, customer *domaincustomer.Customer) []pg.Subscription {
+	if config.MaxSubscriptionsPerCustomer() < 2 {
+		return nil
+	}
+	subs, err := h.paymentService.Subscriptions(ctx, customer)
+	if err != nil {
+		slog.Error("list subscriptions", "err", err)
+		return nil
+	}
+	return subs
+}
+
+func active(sub *pg.Subscription) bool {
+	return sub.ExpireAt != nil && sub.ExpireAt.After(time.Now())
+}
+
+func (h *Handler) subscriptionButtonText(lang string, number int, sub *pg.Subscription) string {
+	if !active(sub) {
+		return h.translation.Format(lang, "subscription_button_inactive", translation.Args{"number": number})
+	}
+	return h.translation.Format(lang, "subscription_button", translation.Args{
+		"number": number,
+		"expire": sub.ExpireAt.Format("02.01.2006"),
+	})
+}
+
+// subscriptionPicker returns the keyboard choosing the subscription to renew,
+// or nil when there is nothing to choose: the customer has one subscription
+// and can't buy another one.
+func (h *Handler) subscriptionPicker(lang string, subs []pg.Subscription) [][]models.InlineKeyboardButton {
+	canAdd := len(subs) > 0 && len(subs) < config.MaxSubscriptionsPerCustomer()
+	if len(subs) < 2 && !canAdd {
+		return nil
+	}
+	var kb [][]models.InlineKeyboardButton
+	for i := range subs {
+		kb = append(kb, []models.InlineKeyboardButton{{
+			Text:         h.subscriptionButtonText(lang, i+1, &subs[i]),
+			CallbackData: fmt.Sprintf("%s?sub=%d", CallbackBuy, subs[i].ID),
+		}})
+	}
+	if canAdd {
+		kb = append(kb, []models.InlineKeyboardButton{{
+			Text:         h.translation.GetText(lang, "new_subscription_button"),
+			CallbackData: fmt.Sprintf("%s?sub=%s", CallbackBuy, subscriptionNew),
+		}})
+	}
+	return append(kb, []models.InlineKeyboardButton{
+		{Text: h.translation.GetText(lang, "back_to_account_button"), CallbackData: CallbackStart},
+	})
+}
@@ -0,0 +1,474 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/internal/service/support"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// supportClose is the CallbackSupport argument closing the ticket: "support:close".
+const supportClose = "close"
+
+// maxTopicName is the forum topic name limit of Telegram.
+const maxTopicName = 128
+
+// SupportCallbackHandler opens the ticket of the user for "support" and closes
+// it for "support:close". While the ticket is open the messages of the user
+// are relayed to their topic in the support group.
+func (h *Handler) SupportCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	lang := userLanguage(ctx, update)
+	user := update.CallbackQuery.From
+	back := []models.InlineKeyboardButton{{Text: h.translation.GetText(lang, "back_button"), CallbackData: CallbackStart}}
+
+	var (
+		text string
+		kb   [][]models.InlineKeyboardButton
+	)
+	if update.CallbackQuery.Data == CallbackSupport+":"+supportClose {
+		if err := h.closeTicketByUser(ctx, b, user.ID); err != nil {
+			slog.Error("close support ticket", "err", err)
+		}
+		text = h.translation.GetText(lang, "support_closed_text")
+		kb = [][]models.InlineKeyboardButton{back}
+	} else if _, err := h.openTicket(ctx, b, user); err != nil {
+		slog.Error("open support ticket", "err", err)
+		text = h.translation.GetText(lang, "support_error")
+		kb = [][]models.InlineKeyboardButton{back}
+	} else {
+		text = h.translation.GetText(lang, "support_open_text")
+		kb = [][]models.InlineKeyboardButton{
+			{{Text: h.translation.GetText(lang, "support_close_button"), CallbackData: CallbackSupport + ":" + supportClose}},
+			back,
+		}
+	}
+
+	chatID, msgID, ok := callbackChatMessage(update)
+	if !ok {
+		slog.Error("callback message missing")
+		return
+	}
+	_, err := SafeEditMessageText(ctx, b, update.CallbackQuery.Message.Message, &bot.EditMessageTextParams{
+		ChatID:      chatID,
+		MessageID:   msgID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        text,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: kb},
+	})
+	if err != nil {
+		slog.Error("Error editing support menu", "err", err)
+	}
+}
+
+// SupportMessageHandler relays a private message of a user with an open
+// ticket to their topic. Messages of other users are ignored.
+func (h *Handler) SupportMessageHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	msg := update.Message
+	t, err := h.supportService.Ticket(ctx, msg.From.ID)
+	if err != nil {
+		slog.Error("find support ticket", "err", err)
+		return
+	}
+	if t == nil || t.Status != pg.SupportTicketOpen {
+		return
+	}
+	lang := userLanguage(ctx, update)
+	if !isSupportContent(msg) {
+		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{ChatID: msg.Chat.ID, Text: h.translation.GetText(lang, "support_unsupported")})
+		return
+	}
+
+	err = h.inTopic(ctx, b, t, *msg.From, func(threadID int) error {
+		_, err := b.CopyMessage(ctx, &bot.CopyMessageParams{
+			ChatID:          config.SupportChatID(),
+			MessageThreadID: threadID,
+			FromChatID:      msg.Chat.ID,
+			MessageID:       msg.ID,
+		})
+		return err
+	})
+	if err != nil {
+		slog.Error("relay message to support", "customer", utils.MaskHalfInt64(msg.From.ID), "err", err)
+		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{ChatID: msg.Chat.ID, Text: h.translation.GetText(lang, "support_error")})
+	}
+}
+
+// SupportGroupMessageHandler handles the messages of the support group: the
+// commands /close, /r <name>, /canned, /canned_add <name> <text> and
+// /canned_delete <name> (admins only), and the replies in ticket topics, which are relayed
+// to the customer.
+func (h *Handler) SupportGroupMessageHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	msg := update.Message
+	if msg.From == nil || msg.From.IsBot {
+		return
+	}
+	command, args, isCommand := parseGroupCommand(msg.Text)
+	if isCommand && h.cannedCommand(ctx, b, msg.From.ID, msg.MessageThreadID, command, args) {
+		return
+	}
+	if msg.MessageThreadID == 0 {
+		return
+	}
+	t, err := h.supportService.TicketByThread(ctx, msg.MessageThreadID)
+	if err != nil {
+		slog.Error("find support ticket by topic", "err", err)
+		return
+	}
+	if t == nil {
+		return
+	}
+
+	switch {
+	case isCommand && command == "close":
+		h.closeTicketByAdmin(ctx, b, t)
+	case isCommand && command == "r":
+		h.sendCannedResponse(ctx, b, t, args)
+	case isCommand:
+		// commands of other bots or typos are not sent to the customer
+	case isSupportContent(msg):
+		h.reopenByAdmin(ctx, b, t)
+		_, err := b.CopyMessage(ctx, &bot.CopyMessageParams{ChatID: t.CustomerID, FromChatID: msg.Chat.ID, MessageID: msg.ID})
+		if err != nil {
+			slog.Error("relay support reply", "customer", utils.MaskHalfInt64(t.CustomerID), "err", err)
+			h.topicNotice(ctx, b, t.ThreadID, h.translation.GetText(config.SupportLanguage(), "support_delivery_failed"))
+		}
+	}
+}
+
+// openTicket returns the open ticket of the user. The first ticket creates the
+// topic, a closed one is reopened; both post the customer header.
+func (h *Handler) openTicket(ctx context.Context, b *bot.Bot, user models.User) (*pg.SupportTicket, error) {
+	t, err := h.supportService.Ticket(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		threadID, err := h.createSupportTopic(ctx, b, user)
+		if err != nil {
+			return nil, err
+		}
+		if t, err = h.supportService.Create(ctx, user.ID, threadID); err != nil {
+			return nil, err
+		}
+		h.postSupportHeader(ctx, b, t, user)
+		return t, nil
+	}
+
+	reopened, err := h.supportService.Reopen(ctx, t)
+	if err != nil || !reopened {
+		return t, err
+	}
+	err = h.inTopic(ctx, b, t, user, func(threadID int) error {
+		_, err := b.ReopenForumTopic(ctx, &bot.ReopenForumTopicParams{ChatID: config.SupportChatID(), MessageThreadID: threadID})
+		if err != nil && strings.Contains(err.Error(), "TOPIC_NOT_MODIFIED") {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		slog.Warn("reopen support topic", "err", err)
+	}
+	h.postSupportHeader(ctx, b, t, user)
+	return t, nil
+}
+
+func (h *Handler) closeTicketByUser(ctx context.Context, b *bot.Bot, telegramID int64) error {
+	t, err := h.supportService.Ticket(ctx, telegramID)
+	if err != nil || t == nil {
+		return err
+	}
+	closed, err := h.supportService.Close(ctx, t)
+	if err != nil || !closed {
+		return err
+	}
+	h.topicNotice(ctx, b, t.ThreadID, h.translation.GetText(config.SupportLanguage(), "support_closed_by_user"))
+	h.closeTopic(ctx, b, t.ThreadID)
+	return nil
+}
+
+func (h *Handler) closeTicketByAdmin(ctx context.Context, b *bot.Bot, t *pg.SupportTicket) {
+	closed, err := h.supportService.Close(ctx, t)
+	if err != nil {
+		slog.Error("close support ticket", "err", err)
+		return
+	}
+	if !closed {
+		return
+	}
+	lang := h.customerLanguage(ctx, t.CustomerID)
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: t.CustomerID,
+		Text:   h.translation.GetText(lang, "support_closed_by_admin"),
+	})
+	if err != nil {
+		slog.Error("Error sending support ticket closed", "err", err)
+	}
+	h.topicNotice(ctx, b, t.ThreadID, h.translation.GetText(config.SupportLanguage(), "support_ticket_closed"))
+	h.closeTopic(ctx, b, t.ThreadID)
+}
+
+// reopenByAdmin reopens a closed ticket an admin answers in, so the reply of
+// the customer reaches the topic again.
+func (h *Handler) reopenByAdmin(ctx context.Context, b *bot.Bot, t *pg.SupportTicket) {
+	reopened, err := h.supportService.Reopen(ctx, t)
+	if err != nil {
+		slog.Error("reopen support ticket", "err", err)
+		return
+	}
+	if reopened {
+		_, err := b.ReopenForumTopic(ctx, &bot.ReopenForumTopicParams{ChatID: config.SupportChatID(), MessageThreadID: t.ThreadID})
+		if err != nil {
+			slog.Warn("reopen support topic", "err", err)
+		}
+	}
+}
+
+func (h *Handler) sendCannedResponse(ctx context.Context, b *bot.Bot, t *pg.SupportTicket, name string) {
+	sl := config.SupportLanguage()
+	canned, err := h.supportService.CannedResponse(ctx, name)
+	if err != nil {
+		h.topicNotice(ctx, b, t.ThreadID, h.cannedErrorText(sl, err))
+		return
+	}
+	h.reopenByAdmin(ctx, b, t)
+	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{ChatID: t.CustomerID, Text: canned.Text}); err != nil {
+		slog.Error("send canned response", "customer", utils.MaskHalfInt64(t.CustomerID), "err", err)
+		h.topicNotice(ctx, b, t.ThreadID, h.translation.GetText(sl, "support_delivery_failed"))
+		return
+	}
+	h.topicNotice(ctx, b, t.ThreadID, h.translation.Format(sl, "support_canned_sent", translation.Args{"name": canned.Name, "text": canned.Text}))
+}
+
+// cannedCommand manages the canned responses, only admins may change them. It
+// reports false for other commands.
+func (h *Handler) cannedCommand(ctx context.Context, b *bot.Bot, fromID int64, threadID int, command, args string) bool {
+	sl := config.SupportLanguage()
+	if (command == "canned_add" || command == "canned_delete") && !config.IsAdmin(fromID) {
+		return true
+	}
+	var text string
+	switch command {
+	case "canned":
+		responses, err := h.supportService.CannedResponses(ctx)
+		if err != nil {
+			slog.Error("list canned responses", "err", err)
+			return true
+		}
+		text = h.translation.GetText(sl, "support_canned_empty")
+		if len(responses) > 0 {
+			var sb strings.Builder
+			sb.WriteString(h.translation.GetText(sl, "support_canned_list"))
+			for _, c := range responses {
+				sb.WriteString("\n" + h.translation.Format(sl, "support_canned_item", translation.Args{"name": c.Name, "text": c.Text}))
+			}
+			text = sb.String()
+		}
+	case "canned_add":
+		name, cannedText, err := support.ParseCanned(args)
+		if err == nil {
+			err = h.supportService.SaveCannedResponse(ctx, name, cannedText)
+		}
+		text = h.translation.Format(sl, "support_canned_saved", translation.Args{"name": name})
+		if err != nil {
+			text = h.cannedErrorText(sl, err)
+		}
+	case "canned_delete":
+		text = h.translation.GetText(sl, "support_canned_deleted")
+		if err := h.supportService.DeleteCannedResponse(ctx, args); err != nil {
+			text = h.cannedErrorText(sl, err)
+		}
+	default:
+		return false
+	}
+	h.topicNotice(ctx, b, threadID, text)
+	return true
+}
+
+func (h *Handler) cannedErrorText(lang string, err error) string {
+	switch {
+	case errors.Is(err, support.ErrFormat):
+		return h.translation.GetText(lang, "support_canned_usage")
+	case errors.Is(err, support.ErrNotFound):
+		return h.translation.GetText(lang, "support_canned_not_found")
+	default:
+		slog.Error("canned response", "err", err)
+		return h.translation.GetText(lang, "support_error")
+	}
+}
+
+// inTopic runs send in the topic of the ticket. A topic deleted by admins is
+// created again and the ticket moved to it.
+func (h *Handler) inTopic(ctx context.Context, b *bot.Bot, t *pg.SupportTicket, user models.User, send func(threadID int) error) error {
+	err := send(t.ThreadID)
+	if err == nil || !isTopicMissing(err) {
+		return err
+	}
+	threadID, err := h.createSupportTopic(ctx, b, user)
+	if err != nil {
+		return err
+	}
+	if err := h.supportService.MoveThread(ctx, t, threadID); err != nil {
+		return err
+	}
+	h.postSupportHeader(ctx, b, t, user)
+	return send(threadID)
+}
+
+func isTopicMissing(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "thread not found") || strings.Contains(msg, "TOPIC_DELETED") || strings.Contains(msg, "TOPIC_ID_INVALID")
+}
+
+func (h *Handler) createSupportTopic(ctx context.Context, b *bot.Bot, user models.User) (int, error) {
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if user.Username != "" {
+		name += " @" + user.Username
+	}
+	name = fmt.Sprintf("%s #%d", strings.TrimSpace(name), user.ID)
+	if r := []rune(name); len(r) > maxTopicName {
+		name = string(r[:maxTopicName])
+	}
+	topic, err := b.CreateForumTopic(ctx, &bot.CreateForumTopicParams{ChatID: config.SupportChatID(), Name: name})
+	if err != nil {
+		return 0, fmt.Errorf("create support topic: %w", err)
+	}
+	return topic.MessageThreadID, nil
+}
+
+// postSupportHeader posts and pins the customer context in the topic.
+func (h *Handler) postSupportHeader(ctx context.Context, b *bot.Bot, t *pg.SupportTicket, user models.User) {
+	summary, err := h.supportService.Summary(ctx, t.CustomerID)
+	if err != nil {
+		slog.Error("load support summary", "err", err)
+	}
+	msg, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:          config.SupportChatID(),
+		MessageThreadID: t.ThreadID,
+		ParseMode:       models.ParseModeHTML,
+		Text:            h.supportHeader(user, summary),
+	})
+	if err != nil {
+		slog.Error("Error sending support header", "err", err)
+		return
+	}
+	_, err = b.PinChatMessage(ctx, &bot.PinChatMessageParams{ChatID: config.SupportChatID(), MessageID: msg.ID, DisableNotification: true})
+	if err != nil {
+		slog.Warn("pin support header", "err", err)
+	}
+}
+
+func (h *Handler) supportHeader(user models.User, summary *support.Summary) string {
+	sl := config.SupportLanguage()
+	args := translation.Args{
+		"id":        user.ID,
+		"name":      strings.TrimSpace(user.FirstName + " " + user.LastName),
+		"username":  "—",
+		"language":  "—",
+		"balance":   0,
+		"expire":    "—",
+		"purchases": h.translation.GetText(sl, "support_header_no_purchases"),
+	}
+	if user.Username != "" {
+		args["username"] = "@" + user.Username
+	}
+	if summary != nil {
+		c := summary.Customer
+		args["language"] = c.Language
+		args["balance"] = int(c.Balance)
+		if c.ExpireAt != nil {
+			args["expire"] = c.ExpireAt.Format("02.01.2006 15:04")
+		}
+		if len(summary.Purchases) > 0 {
+			lines := make([]string, 0, len(summary.Purchases))
+			for _, p := range summary.Purchases {
+				paidAt := p.CreatedAt
+				if p.PaidAt != nil {
+					paidAt = *p.PaidAt
+				}
+				date := paidAt.Format("02.01.2006")
+				lines = append(lines, h.translation.Format(sl, "support_header_purchase", translation.Args{
+					"date":     date,
+					"amount":   strconv.FormatFloat(p.Amount, 'f', -1, 64),
+					"currency": p.Currency,
+					"months":   p.Month,
+				}))
+			}
+			args["purchases"] = translation.Raw(strings.Join(lines, "\n"))
+		}
+	}
+	return h.translation.Format(sl, "support_header", args)
+}
+
+func (h *Handler) topicNotice(ctx context.Context, b *bot.Bot, threadID int, text string) {
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:          config.SupportChatID(),
+		MessageThreadID: threadID,
+		ParseMode:       models.ParseModeHTML,
+		Text:            text,
+	})
+	if err != nil {
+		slog.Error("Error sending support notice", "err", err)
+	}
+}
+
+func (h *Handler) closeTopic(ctx context.Context, b *bot.Bot, threadID int) {
+	_, err := b.CloseForumTopic(ctx, &bot.CloseForumTopicParams{ChatID: config.SupportChatID(), MessageThreadID: threadID})
+	if err != nil {
+		slog.Warn("close support topic", "err", err)
+	}
+}
+
+func (h *Handler) customerLanguage(ctx context.Context, telegramID int64) string {
+	customer, err := h.customerRepository.FindByTelegramId(ctx, telegramID)
+	if err != nil || customer == nil {
+		return ""
+	}
+	return customer.Language
+}
+
+// isSupportContent reports whether the message is a kind relayed between the
+// customer and support: text, photo or document.
+func isSupportContent(msg *models.Message) bool {
+	return msg.Text != "" || len(msg.Photo) > 0 || msg.Document != nil
+}
+
+// parseGroupCommand splits "/command@bot args" into the command and its arguments.
+func parseGroupCommand(text string) (string, string, bool) {
+	if !strings.HasPrefix(text, "/") {
+		return "", "", false
+	}
+	command, args := splitFirstWord(text[1:])
+	command, _, _ = strings.Cut(command, "@")
+	return command, args, true
+}
+
+// IsSupportGroupMessage reports whether the update is a message of the support group.
+func IsSupportGroupMessage(upd *models.Update) bool {
+	return upd.Message != nil && config.SupportChatID() != 0 && upd.Message.Chat.ID == config.SupportChatID()
+}
+
+// IsSupportMessage reports whether the update is a private message that may
+// belong to a support ticket.
+func IsSupportMessage(upd *models.Update) bool {
+	return upd.Message != nil && config.SupportChatID() != 0 && upd.Message.From != nil &&
+		upd.Message.Chat.Type == models.ChatTypePrivate && !strings.HasPrefix(upd.Message.Text, "/")
+}
+
+// splitFirstWord splits s at the first space or line break.
+func splitFirstWord(s string) (string, string) {
+	if i := strings.IndexAny(s, " \n"); i >= 0 {
+		return s[:i], strings.TrimSpace(s[i+1:])
+	}
+	return s, ""
+}
@@ -2,19 +2,77 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
-	"log/slog"
+
+	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	syncsvc "remnawave-tg-shop-bot/internal/service/sync"
 )
 
+// SyncUsersCommandHandler syncs the customers with the panel users and replies
+// with the report: /sync [dryrun]
 func (h *Handler) SyncUsersCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	h.syncService.Sync()
-	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: update.Message.Chat.ID,
-		Text:   "Users synced",
+	if !config.IsAdmin(update.Message.From.ID) {
+		return
+	}
+	lang := userLanguage(ctx, update)
+	_, args, _ := strings.Cut(update.Message.Text, " ")
+	dryRun := strings.EqualFold(strings.TrimSpace(args), "dryrun")
+
+	report, err := h.syncService.Sync(ctx, dryRun)
+	var text string
+	switch {
+	case err == nil:
+		text = h.syncReportText(lang, report)
+	case errors.Is(err, syncsvc.ErrRunning):
+		text = h.translation.GetText(lang, "sync_running")
+	default:
+		slog.Error("sync users", "err", err)
+		text = h.translation.GetText(lang, "sync_error")
+	}
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    update.Message.Chat.ID,
+		ParseMode: models.ParseModeHTML,
+		Text:      text,
 	})
 	if err != nil {
 		slog.Error("Error sending sync message", "err", err)
 	}
 }
+
+func (h *Handler) syncReportText(lang string, report *syncsvc.Report) string {
+	text := h.translation.Format(lang, "sync_report", translation.Args{
+		"created":         report.Created.Count,
+		"created_sample":  syncSample(report.Created),
+		"updated":         report.Updated.Count,
+		"updated_sample":  syncSample(report.Updated),
+		"orphaned":        report.Orphaned.Count,
+		"orphaned_sample": syncSample(report.Orphaned),
+	})
+	if report.DryRun {
+		text += "\n\n" + h.translation.GetText(lang, "sync_dry_run")
+	}
+	return text
+}
+
+// syncSample lists the sample telegram ids of the diff, " (1, 2, …)".
+func syncSample(d syncsvc.Diff) string {
+	if len(d.Sample) == 0 {
+		return ""
+	}
+	ids := make([]string, len(d.Sample))
+	for i, id := range d.Sample {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	sample := strings.Join(ids, ", ")
+	if d.Count > len(d.Sample) {
+		sample += ", …"
+	}
+	return " (" + sample + ")"
+}
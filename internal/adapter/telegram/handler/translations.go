@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"html"
+	"log/slog"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+)
+
+// maxReportedIssues caps the validation issues listed in a reload report.
+const maxReportedIssues = 20
+
+// ReloadTranslationsCommandHandler reloads the translation overrides: /reload_translations
+func (h *Handler) ReloadTranslationsCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if !config.IsAdmin(update.Message.From.ID) {
+		return
+	}
+	h.ReloadTranslations(ctx, b)
+}
+
+// ReloadTranslations re-reads the embedded translations and the overrides
+// directory and sends the validation report to every admin. The bot also
+// calls it on SIGHUP.
+func (h *Handler) ReloadTranslations(ctx context.Context, b *bot.Bot) {
+	var issues []translation.Issue
+	err := h.translation.Reload()
+	if err != nil {
+		slog.Error("reload translations", "err", err)
+	} else {
+		issues = h.translation.Validate()
+		slog.Info("translations reloaded", "issues", len(issues))
+	}
+
+	for _, adminID := range config.GetAdminTelegramIds() {
+		lang := ""
+		if admin, err := h.customerRepository.FindByTelegramId(ctx, adminID); err == nil && admin != nil {
+			lang = admin.Language
+		}
+		_, sendErr := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    adminID,
+			ParseMode: models.ParseModeHTML,
+			Text:      h.reloadReport(lang, issues, err),
+		})
+		if sendErr != nil {
+			slog.Error("Error sending translations reload report", "err", sendErr)
+		}
+	}
+}
+
+func (h *Handler) reloadReport(lang string, issues []translation.Issue, err error) string {
+	if err != nil {
+		return h.translation.Format(lang, "translations_reload_failed", translation.Args{"err": err.Error()})
+	}
+	var sb strings.Builder
+	sb.WriteString(h.translation.Format(lang, "translations_reloaded", translation.Args{"count": len(issues)}))
+	for i, issue := range issues {
+		if i == maxReportedIssues {
+			sb.WriteString("\n" + h.translation.Format(lang, "translations_more_issues", translation.Args{"count": len(issues) - i}))
+			break
+		}
+		sb.WriteString("\n• " + html.EscapeString(issue.String()))
+	}
+	return sb.String()
+}
@@ -2,22 +2,23 @@ package handler
 
 import (
 	"context"
+	"errors"
 
 	"log/slog"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 
+	"remnawave-tg-shop-bot/internal/adapter/remnawave"
 	"remnawave-tg-shop-bot/internal/pkg/config"
 	"remnawave-tg-shop-bot/internal/pkg/contextkey"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	"remnawave-tg-shop-bot/internal/service/trial"
 	"remnawave-tg-shop-bot/internal/ui"
 	"remnawave-tg-shop-bot/utils"
 )
 
 func (h *Handler) TrialCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	if config.TrialDays() == 0 {
-		return
-	}
 	c, err := h.customerRepository.FindByTelegramId(ctx, update.CallbackQuery.From.ID)
 	if err != nil {
 		slog.Error("Error finding customer", "err", err)
@@ -27,15 +28,18 @@ func (h *Handler) TrialCallbackHandler(ctx context.Context, b *bot.Bot, update *
 		slog.Error("customer not exist", "telegramId", utils.MaskHalfInt64(update.CallbackQuery.From.ID), "error", err)
 		return
 	}
-	if c.SubscriptionLink != nil {
-		return
-	}
 	chatID, msgID, ok := callbackChatMessage(update)
 	if !ok {
 		slog.Error("callback message missing")
 		return
 	}
-	langCode := update.CallbackQuery.From.LanguageCode
+	langCode := userLanguage(ctx, update)
+
+	if err := h.trialService.Check(ctx, c); err != nil {
+		h.sendTrialUnavailable(ctx, b, chatID, msgID, langCode, err)
+		return
+	}
+
 	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
 		ChatID:    chatID,
 		MessageID: msgID,
@@ -52,9 +56,6 @@ func (h *Handler) TrialCallbackHandler(ctx context.Context, b *bot.Bot, update *
 }
 
 func (h *Handler) ActivateTrialCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	if config.TrialDays() == 0 {
-		return
-	}
 	c, err := h.customerRepository.FindByTelegramId(ctx, update.CallbackQuery.From.ID)
 	if err != nil {
 		slog.Error("Error finding customer", "err", err)
@@ -64,21 +65,19 @@ func (h *Handler) ActivateTrialCallbackHandler(ctx context.Context, b *bot.Bot,
 		slog.Error("customer not exist", "telegramId", utils.MaskHalfInt64(update.CallbackQuery.From.ID), "error", err)
 		return
 	}
-	if c.SubscriptionLink != nil {
-		return
-	}
 	chatID, msgID, ok := callbackChatMessage(update)
 	if !ok {
 		slog.Error("callback message missing")
 		return
 	}
+	langCode := userLanguage(ctx, update)
+
 	ctxWithUsername := context.WithValue(ctx, contextkey.Username, contextkey.CleanUsername(update.CallbackQuery.From.Username))
-	_, err = h.paymentService.ActivateTrial(ctxWithUsername, update.CallbackQuery.From.ID)
-	if err != nil {
-		slog.Error("Error activate trial", "err", err)
+	if _, err = h.trialService.Activate(ctxWithUsername, c); err != nil {
+		h.sendTrialUnavailable(ctx, b, chatID, msgID, langCode, err)
+		return
 	}
 
-	langCode := update.CallbackQuery.From.LanguageCode
 	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
 		ChatID:      chatID,
 		MessageID:   msgID,
@@ -90,3 +89,46 @@ func (h *Handler) ActivateTrialCallbackHandler(ctx context.Context, b *bot.Bot,
 		slog.Error("Error sending /trial message", "err", err)
 	}
 }
+
+// sendTrialUnavailable explains why the trial can't be activated.
+func (h *Handler) sendTrialUnavailable(ctx context.Context, b *bot.Bot, chatID int64, msgID int, langCode string, reason error) {
+	var text string
+	kb := [][]models.InlineKeyboardButton{}
+	switch {
+	case errors.Is(reason, trial.ErrDisabled):
+		return
+	case errors.Is(reason, trial.ErrUsed):
+		text = h.translation.GetText(langCode, "trial_unavailable_used")
+	case errors.Is(reason, trial.ErrSubscribed):
+		text = h.translation.GetText(langCode, "trial_unavailable_subscribed")
+	case errors.Is(reason, trial.ErrAccountTooNew):
+		hours := int(h.trialService.Rules().MinAccountAge.Hours())
+		text = h.translation.Format(langCode, "trial_unavailable_account_age", translation.Args{"hours": hours})
+	case errors.Is(reason, trial.ErrPanelUserExists):
+		text = h.translation.GetText(langCode, "trial_unavailable_panel_user")
+	case errors.Is(reason, remnawave.ErrUnavailable):
+		slog.Error("Error activate trial", "err", reason)
+		text = h.translation.GetText(langCode, "panel_unavailable")
+	case errors.Is(reason, trial.ErrChannelRequired):
+		text = h.translation.GetText(langCode, "trial_unavailable_channel")
+		if config.ChannelURL() != "" {
+			kb = append(kb, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "channel_button"), URL: config.ChannelURL()}})
+		}
+		kb = append(kb, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "trial_check_again_button"), CallbackData: CallbackTrial}})
+	default:
+		slog.Error("Error activate trial", "err", reason)
+		text = h.translation.GetText(langCode, "trial_unavailable_error")
+	}
+	kb = append(kb, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "back_to_account_button"), CallbackData: CallbackStart}})
+
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      chatID,
+		MessageID:   msgID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: kb},
+	})
+	if err != nil {
+		slog.Error("Error sending trial unavailable message", "err", err)
+	}
+}
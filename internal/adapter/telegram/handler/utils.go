@@ -11,6 +11,8 @@ import (
 	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
 
 	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/pkg/contextkey"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
 	"remnawave-tg-shop-bot/utils"
 )
 
@@ -46,6 +48,23 @@ func callbackChatMessage(upd *models.Update) (int64, int, bool) {
 	return 0, 0, false
 }
 
+// userLanguage returns the language the customer is served in: the one
+// resolved by CreateCustomerIfNotExistMiddleware or, for updates that bypass
+// it, the Telegram client language.
+func userLanguage(ctx context.Context, update *models.Update) string {
+	if lang, ok := contextkey.LanguageFromContext(ctx); ok {
+		return lang
+	}
+	switch {
+	case update.Message != nil && update.Message.From != nil:
+		return update.Message.From.LanguageCode
+	case update.CallbackQuery != nil:
+		return update.CallbackQuery.From.LanguageCode
+	default:
+		return ""
+	}
+}
+
 func (h *Handler) findOrCreateCustomer(ctx context.Context, telegramID int64, lang string) (*domaincustomer.Customer, error) {
 	customer, err := h.customerRepository.FindByTelegramId(ctx, telegramID)
 	if err != nil {
@@ -94,7 +113,7 @@ func (h *Handler) promptPromoMonths(ctx context.Context, b *bot.Bot, msg *models
 		ChatID:    msg.Chat.ID,
 		MessageID: msg.ID,
 		ParseMode: models.ParseModeHTML,
-		Text:      fmt.Sprintf(h.translation.GetText(lang, "promo_choose_plan"), bal),
+		Text:      h.translation.Format(lang, "promo_choose_plan", translation.Args{"balance": bal}),
 		ReplyMarkup: models.InlineKeyboardMarkup{
 			InlineKeyboard: kb,
 		},
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	pg "remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/internal/service/referral"
+)
+
+// PayoutCallbackHandler shows the withdrawable referral earnings and asks for
+// the amount and payout details.
+func (h *Handler) PayoutCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	langCode := userLanguage(ctx, update)
+	chatID, msgID, ok := callbackChatMessage(update)
+	if !ok {
+		slog.Error("callback message missing")
+		return
+	}
+	minimum := h.referralService.Rules().MinWithdrawal
+	if minimum <= 0 {
+		return
+	}
+
+	available, err := h.referralService.Withdrawable(ctx, update.CallbackQuery.From.ID)
+	if err != nil {
+		slog.Error("error loading withdrawable amount", "err", err)
+		return
+	}
+
+	args := translation.Args{"available": referral.FormatAmount(available), "minimum": minimum}
+	text := h.translation.Format(langCode, "referral_withdrawal_unavailable", args)
+	if available >= float64(minimum) {
+		h.expectWithdrawal(update.CallbackQuery.From.ID)
+		text = h.translation.Format(langCode, "referral_withdrawal_prompt", args)
+	}
+
+	kb := [][]models.InlineKeyboardButton{
+		{
+			{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackReferralStats},
+		},
+	}
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      chatID,
+		MessageID:   msgID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        text,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: kb},
+	})
+	if err != nil {
+		slog.Error("Error sending withdrawal prompt", "err", err)
+	}
+}
+
+func (h *Handler) WithdrawalMessageHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if !h.consumeWithdrawal(update.Message.Chat.ID) {
+		return
+	}
+	lang := userLanguage(ctx, update)
+
+	amount, details, err := referral.ParseWithdrawal(update.Message.Text)
+	if err == nil {
+		var w *pg.ReferralWithdrawal
+		w, err = h.referralService.RequestWithdrawal(ctx, update.Message.From.ID, amount, details)
+		if err == nil {
+			_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: update.Message.Chat.ID,
+				Text:   h.translation.Format(lang, "referral_withdrawal_created", translation.Args{"id": w.ID, "amount": translation.Raw(referral.FormatAmount(w.Amount))}),
+			})
+			return
+		}
+	}
+	_, _ = b.SendMessage(ctx, &bot.SendMessageParams{ChatID: update.Message.Chat.ID, Text: h.withdrawalErrorText(ctx, lang, update.Message.From.ID, err)})
+}
+
+func (h *Handler) withdrawalErrorText(ctx context.Context, lang string, telegramID int64, err error) string {
+	switch {
+	case errors.Is(err, referral.ErrWithdrawalBelowMinimum):
+		return h.translation.Format(lang, "referral_withdrawal_below_minimum", translation.Args{"minimum": h.referralService.Rules().MinWithdrawal})
+	case errors.Is(err, pg.ErrInsufficientFunds):
+		available, _ := h.referralService.Withdrawable(ctx, telegramID)
+		return h.translation.Format(lang, "referral_withdrawal_insufficient", translation.Args{"available": translation.Raw(referral.FormatAmount(available))})
+	case errors.Is(err, referral.ErrWithdrawalFormat), errors.Is(err, referral.ErrWithdrawalDetails):
+		return h.translation.GetText(lang, "referral_withdrawal_format")
+	default:
+		slog.Error("request referral withdrawal", "err", err)
+		return h.translation.GetText(lang, "referral_withdrawal_failed")
+	}
+}
+
+// WithdrawalsCommandHandler lists withdrawals waiting for payout with paid and reject buttons.
+func (h *Handler) WithdrawalsCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if !config.IsAdmin(update.Message.From.ID) {
+		return
+	}
+	lang := userLanguage(ctx, update)
+
+	pending, err := h.referralService.PendingWithdrawals(ctx)
+	if err != nil {
+		slog.Error("error getting pending withdrawals", "err", err)
+		return
+	}
+	if len(pending) == 0 {
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{ChatID: update.Message.Chat.ID, Text: h.translation.GetText(lang, "referral_withdrawals_empty")})
+		if err != nil {
+			slog.Error("Error sending referral_withdrawals_empty msg", "err", err)
+		}
+		return
+	}
+
+	var textBuilder strings.Builder
+	textBuilder.WriteString(h.translation.GetText(lang, "referral_withdrawals_intro"))
+	var kb [][]models.InlineKeyboardButton
+	for _, w := range pending {
+		textBuilder.WriteString("\n\n")
+		textBuilder.WriteString(h.translation.Format(lang, "referral_withdrawals_item", translation.Args{
+			"id":       w.ID,
+			"customer": w.CustomerID,
+			"amount":   translation.Raw(referral.FormatAmount(w.Amount)),
+			"details":  translation.Raw(w.Details),
+			"created":  translation.Raw(w.CreatedAt.Format("02.01.2006 15:04")),
+		}))
+		kb = append(kb, []models.InlineKeyboardButton{
+			{Text: h.translation.Format(lang, "referral_withdrawal_paid_button", translation.Args{"id": w.ID}), CallbackData: fmt.Sprintf("%s:%d", CallbackWithdrawalPaid, w.ID)},
+			{Text: h.translation.Format(lang, "referral_withdrawal_reject_button", translation.Args{"id": w.ID}), CallbackData: fmt.Sprintf("%s:%d", CallbackWithdrawalReject, w.ID)},
+		})
+	}
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      update.Message.Chat.ID,
+		Text:        textBuilder.String(),
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: kb},
+	})
+	if err != nil {
+		slog.Error("Error sending pending withdrawals", "err", err)
+	}
+}
+
+func (h *Handler) WithdrawalPaidCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	h.processWithdrawal(ctx, b, update, h.referralService.ApproveWithdrawal, func(lang string, args translation.Args) string {
+		return h.translation.Format(lang, "referral_withdrawal_marked_paid", args)
+	})
+}
+
+func (h *Handler) WithdrawalRejectCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	h.processWithdrawal(ctx, b, update, h.referralService.RejectWithdrawal, func(lang string, args translation.Args) string {
+		return h.translation.Format(lang, "referral_withdrawal_marked_rejected", args)
+	})
+}
+
+func (h *Handler) processWithdrawal(ctx context.Context, b *bot.Bot, update *models.Update,
+	process func(context.Context, int64, int64) (*pg.ReferralWithdrawal, error), result func(string, translation.Args) string) {
+	adminID := update.CallbackQuery.From.ID
+	if !config.IsAdmin(adminID) {
+		return
+	}
+	chatID, msgID, ok := callbackChatMessage(update)
+	if !ok {
+		slog.Error("callback message missing")
+		return
+	}
+	id, ok := parseIDCallback(update.CallbackQuery.Data)
+	if !ok {
+		return
+	}
+
+	w, err := process(ctx, id, adminID)
+	if err != nil {
+		slog.Error("error processing referral withdrawal", "err", err)
+		return
+	}
+	lang := userLanguage(ctx, update)
+	text := h.translation.GetText(lang, "referral_withdrawal_processed")
+	if w != nil {
+		text = result(lang, translation.Args{
+			"id":       w.ID,
+			"amount":   translation.Raw(referral.FormatAmount(w.Amount)),
+			"customer": w.CustomerID,
+		})
+	}
+
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    chatID,
+		MessageID: msgID,
+		Text:      text,
+	})
+	if err != nil {
+		slog.Error("Error sending withdrawal result", "err", err)
+	}
+}
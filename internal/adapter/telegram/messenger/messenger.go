@@ -2,6 +2,7 @@ package messenger
 
 import (
 	"context"
+	"strconv"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
@@ -11,6 +12,7 @@ import (
 type Messenger interface {
 	SendMessage(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error)
 	DeleteMessage(ctx context.Context, params *bot.DeleteMessageParams) (bool, error)
+	EditMessageText(ctx context.Context, params *bot.EditMessageTextParams) (*models.Message, error)
 	CreateInvoiceLink(ctx context.Context, params *bot.CreateInvoiceLinkParams) (string, error)
 }
 
@@ -28,6 +30,31 @@ func (m *BotMessenger) DeleteMessage(ctx context.Context, params *bot.DeleteMess
 	return m.b.DeleteMessage(ctx, params)
 }
 
+func (m *BotMessenger) EditMessageText(ctx context.Context, params *bot.EditMessageTextParams) (*models.Message, error) {
+	return m.b.EditMessageText(ctx, params)
+}
+
 func (m *BotMessenger) CreateInvoiceLink(ctx context.Context, params *bot.CreateInvoiceLinkParams) (string, error) {
 	return m.b.CreateInvoiceLink(ctx, params)
 }
+
+// IsChatMember reports whether the user is a member of the chat given as
+// @username or numeric id. The bot must be able to see the chat members.
+func (m *BotMessenger) IsChatMember(ctx context.Context, chat string, userID int64) (bool, error) {
+	var chatID any = chat
+	if id, err := strconv.ParseInt(chat, 10, 64); err == nil {
+		chatID = id
+	}
+	member, err := m.b.GetChatMember(ctx, &bot.GetChatMemberParams{ChatID: chatID, UserID: userID})
+	if err != nil {
+		return false, err
+	}
+	switch member.Type {
+	case models.ChatMemberTypeOwner, models.ChatMemberTypeAdministrator, models.ChatMemberTypeMember:
+		return true, nil
+	case models.ChatMemberTypeRestricted:
+		return member.Restricted != nil && member.Restricted.IsMember, nil
+	default:
+		return false, nil
+	}
+}
@@ -10,9 +10,12 @@ import (
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
 	"log/slog"
 
+	"remnawave-tg-shop-bot/internal/adapter/telegram/handler"
 	"remnawave-tg-shop-bot/internal/observability"
 	"remnawave-tg-shop-bot/internal/pkg/cache"
 	"remnawave-tg-shop-bot/internal/pkg/config"
@@ -26,28 +29,48 @@ type App struct {
 	Bot   *bot.Bot
 	Pool  *pgxpool.Pool
 	Cron  *cron.Cron
-	Cache *cache.Cache
+	Cache *cache.Cache[int]
+	// Health serves the readiness probe, services register their checks.
+	Health *observability.Health
+	// Mux serves the probes and metrics on HEALTH_CHECK_PORT, services register
+	// their webhooks on it.
+	Mux *http.ServeMux
+	// shutdownTracing flushes the pending spans.
+	shutdownTracing func(context.Context) error
 }
 
 func New(ctx context.Context) (*App, error) {
 	config.InitConfig()
 
+	shutdownTracing, err := observability.InitTracing(ctx, config.TracingExporter())
+	if err != nil {
+		return nil, fmt.Errorf("init tracing: %w", err)
+	}
+
 	tm := translation.GetInstance()
-	if err := tm.InitDefaultTranslations(); err != nil {
+	if err := tm.InitWithOverrides(config.TranslationsDir()); err != nil {
 		return nil, fmt.Errorf("init translations: %w", err)
 	}
+	for _, issue := range tm.Validate() {
+		slog.Warn("translation issue", "issue", issue.String())
+	}
 
 	pool, err := InitDatabase(ctx, config.DatabaseURL())
 	if err != nil {
 		return nil, fmt.Errorf("connect db: %w", err)
 	}
 
+	prometheus.MustRegister(observability.NewPoolCollector(pool))
+
 	b, err := bot.New(config.TelegramToken(), bot.WithMiddlewares(
 		func(next bot.HandlerFunc) bot.HandlerFunc {
 			return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+				label := handler.UpdateLabel(update)
+				ctx, span := observability.StartSpan(ctx, "update "+label, attribute.Int64("telegram.update_id", update.ID))
+				defer span.End()
 				start := time.Now()
 				next(ctx, b, update)
-				observability.RequestDuration.WithLabelValues("telegram").Observe(time.Since(start).Seconds())
+				observability.RequestDuration.WithLabelValues(label).Observe(time.Since(start).Seconds())
 			}
 		},
 	))
@@ -55,16 +78,25 @@ func New(ctx context.Context) (*App, error) {
 		return nil, fmt.Errorf("create bot: %w", err)
 	}
 	customerRepo := pg.NewCustomerRepository(pool)
-	subSvc := notification.NewSubscriptionService(customerRepo, b, tm)
+	subSvc := notification.NewSubscriptionService(customerRepo, pg.NewSubscriptionRepository(pool), b, tm)
 
 	sched := cron.New(cron.WithLocation(time.UTC))
 	if err := notification.RegisterSubscriptionCron(sched, subSvc); err != nil {
 		return nil, fmt.Errorf("schedule subscription cron: %w", err)
 	}
 
+	health := observability.NewHealth(config.HealthCacheTTL())
+	health.Register(coreChecks(pool, b, config.MigrationsDir())...)
+
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", health.LiveHandler())
+	mux.Handle("/readyz", health.ReadyHandler())
+	// metrics are served on every other path, scrape configs predate /metrics
+	mux.Handle("/", observability.Handler())
+
 	metricsSrv := &http.Server{
 		Addr:              fmt.Sprintf(":%d", config.GetHealthCheckPort()),
-		Handler:           observability.Handler(),
+		Handler:           mux,
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 
@@ -78,9 +110,9 @@ func New(ctx context.Context) (*App, error) {
 		<-ctx.Done()
 		_ = metricsSrv.Shutdown(context.Background())
 	}()
-	cache := cache.NewCache(ctx, time.Hour)
+	cache := cache.NewCache[int](ctx, time.Hour)
 
-	return &App{Bot: b, Pool: pool, Cron: sched, Cache: cache}, nil
+	return &App{Bot: b, Pool: pool, Cron: sched, Cache: cache, Health: health, Mux: mux, shutdownTracing: shutdownTracing}, nil
 }
 
 func (a *App) Start() {
@@ -105,4 +137,12 @@ func (a *App) Shutdown(ctx context.Context) {
 		_, _ = a.Bot.Close(ctx)
 	}
 	a.Pool.Close()
+	if a.shutdownTracing != nil {
+		// ctx is already cancelled on a signal
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := a.shutdownTracing(flushCtx); err != nil {
+			slog.Error("flush traces", "err", err)
+		}
+	}
 }
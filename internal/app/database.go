@@ -2,8 +2,15 @@ package app
 
 import (
 	"context"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"remnawave-tg-shop-bot/internal/observability"
 )
 
 // InitDatabase creates a connection pool with sane defaults.
@@ -14,5 +21,41 @@ func InitDatabase(ctx context.Context, connString string) (*pgxpool.Pool, error)
 	}
 	cfg.MaxConns = 20
 	cfg.MinConns = 5
+	cfg.ConnConfig.Logger = dbLogger{}
+	cfg.ConnConfig.LogLevel = pgx.LogLevelError
+	if observability.TracingEnabled() {
+		// pgx reports every finished query at the info level
+		cfg.ConnConfig.LogLevel = pgx.LogLevelInfo
+	}
 	return pgxpool.ConnectConfig(ctx, cfg)
 }
+
+// dbLogger counts the failed queries pgx reports, the repositories log them,
+// and traces the queries when tracing is enabled.
+type dbLogger struct{}
+
+func (dbLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	if level == pgx.LogLevelError {
+		observability.DBErrors.Inc()
+	}
+	if observability.TracingEnabled() {
+		traceQuery(ctx, msg, data)
+	}
+}
+
+// traceQuery records a span of a query pgx has finished. pgx v4 has no query
+// hooks, the span is started back by the duration pgx logged.
+func traceQuery(ctx context.Context, msg string, data map[string]interface{}) {
+	sql, ok := data["sql"].(string)
+	if !ok {
+		return
+	}
+	duration, _ := data["time"].(time.Duration)
+	_, span := observability.Tracer().Start(ctx, "db "+strings.ToLower(msg),
+		trace.WithTimestamp(time.Now().Add(-duration)),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBQueryText(sql)),
+	)
+	err, _ := data["err"].(error)
+	observability.EndSpan(span, err)
+}
@@ -8,48 +8,94 @@ import (
 
 func (a *App) InitHandlers(h *handler.Handler) {
 	b := a.Bot
-	b.RegisterHandler(bot.HandlerTypeMessageText, "/start", bot.MatchTypeExact, h.StartCommandHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeMessageText, "/menu", bot.MatchTypeExact, h.MenuCommandHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeMessageText, "/help", bot.MatchTypeExact, h.HelpCommandHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeMessageText, "/promo", bot.MatchTypeExact, h.PromoCommandHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeMessageText, "/connect", bot.MatchTypeExact, h.ConnectCommandHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeMessageText, "/sync", bot.MatchTypeExact, h.SyncUsersCommandHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackStart, bot.MatchTypePrefix, h.StartCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackConnect, bot.MatchTypePrefix, h.ConnectCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackBuy, bot.MatchTypePrefix, h.BuyCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackSell, bot.MatchTypePrefix, h.SellCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPayment, bot.MatchTypePrefix, h.PaymentCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackBalance, bot.MatchTypePrefix, h.BalanceCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTopup, bot.MatchTypePrefix, h.TopupCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTopupMethod, bot.MatchTypePrefix, h.TopupMethodCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPayFromBal, bot.MatchTypePrefix, h.PayFromBalanceCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTrial, bot.MatchTypePrefix, h.TrialCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackActivateTrial, bot.MatchTypePrefix, h.ActivateTrialCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackReferral, bot.MatchTypePrefix, h.ReferralCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackReferralStats, bot.MatchTypePrefix, h.ReferralStatsCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoCodes, bot.MatchTypePrefix, h.PromoCodesCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoCreate, bot.MatchTypePrefix, h.PromoCreateCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoEnter, bot.MatchTypePrefix, h.PromoEnterCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoList, bot.MatchTypePrefix, h.PromoListCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoFreeze, bot.MatchTypePrefix, h.PromoFreezeCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoUnfreeze, bot.MatchTypePrefix, h.PromoUnfreezeCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoConfirmationDelete, bot.MatchTypePrefix, h.PromoDeleteConfirmationCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoDelete, bot.MatchTypePrefix, h.PromoDeleteCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackOther, bot.MatchTypePrefix, h.OtherCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackFAQ, bot.MatchTypePrefix, h.FAQCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTrafficLimit, bot.MatchTypePrefix, h.TrafficLimitCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackKeys, bot.MatchTypePrefix, h.KeysCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackQR, bot.MatchTypePrefix, h.QRCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackShortLink, bot.MatchTypePrefix, h.ShortLinkCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackShortList, bot.MatchTypePrefix, h.ShortListCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackLocations, bot.MatchTypePrefix, h.LocationsCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackRegenKey, bot.MatchTypePrefix, h.RegenKeyCallbackHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
+	// messages of the support group never reach the customer handlers
+	b.RegisterHandlerMatchFunc(handler.IsSupportGroupMessage, h.SupportGroupMessageHandler, handler.LogUpdateMiddleware)
+
+	b.RegisterHandler(bot.HandlerTypeMessageText, "start", bot.MatchTypeCommandStartOnly, h.StartCommandHandler, h.RateLimit(handler.BudgetExpensive), h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/menu", bot.MatchTypeExact, h.MenuCommandHandler, h.RateLimit(handler.BudgetExpensive), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/help", bot.MatchTypeExact, h.HelpCommandHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/promo", bot.MatchTypeExact, h.PromoCommandHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/connect", bot.MatchTypeExact, h.ConnectCommandHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/language", bot.MatchTypeExact, h.LanguageCommandHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "sync", bot.MatchTypeCommandStartOnly, h.SyncUsersCommandHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/referral_review", bot.MatchTypeExact, h.ReferralReviewCommandHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/withdrawals", bot.MatchTypeExact, h.WithdrawalsCommandHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "block", bot.MatchTypeCommandStartOnly, h.BlockCommandHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "ban", bot.MatchTypeCommandStartOnly, h.BanCommandHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "unblock", bot.MatchTypeCommandStartOnly, h.UnblockCommandHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "faq_category", bot.MatchTypeCommandStartOnly, h.FAQCategoryCommandHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "faq_add", bot.MatchTypeCommandStartOnly, h.FAQAddCommandHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "faq_edit", bot.MatchTypeCommandStartOnly, h.FAQEditCommandHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "faq_page", bot.MatchTypeCommandStartOnly, h.FAQPageCommandHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "faq_delete", bot.MatchTypeCommandStartOnly, h.FAQDeleteCommandHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "faq_delete_category", bot.MatchTypeCommandStartOnly, h.FAQDeleteCategoryCommandHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "stats", bot.MatchTypeCommandStartOnly, h.StatsCommandHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "reload_translations", bot.MatchTypeCommandStartOnly, h.ReloadTranslationsCommandHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/promo_batch", bot.MatchTypePrefix, h.PromoBatchCommandHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackChannelCheck, bot.MatchTypePrefix, h.ChannelCheckCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackStart, bot.MatchTypePrefix, h.StartCallbackHandler, h.RateLimit(handler.BudgetExpensive), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackConnect, bot.MatchTypePrefix, h.ConnectCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackBuy, bot.MatchTypePrefix, h.BuyCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackSell, bot.MatchTypePrefix, h.SellCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPayment, bot.MatchTypePrefix, h.PaymentCallbackHandler, h.RateLimit(handler.BudgetExpensive), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackBalance, bot.MatchTypePrefix, h.BalanceCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTopup, bot.MatchTypePrefix, h.TopupCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTopupMethod, bot.MatchTypePrefix, h.TopupMethodCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPayFromBal, bot.MatchTypePrefix, h.PayFromBalanceCallbackHandler, h.RateLimit(handler.BudgetExpensive), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTrial, bot.MatchTypePrefix, h.TrialCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackActivateTrial, bot.MatchTypePrefix, h.ActivateTrialCallbackHandler, h.RateLimit(handler.BudgetExpensive), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackReferralStats, bot.MatchTypePrefix, h.ReferralStatsCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackReferral, bot.MatchTypePrefix, h.ReferralCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoCodes, bot.MatchTypePrefix, h.PromoCodesCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoCreate, bot.MatchTypePrefix, h.PromoCreateCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoEnter, bot.MatchTypePrefix, h.PromoEnterCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoList, bot.MatchTypePrefix, h.PromoListCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoFreeze, bot.MatchTypePrefix, h.PromoFreezeCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoUnfreeze, bot.MatchTypePrefix, h.PromoUnfreezeCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoConfirmationDelete, bot.MatchTypePrefix, h.PromoDeleteConfirmationCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoDelete, bot.MatchTypePrefix, h.PromoDeleteCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoBatches, bot.MatchTypePrefix, h.PromoBatchesCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoBatchView, bot.MatchTypePrefix, h.PromoBatchViewCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoBatchExport, bot.MatchTypePrefix, h.PromoBatchExportCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackReviewApprove, bot.MatchTypePrefix, h.ReviewApproveCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackReviewReject, bot.MatchTypePrefix, h.ReviewRejectCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPayout, bot.MatchTypePrefix, h.PayoutCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackWithdrawalPaid, bot.MatchTypePrefix, h.WithdrawalPaidCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackWithdrawalReject, bot.MatchTypePrefix, h.WithdrawalRejectCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackStats, bot.MatchTypePrefix, h.StatsCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackOther, bot.MatchTypePrefix, h.OtherCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackLanguage, bot.MatchTypePrefix, h.LanguageCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackSupport, bot.MatchTypePrefix, h.SupportCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackFAQ, bot.MatchTypePrefix, h.FAQCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTrafficLimit, bot.MatchTypePrefix, h.TrafficLimitCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackKeys, bot.MatchTypePrefix, h.KeysCallbackHandler, h.RateLimit(handler.BudgetExpensive), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackQR, bot.MatchTypePrefix, h.QRCallbackHandler, h.RateLimit(handler.BudgetExpensive), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackShortLink, bot.MatchTypePrefix, h.ShortLinkCallbackHandler, h.RateLimit(handler.BudgetExpensive), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackShortList, bot.MatchTypePrefix, h.ShortListCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackLocations, bot.MatchTypePrefix, h.LocationsCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackRegenKey, bot.MatchTypePrefix, h.RegenKeyCallbackHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
 
 	b.RegisterHandlerMatchFunc(func(upd *models.Update) bool {
 		if upd.Message == nil {
 			return false
 		}
 		return h.IsAwaitingPromo(upd.Message.Chat.ID)
-	}, h.PromoCodeMessageHandler, h.CreateCustomerIfNotExistMiddleware, handler.LogUpdateMiddleware)
+	}, h.PromoCodeMessageHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+
+	b.RegisterHandlerMatchFunc(func(upd *models.Update) bool {
+		if upd.Message == nil {
+			return false
+		}
+		return h.IsAwaitingWithdrawal(upd.Message.Chat.ID)
+	}, h.WithdrawalMessageHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+
+	b.RegisterHandlerMatchFunc(func(upd *models.Update) bool {
+		if upd.Message == nil {
+			return false
+		}
+		return h.IsAwaitingFAQSearch(upd.Message.Chat.ID)
+	}, h.FAQSearchMessageHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
+
+	b.RegisterHandlerMatchFunc(handler.IsSupportMessage, h.SupportMessageHandler, h.RateLimit(handler.BudgetCheap), h.CreateCustomerIfNotExistMiddleware, h.BlockedUserMiddleware, h.ChannelGateMiddleware, handler.LogUpdateMiddleware)
 }
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-telegram/bot"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"remnawave-tg-shop-bot/internal/observability"
+	pg "remnawave-tg-shop-bot/internal/repository/pg"
+)
+
+// coreChecks probes the dependencies the bot can't work without.
+func coreChecks(pool *pgxpool.Pool, b *bot.Bot, migrationsDir string) []observability.HealthCheck {
+	return []observability.HealthCheck{
+		{Name: "postgres", Check: pool.Ping},
+		{Name: "migrations", Check: func(context.Context) error { return checkMigrations(migrationsDir) }},
+		{Name: "telegram", Check: func(ctx context.Context) error {
+			_, err := b.GetMe(ctx)
+			return err
+		}},
+	}
+}
+
+// checkMigrations fails unless the schema is at the newest migration.
+func checkMigrations(dir string) error {
+	latest, err := pg.LatestMigrationVersion(dir)
+	if err != nil {
+		return err
+	}
+	version, dirty, err := pg.GetMigrationVersion(dir)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("migration %d is dirty", version)
+	}
+	if version < latest {
+		return fmt.Errorf("schema version %d is behind migration %d", version, latest)
+	}
+	return nil
+}
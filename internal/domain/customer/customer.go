@@ -9,5 +9,14 @@ type Customer struct {
 	CreatedAt        time.Time
 	SubscriptionLink *string
 	Language         string
-	Balance          float64
+	// LanguageChosen is set when the user picked Language in the bot, it is
+	// no longer taken from the Telegram client then.
+	LanguageChosen bool
+	Balance        float64
+	// UnreachableAt is set when Telegram refused messages to the customer for
+	// good, e.g. the bot was blocked.
+	UnreachableAt *time.Time
+	// MissingFromPanelAt is set by the panel sync when the customer has a
+	// subscription but no panel user.
+	MissingFromPanelAt *time.Time
 }
@@ -11,9 +11,12 @@ type Repository interface {
 	FindByTelegramId(ctx context.Context, telegramId int64) (*Customer, error)
 	Create(ctx context.Context, c *Customer) (*Customer, error)
 	UpdateFields(ctx context.Context, id int64, updates map[string]interface{}) error
+	// SpendBalance debits amount from the balance and returns the new balance.
+	SpendBalance(ctx context.Context, id int64, amount float64) (float64, error)
 	FindByTelegramIds(ctx context.Context, telegramIDs []int64) ([]Customer, error)
-	DeleteByNotInTelegramIds(ctx context.Context, telegramIDs []int64) error
 	CreateBatch(ctx context.Context, customers []Customer) error
 	UpdateBatch(ctx context.Context, customers []Customer) error
+	FindMissingFromPanel(ctx context.Context, telegramIDs []int64) ([]int64, error)
+	MarkMissingFromPanel(ctx context.Context, telegramIDs []int64) error
 	FindByExpirationRange(ctx context.Context, startDate, endDate time.Time) (*[]Customer, error)
 }
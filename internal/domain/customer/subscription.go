@@ -0,0 +1,18 @@
+package customer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SubscriptionUsername names the panel user of an extra subscription of the
+// customer. It never contains "_<telegramID>", which marks the primary user.
+func SubscriptionUsername(telegramID, subscriptionID int64) string {
+	return fmt.Sprintf("%d_sub%d", telegramID, subscriptionID)
+}
+
+// IsSubscriptionUsername reports whether username names an extra subscription
+// of the customer, see SubscriptionUsername.
+func IsSubscriptionUsername(telegramID int64, username string) bool {
+	return strings.HasPrefix(username, fmt.Sprintf("%d_sub", telegramID))
+}
@@ -0,0 +1,148 @@
+package observability
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//go:generate go run ../../cmd/dashboard -out ../../docs/grafana-dashboard.json
+
+const (
+	dashboardUID  = "remnawave-shop-bot"
+	rateInterval  = "5m"
+	panelWidth    = 12
+	panelHeight   = 8
+	panelsPerLine = 2
+)
+
+type dashboard struct {
+	UID           string     `json:"uid"`
+	Title         string     `json:"title"`
+	Tags          []string   `json:"tags"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Refresh       string     `json:"refresh"`
+	Time          timeRange  `json:"time"`
+	Templating    templating `json:"templating"`
+	Panels        []panel    `json:"panels"`
+}
+
+type timeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type templating struct {
+	List []variable `json:"list"`
+}
+
+type variable struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Type  string `json:"type"`
+	Query string `json:"query"`
+}
+
+type panel struct {
+	ID          int         `json:"id"`
+	Type        string      `json:"type"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Datasource  datasource  `json:"datasource"`
+	GridPos     gridPos     `json:"gridPos"`
+	FieldConfig fieldConfig `json:"fieldConfig"`
+	Targets     []target    `json:"targets"`
+}
+
+type datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type gridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type fieldConfig struct {
+	Defaults  fieldDefaults `json:"defaults"`
+	Overrides []any         `json:"overrides"`
+}
+
+type fieldDefaults struct {
+	Unit string `json:"unit"`
+}
+
+type target struct {
+	RefID        string     `json:"refId"`
+	Datasource   datasource `json:"datasource"`
+	Expr         string     `json:"expr"`
+	LegendFormat string     `json:"legendFormat"`
+}
+
+// Dashboard renders a Grafana dashboard with a panel per metric of Definitions.
+// docs/grafana-dashboard.json is generated from it with go generate.
+func Dashboard() ([]byte, error) {
+	ds := datasource{Type: "prometheus", UID: "${datasource}"}
+	d := dashboard{
+		UID:           dashboardUID,
+		Title:         "Remnawave shop bot",
+		Tags:          []string{"remnawave", "telegram"},
+		SchemaVersion: 39,
+		Refresh:       "1m",
+		Time:          timeRange{From: "now-24h", To: "now"},
+		Templating:    templating{List: []variable{{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"}}},
+	}
+	for i, def := range Definitions {
+		title, expr := panelQuery(def)
+		d.Panels = append(d.Panels, panel{
+			ID:          i + 1,
+			Type:        "timeseries",
+			Title:       title,
+			Description: def.Name,
+			Datasource:  ds,
+			GridPos: gridPos{
+				H: panelHeight,
+				W: panelWidth,
+				X: (i % panelsPerLine) * panelWidth,
+				Y: (i / panelsPerLine) * panelHeight,
+			},
+			FieldConfig: fieldConfig{Defaults: fieldDefaults{Unit: def.Unit}, Overrides: []any{}},
+			Targets:     []target{{RefID: "A", Datasource: ds, Expr: expr, LegendFormat: legend(def.Labels)}},
+		})
+	}
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// panelQuery returns the title and PromQL expression charting the metric.
+func panelQuery(def Definition) (string, string) {
+	by := ""
+	if len(def.Labels) > 0 {
+		by = " by (" + strings.Join(def.Labels, ", ") + ")"
+	}
+	switch def.Kind {
+	case KindCounter:
+		return def.Help + " per second", fmt.Sprintf("sum%s (rate(%s[%s]))", by, def.Name, rateInterval)
+	case KindHistogram:
+		labels := strings.Join(append([]string{"le"}, def.Labels...), ", ")
+		return def.Help + " (p95)", fmt.Sprintf("histogram_quantile(0.95, sum by (%s) (rate(%s_bucket[%s])))", labels, def.Name, rateInterval)
+	case KindTimestamp:
+		return "Time since: " + strings.ToLower(def.Help[:1]) + def.Help[1:], fmt.Sprintf("time() - max%s (%s)", by, def.Name)
+	default:
+		return def.Help, fmt.Sprintf("sum%s (%s)", by, def.Name)
+	}
+}
+
+func legend(labels []string) string {
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		parts[i] = "{{" + l + "}}"
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,134 @@
+package observability
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Statuses of the health report and its components.
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusFail     = "fail"
+)
+
+// checkTimeout bounds a single component check.
+const checkTimeout = 5 * time.Second
+
+// HealthCheck is a component probed by the readiness endpoint.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+	// Optional components degrade the report without failing the readiness.
+	Optional bool
+}
+
+type ComponentStatus struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+type HealthReport struct {
+	Status     string                     `json:"status"`
+	CheckedAt  time.Time                  `json:"checked_at"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// Health runs the registered checks and caches the report for ttl, so that
+// frequent probes don't reach the dependencies.
+type Health struct {
+	ttl time.Duration
+
+	mu     sync.Mutex
+	checks []HealthCheck
+	report *HealthReport
+}
+
+func NewHealth(ttl time.Duration) *Health {
+	return &Health{ttl: ttl}
+}
+
+// Register adds checks to the readiness report.
+func (h *Health) Register(checks ...HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, checks...)
+	h.report = nil
+}
+
+// Report returns the cached report, running the checks concurrently when it is
+// older than ttl. Concurrent callers wait for the same run.
+func (h *Health) Report(ctx context.Context) HealthReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.report != nil && time.Since(h.report.CheckedAt) < h.ttl {
+		return *h.report
+	}
+
+	statuses := make([]ComponentStatus, len(h.checks))
+	var wg sync.WaitGroup
+	for i, c := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// the report is cached, a probe giving up must not fail it
+			checkCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), checkTimeout)
+			defer cancel()
+			start := time.Now()
+			err := c.Check(checkCtx)
+			statuses[i] = ComponentStatus{Status: StatusOK, DurationMS: time.Since(start).Milliseconds()}
+			if err != nil {
+				statuses[i].Status = StatusFail
+				statuses[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	report := HealthReport{Status: StatusOK, CheckedAt: time.Now(), Components: make(map[string]ComponentStatus, len(h.checks))}
+	for i, c := range h.checks {
+		report.Components[c.Name] = statuses[i]
+		if statuses[i].Status == StatusOK {
+			continue
+		}
+		switch {
+		case !c.Optional:
+			report.Status = StatusFail
+		case report.Status == StatusOK:
+			report.Status = StatusDegraded
+		}
+	}
+	h.report = &report
+	return report
+}
+
+// LiveHandler answers the liveness probe. It doesn't check the dependencies,
+// restarting the bot doesn't fix them.
+func (h *Health) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": StatusOK})
+	})
+}
+
+// ReadyHandler answers the readiness probe with the per-component report,
+// 503 when a required component fails.
+func (h *Health) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := h.Report(r.Context())
+		code := http.StatusOK
+		if report.Status == StatusFail {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, report)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
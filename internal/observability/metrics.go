@@ -6,34 +6,93 @@ import (
 	"net/http"
 )
 
+const namespace = "bot"
+
+// Metric kinds of Definition.
+const (
+	KindCounter   = "counter"
+	KindGauge     = "gauge"
+	KindHistogram = "histogram"
+	// KindTimestamp is a gauge holding a unix time, charted as the age.
+	KindTimestamp = "timestamp"
+)
+
+// Definition describes an exported metric. The Grafana dashboard is
+// generated from Definitions, see Dashboard.
+type Definition struct {
+	Name   string
+	Help   string
+	Kind   string
+	Labels []string
+	// Unit is the Grafana unit of the charted value.
+	Unit string
+}
+
+// Definitions lists every metric of the package in declaration order.
+var Definitions []Definition
+
+func define(name, help, kind, unit string, labels []string) string {
+	fqName := prometheus.BuildFQName(namespace, "", name)
+	Definitions = append(Definitions, Definition{Name: fqName, Help: help, Kind: kind, Labels: labels, Unit: unit})
+	return fqName
+}
+
+func newCounter(name, help string) prometheus.Counter {
+	define(name, help, KindCounter, "short", nil)
+	return prometheus.NewCounter(prometheus.CounterOpts{Namespace: namespace, Name: name, Help: help})
+}
+
+func newCounterVec(name, help, unit string, labels ...string) *prometheus.CounterVec {
+	define(name, help, KindCounter, unit, labels)
+	return prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: namespace, Name: name, Help: help}, labels)
+}
+
+func newGaugeVec(name, help, kind string, labels ...string) *prometheus.GaugeVec {
+	unit := "short"
+	if kind == KindTimestamp {
+		unit = "s"
+	}
+	define(name, help, kind, unit, labels)
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: namespace, Name: name, Help: help}, labels)
+}
+
+func newHistogramVec(name, help string, labels ...string) *prometheus.HistogramVec {
+	define(name, help, KindHistogram, "s", labels)
+	return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+		Buckets:   prometheus.DefBuckets,
+	}, labels)
+}
+
 var (
-	RequestDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Namespace: "bot",
-			Name:      "handler_duration_seconds",
-			Help:      "Duration of bot handlers",
-			Buckets:   prometheus.DefBuckets,
-		},
-		[]string{"handler"},
-	)
-	DBErrors = prometheus.NewCounter(
-		prometheus.CounterOpts{
-			Namespace: "bot",
-			Name:      "db_errors_total",
-			Help:      "Total number of database errors",
-		},
-	)
-	PaymentAttempts = prometheus.NewCounter(
-		prometheus.CounterOpts{
-			Namespace: "bot",
-			Name:      "payment_attempts_total",
-			Help:      "Number of payment attempts",
-		},
-	)
+	// RequestDuration is labelled with the command or callback of the update, see handler.UpdateLabel.
+	RequestDuration = newHistogramVec("handler_duration_seconds", "Duration of bot handlers", "handler")
+	DBErrors        = newCounter("db_errors_total", "Total number of database errors")
+	// PaymentAttempts counts payments by provider and outcome: created, failed, paid or cancelled.
+	PaymentAttempts  = newCounterVec("payment_attempts_total", "Number of payment attempts", "short", "provider", "outcome")
+	Revenue          = newCounterVec("revenue_total", "Sum of paid purchases", "short", "provider", "currency")
+	ThrottledUpdates = newCounterVec("throttled_updates_total", "Number of updates dropped by the per-user rate limit", "short", "budget")
+	Subscriptions    = newGaugeVec("subscriptions", "Number of subscriptions: active, and expiring within 3 days", KindGauge, "state")
+	// DependencyDuration and DependencyErrors cover the calls to Remnawave and CryptoPay.
+	DependencyDuration = newHistogramVec("dependency_request_duration_seconds", "Duration of requests to external services", "dependency", "operation")
+	DependencyErrors   = newCounterVec("dependency_errors_total", "Number of failed requests to external services", "short", "dependency", "operation")
+	CronLastSuccess    = newGaugeVec("cron_last_success_timestamp_seconds", "Unix time of the last successful run of a cron job", KindTimestamp, "job")
+	// CircuitOpen is 1 while the circuit breaker of the dependency fails calls fast.
+	CircuitOpen = newGaugeVec("circuit_breaker_open", "Open circuit breakers of external services", KindGauge, "dependency")
+	// ProvisioningJobs counts subscription provisioning jobs by outcome: enqueued, done, retried or dead.
+	ProvisioningJobs = newCounterVec("provisioning_jobs_total", "Number of subscription provisioning jobs", "short", "outcome")
+	// OutboxMessages counts delivery attempts of outbox notifications by outcome: sent, retried, throttled, failed or unreachable.
+	OutboxMessages = newCounterVec("outbox_messages_total", "Number of outbox notification deliveries", "short", "outcome")
+	// PanelEvents counts the received Remnawave webhooks by event and outcome: applied, ignored or failed.
+	PanelEvents = newCounterVec("panel_events_total", "Number of received Remnawave webhooks", "short", "event", "outcome")
 )
 
 func init() {
-	prometheus.MustRegister(RequestDuration, DBErrors, PaymentAttempts)
+	prometheus.MustRegister(RequestDuration, DBErrors, PaymentAttempts, Revenue, ThrottledUpdates, Subscriptions,
+		DependencyDuration, DependencyErrors, CronLastSuccess, CircuitOpen, ProvisioningJobs, OutboxMessages,
+		PanelEvents)
 }
 
 // Handler returns http.Handler to expose metrics.
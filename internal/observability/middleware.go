@@ -15,3 +15,16 @@ func Measure(name string, next HandlerFunc) HandlerFunc {
 		return err
 	}
 }
+
+// ObserveDependency records a call to an external service started at start.
+func ObserveDependency(dependency, operation string, start time.Time, err error) {
+	DependencyDuration.WithLabelValues(dependency, operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		DependencyErrors.WithLabelValues(dependency, operation).Inc()
+	}
+}
+
+// CronSucceeded records a successful run of the cron job.
+func CronSucceeded(job string) {
+	CronLastSuccess.WithLabelValues(job).SetToCurrentTime()
+}
@@ -0,0 +1,58 @@
+package observability
+
+import (
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type poolMetric struct {
+	desc  *prometheus.Desc
+	kind  prometheus.ValueType
+	value func(*pgxpool.Stat) float64
+}
+
+func newPoolMetric(name, help string, kind prometheus.ValueType, value func(*pgxpool.Stat) float64) poolMetric {
+	defKind := KindGauge
+	if kind == prometheus.CounterValue {
+		defKind = KindCounter
+	}
+	fqName := define(name, help, defKind, "short", nil)
+	return poolMetric{desc: prometheus.NewDesc(fqName, help, nil, nil), kind: kind, value: value}
+}
+
+var poolMetrics = []poolMetric{
+	newPoolMetric("db_pool_acquired_connections", "Number of connections in use", prometheus.GaugeValue,
+		func(s *pgxpool.Stat) float64 { return float64(s.AcquiredConns()) }),
+	newPoolMetric("db_pool_idle_connections", "Number of idle connections", prometheus.GaugeValue,
+		func(s *pgxpool.Stat) float64 { return float64(s.IdleConns()) }),
+	newPoolMetric("db_pool_max_connections", "Maximum size of the pool", prometheus.GaugeValue,
+		func(s *pgxpool.Stat) float64 { return float64(s.MaxConns()) }),
+	newPoolMetric("db_pool_acquires_total", "Number of connection acquires", prometheus.CounterValue,
+		func(s *pgxpool.Stat) float64 { return float64(s.AcquireCount()) }),
+	newPoolMetric("db_pool_empty_acquires_total", "Number of acquires that waited for a free connection", prometheus.CounterValue,
+		func(s *pgxpool.Stat) float64 { return float64(s.EmptyAcquireCount()) }),
+	newPoolMetric("db_pool_acquire_seconds_total", "Time spent waiting for connections", prometheus.CounterValue,
+		func(s *pgxpool.Stat) float64 { return s.AcquireDuration().Seconds() }),
+}
+
+// PoolCollector exports the statistics of a pgx pool.
+type PoolCollector struct {
+	pool *pgxpool.Pool
+}
+
+func NewPoolCollector(pool *pgxpool.Pool) *PoolCollector {
+	return &PoolCollector{pool: pool}
+}
+
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range poolMetrics {
+		ch <- m.desc
+	}
+}
+
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	for _, m := range poolMetrics {
+		ch <- prometheus.MustNewConstMetric(m.desc, m.kind, m.value(stat))
+	}
+}
@@ -0,0 +1,120 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned by Allow while the breaker fails calls fast.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// Breaker opens after threshold consecutive failures and fails calls fast for
+// cooldown. Then a single probe call is let through, its success closes the
+// breaker and its failure opens it again.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	onChange  func(open bool)
+	nowFor    func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	open     bool
+	openedAt time.Time
+	probing  bool
+}
+
+// New returns a closed breaker. A non-positive threshold disables it.
+func New(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{threshold: threshold, cooldown: cooldown, nowFor: time.Now}
+}
+
+// WithClock replaces the time source, for tests.
+func (b *Breaker) WithClock(now func() time.Time) *Breaker {
+	b.nowFor = now
+	return b
+}
+
+// OnChange sets the function called when the breaker opens or closes.
+func (b *Breaker) OnChange(f func(open bool)) *Breaker {
+	b.onChange = f
+	return b
+}
+
+// Allow reports whether a call may be made, ErrOpen otherwise. Every allowed
+// call must be followed by Success, Failure or Cancel.
+func (b *Breaker) Allow() error {
+	if b == nil || b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return nil
+	}
+	if b.probing || b.nowFor().Sub(b.openedAt) < b.cooldown {
+		return ErrOpen
+	}
+	b.probing = true
+	return nil
+}
+
+// Success records a successful call and closes the breaker.
+func (b *Breaker) Success() {
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	wasOpen := b.open
+	b.failures, b.open, b.probing = 0, false, false
+	b.mu.Unlock()
+	if wasOpen {
+		b.changed(false)
+	}
+}
+
+// Failure records a failed call, opening the breaker after threshold
+// consecutive failures or a failed probe.
+func (b *Breaker) Failure() {
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	b.failures++
+	opened := !b.open && b.failures >= b.threshold
+	if opened || b.probing {
+		b.open, b.probing, b.openedAt = true, false, b.nowFor()
+	}
+	b.mu.Unlock()
+	if opened {
+		b.changed(true)
+	}
+}
+
+// Cancel releases an allowed call abandoned by the caller, its outcome says
+// nothing about the dependency.
+func (b *Breaker) Cancel() {
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// Open reports whether calls are failed fast.
+func (b *Breaker) Open() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open
+}
+
+func (b *Breaker) changed(open bool) {
+	if b.onChange != nil {
+		b.onChange(open)
+	}
+}
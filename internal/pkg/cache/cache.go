@@ -6,22 +6,23 @@ import (
 	"time"
 )
 
-type Item struct {
-	Value     int
+type Item[V any] struct {
+	Value     V
 	ExpiresAt time.Time
 }
 
-type Cache struct {
-	data   map[int64]Item
+// Cache keeps values by telegram or purchase id for a fixed TTL.
+type Cache[V any] struct {
+	data   map[int64]Item[V]
 	mutex  sync.RWMutex
 	ttl    time.Duration
 	cancel context.CancelFunc
 }
 
-func NewCache(ctx context.Context, ttl time.Duration) *Cache {
+func NewCache[V any](ctx context.Context, ttl time.Duration) *Cache[V] {
 	ctx, cancel := context.WithCancel(ctx)
-	c := &Cache{
-		data:   make(map[int64]Item),
+	c := &Cache[V]{
+		data:   make(map[int64]Item[V]),
 		ttl:    ttl,
 		cancel: cancel,
 	}
@@ -29,39 +30,53 @@ func NewCache(ctx context.Context, ttl time.Duration) *Cache {
 	return c
 }
 
-func (c *Cache) Set(key int64, value int) {
+func (c *Cache[V]) Set(key int64, value V) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	c.data[key] = Item{
+	c.data[key] = Item[V]{
 		Value:     value,
 		ExpiresAt: time.Now().Add(c.ttl),
 	}
 }
 
-func (c *Cache) Get(key int64) (int, bool) {
+func (c *Cache[V]) Get(key int64) (V, bool) {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 	item, found := c.data[key]
 	if !found || time.Now().After(item.ExpiresAt) {
-		return 0, false
+		var zero V
+		return zero, false
 	}
 	return item.Value, true
 }
 
-func (c *Cache) Delete(key int64) {
+// Take returns the value and removes it.
+func (c *Cache[V]) Take(key int64) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	item, found := c.data[key]
+	delete(c.data, key)
+	if !found || time.Now().After(item.ExpiresAt) {
+		var zero V
+		return zero, false
+	}
+	return item.Value, true
+}
+
+func (c *Cache[V]) Delete(key int64) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 	delete(c.data, key)
 }
 
 // Close stops background cleanup goroutine.
-func (c *Cache) Close() {
+func (c *Cache[V]) Close() {
 	if c.cancel != nil {
 		c.cancel()
 	}
 }
 
-func (c *Cache) cleanupExpired(ctx context.Context) {
+func (c *Cache[V]) cleanupExpired(ctx context.Context) {
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
 	for {
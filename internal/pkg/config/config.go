@@ -8,6 +8,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type config struct {
@@ -15,6 +16,12 @@ type config struct {
 	price1, price3, price6                              int
 	starsPrice1, starsPrice3, starsPrice6               int
 	remnawaveUrl, remnawaveToken, remnawaveMode         string
+	remnawaveTimeout, remnawaveBreakerCooldown          time.Duration
+	remnawaveRetries, remnawaveBreakerFailures          int
+	remnawaveWebhookSecret, remnawaveWebhookPath        string
+	provisioningMaxAttempts                             int
+	maxSubscriptionsPerCustomer                         int
+	syncSchedule                                        string
 	databaseURL                                         string
 	cryptoPayURL, cryptoPayToken                        string
 	botURL                                              string
@@ -29,8 +36,34 @@ type config struct {
 	adminTelegramIds                                    map[int64]struct{}
 	trialDays                                           int
 	inboundUUIDs                                        map[uuid.UUID]uuid.UUID
+	trialInboundUUIDs                                   map[uuid.UUID]uuid.UUID
+	planInboundUUIDs                                    map[int]map[uuid.UUID]uuid.UUID
+	trialVariant                                        string
+	trialMinAccountAgeHours                             int
+	trialRequiredChannel                                string
+	trialNewPanelUsersOnly                              bool
+	channelID                                           string
+	channelGate                                         string
+	channelGateCacheTTL                                 time.Duration
+	rateLimitCheapPerMinute, rateLimitCheapBurst        int
+	rateLimitExpensivePerMinute                         int
+	rateLimitExpensiveBurst                             int
+	translationsDir                                     string
+	supportChatID                                       int64
+	supportLanguage                                     string
+	statsReportHour                                     int
+	tracingExporter                                     string
+	migrationsDir                                       string
+	healthCacheTTL                                      time.Duration
 	referralDays                                        int
 	referralBonus                                       int
+	referralMode                                        string
+	referralPercent, referralPayments                   int
+	referralRefereeBonus, referralRefereeDays           int
+	referralSecondLevelPercent, referralHoldDays        int
+	referralMinPayment, referralMinRefereeAgeHours      int
+	referralDailyCap                                    int
+	referralWithdrawalMin                               int
 	miniApp                                             string
 	enableAutoPayment                                   bool
 	healthCheckPort                                     int
@@ -60,6 +93,53 @@ func GetReferralBonus() int {
 	return conf.referralBonus
 }
 
+func GetReferralMode() string {
+	return conf.referralMode
+}
+
+func GetReferralPercent() int {
+	return conf.referralPercent
+}
+
+// GetReferralPayments returns how many payments of a referee are rewarded, 0 means every payment.
+func GetReferralPayments() int {
+	return conf.referralPayments
+}
+
+func GetReferralRefereeBonus() int {
+	return conf.referralRefereeBonus
+}
+
+func GetReferralRefereeDays() int {
+	return conf.referralRefereeDays
+}
+
+func GetReferralSecondLevelPercent() int {
+	return conf.referralSecondLevelPercent
+}
+
+func GetReferralHoldDays() int {
+	return conf.referralHoldDays
+}
+
+func GetReferralMinPayment() int {
+	return conf.referralMinPayment
+}
+
+func GetReferralMinRefereeAgeHours() int {
+	return conf.referralMinRefereeAgeHours
+}
+
+// GetReferralDailyCap returns the max number of rewards per referrer per day, 0 means unlimited.
+func GetReferralDailyCap() int {
+	return conf.referralDailyCap
+}
+
+// GetReferralWithdrawalMin returns the smallest payout referrers can request, 0 disables withdrawals.
+func GetReferralWithdrawalMin() int {
+	return conf.referralWithdrawalMin
+}
+
 func GetMiniAppURL() string {
 	return conf.miniApp
 }
@@ -75,6 +155,89 @@ func TrialTrafficLimit() int {
 func TrialDays() int {
 	return conf.trialDays
 }
+
+// PlanInboundUUIDs returns the inbounds of the plan of the given months,
+// INBOUND_UUIDS when the plan has none. The result is never nil, nil inbounds
+// keep those of the user, see remnawave.Client.SetSubscriptionExpire.
+func PlanInboundUUIDs(months int) map[uuid.UUID]uuid.UUID {
+	if inbounds := conf.planInboundUUIDs[months]; len(inbounds) > 0 {
+		return inbounds
+	}
+	if conf.inboundUUIDs == nil {
+		return map[uuid.UUID]uuid.UUID{}
+	}
+	return conf.inboundUUIDs
+}
+
+// InboundSets returns the configured inbounds by their .env variable.
+func InboundSets() map[string]map[uuid.UUID]uuid.UUID {
+	sets := map[string]map[uuid.UUID]uuid.UUID{
+		"INBOUND_UUIDS":       conf.inboundUUIDs,
+		"TRIAL_INBOUND_UUIDS": conf.trialInboundUUIDs,
+	}
+	for months, inbounds := range conf.planInboundUUIDs {
+		sets["INBOUND_UUIDS_"+strconv.Itoa(months)] = inbounds
+	}
+	return sets
+}
+
+// TrialInboundUUIDs returns inbounds assigned to trial users, INBOUND_UUIDS when not set.
+func TrialInboundUUIDs() map[uuid.UUID]uuid.UUID {
+	if len(conf.trialInboundUUIDs) == 0 {
+		return conf.inboundUUIDs
+	}
+	return conf.trialInboundUUIDs
+}
+
+// TrialVariant names the trial offered by this deployment, it is stored with every trial usage.
+func TrialVariant() string {
+	return conf.trialVariant
+}
+
+// ChannelID returns the chat (@username or id) checked by the channel gate.
+func ChannelID() string {
+	return conf.channelID
+}
+
+// ChannelGate returns where channel membership is required: "off", "trial" or "all".
+func ChannelGate() string {
+	return conf.channelGate
+}
+
+func ChannelGateCacheTTL() time.Duration {
+	return conf.channelGateCacheTTL
+}
+
+// TranslationsDir returns the directory of translation overrides, empty if not set.
+func TranslationsDir() string {
+	return conf.translationsDir
+}
+
+// RateLimitCheap returns the per-user budget of plain handlers as updates per
+// minute and burst. Zero disables the limit.
+func RateLimitCheap() (perMinute, burst int) {
+	return conf.rateLimitCheapPerMinute, conf.rateLimitCheapBurst
+}
+
+// RateLimitExpensive returns the per-user budget of handlers that call
+// Remnawave or a payment provider.
+func RateLimitExpensive() (perMinute, burst int) {
+	return conf.rateLimitExpensivePerMinute, conf.rateLimitExpensiveBurst
+}
+
+func TrialMinAccountAgeHours() int {
+	return conf.trialMinAccountAgeHours
+}
+
+// TrialRequiredChannel returns the chat (@username or id) users must join to get a trial.
+func TrialRequiredChannel() string {
+	return conf.trialRequiredChannel
+}
+
+// IsTrialNewPanelUsersOnly reports whether users already present in Remnawave are denied a trial.
+func IsTrialNewPanelUsersOnly() bool {
+	return conf.trialNewPanelUsersOnly
+}
 func FeedbackURL() string {
 	return conf.feedbackURL
 }
@@ -91,6 +254,39 @@ func SupportURL() string {
 	return conf.supportURL
 }
 
+// SupportChatID returns the forum supergroup receiving support tickets, 0 when
+// in-bot support is disabled.
+func SupportChatID() int64 {
+	return conf.supportChatID
+}
+
+// SupportLanguage returns the language of the texts the bot posts in the support group.
+func SupportLanguage() string {
+	return conf.supportLanguage
+}
+
+// StatsReportHour is the UTC hour of the daily statistics report, negative
+// when the report is disabled.
+func StatsReportHour() int {
+	return conf.statsReportHour
+}
+
+// MigrationsDir is the directory of the database migrations the readiness
+// probe compares the schema version with.
+func MigrationsDir() string {
+	return conf.migrationsDir
+}
+
+// HealthCacheTTL is how long the readiness report is cached.
+func HealthCacheTTL() time.Duration {
+	return conf.healthCacheTTL
+}
+
+// TracingExporter is the exporter of the traces: otlp, stdout or none.
+func TracingExporter() string {
+	return conf.tracingExporter
+}
+
 func TosURL() string {
 	return conf.tosURL
 }
@@ -147,6 +343,57 @@ func RemnawaveToken() string {
 func RemnawaveMode() string {
 	return conf.remnawaveMode
 }
+
+// RemnawaveTimeout limits every call to the panel.
+func RemnawaveTimeout() time.Duration {
+	return conf.remnawaveTimeout
+}
+
+// RemnawaveRetries is the number of retries of idempotent panel calls.
+func RemnawaveRetries() int {
+	return conf.remnawaveRetries
+}
+
+// RemnawaveBreakerFailures is the number of consecutive failed panel calls
+// opening the circuit breaker, 0 disables it.
+func RemnawaveBreakerFailures() int {
+	return conf.remnawaveBreakerFailures
+}
+
+// RemnawaveBreakerCooldown is how long the open breaker fails panel calls fast.
+func RemnawaveBreakerCooldown() time.Duration {
+	return conf.remnawaveBreakerCooldown
+}
+
+// RemnawaveWebhookSecret signs the panel webhooks, the webhook endpoint is
+// disabled when it is empty.
+func RemnawaveWebhookSecret() string {
+	return conf.remnawaveWebhookSecret
+}
+
+// RemnawaveWebhookPath is the path of the panel webhook endpoint.
+func RemnawaveWebhookPath() string {
+	return conf.remnawaveWebhookPath
+}
+
+// ProvisioningMaxAttempts is how many times a paid subscription is applied to
+// the panel before the job is reported to admins.
+func ProvisioningMaxAttempts() int {
+	return conf.provisioningMaxAttempts
+}
+
+// MaxSubscriptionsPerCustomer limits the subscriptions a customer can buy,
+// 1 keeps a single subscription per customer.
+func MaxSubscriptionsPerCustomer() int {
+	return conf.maxSubscriptionsPerCustomer
+}
+
+// SyncSchedule is the cron schedule of the panel sync, empty when the sync only
+// runs with /sync.
+func SyncSchedule() string {
+	return conf.syncSchedule
+}
+
 func CryptoPayUrl() string {
 	return conf.cryptoPayURL
 }
@@ -234,6 +481,25 @@ func envBool(key string) bool {
 	return os.Getenv(key) == "true"
 }
 
+// parseUUIDs reads a comma separated list of UUIDs, panicking on invalid values.
+func parseUUIDs(key string) map[uuid.UUID]uuid.UUID {
+	v := os.Getenv(key)
+	if v == "" {
+		return map[uuid.UUID]uuid.UUID{}
+	}
+	uuids := strings.Split(v, ",")
+	result := make(map[uuid.UUID]uuid.UUID, len(uuids))
+	for _, value := range uuids {
+		id, err := uuid.Parse(strings.TrimSpace(value))
+		if err != nil {
+			panic(err)
+		}
+		result[id] = id
+	}
+	slog.Info("Loaded UUIDs", "key", key, "uuids", uuids)
+	return result
+}
+
 func InitConfig() {
 	if os.Getenv("DISABLE_ENV_FILE") != "true" {
 		if err := godotenv.Load(".env"); err != nil {
@@ -271,8 +537,20 @@ func InitConfig() {
 	conf.trialTrafficLimit = mustEnvInt("TRIAL_TRAFFIC_LIMIT")
 
 	conf.healthCheckPort = envIntDefault("HEALTH_CHECK_PORT", 8080)
+	conf.healthCacheTTL = time.Duration(envIntDefault("HEALTH_CACHE_SECONDS", 15)) * time.Second
+	conf.migrationsDir = os.Getenv("MIGRATIONS_DIR")
+	if conf.migrationsDir == "" {
+		conf.migrationsDir = "./db/migrations"
+	}
 
 	conf.trialDays = mustEnvInt("TRIAL_DAYS")
+	conf.trialVariant = os.Getenv("TRIAL_VARIANT")
+	if conf.trialVariant == "" {
+		conf.trialVariant = "default"
+	}
+	conf.trialMinAccountAgeHours = envIntDefault("TRIAL_MIN_ACCOUNT_AGE_HOURS", 0)
+	conf.trialRequiredChannel = strings.TrimSpace(os.Getenv("TRIAL_REQUIRED_CHANNEL"))
+	conf.trialNewPanelUsersOnly = envBool("TRIAL_NEW_PANEL_USERS_ONLY")
 
 	conf.enableAutoPayment = envBool("ENABLE_AUTO_PAYMENT")
 
@@ -303,6 +581,27 @@ func InitConfig() {
 	}()
 
 	conf.remnawaveToken = mustEnv("REMNAWAVE_TOKEN")
+	conf.remnawaveTimeout = time.Duration(envIntDefault("REMNAWAVE_TIMEOUT_SECONDS", 10)) * time.Second
+	conf.remnawaveRetries = envIntDefault("REMNAWAVE_RETRIES", 2)
+	conf.remnawaveBreakerFailures = envIntDefault("REMNAWAVE_BREAKER_FAILURES", 5)
+	conf.remnawaveBreakerCooldown = time.Duration(envIntDefault("REMNAWAVE_BREAKER_COOLDOWN_SECONDS", 30)) * time.Second
+	conf.remnawaveWebhookSecret = os.Getenv("REMNAWAVE_WEBHOOK_SECRET")
+	conf.remnawaveWebhookPath = os.Getenv("REMNAWAVE_WEBHOOK_PATH")
+	if conf.remnawaveWebhookPath == "" {
+		conf.remnawaveWebhookPath = "/remnawave/webhook"
+	}
+	if !strings.HasPrefix(conf.remnawaveWebhookPath, "/") {
+		panic("REMNAWAVE_WEBHOOK_PATH .env variable must start with /")
+	}
+	conf.provisioningMaxAttempts = envIntDefault("PROVISIONING_MAX_ATTEMPTS", 10)
+	if conf.provisioningMaxAttempts < 1 {
+		panic("PROVISIONING_MAX_ATTEMPTS .env variable must be a positive number")
+	}
+	conf.maxSubscriptionsPerCustomer = envIntDefault("MAX_SUBSCRIPTIONS_PER_CUSTOMER", 1)
+	if conf.maxSubscriptionsPerCustomer < 1 {
+		panic("MAX_SUBSCRIPTIONS_PER_CUSTOMER .env variable must be a positive number")
+	}
+	conf.syncSchedule = strings.TrimSpace(os.Getenv("SYNC_SCHEDULE"))
 
 	conf.databaseURL = mustEnv("DATABASE_URL")
 
@@ -315,33 +614,92 @@ func InitConfig() {
 	conf.trafficLimit = mustEnvInt("TRAFFIC_LIMIT")
 	conf.referralDays = envIntDefault("REFERRAL_DAYS", 0)
 	conf.referralBonus = envIntDefault("REFERRAL_BONUS", 150)
+	conf.referralMode = func() string {
+		v := os.Getenv("REFERRAL_MODE")
+		switch v {
+		case "":
+			return "fixed"
+		case "fixed", "percent", "days":
+			return v
+		default:
+			panic("REFERRAL_MODE .env variable must be one of 'fixed', 'percent' or 'days'")
+		}
+	}()
+	conf.referralPercent = envIntDefault("REFERRAL_PERCENT", 0)
+	conf.referralPayments = envIntDefault("REFERRAL_PAYMENTS", 1)
+	conf.referralRefereeBonus = envIntDefault("REFERRAL_REFEREE_BONUS", 0)
+	conf.referralRefereeDays = envIntDefault("REFERRAL_REFEREE_DAYS", 0)
+	conf.referralSecondLevelPercent = envIntDefault("REFERRAL_SECOND_LEVEL_PERCENT", 0)
+	conf.referralHoldDays = envIntDefault("REFERRAL_HOLD_DAYS", 0)
+	conf.referralMinPayment = envIntDefault("REFERRAL_MIN_PAYMENT", 0)
+	conf.referralMinRefereeAgeHours = envIntDefault("REFERRAL_MIN_REFEREE_AGE_HOURS", 0)
+	conf.referralDailyCap = envIntDefault("REFERRAL_DAILY_CAP", 0)
+	conf.referralWithdrawalMin = envIntDefault("REFERRAL_WITHDRAWAL_MIN", 0)
 
 	conf.serverStatusURL = os.Getenv("SERVER_STATUS_URL")
 	conf.supportURL = os.Getenv("SUPPORT_URL")
+	if v := os.Getenv("SUPPORT_CHAT_ID"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			panic("SUPPORT_CHAT_ID .env variable must be the id of a supergroup")
+		}
+		conf.supportChatID = id
+	}
+	conf.supportLanguage = os.Getenv("SUPPORT_LANGUAGE")
+	if conf.supportLanguage == "" {
+		conf.supportLanguage = "en"
+	}
 	conf.feedbackURL = os.Getenv("FEEDBACK_URL")
 	conf.channelURL = os.Getenv("CHANNEL_URL")
+	conf.channelID = strings.TrimSpace(os.Getenv("CHANNEL_ID"))
+	conf.channelGate = func() string {
+		v := os.Getenv("CHANNEL_GATE")
+		switch v {
+		case "":
+			return "off"
+		case "off", "trial", "all":
+			return v
+		default:
+			panic("CHANNEL_GATE .env variable must be one of 'off', 'trial' or 'all'")
+		}
+	}()
+	if conf.channelGate != "off" && conf.channelID == "" {
+		panic("CHANNEL_ID .env variable must be set when CHANNEL_GATE is enabled")
+	}
+	conf.channelGateCacheTTL = time.Duration(envIntDefault("CHANNEL_GATE_CACHE_SECONDS", 300)) * time.Second
+	conf.rateLimitCheapPerMinute = envIntDefault("RATE_LIMIT_CHEAP_PER_MINUTE", 60)
+	conf.rateLimitCheapBurst = envIntDefault("RATE_LIMIT_CHEAP_BURST", 10)
+	conf.rateLimitExpensivePerMinute = envIntDefault("RATE_LIMIT_EXPENSIVE_PER_MINUTE", 10)
+	conf.rateLimitExpensiveBurst = envIntDefault("RATE_LIMIT_EXPENSIVE_BURST", 3)
 	conf.tosURL = os.Getenv("TOS_URL")
-
-	conf.inboundUUIDs = func() map[uuid.UUID]uuid.UUID {
-		v := os.Getenv("INBOUND_UUIDS")
-		if v != "" {
-			uuids := strings.Split(v, ",")
-			var inboundsMap = make(map[uuid.UUID]uuid.UUID)
-			for _, value := range uuids {
-				uuid, err := uuid.Parse(value)
-				if err != nil {
-					panic(err)
-				}
-				inboundsMap[uuid] = uuid
-			}
-			slog.Info("Loaded inbound UUIDs", "uuids", uuids)
-			return inboundsMap
-		} else {
-			slog.Info("No inbound UUIDs specified, all will be used")
-			return map[uuid.UUID]uuid.UUID{}
+	conf.statsReportHour = envIntDefault("STATS_REPORT_HOUR", 9)
+	if conf.statsReportHour > 23 {
+		panic("STATS_REPORT_HOUR .env variable must be an hour from 0 to 23, or negative to disable the report")
+	}
+	conf.translationsDir = strings.TrimSpace(os.Getenv("TRANSLATIONS_DIR"))
+	conf.tracingExporter = func() string {
+		v := os.Getenv("TRACING_EXPORTER")
+		switch v {
+		case "":
+			return "none"
+		case "none", "otlp", "stdout":
+			return v
+		default:
+			panic("TRACING_EXPORTER .env variable must be one of 'none', 'otlp' or 'stdout'")
 		}
 	}()
 
+	conf.inboundUUIDs = parseUUIDs("INBOUND_UUIDS")
+	if len(conf.inboundUUIDs) == 0 {
+		slog.Info("No inbound UUIDs specified, all will be used")
+	}
+	conf.trialInboundUUIDs = parseUUIDs("TRIAL_INBOUND_UUIDS")
+	conf.planInboundUUIDs = map[int]map[uuid.UUID]uuid.UUID{
+		1: parseUUIDs("INBOUND_UUIDS_1"),
+		3: parseUUIDs("INBOUND_UUIDS_3"),
+		6: parseUUIDs("INBOUND_UUIDS_6"),
+	}
+
 	conf.tributeWebhookUrl = os.Getenv("TRIBUTE_WEBHOOK_URL")
 	if conf.tributeWebhookUrl != "" {
 		conf.tributeAPIKey = mustEnv("TRIBUTE_API_KEY")
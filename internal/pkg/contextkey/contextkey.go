@@ -7,7 +7,10 @@ import (
 
 type contextKey string
 
-const Username contextKey = "username"
+const (
+	Username contextKey = "username"
+	Language contextKey = "language"
+)
 
 func CleanUsername(username string) string {
 	return strings.TrimPrefix(strings.TrimSpace(username), "@")
@@ -20,3 +23,15 @@ func UsernameFromContext(ctx context.Context) string {
 	}
 	return v
 }
+
+// WithLanguage stores the language the customer is served in.
+func WithLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, Language, lang)
+}
+
+// LanguageFromContext returns the language stored by WithLanguage and whether
+// it was set.
+func LanguageFromContext(ctx context.Context) (string, bool) {
+	v, ok := ctx.Value(Language).(string)
+	return v, ok
+}
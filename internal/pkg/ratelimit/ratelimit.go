@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// pruneEvery is how many calls pass between sweeps of refilled buckets.
+const pruneEvery = 1024
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a token bucket per key. Each key may spend burst tokens at once
+// and gets rate tokens back per second.
+type Limiter struct {
+	rate   float64
+	burst  float64
+	mu     sync.Mutex
+	keys   map[int64]*bucket
+	calls  int
+	nowFor func() time.Time
+}
+
+// New returns a limiter refilling perMinute tokens a minute. A non-positive
+// perMinute or burst disables limiting.
+func New(perMinute, burst int) *Limiter {
+	return &Limiter{
+		rate:   float64(perMinute) / 60,
+		burst:  float64(burst),
+		keys:   make(map[int64]*bucket),
+		nowFor: time.Now,
+	}
+}
+
+// WithClock replaces the time source, for tests.
+func (l *Limiter) WithClock(now func() time.Time) *Limiter {
+	l.nowFor = now
+	return l
+}
+
+// Allow takes a token for key and reports whether one was available.
+func (l *Limiter) Allow(key int64) bool {
+	if l == nil || l.rate <= 0 || l.burst <= 0 {
+		return true
+	}
+	now := l.nowFor()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.calls++
+	if l.calls%pruneEvery == 0 {
+		l.prune(now)
+	}
+
+	b, ok := l.keys[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.keys[key] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// prune drops buckets that are full again, they behave like new ones.
+func (l *Limiter) prune(now time.Time) {
+	for key, b := range l.keys {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.keys, key)
+		}
+	}
+}
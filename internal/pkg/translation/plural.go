@@ -0,0 +1,44 @@
+package translation
+
+// Plural categories of the CLDR plural rules.
+const (
+	PluralOne   = "one"
+	PluralFew   = "few"
+	PluralMany  = "many"
+	PluralOther = "other"
+)
+
+// PluralCategory returns the CLDR plural category of the integer n in lang.
+// Languages without a rule here use the English one.
+func PluralCategory(lang string, n int64) string {
+	if n < 0 {
+		n = -n
+	}
+	switch lang {
+	case "ru", "uk", "be":
+		mod10, mod100 := n%10, n%100
+		switch {
+		case mod10 == 1 && mod100 != 11:
+			return PluralOne
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return PluralFew
+		default:
+			return PluralMany
+		}
+	default:
+		if n == 1 {
+			return PluralOne
+		}
+		return PluralOther
+	}
+}
+
+// pluralCategories lists the categories a plural in lang must define.
+func pluralCategories(lang string) []string {
+	switch lang {
+	case "ru", "uk", "be":
+		return []string{PluralOne, PluralFew, PluralMany}
+	default:
+		return []string{PluralOne, PluralOther}
+	}
+}
@@ -0,0 +1,194 @@
+package translation
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// Args are the values of named placeholders.
+type Args map[string]any
+
+// Raw is inserted into a template without HTML escaping.
+type Raw string
+
+// Templates use named placeholders and ICU-style plurals:
+//
+//	Balance: {balance} ₽
+//	+{days, plural, one {# day} other {# days}}
+//
+// Inside a plural branch "#" stands for the number. Values are HTML escaped
+// unless passed as Raw.
+
+// render fills tmpl with args, choosing plural forms by the rules of lang.
+func render(lang, tmpl string, args Args) (string, error) {
+	var out strings.Builder
+	for i := 0; i < len(tmpl); i++ {
+		switch tmpl[i] {
+		case '{':
+			end, err := closingBrace(tmpl, i)
+			if err != nil {
+				return "", err
+			}
+			s, err := renderPlaceholder(lang, tmpl[i+1:end], args)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(s)
+			i = end
+		case '}':
+			return "", fmt.Errorf("unexpected } at %d", i)
+		default:
+			out.WriteByte(tmpl[i])
+		}
+	}
+	return out.String(), nil
+}
+
+func renderPlaceholder(lang, inner string, args Args) (string, error) {
+	name, rest, isPlural := strings.Cut(inner, ",")
+	name = strings.TrimSpace(name)
+	value, ok := args[name]
+	if !ok {
+		return "", fmt.Errorf("no value for {%s}", name)
+	}
+	if !isPlural {
+		return formatValue(value), nil
+	}
+
+	branches, err := parsePlural(rest)
+	if err != nil {
+		return "", fmt.Errorf("{%s}: %w", name, err)
+	}
+	n, ok := toInt64(value)
+	if !ok {
+		return "", fmt.Errorf("{%s}: plural value %v is not an integer", name, value)
+	}
+	branch, ok := branches[PluralCategory(lang, n)]
+	if !ok {
+		branch, ok = branches[PluralOther]
+	}
+	if !ok {
+		return "", fmt.Errorf("{%s}: no %q form", name, PluralCategory(lang, n))
+	}
+	return render(lang, strings.ReplaceAll(branch, "#", strconv.FormatInt(n, 10)), args)
+}
+
+// parsePlural parses " plural, one {...} few {...}" into branches by category.
+func parsePlural(s string) (map[string]string, error) {
+	kind, body, _ := strings.Cut(s, ",")
+	if strings.TrimSpace(kind) != "plural" {
+		return nil, fmt.Errorf("unknown placeholder type %q", strings.TrimSpace(kind))
+	}
+	branches := make(map[string]string)
+	for {
+		body = strings.TrimSpace(body)
+		if body == "" {
+			break
+		}
+		open := strings.IndexByte(body, '{')
+		if open <= 0 {
+			return nil, fmt.Errorf("expected category {text} in %q", body)
+		}
+		end, err := closingBrace(body, open)
+		if err != nil {
+			return nil, err
+		}
+		branches[strings.TrimSpace(body[:open])] = body[open+1 : end]
+		body = body[end+1:]
+	}
+	if len(branches) == 0 {
+		return nil, fmt.Errorf("plural without forms")
+	}
+	return branches, nil
+}
+
+// closingBrace returns the index of the } matching the { at open.
+func closingBrace(s string, open int) (int, error) {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unclosed { at %d", open)
+}
+
+func formatValue(v any) string {
+	switch val := v.(type) {
+	case Raw:
+		return string(val)
+	case string:
+		return html.EscapeString(val)
+	case fmt.Stringer:
+		return html.EscapeString(val.String())
+	default:
+		return html.EscapeString(fmt.Sprint(val))
+	}
+}
+
+func toInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		return int64(n), true //nolint:gosec // counts are small
+	case uint32:
+		return int64(n), true
+	case float64:
+		return int64(n), n == float64(int64(n))
+	default:
+		return 0, false
+	}
+}
+
+// placeholders returns the names used by tmpl with the plural categories
+// defined for each plural placeholder.
+func placeholders(tmpl string) (names map[string]struct{}, plurals map[string][]string, err error) {
+	names = make(map[string]struct{})
+	plurals = make(map[string][]string)
+	err = collectPlaceholders(tmpl, names, plurals)
+	return names, plurals, err
+}
+
+func collectPlaceholders(tmpl string, names map[string]struct{}, plurals map[string][]string) error {
+	for i := 0; i < len(tmpl); i++ {
+		switch tmpl[i] {
+		case '{':
+			end, err := closingBrace(tmpl, i)
+			if err != nil {
+				return err
+			}
+			name, rest, isPlural := strings.Cut(tmpl[i+1:end], ",")
+			name = strings.TrimSpace(name)
+			names[name] = struct{}{}
+			if isPlural {
+				branches, err := parsePlural(rest)
+				if err != nil {
+					return fmt.Errorf("{%s}: %w", name, err)
+				}
+				for category, branch := range branches {
+					plurals[name] = append(plurals[name], category)
+					if err := collectPlaceholders(branch, names, plurals); err != nil {
+						return err
+					}
+				}
+			}
+			i = end
+		case '}':
+			return fmt.Errorf("unexpected } at %d", i)
+		}
+	}
+	return nil
+}
@@ -3,8 +3,10 @@ package translation
 import (
 	"fmt"
 	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 
@@ -17,7 +19,9 @@ type Translation map[string]string
 type Manager struct {
 	translations    map[string]Translation
 	defaultLanguage string
-	mu              sync.RWMutex
+	// overridesDir holds operator files merged over the embedded ones.
+	overridesDir string
+	mu           sync.RWMutex
 }
 
 var (
@@ -27,22 +31,102 @@ var (
 
 func GetInstance() *Manager {
 	once.Do(func() {
-		instance = &Manager{
-			translations:    make(map[string]Translation),
-			defaultLanguage: "en",
-		}
+		instance = NewManager("en")
 	})
 	return instance
 }
 
+// NewManager returns an empty manager, most code uses the shared GetInstance.
+func NewManager(defaultLanguage string) *Manager {
+	return &Manager{
+		translations:    make(map[string]Translation),
+		defaultLanguage: defaultLanguage,
+	}
+}
+
 func (tm *Manager) InitFromFS(fsys fs.FS, dir string) error {
+	loaded, err := readFS(fsys, dir)
+	if err != nil {
+		return err
+	}
+
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
+	for langCode, translation := range loaded {
+		tm.translations[langCode] = translation
+	}
+
+	if _, exists := tm.translations[tm.defaultLanguage]; !exists {
+		return fmt.Errorf("default language %s translation not found", tm.defaultLanguage)
+	}
+
+	return nil
+}
+
+func (tm *Manager) InitTranslations(dir string) error {
+	return tm.InitFromFS(os.DirFS(dir), ".")
+}
+
+func (tm *Manager) InitDefaultTranslations() error {
+	return tm.InitFromFS(translations.FS, ".")
+}
+
+// InitWithOverrides loads the embedded translations and merges the files of
+// overridesDir over them key by key, a file for a new language adds it. An
+// empty overridesDir loads the embedded translations only. The directory is
+// remembered for Reload.
+func (tm *Manager) InitWithOverrides(overridesDir string) error {
+	tm.mu.Lock()
+	tm.overridesDir = overridesDir
+	tm.mu.Unlock()
+	return tm.Reload()
+}
+
+// Reload rebuilds the translations from the embedded files and the overrides
+// directory. The current translations stay in use when loading fails.
+func (tm *Manager) Reload() error {
+	tm.mu.RLock()
+	overridesDir := tm.overridesDir
+	tm.mu.RUnlock()
+
+	merged, err := readFS(translations.FS, ".")
+	if err != nil {
+		return err
+	}
+	if overridesDir != "" {
+		overrides, err := readFS(os.DirFS(overridesDir), ".")
+		if err != nil {
+			return err
+		}
+		for langCode, translation := range overrides {
+			base, exists := merged[langCode]
+			if !exists {
+				base = make(Translation, len(translation))
+				merged[langCode] = base
+			}
+			for key, text := range translation {
+				base[key] = text
+			}
+		}
+	}
+	if _, exists := merged[tm.defaultLanguage]; !exists {
+		return fmt.Errorf("default language %s translation not found", tm.defaultLanguage)
+	}
+
+	tm.mu.Lock()
+	tm.translations = merged
+	tm.mu.Unlock()
+	return nil
+}
+
+// readFS parses every <lang>.yml file of dir.
+func readFS(fsys fs.FS, dir string) (map[string]Translation, error) {
 	files, err := fs.ReadDir(fsys, dir)
 	if err != nil {
-		return fmt.Errorf("failed to read translation directory: %w", err)
+		return nil, fmt.Errorf("failed to read translation directory: %w", err)
 	}
 
+	loaded := make(map[string]Translation)
 	for _, file := range files {
 		if file.IsDir() || !strings.HasSuffix(file.Name(), ".yml") {
 			continue
@@ -53,47 +137,94 @@ func (tm *Manager) InitFromFS(fsys fs.FS, dir string) error {
 
 		content, err := fs.ReadFile(fsys, filePath)
 		if err != nil {
-			return fmt.Errorf("failed to read translation file %s: %w", file.Name(), err)
+			return nil, fmt.Errorf("failed to read translation file %s: %w", file.Name(), err)
 		}
 
 		var translation Translation
 		if err := yaml.Unmarshal(content, &translation); err != nil {
-			return fmt.Errorf("failed to parse translation file %s: %w", file.Name(), err)
+			return nil, fmt.Errorf("failed to parse translation file %s: %w", file.Name(), err)
 		}
 
-		tm.translations[langCode] = translation
-	}
-
-	if _, exists := tm.translations[tm.defaultLanguage]; !exists {
-		return fmt.Errorf("default language %s translation not found", tm.defaultLanguage)
+		loaded[langCode] = translation
 	}
+	return loaded, nil
+}
 
-	return nil
+func (tm *Manager) GetText(langCode, key string) string {
+	text, _ := tm.lookup(langCode, key)
+	return text
 }
 
-func (tm *Manager) InitTranslations(dir string) error {
-	return tm.InitFromFS(os.DirFS(dir), ".")
+// Format renders the template of key with named placeholders and plurals,
+// see render. A template that fails to render is logged and the default
+// language template is rendered instead, the key is returned when that fails
+// too.
+func (tm *Manager) Format(langCode, key string, args Args) string {
+	text, lang := tm.lookup(langCode, key)
+	out, err := renderArgs(lang, text, args)
+	if err == nil {
+		return out
+	}
+	slog.Error("render translation", "lang", lang, "key", key, "err", err)
+	if lang != tm.defaultLanguage {
+		text, lang = tm.lookup(tm.defaultLanguage, key)
+		if out, err = renderArgs(lang, text, args); err == nil {
+			return out
+		}
+		slog.Error("render translation", "lang", lang, "key", key, "err", err)
+	}
+	return key
 }
 
-func (tm *Manager) InitDefaultTranslations() error {
-	return tm.InitFromFS(translations.FS, ".")
+// renderArgs is render that also rejects the %s verbs of templates written
+// before named placeholders, which would otherwise reach users as is.
+func renderArgs(lang, text string, args Args) (string, error) {
+	if len(args) > 0 {
+		if verbs := formatVerbs(text); len(verbs) > 0 {
+			return "", fmt.Errorf("format verbs %v, use named placeholders", verbs)
+		}
+	}
+	return render(lang, text, args)
 }
 
-func (tm *Manager) GetText(langCode, key string) string {
+// lookup returns the text of key and the language it was found in.
+func (tm *Manager) lookup(langCode, key string) (string, string) {
 	tm.mu.RLock()
 	defer tm.mu.RUnlock()
 
 	if translation, exists := tm.translations[langCode]; exists {
 		if text, exists := translation[key]; exists && text != "" {
-			return text
+			return text, langCode
 		}
 	}
 
 	if translation, exists := tm.translations[tm.defaultLanguage]; exists {
 		if text, exists := translation[key]; exists {
-			return text
+			return text, tm.defaultLanguage
 		}
 	}
 
-	return key
+	return key, tm.defaultLanguage
+}
+
+// Languages returns the codes of the loaded translations in alphabetical order.
+func (tm *Manager) Languages() []string {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	langs := make([]string, 0, len(tm.translations))
+	for lang := range tm.translations {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Has reports whether a translation for langCode is loaded.
+func (tm *Manager) Has(langCode string) bool {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	_, exists := tm.translations[langCode]
+	return exists
 }
@@ -0,0 +1,119 @@
+package translation
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Issue is a problem found in a translation file.
+type Issue struct {
+	Lang    string
+	Key     string
+	Problem string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s.yml: %s: %s", i.Lang, i.Key, i.Problem)
+}
+
+var formatVerb = regexp.MustCompile(`%[-+#0]*[0-9]*(?:\.[0-9]+)?[a-zA-Z]`)
+
+// Validate checks the loaded translations against the default language.
+func (tm *Manager) Validate() []Issue {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	return validate(tm.translations, tm.defaultLanguage)
+}
+
+// ValidateDir loads every .yml file of dir and validates them.
+func ValidateDir(dir string) ([]Issue, error) {
+	tm := NewManager("en")
+	if err := tm.InitTranslations(dir); err != nil {
+		return nil, err
+	}
+	return tm.Validate(), nil
+}
+
+// validate reports keys missing from or extra to each language compared to
+// def, templates that don't parse, plurals without the forms the language
+// needs and placeholders or fmt verbs that differ from def.
+func validate(translations map[string]Translation, def string) []Issue {
+	var issues []Issue
+	base := translations[def]
+
+	for lang, tr := range translations {
+		for key, text := range tr {
+			names, plurals, err := placeholders(text)
+			if err != nil {
+				issues = append(issues, Issue{lang, key, "template error: " + err.Error()})
+				continue
+			}
+			for name, categories := range plurals {
+				for _, required := range pluralCategories(lang) {
+					if !slices.Contains(categories, required) && !slices.Contains(categories, PluralOther) {
+						issues = append(issues, Issue{lang, key, fmt.Sprintf("plural {%s} has no %q form", name, required)})
+					}
+				}
+			}
+			if lang == def {
+				continue
+			}
+			baseText, ok := base[key]
+			if !ok {
+				issues = append(issues, Issue{lang, key, "extra key, not in " + def})
+				continue
+			}
+			baseNames, _, err := placeholders(baseText)
+			if err == nil && !sameKeys(names, baseNames) {
+				issues = append(issues, Issue{lang, key, fmt.Sprintf("placeholders %v, %s has %v", sortedKeys(names), def, sortedKeys(baseNames))})
+			}
+			if verbs, baseVerbs := formatVerbs(text), formatVerbs(baseText); !slices.Equal(verbs, baseVerbs) {
+				issues = append(issues, Issue{lang, key, fmt.Sprintf("format verbs %v, %s has %v", verbs, def, baseVerbs)})
+			}
+		}
+		for key := range base {
+			if _, ok := tr[key]; !ok {
+				issues = append(issues, Issue{lang, key, "missing key"})
+			}
+		}
+	}
+
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].Lang != issues[j].Lang {
+			return issues[i].Lang < issues[j].Lang
+		}
+		if issues[i].Key != issues[j].Key {
+			return issues[i].Key < issues[j].Key
+		}
+		return issues[i].Problem < issues[j].Problem
+	})
+	return issues
+}
+
+func formatVerbs(text string) []string {
+	return formatVerb.FindAllString(strings.ReplaceAll(text, "%%", ""), -1)
+}
+
+func sameKeys(a, b map[string]struct{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
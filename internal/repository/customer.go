@@ -12,8 +12,9 @@ type CustomerRepository interface {
 	Create(ctx context.Context, c *customer.Customer) (*customer.Customer, error)
 	UpdateFields(ctx context.Context, id int64, updates map[string]interface{}) error
 	FindByTelegramIds(ctx context.Context, telegramIDs []int64) ([]customer.Customer, error)
-	DeleteByNotInTelegramIds(ctx context.Context, telegramIDs []int64) error
 	CreateBatch(ctx context.Context, customers []customer.Customer) error
 	UpdateBatch(ctx context.Context, customers []customer.Customer) error
+	FindMissingFromPanel(ctx context.Context, telegramIDs []int64) ([]int64, error)
+	MarkMissingFromPanel(ctx context.Context, telegramIDs []int64) error
 	FindByExpirationRange(ctx context.Context, startDate, endDate time.Time) (*[]customer.Customer, error)
 }
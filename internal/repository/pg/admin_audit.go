@@ -0,0 +1,43 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// AdminAudit records an action an admin took against a user.
+type AdminAudit struct {
+	ID        int64     `db:"id"`
+	AdminID   int64     `db:"admin_id"`
+	Action    string    `db:"action"`
+	TargetID  int64     `db:"target_id"`
+	Details   string    `db:"details"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type AdminAuditRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewAdminAuditRepository(pool *pgxpool.Pool) *AdminAuditRepository {
+	return &AdminAuditRepository{pool: pool}
+}
+
+func (r *AdminAuditRepository) Create(ctx context.Context, a *AdminAudit) error {
+	sql, args, err := sq.Insert("admin_audit").
+		Columns("admin_id", "action", "target_id", "details").
+		Values(a.AdminID, a.Action, a.TargetID, a.Details).
+		Suffix("RETURNING id, created_at").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert admin_audit: %w", err)
+	}
+	if err := r.pool.QueryRow(ctx, sql, args...).Scan(&a.ID, &a.CreatedAt); err != nil {
+		return fmt.Errorf("failed to insert admin_audit: %w", err)
+	}
+	return nil
+}
@@ -29,11 +29,12 @@ func NewCustomerRepository(pool *pgxpool.Pool) *CustomerRepository {
 type Customer = domain.Customer
 
 func (cr *CustomerRepository) FindByExpirationRange(ctx context.Context, startDate, endDate time.Time) (*[]Customer, error) {
-	buildSelect := sq.Select("id", "telegram_id", "expire_at", "created_at", "subscription_link", "language", "balance").
+	buildSelect := sq.Select("id", "telegram_id", "expire_at", "created_at", "subscription_link", "language", "language_chosen", "balance", "unreachable_at", "missing_from_panel_at").
 		From("customer").
 		Where(
 			sq.And{
 				sq.NotEq{"expire_at": nil},
+				sq.Eq{"unreachable_at": nil},
 				sq.GtOrEq{"expire_at": startDate},
 				sq.LtOrEq{"expire_at": endDate},
 			},
@@ -61,7 +62,10 @@ func (cr *CustomerRepository) FindByExpirationRange(ctx context.Context, startDa
 			&customer.CreatedAt,
 			&customer.SubscriptionLink,
 			&customer.Language,
+			&customer.LanguageChosen,
 			&customer.Balance,
+			&customer.UnreachableAt,
+			&customer.MissingFromPanelAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan customer row: %w", err)
@@ -77,7 +81,7 @@ func (cr *CustomerRepository) FindByExpirationRange(ctx context.Context, startDa
 }
 
 func (cr *CustomerRepository) FindById(ctx context.Context, id int64) (*Customer, error) {
-	buildSelect := sq.Select("id", "telegram_id", "expire_at", "created_at", "subscription_link", "language", "balance").
+	buildSelect := sq.Select("id", "telegram_id", "expire_at", "created_at", "subscription_link", "language", "language_chosen", "balance", "unreachable_at", "missing_from_panel_at").
 		From("customer").
 		Where(sq.Eq{"id": id}).
 		PlaceholderFormat(sq.Dollar)
@@ -96,7 +100,10 @@ func (cr *CustomerRepository) FindById(ctx context.Context, id int64) (*Customer
 		&customer.CreatedAt,
 		&customer.SubscriptionLink,
 		&customer.Language,
+		&customer.LanguageChosen,
 		&customer.Balance,
+		&customer.UnreachableAt,
+		&customer.MissingFromPanelAt,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -108,7 +115,7 @@ func (cr *CustomerRepository) FindById(ctx context.Context, id int64) (*Customer
 }
 
 func (cr *CustomerRepository) FindByTelegramId(ctx context.Context, telegramId int64) (*Customer, error) {
-	buildSelect := sq.Select("id", "telegram_id", "expire_at", "created_at", "subscription_link", "language", "balance").
+	buildSelect := sq.Select("id", "telegram_id", "expire_at", "created_at", "subscription_link", "language", "language_chosen", "balance", "unreachable_at", "missing_from_panel_at").
 		From("customer").
 		Where(sq.Eq{"telegram_id": telegramId}).
 		PlaceholderFormat(sq.Dollar)
@@ -127,7 +134,10 @@ func (cr *CustomerRepository) FindByTelegramId(ctx context.Context, telegramId i
 		&customer.CreatedAt,
 		&customer.SubscriptionLink,
 		&customer.Language,
+		&customer.LanguageChosen,
 		&customer.Balance,
+		&customer.UnreachableAt,
+		&customer.MissingFromPanelAt,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -140,9 +150,9 @@ func (cr *CustomerRepository) FindByTelegramId(ctx context.Context, telegramId i
 
 func (cr *CustomerRepository) Create(ctx context.Context, customer *Customer) (*Customer, error) {
 	buildInsert := sq.Insert("customer").
-		Columns("telegram_id", "expire_at", "language", "balance").
+		Columns("telegram_id", "expire_at", "language", "language_chosen", "balance").
 		PlaceholderFormat(sq.Dollar).
-		Values(customer.TelegramID, customer.ExpireAt, customer.Language, customer.Balance).
+		Values(customer.TelegramID, customer.ExpireAt, customer.Language, customer.LanguageChosen, customer.Balance).
 		Suffix("RETURNING id, created_at")
 	sqlStr, args, err := buildInsert.ToSql()
 	if err != nil {
@@ -203,7 +213,7 @@ func (cr *CustomerRepository) UpdateFields(ctx context.Context, id int64, update
 }
 
 func (cr *CustomerRepository) FindByTelegramIds(ctx context.Context, telegramIDs []int64) ([]Customer, error) {
-	buildSelect := sq.Select("id", "telegram_id", "expire_at", "created_at", "subscription_link", "language", "balance").
+	buildSelect := sq.Select("id", "telegram_id", "expire_at", "created_at", "subscription_link", "language", "language_chosen", "balance", "unreachable_at", "missing_from_panel_at").
 		From("customer").
 		Where(sq.Eq{"telegram_id": telegramIDs}).
 		PlaceholderFormat(sq.Dollar)
@@ -229,7 +239,10 @@ func (cr *CustomerRepository) FindByTelegramIds(ctx context.Context, telegramIDs
 			&customer.CreatedAt,
 			&customer.SubscriptionLink,
 			&customer.Language,
+			&customer.LanguageChosen,
 			&customer.Balance,
+			&customer.UnreachableAt,
+			&customer.MissingFromPanelAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan customer row: %w", err)
@@ -243,149 +256,135 @@ func (cr *CustomerRepository) FindByTelegramIds(ctx context.Context, telegramIDs
 	return customers, nil
 }
 
+// CreateBatch inserts the customers, leaving language and balance to their
+// defaults. Customers that already exist are skipped.
 func (cr *CustomerRepository) CreateBatch(ctx context.Context, customers []Customer) error {
 	if len(customers) == 0 {
 		return nil
 	}
 	builder := sq.Insert("customer").
-		Columns("telegram_id", "expire_at", "language", "subscription_link", "balance").
+		Columns("telegram_id", "expire_at", "subscription_link").
+		Suffix("ON CONFLICT (telegram_id) DO NOTHING").
 		PlaceholderFormat(sq.Dollar)
 	for _, cust := range customers {
-		builder = builder.Values(cust.TelegramID, cust.ExpireAt, cust.Language, cust.SubscriptionLink, cust.Balance)
+		builder = builder.Values(cust.TelegramID, cust.ExpireAt, cust.SubscriptionLink)
 	}
 	sqlStr, args, err := builder.ToSql()
 	if err != nil {
 		return fmt.Errorf("failed to build batch insert query: %w", err)
 	}
 
-	tx, err := cr.pool.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback(ctx) //nolint:errcheck // ignore rollback error
-
-	_, err = tx.Exec(ctx, sqlStr, args...)
+	_, err = cr.pool.Exec(ctx, sqlStr, args...)
 	if err != nil {
 		return fmt.Errorf("failed to execute batch insert: %w", err)
 	}
-
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
 	return nil
 }
 
+// UpdateBatch sets the subscription of the customers from the panel and clears
+// their missing mark. Language, balance and everything else are left alone.
 func (cr *CustomerRepository) UpdateBatch(ctx context.Context, customers []Customer) error {
 	if len(customers) == 0 {
 		return nil
 	}
-	query := "UPDATE customer SET expire_at = c.expire_at, language = c.language, subscription_link = c.subscription_link, balance = c.balance FROM (VALUES "
+	query := "UPDATE customer SET expire_at = c.expire_at, subscription_link = c.subscription_link, missing_from_panel_at = NULL FROM (VALUES "
 	var args []interface{}
 	for i, cust := range customers {
 		if i > 0 {
 			query += ", "
 		}
-		query += fmt.Sprintf("($%d::bigint, $%d::timestamp, $%d::text, $%d::text, $%d::numeric)", i*5+1, i*5+2, i*5+3, i*5+4, i*5+5)
-		args = append(args, cust.TelegramID, cust.ExpireAt, cust.Language, cust.SubscriptionLink, cust.Balance)
-	}
-	query += ") AS c(telegram_id, expire_at, language, subscription_link, balance) WHERE customer.telegram_id = c.telegram_id"
-
-	tx, err := cr.pool.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		query += fmt.Sprintf("($%d::bigint, $%d::timestamptz, $%d::text)", i*3+1, i*3+2, i*3+3)
+		args = append(args, cust.TelegramID, cust.ExpireAt, cust.SubscriptionLink)
 	}
-	defer tx.Rollback(ctx) //nolint:errcheck // ignore rollback error
+	query += ") AS c(telegram_id, expire_at, subscription_link) WHERE customer.telegram_id = c.telegram_id"
 
-	_, err = tx.Exec(ctx, query, args...)
+	_, err := cr.pool.Exec(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to execute batch update: %w", err)
 	}
-
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
 	return nil
 }
 
-func (cr *CustomerRepository) DeleteByNotInTelegramIds(ctx context.Context, telegramIDs []int64) error {
-	var buildDeleteUsers sq.DeleteBuilder
-	var buildDeletePromo sq.DeleteBuilder
-	var buildDeletePromoUsage sq.DeleteBuilder
-
-	if len(telegramIDs) == 0 {
-		buildDeleteUsers = sq.Delete("customer")
-		buildDeletePromo = sq.Delete("promocode")
-		buildDeletePromoUsage = sq.Delete("promocode_usage")
-	} else {
-		normalPromo := sq.Select("id").
-			From("promocode").
-			PlaceholderFormat(sq.Dollar).
-			Where(sq.Eq{"created_by": telegramIDs})
-
-		sqlNormalPromoStr, args, err := normalPromo.ToSql()
-		if err != nil {
-			return fmt.Errorf("failed to build select query: %w", err)
-		}
-
-		promoIDs := make([]int, 0)
-		promoRows, err := cr.pool.Query(ctx, sqlNormalPromoStr, args...)
-		if err != nil {
-			return fmt.Errorf("failed to collect promo id from select query: %w", err)
-		}
-		for promoRows.Next() {
-			var id int
-			if err := promoRows.Scan(&id); err != nil {
-				return fmt.Errorf("scan promo id: %w", err)
-			}
-			promoIDs = append(promoIDs, id)
-		}
-
-		buildDeleteUsers = sq.Delete("customer").
-			PlaceholderFormat(sq.Dollar).
-			Where(sq.NotEq{"telegram_id": telegramIDs})
-		buildDeletePromo = sq.Delete("promocode").
-			PlaceholderFormat(sq.Dollar).
-			Where(sq.NotEq{"created_by": telegramIDs})
-
-		if len(promoIDs) == 0 {
-			buildDeletePromoUsage = sq.Delete("promocode_usage")
-		} else {
-			buildDeletePromoUsage = sq.Delete("promocode_usage").
-				PlaceholderFormat(sq.Dollar).
-				Where(sq.NotEq{"id": promoIDs})
-		}
-
+// FindMissingFromPanel returns the telegram ids of the customers with a
+// subscription whose telegram id isn't in telegramIDs, the ids of the panel users.
+func (cr *CustomerRepository) FindMissingFromPanel(ctx context.Context, telegramIDs []int64) ([]int64, error) {
+	buildSelect := sq.Select("telegram_id").
+		From("customer").
+		Where(sq.NotEq{"subscription_link": nil}).
+		OrderBy("telegram_id").
+		PlaceholderFormat(sq.Dollar)
+	if len(telegramIDs) > 0 {
+		buildSelect = buildSelect.Where(sq.Expr("NOT (telegram_id = ANY(?))", telegramIDs))
 	}
 
-	sqlStr, args, err := buildDeletePromoUsage.ToSql()
+	sqlStr, args, err := buildSelect.ToSql()
 	if err != nil {
-		return fmt.Errorf("failed to build delete promo_usage query: %w", err)
+		return nil, fmt.Errorf("failed to build select query: %w", err)
 	}
-
-	_, err = cr.pool.Exec(ctx, sqlStr, args...)
+	rows, err := cr.pool.Query(ctx, sqlStr, args...)
 	if err != nil {
-		return fmt.Errorf("failed to delete promo_usage: %w", err)
+		return nil, fmt.Errorf("failed to query customers missing from panel: %w", err)
 	}
+	defer rows.Close()
 
-	sqlStr, args, err = buildDeletePromo.ToSql()
-	if err != nil {
-		return fmt.Errorf("failed to build delete promo query: %w", err)
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan telegram id: %w", err)
+		}
+		ids = append(ids, id)
 	}
-
-	_, err = cr.pool.Exec(ctx, sqlStr, args...)
-	if err != nil {
-		return fmt.Errorf("failed to delete promo: %w", err)
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over customer rows: %w", err)
 	}
+	return ids, nil
+}
 
-	sqlStr, args, err = buildDeleteUsers.ToSql()
+// MarkMissingFromPanel sets the missing mark of the customers that don't have
+// it yet, keeping the time they went missing first.
+func (cr *CustomerRepository) MarkMissingFromPanel(ctx context.Context, telegramIDs []int64) error {
+	if len(telegramIDs) == 0 {
+		return nil
+	}
+	sqlStr, args, err := sq.Update("customer").
+		Set("missing_from_panel_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"telegram_id": telegramIDs, "missing_from_panel_at": nil}).
+		PlaceholderFormat(sq.Dollar).ToSql()
 	if err != nil {
-		return fmt.Errorf("failed to build delete customer query: %w", err)
+		return fmt.Errorf("failed to build update query: %w", err)
 	}
+	if _, err := cr.pool.Exec(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("failed to mark customers missing from panel: %w", err)
+	}
+	return nil
+}
 
-	_, err = cr.pool.Exec(ctx, sqlStr, args...)
+// SpendBalance debits amount from the customer balance and returns the new
+// balance, ErrInsufficientBalance when the balance doesn't cover it.
+func (cr *CustomerRepository) SpendBalance(ctx context.Context, id int64, amount float64) (float64, error) {
+	return spendBalance(ctx, cr.pool, id, amount)
+}
+
+// spendBalance debits the referral money of the balance first, so that top-ups
+// never become withdrawable.
+func spendBalance(ctx context.Context, q rowQuerier, id int64, amount float64) (float64, error) {
+	sql, args, err := sq.Update("customer").
+		Set("balance", sq.Expr("balance - ?", amount)).
+		Set("referral_balance", sq.Expr("GREATEST(referral_balance - ?, 0)", amount)).
+		Where(sq.Eq{"id": id}).
+		Where(sq.GtOrEq{"balance": amount}).
+		Suffix("RETURNING balance").
+		PlaceholderFormat(sq.Dollar).ToSql()
 	if err != nil {
-		return fmt.Errorf("failed to delete customer: %w", err)
+		return 0, fmt.Errorf("failed to build update customer balance: %w", err)
 	}
-
-	return nil
+	var balance float64
+	if err := q.QueryRow(ctx, sql, args...).Scan(&balance); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrInsufficientBalance
+		}
+		return 0, fmt.Errorf("failed to update customer balance: %w", err)
+	}
+	return balance, nil
 }
@@ -0,0 +1,112 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// CustomerBlock is the blocked status stored on the customer row.
+type CustomerBlock struct {
+	TelegramID int64      `db:"telegram_id"`
+	Reason     string     `db:"blocked_reason"`
+	BlockedAt  time.Time  `db:"blocked_at"`
+	Until      *time.Time `db:"blocked_until"`
+	// Panel is set when the Remnawave user was disabled together with the block.
+	Panel    bool `db:"blocked_panel"`
+	Notified bool `db:"block_notified"`
+}
+
+// Expired reports whether a temporary block is over at now.
+func (b *CustomerBlock) Expired(now time.Time) bool {
+	return b.Until != nil && !now.Before(*b.Until)
+}
+
+type CustomerBlockRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewCustomerBlockRepository(pool *pgxpool.Pool) *CustomerBlockRepository {
+	return &CustomerBlockRepository{pool: pool}
+}
+
+// Find returns the block of the customer, including an expired one, or nil.
+func (r *CustomerBlockRepository) Find(ctx context.Context, telegramID int64) (*CustomerBlock, error) {
+	sql, args, err := sq.Select("telegram_id", "COALESCE(blocked_reason, '')", "blocked_at", "blocked_until", "blocked_panel", "block_notified").
+		From("customer").
+		Where(sq.And{sq.Eq{"telegram_id": telegramID}, sq.NotEq{"blocked_at": nil}}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select customer block: %w", err)
+	}
+	var b CustomerBlock
+	err = r.pool.QueryRow(ctx, sql, args...).Scan(&b.TelegramID, &b.Reason, &b.BlockedAt, &b.Until, &b.Panel, &b.Notified)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query customer block: %w", err)
+	}
+	return &b, nil
+}
+
+// Block sets or replaces the block of the customer. It returns false when the
+// customer does not exist.
+func (r *CustomerBlockRepository) Block(ctx context.Context, telegramID int64, reason string, until *time.Time, panel bool) (bool, error) {
+	sql, args, err := sq.Update("customer").
+		Set("blocked_at", sq.Expr("NOW()")).
+		Set("blocked_reason", reason).
+		Set("blocked_until", until).
+		Set("blocked_panel", panel).
+		Set("block_notified", false).
+		Where(sq.Eq{"telegram_id": telegramID}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build block customer: %w", err)
+	}
+	tag, err := r.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to block customer: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *CustomerBlockRepository) Unblock(ctx context.Context, telegramID int64) error {
+	sql, args, err := sq.Update("customer").
+		Set("blocked_at", nil).
+		Set("blocked_reason", nil).
+		Set("blocked_until", nil).
+		Set("blocked_panel", false).
+		Set("block_notified", false).
+		Where(sq.Eq{"telegram_id": telegramID}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build unblock customer: %w", err)
+	}
+	if _, err := r.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("failed to unblock customer: %w", err)
+	}
+	return nil
+}
+
+// MarkNotified flags the block as announced to the user. It returns true only
+// for the first call, so concurrent updates send a single notice.
+func (r *CustomerBlockRepository) MarkNotified(ctx context.Context, telegramID int64) (bool, error) {
+	sql, args, err := sq.Update("customer").
+		Set("block_notified", true).
+		Where(sq.And{sq.Eq{"telegram_id": telegramID}, sq.NotEq{"blocked_at": nil}, sq.Eq{"block_notified": false}}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build mark block notified: %w", err)
+	}
+	tag, err := r.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark block notified: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,249 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type FAQCategory struct {
+	ID       int64  `db:"id"`
+	Language string `db:"language"`
+	Title    string `db:"title"`
+}
+
+// FAQArticle is an answer of an FAQ category, or an info page addressed by
+// Slug when CategoryID is nil.
+type FAQArticle struct {
+	ID         int64     `db:"id"`
+	CategoryID *int64    `db:"category_id"`
+	Language   string    `db:"language"`
+	Slug       *string   `db:"slug"`
+	Title      string    `db:"title"`
+	Body       string    `db:"body"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
+
+var faqArticleColumns = []string{"id", "category_id", "language", "slug", "title", "body", "updated_at"}
+
+type FAQRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewFAQRepository(pool *pgxpool.Pool) *FAQRepository {
+	return &FAQRepository{pool: pool}
+}
+
+// Categories returns the categories of lang in creation order.
+func (r *FAQRepository) Categories(ctx context.Context, lang string) ([]FAQCategory, error) {
+	sql, args, err := sq.Select("id", "language", "title").
+		From("faq_category").
+		Where(sq.Eq{"language": lang}).
+		OrderBy("id").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select faq categories: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query faq categories: %w", err)
+	}
+	defer rows.Close()
+
+	var categories []FAQCategory
+	for rows.Next() {
+		var c FAQCategory
+		if err := rows.Scan(&c.ID, &c.Language, &c.Title); err != nil {
+			return nil, fmt.Errorf("failed to scan faq category: %w", err)
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+func (r *FAQRepository) FindCategory(ctx context.Context, id int64) (*FAQCategory, error) {
+	sql, args, err := sq.Select("id", "language", "title").
+		From("faq_category").
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select faq category: %w", err)
+	}
+	var c FAQCategory
+	err = r.pool.QueryRow(ctx, sql, args...).Scan(&c.ID, &c.Language, &c.Title)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query faq category: %w", err)
+	}
+	return &c, nil
+}
+
+func (r *FAQRepository) CreateCategory(ctx context.Context, c *FAQCategory) error {
+	sql, args, err := sq.Insert("faq_category").
+		Columns("language", "title").
+		Values(c.Language, c.Title).
+		Suffix("RETURNING id").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert faq category: %w", err)
+	}
+	if err := r.pool.QueryRow(ctx, sql, args...).Scan(&c.ID); err != nil {
+		return fmt.Errorf("failed to insert faq category: %w", err)
+	}
+	return nil
+}
+
+// DeleteCategory removes the category with its articles. It returns false
+// when the category does not exist.
+func (r *FAQRepository) DeleteCategory(ctx context.Context, id int64) (bool, error) {
+	sql, args, err := sq.Delete("faq_category").
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build delete faq category: %w", err)
+	}
+	tag, err := r.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete faq category: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Articles returns the articles of the category in creation order.
+func (r *FAQRepository) Articles(ctx context.Context, categoryID int64) ([]FAQArticle, error) {
+	return r.queryArticles(ctx, sq.Select(faqArticleColumns...).
+		From("faq_article").
+		Where(sq.Eq{"category_id": categoryID}).
+		OrderBy("id"))
+}
+
+// Pages returns the info pages of lang.
+func (r *FAQRepository) Pages(ctx context.Context, lang string) ([]FAQArticle, error) {
+	return r.queryArticles(ctx, sq.Select(faqArticleColumns...).
+		From("faq_article").
+		Where(sq.And{sq.Eq{"language": lang}, sq.NotEq{"slug": nil}}).
+		OrderBy("slug"))
+}
+
+// Search returns up to limit articles of lang whose title or body contains
+// query, ignoring case. Info pages are not searched.
+func (r *FAQRepository) Search(ctx context.Context, lang, query string, limit int) ([]FAQArticle, error) {
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+	return r.queryArticles(ctx, sq.Select(faqArticleColumns...).
+		From("faq_article").
+		Where(sq.And{
+			sq.Eq{"language": lang},
+			sq.NotEq{"category_id": nil},
+			sq.Or{sq.ILike{"title": pattern}, sq.ILike{"body": pattern}},
+		}).
+		OrderBy("id").
+		Limit(uint64(limit)))
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (r *FAQRepository) FindArticle(ctx context.Context, id int64) (*FAQArticle, error) {
+	articles, err := r.queryArticles(ctx, sq.Select(faqArticleColumns...).
+		From("faq_article").
+		Where(sq.Eq{"id": id}))
+	if err != nil || len(articles) == 0 {
+		return nil, err
+	}
+	return &articles[0], nil
+}
+
+func (r *FAQRepository) CreateArticle(ctx context.Context, a *FAQArticle) error {
+	sql, args, err := sq.Insert("faq_article").
+		Columns("category_id", "language", "title", "body").
+		Values(a.CategoryID, a.Language, a.Title, a.Body).
+		Suffix("RETURNING id, updated_at").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert faq article: %w", err)
+	}
+	if err := r.pool.QueryRow(ctx, sql, args...).Scan(&a.ID, &a.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to insert faq article: %w", err)
+	}
+	return nil
+}
+
+// SavePage creates the info page a.Slug of a.Language or replaces its text.
+func (r *FAQRepository) SavePage(ctx context.Context, a *FAQArticle) error {
+	sql, args, err := sq.Insert("faq_article").
+		Columns("language", "slug", "title", "body").
+		Values(a.Language, a.Slug, a.Title, a.Body).
+		Suffix(`ON CONFLICT (slug, language) WHERE slug IS NOT NULL
+			DO UPDATE SET title = EXCLUDED.title, body = EXCLUDED.body, updated_at = NOW()
+			RETURNING id, updated_at`).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build upsert faq page: %w", err)
+	}
+	if err := r.pool.QueryRow(ctx, sql, args...).Scan(&a.ID, &a.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to upsert faq page: %w", err)
+	}
+	return nil
+}
+
+// UpdateArticle replaces the text of the article. It returns false when the
+// article does not exist.
+func (r *FAQRepository) UpdateArticle(ctx context.Context, id int64, title, body string) (bool, error) {
+	sql, args, err := sq.Update("faq_article").
+		Set("title", title).
+		Set("body", body).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build update faq article: %w", err)
+	}
+	tag, err := r.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to update faq article: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *FAQRepository) DeleteArticle(ctx context.Context, id int64) (bool, error) {
+	sql, args, err := sq.Delete("faq_article").
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build delete faq article: %w", err)
+	}
+	tag, err := r.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete faq article: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *FAQRepository) queryArticles(ctx context.Context, query sq.SelectBuilder) ([]FAQArticle, error) {
+	sql, args, err := query.PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select faq articles: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query faq articles: %w", err)
+	}
+	defer rows.Close()
+
+	var articles []FAQArticle
+	for rows.Next() {
+		var a FAQArticle
+		if err := rows.Scan(&a.ID, &a.CategoryID, &a.Language, &a.Slug, &a.Title, &a.Body, &a.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan faq article: %w", err)
+		}
+		articles = append(articles, a)
+	}
+	return articles, rows.Err()
+}
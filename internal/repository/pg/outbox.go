@@ -0,0 +1,194 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSent    = "sent"
+	// OutboxStatusFailed marks messages Telegram refused or that ran out of attempts.
+	OutboxStatusFailed = "failed"
+)
+
+// OutboxMessage is a Telegram message stored with the change it reports and
+// delivered by the dispatcher. ReplyMarkup holds the JSON encoded keyboard.
+type OutboxMessage struct {
+	ID          int64      `db:"id"`
+	ChatID      int64      `db:"chat_id"`
+	Text        string     `db:"text"`
+	ParseMode   string     `db:"parse_mode"`
+	ReplyMarkup []byte     `db:"reply_markup"`
+	Status      string     `db:"status"`
+	Attempts    int        `db:"attempts"`
+	LastError   *string    `db:"last_error"`
+	RunAt       time.Time  `db:"run_at"`
+	CreatedAt   time.Time  `db:"created_at"`
+	SentAt      *time.Time `db:"sent_at"`
+}
+
+type OutboxRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewOutboxRepository(pool *pgxpool.Pool) *OutboxRepository {
+	return &OutboxRepository{pool: pool}
+}
+
+var outboxMessageColumns = []string{
+	"id", "chat_id", "text", "parse_mode", "reply_markup", "status", "attempts", "last_error", "run_at", "created_at", "sent_at",
+}
+
+// insertOutboxMessage stores the message, within the transaction of the change
+// it reports when q is a transaction.
+func insertOutboxMessage(ctx context.Context, q rowQuerier, msg *OutboxMessage) error {
+	sql, args, err := sq.Insert("notification_outbox").
+		Columns("chat_id", "text", "parse_mode", "reply_markup").
+		Values(msg.ChatID, msg.Text, msg.ParseMode, msg.ReplyMarkup).
+		Suffix("RETURNING id, status, run_at, created_at").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert notification_outbox: %w", err)
+	}
+	if err := q.QueryRow(ctx, sql, args...).Scan(&msg.ID, &msg.Status, &msg.RunAt, &msg.CreatedAt); err != nil {
+		return fmt.Errorf("failed to insert notification_outbox: %w", err)
+	}
+	return nil
+}
+
+// Enqueue stores a message not tied to another change.
+func (r *OutboxRepository) Enqueue(ctx context.Context, msg *OutboxMessage) error {
+	return insertOutboxMessage(ctx, r.pool, msg)
+}
+
+// Claim returns up to limit due pending messages and hides them from other
+// dispatchers for lease. Only the oldest pending message of a chat is claimed,
+// so messages reach a chat in the order they were written.
+func (r *OutboxRepository) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxMessage, error) {
+	due := sq.Select("m.id").
+		From("notification_outbox m").
+		Where(sq.Eq{"m.status": OutboxStatusPending}).
+		Where(sq.LtOrEq{"m.run_at": now}).
+		Where(sq.Expr("NOT EXISTS (SELECT 1 FROM notification_outbox o WHERE o.chat_id = m.chat_id AND o.status = ? AND o.id < m.id)",
+			OutboxStatusPending)).
+		OrderBy("m.id").
+		Limit(uint64(limit)). //nolint:gosec // limit is a small positive constant
+		Suffix("FOR UPDATE SKIP LOCKED")
+
+	sql, args, err := sq.Update("notification_outbox").
+		Set("run_at", now.Add(lease)).
+		Where(sq.Expr("id IN (?)", due)).
+		Suffix("RETURNING " + strings.Join(outboxMessageColumns, ", ")).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build claim notification_outbox: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim notification_outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var list []OutboxMessage
+	for rows.Next() {
+		var m OutboxMessage
+		if err := rows.Scan(&m.ID, &m.ChatID, &m.Text, &m.ParseMode, &m.ReplyMarkup, &m.Status, &m.Attempts, &m.LastError,
+			&m.RunAt, &m.CreatedAt, &m.SentAt); err != nil {
+			return nil, fmt.Errorf("failed to scan notification_outbox: %w", err)
+		}
+		list = append(list, m)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating notification_outbox rows: %w", rows.Err())
+	}
+	return list, nil
+}
+
+func (r *OutboxRepository) MarkSent(ctx context.Context, id int64) error {
+	return r.update(ctx, sq.Eq{"id": id}, map[string]interface{}{
+		"status":  OutboxStatusSent,
+		"sent_at": sq.Expr("NOW()"),
+	})
+}
+
+// Retry counts a failed attempt and schedules the next one.
+func (r *OutboxRepository) Retry(ctx context.Context, id int64, runAt time.Time, lastError string) error {
+	return r.update(ctx, sq.Eq{"id": id}, map[string]interface{}{
+		"attempts":   sq.Expr("attempts + 1"),
+		"run_at":     runAt,
+		"last_error": lastError,
+	})
+}
+
+// Postpone delays the pending messages of the chat without counting an
+// attempt, when Telegram asks to slow down.
+func (r *OutboxRepository) Postpone(ctx context.Context, chatID int64, runAt time.Time) error {
+	return r.update(ctx, sq.Eq{"chat_id": chatID}, map[string]interface{}{"run_at": runAt})
+}
+
+func (r *OutboxRepository) Fail(ctx context.Context, id int64, lastError string) error {
+	return r.update(ctx, sq.Eq{"id": id}, map[string]interface{}{
+		"status":     OutboxStatusFailed,
+		"attempts":   sq.Expr("attempts + 1"),
+		"last_error": lastError,
+	})
+}
+
+// MarkUnreachable fails the pending messages of the chat and marks its
+// customer unreachable in one transaction.
+func (r *OutboxRepository) MarkUnreachable(ctx context.Context, chatID int64, lastError string) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // ignore rollback error
+
+	sql, args, err := sq.Update("notification_outbox").
+		Set("status", OutboxStatusFailed).
+		Set("last_error", lastError).
+		Where(sq.Eq{"chat_id": chatID, "status": OutboxStatusPending}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build update notification_outbox: %w", err)
+	}
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("failed to update notification_outbox: %w", err)
+	}
+
+	sql, args, err = sq.Update("customer").
+		Set("unreachable_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"telegram_id": chatID, "unreachable_at": nil}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build update customer: %w", err)
+	}
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("failed to mark customer unreachable: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *OutboxRepository) update(ctx context.Context, where sq.Eq, fields map[string]interface{}) error {
+	where["status"] = OutboxStatusPending
+	sql, args, err := sq.Update("notification_outbox").
+		SetMap(fields).
+		Where(where).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build update notification_outbox: %w", err)
+	}
+	if _, err := r.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("failed to update notification_outbox: %w", err)
+	}
+	return nil
+}
@@ -14,6 +14,8 @@ import (
 	"os"
 	"path/filepath"
 	"remnawave-tg-shop-bot/internal/pkg/config"
+	"strconv"
+	"strings"
 )
 
 type MigrationConfig struct {
@@ -146,3 +148,27 @@ func GetMigrationVersion(migrationsPath string) (uint, bool, error) {
 
 	return version, dirty, nil
 }
+
+// LatestMigrationVersion returns the version of the newest migration in migrationsPath.
+func LatestMigrationVersion(migrationsPath string) (uint, error) {
+	entries, err := os.ReadDir(migrationsPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+	var latest uint
+	for _, e := range entries {
+		prefix, _, ok := strings.Cut(e.Name(), "_")
+		if !ok || e.IsDir() {
+			continue
+		}
+		v, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			continue
+		}
+		latest = max(latest, uint(v))
+	}
+	if latest == 0 {
+		return 0, fmt.Errorf("no migrations found in %s", migrationsPath)
+	}
+	return latest, nil
+}
@@ -2,6 +2,7 @@ package pg
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -20,6 +21,13 @@ type Promocode struct {
 	Deleted   bool      `db:"deleted"`
 }
 
+var (
+	// ErrPromocodeUnavailable is returned when the code is frozen, deleted or has no uses left.
+	ErrPromocodeUnavailable = errors.New("promocode is not available")
+	// ErrPromocodeAlreadyUsed is returned when the customer has already redeemed the code.
+	ErrPromocodeAlreadyUsed = errors.New("promocode already used by customer")
+)
+
 type PromocodeRepository struct {
 	pool *pgxpool.Pool
 }
@@ -76,16 +84,59 @@ func (r *PromocodeRepository) GetById(ctx context.Context, id int64) (*Promocode
 	return promo, nil
 }
 
-func (r *PromocodeRepository) DecrementUses(ctx context.Context, id int64) error {
+// Redeem consumes one use of the promocode on behalf of usedBy. The use is
+// reserved with a conditional decrement and a unique promocode_usage row, and
+// the provisioning job granting the promocode days is stored in the same
+// transaction.
+func (r *PromocodeRepository) Redeem(ctx context.Context, promoID, usedBy int64, job *ProvisioningJob) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // ignore rollback error
+
 	sql, args, err := sq.Update("promocode").
 		Set("uses_left", sq.Expr("uses_left - 1")).
-		Where(sq.Eq{"id": id, "deleted": false}).
+		Where(sq.And{
+			sq.Eq{"id": promoID, "active": true, "deleted": false},
+			sq.Gt{"uses_left": 0},
+		}).
 		PlaceholderFormat(sq.Dollar).ToSql()
 	if err != nil {
 		return fmt.Errorf("failed to build update promocode: %w", err)
 	}
-	_, err = r.pool.Exec(ctx, sql, args...)
-	return err
+	res, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("failed to decrement promocode uses: %w", err)
+	}
+	if res.RowsAffected() == 0 {
+		return ErrPromocodeUnavailable
+	}
+
+	sql, args, err = sq.Insert("promocode_usage").
+		Columns("promocode_id", "used_by").
+		Values(promoID, usedBy).
+		Suffix("ON CONFLICT (promocode_id, used_by) DO NOTHING").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert promocode_usage: %w", err)
+	}
+	res, err = tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("failed to insert promocode_usage: %w", err)
+	}
+	if res.RowsAffected() == 0 {
+		return ErrPromocodeAlreadyUsed
+	}
+
+	if err := insertProvisioningJob(ctx, tx, job); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
 }
 
 func (r *PromocodeRepository) UpdateStatus(ctx context.Context, id int64, active bool) error {
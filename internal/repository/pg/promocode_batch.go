@@ -0,0 +1,232 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ErrPromocodeExists is returned when a generated or vanity code is already taken.
+var ErrPromocodeExists = errors.New("promocode already exists")
+
+type PromocodeBatch struct {
+	ID          int64     `db:"id"`
+	Tag         string    `db:"tag"`
+	Prefix      *string   `db:"prefix"`
+	Months      int       `db:"months"`
+	UsesPerCode int       `db:"uses_per_code"`
+	Size        int       `db:"size"`
+	CreatedBy   int64     `db:"created_by"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// PromocodeBatchCode is a single exported row of a batch.
+type PromocodeBatchCode struct {
+	Code     string
+	Months   int
+	UsesLeft int
+	Redeemed int
+	Active   bool
+}
+
+// PromocodeBatchStats aggregates redemption data of a batch.
+type PromocodeBatchStats struct {
+	Issued   int
+	Redeemed int
+	// Revenue holds paid purchase amounts of redeeming customers, keyed by currency.
+	Revenue map[string]float64
+}
+
+type PromocodeBatchRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPromocodeBatchRepository(pool *pgxpool.Pool) *PromocodeBatchRepository {
+	return &PromocodeBatchRepository{pool: pool}
+}
+
+// Create stores the batch and all of its codes in a single transaction.
+func (r *PromocodeBatchRepository) Create(ctx context.Context, batch *PromocodeBatch, codes []string) (*PromocodeBatch, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // ignore rollback error
+
+	sql, args, err := sq.Insert("promocode_batch").
+		Columns("tag", "prefix", "months", "uses_per_code", "size", "created_by").
+		Values(batch.Tag, batch.Prefix, batch.Months, batch.UsesPerCode, len(codes), batch.CreatedBy).
+		Suffix("RETURNING id, size, created_at").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build insert promocode_batch: %w", err)
+	}
+	if err := tx.QueryRow(ctx, sql, args...).Scan(&batch.ID, &batch.Size, &batch.CreatedAt); err != nil {
+		return nil, fmt.Errorf("failed to insert promocode_batch: %w", err)
+	}
+
+	insert := sq.Insert("promocode").
+		Columns("code", "months", "uses_left", "created_by", "active", "batch_id").
+		Suffix("ON CONFLICT (code) DO NOTHING").
+		PlaceholderFormat(sq.Dollar)
+	for _, code := range codes {
+		insert = insert.Values(code, batch.Months, batch.UsesPerCode, batch.CreatedBy, true, batch.ID)
+	}
+	sql, args, err = insert.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build insert promocodes: %w", err)
+	}
+	res, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert promocodes: %w", err)
+	}
+	if int(res.RowsAffected()) != len(codes) {
+		return nil, ErrPromocodeExists
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return batch, nil
+}
+
+func (r *PromocodeBatchRepository) FindById(ctx context.Context, id int64) (*PromocodeBatch, error) {
+	sql, args, err := sq.Select("id", "tag", "prefix", "months", "uses_per_code", "size", "created_by", "created_at").
+		From("promocode_batch").
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select promocode_batch: %w", err)
+	}
+	var b PromocodeBatch
+	err = r.pool.QueryRow(ctx, sql, args...).Scan(&b.ID, &b.Tag, &b.Prefix, &b.Months, &b.UsesPerCode, &b.Size, &b.CreatedBy, &b.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to query promocode_batch: %w", err)
+	}
+	return &b, nil
+}
+
+// FindRecent returns the latest batches, newest first.
+func (r *PromocodeBatchRepository) FindRecent(ctx context.Context, limit int) ([]PromocodeBatch, error) {
+	sql, args, err := sq.Select("id", "tag", "prefix", "months", "uses_per_code", "size", "created_by", "created_at").
+		From("promocode_batch").
+		OrderBy("created_at DESC").
+		Limit(uint64(limit)). //nolint:gosec // limit is a small positive constant
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select promocode_batch: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query promocode_batch: %w", err)
+	}
+	defer rows.Close()
+
+	var list []PromocodeBatch
+	for rows.Next() {
+		var b PromocodeBatch
+		if err := rows.Scan(&b.ID, &b.Tag, &b.Prefix, &b.Months, &b.UsesPerCode, &b.Size, &b.CreatedBy, &b.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan promocode_batch: %w", err)
+		}
+		list = append(list, b)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating promocode_batch rows: %w", rows.Err())
+	}
+	return list, nil
+}
+
+// FindCodes returns every code of the batch together with its redemption count.
+func (r *PromocodeBatchRepository) FindCodes(ctx context.Context, batchID int64) ([]PromocodeBatchCode, error) {
+	sql, args, err := sq.Select("p.code", "p.months", "p.uses_left", "COUNT(u.id)", "COALESCE(p.active, TRUE)").
+		From("promocode p").
+		LeftJoin("promocode_usage u ON u.promocode_id = p.id").
+		Where(sq.Eq{"p.batch_id": batchID, "p.deleted": false}).
+		GroupBy("p.id").
+		OrderBy("p.id").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select batch codes: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query batch codes: %w", err)
+	}
+	defer rows.Close()
+
+	var list []PromocodeBatchCode
+	for rows.Next() {
+		var c PromocodeBatchCode
+		if err := rows.Scan(&c.Code, &c.Months, &c.UsesLeft, &c.Redeemed, &c.Active); err != nil {
+			return nil, fmt.Errorf("failed to scan batch code: %w", err)
+		}
+		list = append(list, c)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating batch code rows: %w", rows.Err())
+	}
+	return list, nil
+}
+
+// Stats counts issued codes and redemptions of the batch. Revenue is the sum of
+// purchases paid by redeeming customers after their redemption.
+func (r *PromocodeBatchRepository) Stats(ctx context.Context, batchID int64) (*PromocodeBatchStats, error) {
+	stats := &PromocodeBatchStats{Revenue: make(map[string]float64)}
+
+	sql, args, err := sq.Select("COUNT(DISTINCT p.id)", "COUNT(u.id)").
+		From("promocode p").
+		LeftJoin("promocode_usage u ON u.promocode_id = p.id").
+		Where(sq.Eq{"p.batch_id": batchID}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build batch counts: %w", err)
+	}
+	if err := r.pool.QueryRow(ctx, sql, args...).Scan(&stats.Issued, &stats.Redeemed); err != nil {
+		return nil, fmt.Errorf("failed to query batch counts: %w", err)
+	}
+
+	attributed := sq.Select("pu.id").
+		From("purchase pu").
+		Join("customer c ON c.id = pu.customer_id").
+		Join("promocode_usage u ON u.used_by = c.telegram_id").
+		Join("promocode p ON p.id = u.promocode_id").
+		Where(sq.Eq{"p.batch_id": batchID, "pu.status": PurchaseStatusPaid}).
+		Where("pu.paid_at >= u.used_at")
+	sql, args, err = sq.Select("currency", "COALESCE(SUM(amount), 0)").
+		From("purchase").
+		Where(attributed.Prefix("id IN (").Suffix(")")).
+		GroupBy("currency").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build batch revenue: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query batch revenue: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var currency *string
+		var amount float64
+		if err := rows.Scan(&currency, &amount); err != nil {
+			return nil, fmt.Errorf("failed to scan batch revenue: %w", err)
+		}
+		key := ""
+		if currency != nil {
+			key = *currency
+		}
+		stats.Revenue[key] += amount
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating batch revenue rows: %w", rows.Err())
+	}
+	return stats, nil
+}
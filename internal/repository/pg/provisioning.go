@@ -0,0 +1,196 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	ProvisioningStatusPending = "pending"
+	ProvisioningStatusDone    = "done"
+	// ProvisioningStatusDead marks jobs that ran out of attempts and wait for an admin.
+	ProvisioningStatusDead = "dead"
+
+	ProvisioningSourceBalance   = "balance"
+	ProvisioningSourcePromocode = "promocode"
+	// ProvisioningSourceReferral grants the days of a referral reward.
+	ProvisioningSourceReferral = "referral"
+)
+
+// ErrInsufficientBalance is returned when the customer balance doesn't cover the price.
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// ProvisioningJob extends a subscription of the customer by Days. TargetExpireAt
+// is fixed by the first attempt, so that retries set the same expiration instead
+// of extending the user again. PlanMonths is the plan the job was bought for,
+// nil when it grants days without one, e.g. a promocode, and leaves the
+// inbounds of the user alone.
+type ProvisioningJob struct {
+	ID             int64      `db:"id"`
+	CustomerID     int64      `db:"customer_id"`
+	TelegramID     int64      `db:"telegram_id"`
+	SubscriptionID *int64     `db:"subscription_id"`
+	Days           int        `db:"days"`
+	PlanMonths     *int       `db:"plan_months"`
+	Source         string     `db:"source"`
+	Status         string     `db:"status"`
+	TargetExpireAt *time.Time `db:"target_expire_at"`
+	MessageID      *int       `db:"message_id"`
+	Attempts       int        `db:"attempts"`
+	LastError      *string    `db:"last_error"`
+	RunAt          time.Time  `db:"run_at"`
+	CreatedAt      time.Time  `db:"created_at"`
+	DoneAt         *time.Time `db:"done_at"`
+
+	// NewSubscription makes the job add a subscription instead of extending one.
+	NewSubscription bool `db:"-"`
+}
+
+type ProvisioningRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewProvisioningRepository(pool *pgxpool.Pool) *ProvisioningRepository {
+	return &ProvisioningRepository{pool: pool}
+}
+
+var provisioningJobColumns = []string{
+	"id", "customer_id", "telegram_id", "subscription_id", "days", "plan_months", "source", "status", "target_expire_at", "message_id",
+	"attempts", "last_error", "run_at", "created_at", "done_at",
+}
+
+// insertProvisioningJob stores the job within the transaction that took the payment for it.
+func insertProvisioningJob(ctx context.Context, tx pgx.Tx, job *ProvisioningJob) error {
+	subscriptionID, err := subscriptionForJob(ctx, tx, job)
+	if err != nil {
+		return err
+	}
+	job.SubscriptionID = &subscriptionID
+
+	sql, args, err := sq.Insert("provisioning_job").
+		Columns("customer_id", "telegram_id", "subscription_id", "days", "plan_months", "source", "message_id").
+		Values(job.CustomerID, job.TelegramID, job.SubscriptionID, job.Days, job.PlanMonths, job.Source, job.MessageID).
+		Suffix("RETURNING id, status, run_at, created_at").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert provisioning_job: %w", err)
+	}
+	if err := tx.QueryRow(ctx, sql, args...).Scan(&job.ID, &job.Status, &job.RunAt, &job.CreatedAt); err != nil {
+		return fmt.Errorf("failed to insert provisioning_job: %w", err)
+	}
+	return nil
+}
+
+// EnqueueFromBalance debits price from the customer balance and stores the job
+// in one transaction. It returns the new balance.
+func (r *ProvisioningRepository) EnqueueFromBalance(ctx context.Context, job *ProvisioningJob, price float64) (float64, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // ignore rollback error
+
+	balance, err := spendBalance(ctx, tx, job.CustomerID, price)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := insertProvisioningJob(ctx, tx, job); err != nil {
+		return 0, err
+	}
+	if err := closeTrial(ctx, tx, job.TelegramID); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return balance, nil
+}
+
+// Claim returns up to limit due pending jobs, counting an attempt for each and
+// hiding them from other workers for lease. Only the oldest pending job of a
+// customer is claimed, so extensions of its subscriptions are applied in order.
+func (r *ProvisioningRepository) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]ProvisioningJob, error) {
+	due := sq.Select("j.id").
+		From("provisioning_job j").
+		Where(sq.Eq{"j.status": ProvisioningStatusPending}).
+		Where(sq.LtOrEq{"j.run_at": now}).
+		Where(sq.Expr("NOT EXISTS (SELECT 1 FROM provisioning_job o WHERE o.telegram_id = j.telegram_id AND o.status = ? AND o.id < j.id)",
+			ProvisioningStatusPending)).
+		OrderBy("j.id").
+		Limit(uint64(limit)). //nolint:gosec // limit is a small positive constant
+		Suffix("FOR UPDATE SKIP LOCKED")
+
+	sql, args, err := sq.Update("provisioning_job").
+		Set("run_at", now.Add(lease)).
+		Set("attempts", sq.Expr("attempts + 1")).
+		Where(sq.Expr("id IN (?)", due)).
+		Suffix("RETURNING " + strings.Join(provisioningJobColumns, ", ")).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build claim provisioning_job: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim provisioning_job: %w", err)
+	}
+	defer rows.Close()
+
+	var list []ProvisioningJob
+	for rows.Next() {
+		var j ProvisioningJob
+		if err := rows.Scan(&j.ID, &j.CustomerID, &j.TelegramID, &j.SubscriptionID, &j.Days, &j.PlanMonths, &j.Source, &j.Status, &j.TargetExpireAt, &j.MessageID,
+			&j.Attempts, &j.LastError, &j.RunAt, &j.CreatedAt, &j.DoneAt); err != nil {
+			return nil, fmt.Errorf("failed to scan provisioning_job: %w", err)
+		}
+		list = append(list, j)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating provisioning_job rows: %w", rows.Err())
+	}
+	return list, nil
+}
+
+// SetTarget fixes the expiration the job sets on the panel user.
+func (r *ProvisioningRepository) SetTarget(ctx context.Context, id int64, expireAt time.Time) error {
+	return r.update(ctx, id, map[string]interface{}{"target_expire_at": expireAt})
+}
+
+func (r *ProvisioningRepository) Complete(ctx context.Context, id int64) error {
+	return r.update(ctx, id, map[string]interface{}{
+		"status":     ProvisioningStatusDone,
+		"done_at":    sq.Expr("NOW()"),
+		"last_error": nil,
+	})
+}
+
+// Retry schedules the next attempt of the job.
+func (r *ProvisioningRepository) Retry(ctx context.Context, id int64, runAt time.Time, lastError string) error {
+	return r.update(ctx, id, map[string]interface{}{"run_at": runAt, "last_error": lastError})
+}
+
+// Bury moves the job to the dead letters.
+func (r *ProvisioningRepository) Bury(ctx context.Context, id int64, lastError string) error {
+	return r.update(ctx, id, map[string]interface{}{"status": ProvisioningStatusDead, "last_error": lastError})
+}
+
+func (r *ProvisioningRepository) update(ctx context.Context, id int64, fields map[string]interface{}) error {
+	sql, args, err := sq.Update("provisioning_job").
+		SetMap(fields).
+		Where(sq.Eq{"id": id, "status": ProvisioningStatusPending}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build update provisioning_job: %w", err)
+	}
+	if _, err := r.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("failed to update provisioning_job: %w", err)
+	}
+	return nil
+}
@@ -4,10 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
+
 	sq "github.com/Masterminds/squirrel"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"time"
 
 	domain "remnawave-tg-shop-bot/internal/domain/purchase"
 )
@@ -176,13 +177,162 @@ func (p *PurchaseRepository) UpdateFields(ctx context.Context, id int64, updates
 	return nil
 }
 
-func (pr *PurchaseRepository) MarkAsPaid(ctx context.Context, purchaseID int64) error {
-	currentTime := time.Now()
+// Pay marks the purchase paid, credits its amount to the customer balance,
+// queues its referral rewards and stores the notice for the customer in one
+// transaction. It returns false when the purchase was already paid, so
+// repeated payment callbacks credit once.
+func (pr *PurchaseRepository) Pay(ctx context.Context, purchaseID int64, notice *OutboxMessage) (bool, error) {
+	tx, err := pr.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // ignore rollback error
+
+	sql, args, err := sq.Update("purchase").
+		Set("status", domain.StatusPaid).
+		Set("paid_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": purchaseID}).
+		Where(sq.NotEq{"status": domain.StatusPaid}).
+		Suffix("RETURNING customer_id, amount").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build update purchase: %w", err)
+	}
+	var customerID int64
+	var amount float64
+	if err := tx.QueryRow(ctx, sql, args...).Scan(&customerID, &amount); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to mark purchase paid: %w", err)
+	}
+
+	sql, args, err = sq.Update("customer").
+		Set("balance", sq.Expr("balance + ?", amount)).
+		Where(sq.Eq{"id": customerID}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build update customer balance: %w", err)
+	}
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return false, fmt.Errorf("failed to update customer balance: %w", err)
+	}
+
+	sql, args, err = sq.Insert("referral_payment").
+		Columns("purchase_id").
+		Values(purchaseID).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build insert referral_payment: %w", err)
+	}
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return false, fmt.Errorf("failed to insert referral_payment: %w", err)
+	}
+
+	if notice != nil {
+		if err := insertOutboxMessage(ctx, tx, notice); err != nil {
+			return false, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// CountPaidByCustomer returns the number of paid purchases of the customer up
+// to the purchase untilID, so that a retried payment keeps its number.
+func (pr *PurchaseRepository) CountPaidByCustomer(ctx context.Context, customerID, untilID int64) (int, error) {
+	sql, args, err := sq.Select("COUNT(*)").
+		From("purchase").
+		Where(sq.Eq{"customer_id": customerID, "status": domain.StatusPaid}).
+		Where(sq.LtOrEq{"id": untilID}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build count paid purchases query: %w", err)
+	}
 
-	updates := map[string]interface{}{
-		"status":  domain.StatusPaid,
-		"paid_at": currentTime,
+	var count int
+	if err := pr.pool.QueryRow(ctx, sql, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count paid purchases: %w", err)
 	}
+	return count, nil
+}
 
-	return pr.UpdateFields(ctx, purchaseID, updates)
+// FindLastPaidByCustomer returns up to limit paid purchases of the customer,
+// newest first.
+func (pr *PurchaseRepository) FindLastPaidByCustomer(ctx context.Context, customerID int64, limit int) ([]Purchase, error) {
+	sql, args, err := sq.Select("id", "amount", "customer_id", "created_at", "month", "paid_at", "currency", "expire_at", "status", "invoice_type", "crypto_invoice_id", "crypto_invoice_url").
+		From("purchase").
+		Where(sq.Eq{"customer_id": customerID, "status": domain.StatusPaid}).
+		OrderBy("paid_at DESC NULLS LAST", "id DESC").
+		Limit(uint64(limit)).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build last paid purchases query: %w", err)
+	}
+	rows, err := pr.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query last paid purchases: %w", err)
+	}
+	defer rows.Close()
+
+	var purchases []Purchase
+	for rows.Next() {
+		var p Purchase
+		err := rows.Scan(&p.ID, &p.Amount, &p.CustomerID, &p.CreatedAt, &p.Month, &p.PaidAt, &p.Currency, &p.ExpireAt,
+			&p.Status, &p.InvoiceType, &p.CryptoInvoiceID, &p.CryptoInvoiceLink)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan purchase: %w", err)
+		}
+		purchases = append(purchases, p)
+	}
+	return purchases, rows.Err()
+}
+
+// FindReferralPending returns up to limit paid purchases queued before before
+// whose referral rewards are not recorded yet, oldest first.
+func (pr *PurchaseRepository) FindReferralPending(ctx context.Context, before time.Time, limit int) ([]Purchase, error) {
+	sql, args, err := sq.Select("p.id", "p.amount", "p.customer_id", "p.created_at", "p.month", "p.paid_at", "p.currency", "p.expire_at",
+		"p.status", "p.invoice_type", "p.crypto_invoice_id", "p.crypto_invoice_url").
+		From("referral_payment r").
+		Join("purchase p ON p.id = r.purchase_id").
+		Where(sq.Lt{"r.created_at": before}).
+		OrderBy("r.created_at").
+		Limit(uint64(limit)).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build referral_payment query: %w", err)
+	}
+	rows, err := pr.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query referral_payment: %w", err)
+	}
+	defer rows.Close()
+
+	var purchases []Purchase
+	for rows.Next() {
+		var p Purchase
+		err := rows.Scan(&p.ID, &p.Amount, &p.CustomerID, &p.CreatedAt, &p.Month, &p.PaidAt, &p.Currency, &p.ExpireAt,
+			&p.Status, &p.InvoiceType, &p.CryptoInvoiceID, &p.CryptoInvoiceLink)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan purchase: %w", err)
+		}
+		purchases = append(purchases, p)
+	}
+	return purchases, rows.Err()
+}
+
+// CompleteReferral removes the purchase from the referral queue.
+func (pr *PurchaseRepository) CompleteReferral(ctx context.Context, purchaseID int64) error {
+	sql, args, err := sq.Delete("referral_payment").
+		Where(sq.Eq{"purchase_id": purchaseID}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build delete referral_payment: %w", err)
+	}
+	if _, err := pr.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("failed to delete referral_payment: %w", err)
+	}
+	return nil
 }
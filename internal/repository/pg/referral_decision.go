@@ -0,0 +1,74 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type ReferralDecision struct {
+	ID         int64     `db:"id"`
+	ReferrerID int64     `db:"referrer_id"`
+	RefereeID  int64     `db:"referee_id"`
+	PurchaseID *int64    `db:"purchase_id"`
+	Decision   string    `db:"decision"`
+	Reason     string    `db:"reason"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+type ReferralDecisionRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewReferralDecisionRepository(pool *pgxpool.Pool) *ReferralDecisionRepository {
+	return &ReferralDecisionRepository{pool: pool}
+}
+
+func (r *ReferralDecisionRepository) Create(ctx context.Context, d *ReferralDecision) error {
+	sql, args, err := sq.Insert("referral_decision").
+		Columns("referrer_id", "referee_id", "purchase_id", "decision", "reason").
+		Values(d.ReferrerID, d.RefereeID, d.PurchaseID, d.Decision, d.Reason).
+		Suffix("RETURNING id, created_at").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert referral_decision: %w", err)
+	}
+	if err := r.pool.QueryRow(ctx, sql, args...).Scan(&d.ID, &d.CreatedAt); err != nil {
+		return fmt.Errorf("failed to insert referral_decision: %w", err)
+	}
+	return nil
+}
+
+// FindByReferrer returns the latest decisions about the referrer, newest first.
+func (r *ReferralDecisionRepository) FindByReferrer(ctx context.Context, referrerID int64, limit int) ([]ReferralDecision, error) {
+	sql, args, err := sq.Select("id", "referrer_id", "referee_id", "purchase_id", "decision", "reason", "created_at").
+		From("referral_decision").
+		Where(sq.Eq{"referrer_id": referrerID}).
+		OrderBy("created_at DESC").
+		Limit(uint64(limit)). //nolint:gosec // limit is a small positive constant
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select referral_decision: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query referral_decision: %w", err)
+	}
+	defer rows.Close()
+
+	var list []ReferralDecision
+	for rows.Next() {
+		var d ReferralDecision
+		if err := rows.Scan(&d.ID, &d.ReferrerID, &d.RefereeID, &d.PurchaseID, &d.Decision, &d.Reason, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan referral_decision: %w", err)
+		}
+		list = append(list, d)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating referral_decision rows: %w", rows.Err())
+	}
+	return list, nil
+}
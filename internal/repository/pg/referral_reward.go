@@ -0,0 +1,314 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	ReferralRewardStatusHeld     = "held"
+	ReferralRewardStatusReleased = "released"
+	// ReferralRewardStatusReview marks rewards waiting for an admin decision.
+	ReferralRewardStatusReview   = "review"
+	ReferralRewardStatusRejected = "rejected"
+)
+
+type ReferralReward struct {
+	ID            int64      `db:"id"`
+	BeneficiaryID int64      `db:"beneficiary_id"`
+	RefereeID     int64      `db:"referee_id"`
+	PurchaseID    *int64     `db:"purchase_id"`
+	Level         int        `db:"level"`
+	Kind          string     `db:"kind"`
+	Amount        float64    `db:"amount"`
+	Days          int        `db:"days"`
+	Status        string     `db:"status"`
+	AvailableAt   time.Time  `db:"available_at"`
+	ReleasedAt    *time.Time `db:"released_at"`
+	CreatedAt     time.Time  `db:"created_at"`
+}
+
+// ReferralReviewItem groups rewards of one beneficiary waiting for review.
+type ReferralReviewItem struct {
+	BeneficiaryID int64
+	Rewards       int
+	Money         float64
+	Days          int
+	Since         time.Time
+}
+
+// ReferralRewardSummary aggregates rewards of a single beneficiary.
+type ReferralRewardSummary struct {
+	ReleasedMoney float64
+	HeldMoney     float64
+	ReleasedDays  int
+	HeldDays      int
+}
+
+type ReferralRewardRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewReferralRewardRepository(pool *pgxpool.Pool) *ReferralRewardRepository {
+	return &ReferralRewardRepository{pool: pool}
+}
+
+var referralRewardColumns = []string{
+	"id", "beneficiary_id", "referee_id", "purchase_id", "level", "kind", "amount", "days",
+	"status", "available_at", "released_at", "created_at",
+}
+
+// Create stores a reward, held unless Status is set. It returns false when a reward for the same purchase,
+// level and kind already exists, which makes payment processing idempotent.
+func (r *ReferralRewardRepository) Create(ctx context.Context, reward *ReferralReward) (bool, error) {
+	status := reward.Status
+	if status == "" {
+		status = ReferralRewardStatusHeld
+	}
+	sql, args, err := sq.Insert("referral_reward").
+		Columns("beneficiary_id", "referee_id", "purchase_id", "level", "kind", "amount", "days", "status", "available_at").
+		Values(reward.BeneficiaryID, reward.RefereeID, reward.PurchaseID, reward.Level, reward.Kind, reward.Amount, reward.Days, status, reward.AvailableAt).
+		Suffix("ON CONFLICT (purchase_id, level, kind) DO NOTHING RETURNING id, status, created_at").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build insert referral_reward: %w", err)
+	}
+	if err := r.pool.QueryRow(ctx, sql, args...).Scan(&reward.ID, &reward.Status, &reward.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to insert referral_reward: %w", err)
+	}
+	return true, nil
+}
+
+// FindDue returns held rewards whose hold period is over.
+func (r *ReferralRewardRepository) FindDue(ctx context.Context, now time.Time, limit int) ([]ReferralReward, error) {
+	sql, args, err := sq.Select(referralRewardColumns...).
+		From("referral_reward").
+		Where(sq.Eq{"status": ReferralRewardStatusHeld}).
+		Where(sq.LtOrEq{"available_at": now}).
+		OrderBy("available_at").
+		Limit(uint64(limit)). //nolint:gosec // limit is a small positive constant
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select due referral_reward: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due referral_reward: %w", err)
+	}
+	defer rows.Close()
+
+	var list []ReferralReward
+	for rows.Next() {
+		var rw ReferralReward
+		if err := rows.Scan(&rw.ID, &rw.BeneficiaryID, &rw.RefereeID, &rw.PurchaseID, &rw.Level, &rw.Kind, &rw.Amount, &rw.Days,
+			&rw.Status, &rw.AvailableAt, &rw.ReleasedAt, &rw.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan referral_reward: %w", err)
+		}
+		list = append(list, rw)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating referral_reward rows: %w", rows.Err())
+	}
+	return list, nil
+}
+
+// ReleaseMoney marks the reward as released and credits the beneficiary balance
+// in one transaction. It returns false when the reward was already released.
+func (r *ReferralRewardRepository) ReleaseMoney(ctx context.Context, id int64) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // ignore rollback error
+
+	sql, args, err := sq.Update("referral_reward").
+		Set("status", ReferralRewardStatusReleased).
+		Set("released_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": id, "status": ReferralRewardStatusHeld}).
+		Suffix("RETURNING beneficiary_id, amount").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build release referral_reward: %w", err)
+	}
+	var beneficiaryID int64
+	var amount float64
+	if err := tx.QueryRow(ctx, sql, args...).Scan(&beneficiaryID, &amount); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to release referral_reward: %w", err)
+	}
+
+	sql, args, err = sq.Update("customer").
+		Set("balance", sq.Expr("balance + ?", amount)).
+		Set("referral_balance", sq.Expr("referral_balance + ?", amount)).
+		Where(sq.Eq{"telegram_id": beneficiaryID}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build update customer balance: %w", err)
+	}
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return false, fmt.Errorf("failed to update customer balance: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// ReleaseDays marks the days reward released and stores the provisioning job
+// granting the days in one transaction. It returns false when the reward is
+// not held.
+func (r *ReferralRewardRepository) ReleaseDays(ctx context.Context, id int64, job *ProvisioningJob) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // ignore rollback error
+
+	sql, args, err := sq.Update("referral_reward").
+		Set("status", ReferralRewardStatusReleased).
+		Set("released_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": id, "status": ReferralRewardStatusHeld}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build release referral_reward: %w", err)
+	}
+	res, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to release referral_reward: %w", err)
+	}
+	if res.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	if err := insertProvisioningJob(ctx, tx, job); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// Summary sums rewards of the beneficiary by kind and status. Rewards waiting
+// for review are counted as held.
+func (r *ReferralRewardRepository) Summary(ctx context.Context, beneficiaryID int64) (*ReferralRewardSummary, error) {
+	sql, args, err := sq.Select(
+		"COALESCE(SUM(amount) FILTER (WHERE kind = 'money' AND status = 'released'), 0)",
+		"COALESCE(SUM(amount) FILTER (WHERE kind = 'money' AND status IN ('held', 'review')), 0)",
+		"COALESCE(SUM(days) FILTER (WHERE kind = 'days' AND status = 'released'), 0)",
+		"COALESCE(SUM(days) FILTER (WHERE kind = 'days' AND status IN ('held', 'review')), 0)",
+	).
+		From("referral_reward").
+		Where(sq.Eq{"beneficiary_id": beneficiaryID}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build referral_reward summary: %w", err)
+	}
+	var s ReferralRewardSummary
+	if err := r.pool.QueryRow(ctx, sql, args...).Scan(&s.ReleasedMoney, &s.HeldMoney, &s.ReleasedDays, &s.HeldDays); err != nil {
+		return nil, fmt.Errorf("failed to query referral_reward summary: %w", err)
+	}
+	return &s, nil
+}
+
+// CountSince counts rewards of the beneficiary at the level created after since,
+// ignoring rejected ones.
+func (r *ReferralRewardRepository) CountSince(ctx context.Context, beneficiaryID int64, level int, since time.Time) (int, error) {
+	sql, args, err := sq.Select("COUNT(DISTINCT purchase_id)").
+		From("referral_reward").
+		Where(sq.Eq{"beneficiary_id": beneficiaryID, "level": level}).
+		Where(sq.NotEq{"status": ReferralRewardStatusRejected}).
+		Where(sq.GtOrEq{"created_at": since}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build count referral_reward: %w", err)
+	}
+	var count int
+	if err := r.pool.QueryRow(ctx, sql, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count referral_reward: %w", err)
+	}
+	return count, nil
+}
+
+// FindReviewQueue returns beneficiaries with rewards waiting for review, oldest first.
+func (r *ReferralRewardRepository) FindReviewQueue(ctx context.Context, limit int) ([]ReferralReviewItem, error) {
+	sql, args, err := sq.Select(
+		"beneficiary_id",
+		"COUNT(*)",
+		"COALESCE(SUM(amount) FILTER (WHERE kind = 'money'), 0)",
+		"COALESCE(SUM(days) FILTER (WHERE kind = 'days'), 0)",
+		"MIN(created_at)",
+	).
+		From("referral_reward").
+		Where(sq.Eq{"status": ReferralRewardStatusReview}).
+		GroupBy("beneficiary_id").
+		OrderBy("MIN(created_at)").
+		Limit(uint64(limit)). //nolint:gosec // limit is a small positive constant
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build referral review queue: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query referral review queue: %w", err)
+	}
+	defer rows.Close()
+
+	var list []ReferralReviewItem
+	for rows.Next() {
+		var it ReferralReviewItem
+		if err := rows.Scan(&it.BeneficiaryID, &it.Rewards, &it.Money, &it.Days, &it.Since); err != nil {
+			return nil, fmt.Errorf("failed to scan referral review item: %w", err)
+		}
+		list = append(list, it)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating referral review rows: %w", rows.Err())
+	}
+	return list, nil
+}
+
+// ResolveReview moves all rewards of the beneficiary waiting for review to the
+// given status and returns them. The hold period of the rewards is kept.
+func (r *ReferralRewardRepository) ResolveReview(ctx context.Context, beneficiaryID int64, to string) ([]ReferralReward, error) {
+	sql, args, err := sq.Update("referral_reward").
+		Set("status", to).
+		Where(sq.Eq{"beneficiary_id": beneficiaryID, "status": ReferralRewardStatusReview}).
+		Suffix("RETURNING " + strings.Join(referralRewardColumns, ", ")).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build resolve referral review: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve referral review: %w", err)
+	}
+	defer rows.Close()
+
+	var list []ReferralReward
+	for rows.Next() {
+		var rw ReferralReward
+		if err := rows.Scan(&rw.ID, &rw.BeneficiaryID, &rw.RefereeID, &rw.PurchaseID, &rw.Level, &rw.Kind, &rw.Amount, &rw.Days,
+			&rw.Status, &rw.AvailableAt, &rw.ReleasedAt, &rw.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan referral_reward: %w", err)
+		}
+		list = append(list, rw)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating referral_reward rows: %w", rows.Err())
+	}
+	return list, nil
+}
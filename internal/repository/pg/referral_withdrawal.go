@@ -0,0 +1,209 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	// ReferralWithdrawalStatusHeld means the amount is taken from the balance and waits for payout.
+	ReferralWithdrawalStatusHeld     = "held"
+	ReferralWithdrawalStatusPaid     = "paid"
+	ReferralWithdrawalStatusRejected = "rejected"
+)
+
+// ErrInsufficientFunds is returned when a withdrawal exceeds the withdrawable amount.
+var ErrInsufficientFunds = errors.New("insufficient withdrawable funds")
+
+type ReferralWithdrawal struct {
+	ID          int64      `db:"id"`
+	CustomerID  int64      `db:"customer_id"`
+	Amount      float64    `db:"amount"`
+	Details     string     `db:"details"`
+	Status      string     `db:"status"`
+	AdminID     *int64     `db:"admin_id"`
+	ProcessedAt *time.Time `db:"processed_at"`
+	CreatedAt   time.Time  `db:"created_at"`
+}
+
+var referralWithdrawalColumns = []string{"id", "customer_id", "amount", "details", "status", "admin_id", "processed_at", "created_at"}
+
+// withdrawableQuery returns the referral money left in the customer balance.
+// Purchases spend it before top-ups, which can never be cashed out.
+const withdrawableQuery = `SELECT LEAST(balance, referral_balance) FROM customer WHERE telegram_id = $1`
+
+type ReferralWithdrawalRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewReferralWithdrawalRepository(pool *pgxpool.Pool) *ReferralWithdrawalRepository {
+	return &ReferralWithdrawalRepository{pool: pool}
+}
+
+// Withdrawable returns how much the customer can cash out.
+func (r *ReferralWithdrawalRepository) Withdrawable(ctx context.Context, telegramID int64) (float64, error) {
+	var amount float64
+	if err := r.pool.QueryRow(ctx, withdrawableQuery, telegramID).Scan(&amount); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to query withdrawable amount: %w", err)
+	}
+	return max(amount, 0), nil
+}
+
+// Create holds the amount: it is debited from the customer balance and stored
+// as a held withdrawal in one transaction.
+func (r *ReferralWithdrawalRepository) Create(ctx context.Context, telegramID int64, amount float64, details string) (*ReferralWithdrawal, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // ignore rollback error
+
+	if _, err := tx.Exec(ctx, "SELECT 1 FROM customer WHERE telegram_id = $1 FOR UPDATE", telegramID); err != nil {
+		return nil, fmt.Errorf("failed to lock customer: %w", err)
+	}
+	var available float64
+	if err := tx.QueryRow(ctx, withdrawableQuery, telegramID).Scan(&available); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInsufficientFunds
+		}
+		return nil, fmt.Errorf("failed to query withdrawable amount: %w", err)
+	}
+	if amount > available {
+		return nil, ErrInsufficientFunds
+	}
+
+	sql, args, err := sq.Update("customer").
+		Set("balance", sq.Expr("balance - ?", amount)).
+		Set("referral_balance", sq.Expr("referral_balance - ?", amount)).
+		Where(sq.Eq{"telegram_id": telegramID}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build update customer balance: %w", err)
+	}
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return nil, fmt.Errorf("failed to update customer balance: %w", err)
+	}
+
+	sql, args, err = sq.Insert("referral_withdrawal").
+		Columns("customer_id", "amount", "details", "status").
+		Values(telegramID, amount, details, ReferralWithdrawalStatusHeld).
+		Suffix("RETURNING id, created_at").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build insert referral_withdrawal: %w", err)
+	}
+	w := &ReferralWithdrawal{CustomerID: telegramID, Amount: amount, Details: details, Status: ReferralWithdrawalStatusHeld}
+	if err := tx.QueryRow(ctx, sql, args...).Scan(&w.ID, &w.CreatedAt); err != nil {
+		return nil, fmt.Errorf("failed to insert referral_withdrawal: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return w, nil
+}
+
+// FindHeld returns withdrawals waiting for an admin decision, oldest first.
+func (r *ReferralWithdrawalRepository) FindHeld(ctx context.Context, limit int) ([]ReferralWithdrawal, error) {
+	sql, args, err := sq.Select(referralWithdrawalColumns...).
+		From("referral_withdrawal").
+		Where(sq.Eq{"status": ReferralWithdrawalStatusHeld}).
+		OrderBy("created_at").
+		Limit(uint64(limit)). //nolint:gosec // limit is a small positive constant
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select referral_withdrawal: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query referral_withdrawal: %w", err)
+	}
+	defer rows.Close()
+
+	var list []ReferralWithdrawal
+	for rows.Next() {
+		var w ReferralWithdrawal
+		if err := rows.Scan(&w.ID, &w.CustomerID, &w.Amount, &w.Details, &w.Status, &w.AdminID, &w.ProcessedAt, &w.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan referral_withdrawal: %w", err)
+		}
+		list = append(list, w)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating referral_withdrawal rows: %w", rows.Err())
+	}
+	return list, nil
+}
+
+// MarkPaid records the payout of a held withdrawal. It returns nil when the
+// withdrawal is missing or already processed.
+func (r *ReferralWithdrawalRepository) MarkPaid(ctx context.Context, id, adminID int64) (*ReferralWithdrawal, error) {
+	return r.process(ctx, r.pool, id, adminID, ReferralWithdrawalStatusPaid)
+}
+
+// Reject cancels a held withdrawal and returns the amount to the referral money
+// of the customer balance.
+func (r *ReferralWithdrawalRepository) Reject(ctx context.Context, id, adminID int64) (*ReferralWithdrawal, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // ignore rollback error
+
+	w, err := r.process(ctx, tx, id, adminID, ReferralWithdrawalStatusRejected)
+	if err != nil || w == nil {
+		return nil, err
+	}
+
+	sql, args, err := sq.Update("customer").
+		Set("balance", sq.Expr("balance + ?", w.Amount)).
+		Set("referral_balance", sq.Expr("referral_balance + ?", w.Amount)).
+		Where(sq.Eq{"telegram_id": w.CustomerID}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build update customer balance: %w", err)
+	}
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return nil, fmt.Errorf("failed to update customer balance: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return w, nil
+}
+
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+func (r *ReferralWithdrawalRepository) process(ctx context.Context, q rowQuerier, id, adminID int64, status string) (*ReferralWithdrawal, error) {
+	sql, args, err := sq.Update("referral_withdrawal").
+		Set("status", status).
+		Set("admin_id", adminID).
+		Set("processed_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": id, "status": ReferralWithdrawalStatusHeld}).
+		Suffix("RETURNING " + strings.Join(referralWithdrawalColumns, ", ")).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build update referral_withdrawal: %w", err)
+	}
+	var w ReferralWithdrawal
+	err = q.QueryRow(ctx, sql, args...).Scan(&w.ID, &w.CustomerID, &w.Amount, &w.Details, &w.Status, &w.AdminID, &w.ProcessedAt, &w.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update referral_withdrawal: %w", err)
+	}
+	return &w, nil
+}
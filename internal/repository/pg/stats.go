@@ -0,0 +1,126 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// StatsTotals holds the counters of a statistics period.
+type StatsTotals struct {
+	NewCustomers        int
+	Trials              int
+	ConvertedTrials     int
+	ActiveSubscriptions int
+	NewReferrals        int
+	RewardMoney         float64
+	RewardDays          int
+	WithdrawalsPaid     int
+	WithdrawnMoney      float64
+	PromoRedemptions    int
+}
+
+// RevenueRow sums paid purchases of one provider, currency and plan.
+type RevenueRow struct {
+	Provider string
+	Currency string
+	Months   int
+	Count    int
+	Amount   float64
+}
+
+type StatsRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewStatsRepository(pool *pgxpool.Pool) *StatsRepository {
+	return &StatsRepository{pool: pool}
+}
+
+// Totals counts the events of [from, to). Active subscriptions are counted at
+// to, see Subscriptions.
+// A trial is converted when its user paid for a subscription after it started,
+// records of migrated and paying customers are not trials.
+func (r *StatsRepository) Totals(ctx context.Context, from, to time.Time) (*StatsTotals, error) {
+	sql, args, err := sq.Select().
+		Column(sq.Expr("(SELECT COUNT(*) FROM customer WHERE created_at >= ? AND created_at < ?)", from, to)).
+		Column(sq.Expr("(SELECT COUNT(*) FROM trial_usage WHERE variant <> 'paid' AND trial_used_at >= ? AND trial_used_at < ?)", from, to)).
+		Column(sq.Expr(`(SELECT COUNT(*) FROM trial_usage t
+			WHERE t.variant <> 'paid' AND t.trial_used_at >= ? AND t.trial_used_at < ?
+			AND EXISTS (SELECT 1 FROM purchase p JOIN customer c ON c.id = p.customer_id
+				WHERE c.telegram_id = t.telegram_id AND p.status = 'paid' AND p.paid_at >= t.trial_used_at))`, from, to)).
+		Column(sq.Expr(`(SELECT COUNT(*) FROM customer WHERE expire_at > ?)
+			+ (SELECT COUNT(*) FROM subscription WHERE panel_username IS NOT NULL AND expire_at > ?)`, to, to)).
+		Column(sq.Expr("(SELECT COUNT(*) FROM referral WHERE used_at >= ? AND used_at < ?)", from, to)).
+		Column(sq.Expr(`(SELECT COALESCE(SUM(amount) FILTER (WHERE kind = 'money'), 0) FROM referral_reward
+			WHERE status = 'released' AND released_at >= ? AND released_at < ?)`, from, to)).
+		Column(sq.Expr(`(SELECT COALESCE(SUM(days) FILTER (WHERE kind = 'days'), 0) FROM referral_reward
+			WHERE status = 'released' AND released_at >= ? AND released_at < ?)`, from, to)).
+		Column(sq.Expr("(SELECT COUNT(*) FROM referral_withdrawal WHERE status = 'paid' AND processed_at >= ? AND processed_at < ?)", from, to)).
+		Column(sq.Expr("(SELECT COALESCE(SUM(amount), 0) FROM referral_withdrawal WHERE status = 'paid' AND processed_at >= ? AND processed_at < ?)", from, to)).
+		Column(sq.Expr("(SELECT COUNT(*) FROM promocode_usage WHERE used_at >= ? AND used_at < ?)", from, to)).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build stats totals: %w", err)
+	}
+	var t StatsTotals
+	err = r.pool.QueryRow(ctx, sql, args...).Scan(&t.NewCustomers, &t.Trials, &t.ConvertedTrials, &t.ActiveSubscriptions,
+		&t.NewReferrals, &t.RewardMoney, &t.RewardDays, &t.WithdrawalsPaid, &t.WithdrawnMoney, &t.PromoRedemptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stats totals: %w", err)
+	}
+	return &t, nil
+}
+
+// Revenue groups purchases paid in [from, to) by provider, currency and plan.
+func (r *StatsRepository) Revenue(ctx context.Context, from, to time.Time) ([]RevenueRow, error) {
+	sql, args, err := sq.Select("COALESCE(invoice_type, '')", "COALESCE(currency, '')", "month", "COUNT(*)", "SUM(amount)").
+		From("purchase").
+		Where(sq.Eq{"status": PurchaseStatusPaid}).
+		Where(sq.GtOrEq{"paid_at": from}).
+		Where(sq.Lt{"paid_at": to}).
+		GroupBy("1", "2", "3").
+		OrderBy("1", "2", "3").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build stats revenue: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stats revenue: %w", err)
+	}
+	defer rows.Close()
+
+	var revenue []RevenueRow
+	for rows.Next() {
+		var row RevenueRow
+		if err := rows.Scan(&row.Provider, &row.Currency, &row.Months, &row.Count, &row.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan stats revenue: %w", err)
+		}
+		revenue = append(revenue, row)
+	}
+	return revenue, rows.Err()
+}
+
+// Subscriptions counts the subscriptions active at now and the ones of them
+// expiring before until. The primary subscription keeps its expiration on the
+// customer, extra ones on their subscription row.
+func (r *StatsRepository) Subscriptions(ctx context.Context, now, until time.Time) (active, expiring int, err error) {
+	expirations := sq.Select("expire_at").From("customer").
+		Suffix("UNION ALL SELECT expire_at FROM subscription WHERE panel_username IS NOT NULL")
+	sql, args, err := sq.Select("COUNT(*)").
+		Column(sq.Expr("COUNT(*) FILTER (WHERE expire_at < ?)", until)).
+		FromSelect(expirations, "s").
+		Where(sq.Gt{"expire_at": now}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to build subscription counts: %w", err)
+	}
+	if err := r.pool.QueryRow(ctx, sql, args...).Scan(&active, &expiring); err != nil {
+		return 0, 0, fmt.Errorf("failed to query subscription counts: %w", err)
+	}
+	return active, expiring, nil
+}
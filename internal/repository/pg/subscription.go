@@ -0,0 +1,230 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	domain "remnawave-tg-shop-bot/internal/domain/customer"
+)
+
+// Subscription is a panel user of the customer. PanelUsername is nil for the
+// primary subscription, whose link and expiration are kept on the customer.
+type Subscription struct {
+	ID               int64      `db:"id"`
+	CustomerID       int64      `db:"customer_id"`
+	PanelUUID        *uuid.UUID `db:"panel_uuid"`
+	PanelUsername    *string    `db:"panel_username"`
+	ExpireAt         *time.Time `db:"expire_at"`
+	SubscriptionLink *string    `db:"subscription_link"`
+	CreatedAt        time.Time  `db:"created_at"`
+}
+
+// Primary reports whether the subscription is the one found by telegram id.
+func (s *Subscription) Primary() bool {
+	return s.PanelUsername == nil
+}
+
+type SubscriptionRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewSubscriptionRepository(pool *pgxpool.Pool) *SubscriptionRepository {
+	return &SubscriptionRepository{pool: pool}
+}
+
+var subscriptionColumns = []string{
+	"id", "customer_id", "panel_uuid", "panel_username", "expire_at", "subscription_link", "created_at",
+}
+
+func scanSubscription(row pgx.Row, s *Subscription) error {
+	return row.Scan(&s.ID, &s.CustomerID, &s.PanelUUID, &s.PanelUsername, &s.ExpireAt, &s.SubscriptionLink, &s.CreatedAt)
+}
+
+// ListByCustomer returns the subscriptions of the customer, the primary one first.
+func (r *SubscriptionRepository) ListByCustomer(ctx context.Context, customerID int64) ([]Subscription, error) {
+	sql, args, err := sq.Select(subscriptionColumns...).
+		From("subscription").
+		Where(sq.Eq{"customer_id": customerID}).
+		OrderBy("panel_username IS NOT NULL", "id").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select subscription: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subscription: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Subscription
+	for rows.Next() {
+		var s Subscription
+		if err := scanSubscription(rows, &s); err != nil {
+			return nil, fmt.Errorf("failed to scan subscription: %w", err)
+		}
+		list = append(list, s)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating subscription rows: %w", rows.Err())
+	}
+	return list, nil
+}
+
+func (r *SubscriptionRepository) FindByID(ctx context.Context, id int64) (*Subscription, error) {
+	return r.findOne(ctx, sq.Eq{"id": id})
+}
+
+func (r *SubscriptionRepository) FindByPanelUUID(ctx context.Context, panelUUID uuid.UUID) (*Subscription, error) {
+	return r.findOne(ctx, sq.Eq{"panel_uuid": panelUUID})
+}
+
+func (r *SubscriptionRepository) FindByPanelUsername(ctx context.Context, username string) (*Subscription, error) {
+	return r.findOne(ctx, sq.Eq{"panel_username": username})
+}
+
+func (r *SubscriptionRepository) findOne(ctx context.Context, where sq.Eq) (*Subscription, error) {
+	sql, args, err := sq.Select(subscriptionColumns...).
+		From("subscription").
+		Where(where).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select subscription: %w", err)
+	}
+	var s Subscription
+	if err := scanSubscription(r.pool.QueryRow(ctx, sql, args...), &s); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to query subscription: %w", err)
+	}
+	return &s, nil
+}
+
+// ExpiringSubscription is an extra subscription due for an expiry reminder.
+// Number is its position in the subscription list of the customer.
+type ExpiringSubscription struct {
+	ID         int64
+	TelegramID int64
+	Language   string
+	Number     int
+	ExpireAt   time.Time
+}
+
+// FindExpiringExtra returns the extra subscriptions expiring within [start, end]
+// of reachable customers. The primary ones are reminded through the customer.
+func (r *SubscriptionRepository) FindExpiringExtra(ctx context.Context, start, end time.Time) ([]ExpiringSubscription, error) {
+	sql, args, err := sq.Select("s.id", "c.telegram_id", "c.language", "s.expire_at").
+		Column(`(SELECT COUNT(*) FROM subscription o
+			WHERE o.customer_id = s.customer_id AND o.panel_username IS NOT NULL AND o.id <= s.id)
+			+ (c.subscription_link IS NOT NULL OR EXISTS (SELECT 1 FROM subscription p
+				WHERE p.customer_id = s.customer_id AND p.panel_username IS NULL))::int`).
+		From("subscription s").
+		Join("customer c ON c.id = s.customer_id").
+		Where(sq.And{
+			sq.NotEq{"s.panel_username": nil},
+			sq.Eq{"c.unreachable_at": nil},
+			sq.GtOrEq{"s.expire_at": start},
+			sq.LtOrEq{"s.expire_at": end},
+		}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select expiring subscription: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expiring subscription: %w", err)
+	}
+	defer rows.Close()
+
+	var list []ExpiringSubscription
+	for rows.Next() {
+		var s ExpiringSubscription
+		if err := rows.Scan(&s.ID, &s.TelegramID, &s.Language, &s.ExpireAt, &s.Number); err != nil {
+			return nil, fmt.Errorf("failed to scan expiring subscription: %w", err)
+		}
+		list = append(list, s)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating expiring subscription rows: %w", rows.Err())
+	}
+	return list, nil
+}
+
+// SetPanelUser records the panel user the subscription was applied to.
+func (r *SubscriptionRepository) SetPanelUser(ctx context.Context, id int64, panelUUID uuid.UUID, expireAt time.Time, link string) error {
+	sql, args, err := sq.Update("subscription").
+		Set("panel_uuid", panelUUID).
+		Set("expire_at", expireAt).
+		Set("subscription_link", link).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build update subscription: %w", err)
+	}
+	if _, err := r.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+	return nil
+}
+
+// subscriptionForJob returns the id of the subscription the job extends. A job
+// without one extends the primary subscription, which is created on the first
+// purchase, and NewSubscription adds an extra subscription of the customer.
+func subscriptionForJob(ctx context.Context, q rowQuerier, job *ProvisioningJob) (int64, error) {
+	if job.SubscriptionID != nil && !job.NewSubscription {
+		return *job.SubscriptionID, nil
+	}
+
+	var id int64
+	if !job.NewSubscription {
+		sql, args, err := sq.Select("id").
+			From("subscription").
+			Where(sq.Eq{"customer_id": job.CustomerID, "panel_username": nil}).
+			PlaceholderFormat(sq.Dollar).ToSql()
+		if err != nil {
+			return 0, fmt.Errorf("failed to build select subscription: %w", err)
+		}
+		err = q.QueryRow(ctx, sql, args...).Scan(&id)
+		if err == nil {
+			return id, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("failed to query subscription: %w", err)
+		}
+	}
+
+	sql, args, err := sq.Insert("subscription").
+		Columns("customer_id").
+		Values(job.CustomerID).
+		Suffix("RETURNING id").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build insert subscription: %w", err)
+	}
+	if err := q.QueryRow(ctx, sql, args...).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to insert subscription: %w", err)
+	}
+	if !job.NewSubscription {
+		return id, nil
+	}
+
+	sql, args, err = sq.Update("subscription").
+		Set("panel_username", domain.SubscriptionUsername(job.TelegramID, id)).
+		Where(sq.Eq{"id": id}).
+		Suffix("RETURNING id").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build update subscription: %w", err)
+	}
+	if err := q.QueryRow(ctx, sql, args...).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to name subscription: %w", err)
+	}
+	return id, nil
+}
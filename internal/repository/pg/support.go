@@ -0,0 +1,193 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	SupportTicketOpen   = "open"
+	SupportTicketClosed = "closed"
+)
+
+// SupportTicket binds a customer to their forum topic in the support group.
+type SupportTicket struct {
+	ID         int64     `db:"id"`
+	CustomerID int64     `db:"customer_id"`
+	ThreadID   int       `db:"thread_id"`
+	Status     string    `db:"status"`
+	CreatedAt  time.Time `db:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
+
+// CannedResponse is a prepared answer sent to a customer with /r <name>.
+type CannedResponse struct {
+	ID   int64  `db:"id"`
+	Name string `db:"name"`
+	Text string `db:"text"`
+}
+
+type SupportRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewSupportRepository(pool *pgxpool.Pool) *SupportRepository {
+	return &SupportRepository{pool: pool}
+}
+
+func (r *SupportRepository) FindByCustomer(ctx context.Context, customerID int64) (*SupportTicket, error) {
+	return r.findTicket(ctx, sq.Eq{"customer_id": customerID})
+}
+
+func (r *SupportRepository) FindByThread(ctx context.Context, threadID int) (*SupportTicket, error) {
+	return r.findTicket(ctx, sq.Eq{"thread_id": threadID})
+}
+
+func (r *SupportRepository) findTicket(ctx context.Context, where sq.Eq) (*SupportTicket, error) {
+	sql, args, err := sq.Select("id", "customer_id", "thread_id", "status", "created_at", "updated_at").
+		From("support_ticket").
+		Where(where).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select support ticket: %w", err)
+	}
+	var t SupportTicket
+	err = r.pool.QueryRow(ctx, sql, args...).Scan(&t.ID, &t.CustomerID, &t.ThreadID, &t.Status, &t.CreatedAt, &t.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query support ticket: %w", err)
+	}
+	return &t, nil
+}
+
+func (r *SupportRepository) Create(ctx context.Context, t *SupportTicket) error {
+	sql, args, err := sq.Insert("support_ticket").
+		Columns("customer_id", "thread_id", "status").
+		Values(t.CustomerID, t.ThreadID, t.Status).
+		Suffix("RETURNING id, created_at, updated_at").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert support ticket: %w", err)
+	}
+	if err := r.pool.QueryRow(ctx, sql, args...).Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to insert support ticket: %w", err)
+	}
+	return nil
+}
+
+// SetThread moves the ticket to a new topic, used when admins deleted the old one.
+func (r *SupportRepository) SetThread(ctx context.Context, id int64, threadID int) error {
+	sql, args, err := sq.Update("support_ticket").
+		Set("thread_id", threadID).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build update support ticket thread: %w", err)
+	}
+	if _, err := r.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("failed to update support ticket thread: %w", err)
+	}
+	return nil
+}
+
+// SetStatus changes the status of the ticket. It returns false when the
+// ticket already had the status, so concurrent updates change it once.
+func (r *SupportRepository) SetStatus(ctx context.Context, id int64, status string) (bool, error) {
+	sql, args, err := sq.Update("support_ticket").
+		Set("status", status).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.And{sq.Eq{"id": id}, sq.NotEq{"status": status}}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build update support ticket status: %w", err)
+	}
+	tag, err := r.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to update support ticket status: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// CannedResponses returns the canned responses ordered by name.
+func (r *SupportRepository) CannedResponses(ctx context.Context) ([]CannedResponse, error) {
+	sql, args, err := sq.Select("id", "name", "text").
+		From("support_canned_response").
+		OrderBy("name").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select canned responses: %w", err)
+	}
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query canned responses: %w", err)
+	}
+	defer rows.Close()
+
+	var responses []CannedResponse
+	for rows.Next() {
+		var c CannedResponse
+		if err := rows.Scan(&c.ID, &c.Name, &c.Text); err != nil {
+			return nil, fmt.Errorf("failed to scan canned response: %w", err)
+		}
+		responses = append(responses, c)
+	}
+	return responses, rows.Err()
+}
+
+func (r *SupportRepository) FindCannedResponse(ctx context.Context, name string) (*CannedResponse, error) {
+	sql, args, err := sq.Select("id", "name", "text").
+		From("support_canned_response").
+		Where(sq.Eq{"name": name}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select canned response: %w", err)
+	}
+	var c CannedResponse
+	err = r.pool.QueryRow(ctx, sql, args...).Scan(&c.ID, &c.Name, &c.Text)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query canned response: %w", err)
+	}
+	return &c, nil
+}
+
+// SaveCannedResponse creates the canned response or replaces its text.
+func (r *SupportRepository) SaveCannedResponse(ctx context.Context, name, text string) error {
+	sql, args, err := sq.Insert("support_canned_response").
+		Columns("name", "text").
+		Values(name, text).
+		Suffix("ON CONFLICT (name) DO UPDATE SET text = EXCLUDED.text").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build upsert canned response: %w", err)
+	}
+	if _, err := r.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("failed to upsert canned response: %w", err)
+	}
+	return nil
+}
+
+func (r *SupportRepository) DeleteCannedResponse(ctx context.Context, name string) (bool, error) {
+	sql, args, err := sq.Delete("support_canned_response").
+		Where(sq.Eq{"name": name}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build delete canned response: %w", err)
+	}
+	tag, err := r.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete canned response: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,96 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// TrialVariantPaid marks customers who paid before taking a trial.
+const TrialVariantPaid = "paid"
+
+type TrialUsage struct {
+	TelegramID  int64     `db:"telegram_id"`
+	Variant     string    `db:"variant"`
+	TrialUsedAt time.Time `db:"trial_used_at"`
+}
+
+type TrialUsageRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewTrialUsageRepository(pool *pgxpool.Pool) *TrialUsageRepository {
+	return &TrialUsageRepository{pool: pool}
+}
+
+// Claim records the trial of the user. It returns false when the user already had one.
+func (r *TrialUsageRepository) Claim(ctx context.Context, telegramID int64, variant string) (bool, error) {
+	sql, args, err := sq.Insert("trial_usage").
+		Columns("telegram_id", "variant").
+		Values(telegramID, variant).
+		Suffix("ON CONFLICT (telegram_id) DO NOTHING").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build insert trial_usage: %w", err)
+	}
+	tag, err := r.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert trial_usage: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *TrialUsageRepository) FindByTelegramId(ctx context.Context, telegramID int64) (*TrialUsage, error) {
+	sql, args, err := sq.Select("telegram_id", "variant", "trial_used_at").
+		From("trial_usage").
+		Where(sq.Eq{"telegram_id": telegramID}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select trial_usage: %w", err)
+	}
+	var u TrialUsage
+	err = r.pool.QueryRow(ctx, sql, args...).Scan(&u.TelegramID, &u.Variant, &u.TrialUsedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trial_usage: %w", err)
+	}
+	return &u, nil
+}
+
+// Release removes the record so the user can retry after a failed activation.
+func (r *TrialUsageRepository) Release(ctx context.Context, telegramID int64) error {
+	sql, args, err := sq.Delete("trial_usage").
+		Where(sq.Eq{"telegram_id": telegramID}).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build delete trial_usage: %w", err)
+	}
+	if _, err := r.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("failed to delete trial_usage: %w", err)
+	}
+	return nil
+}
+
+// closeTrial records the first payment of the user, so that a trial can't
+// overwrite the paid subscription afterwards.
+func closeTrial(ctx context.Context, tx pgx.Tx, telegramID int64) error {
+	sql, args, err := sq.Insert("trial_usage").
+		Columns("telegram_id", "variant").
+		Values(telegramID, TrialVariantPaid).
+		Suffix("ON CONFLICT (telegram_id) DO NOTHING").
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert trial_usage: %w", err)
+	}
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("failed to insert trial_usage: %w", err)
+	}
+	return nil
+}
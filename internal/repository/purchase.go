@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"remnawave-tg-shop-bot/internal/domain/purchase"
+	"remnawave-tg-shop-bot/internal/repository/pg"
 )
 
 type PurchaseRepository interface {
@@ -10,5 +11,5 @@ type PurchaseRepository interface {
 	FindByInvoiceTypeAndStatus(ctx context.Context, invoiceType purchase.InvoiceType, status purchase.Status) (*[]purchase.Purchase, error)
 	FindById(ctx context.Context, id int64) (*purchase.Purchase, error)
 	UpdateFields(ctx context.Context, id int64, updates map[string]interface{}) error
-	MarkAsPaid(ctx context.Context, purchaseID int64) error
+	Pay(ctx context.Context, purchaseID int64, notice *pg.OutboxMessage) (bool, error)
 }
@@ -0,0 +1,258 @@
+package faq
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"remnawave-tg-shop-bot/internal/repository/pg"
+)
+
+const (
+	// PageSize is the number of categories or articles on one menu page.
+	PageSize = 6
+	// DefaultLanguage is shown to users whose language has no FAQ.
+	DefaultLanguage = "en"
+
+	searchLimit = 10
+	maxTitle    = 100
+	// maxBody leaves room for the title and navigation in a 4096 chars message.
+	maxBody = 3500
+)
+
+var (
+	ErrFormat   = errors.New("unexpected command format")
+	ErrTooLong  = errors.New("title or text is too long")
+	ErrNotFound = errors.New("faq entry not found")
+)
+
+var (
+	languagePattern = regexp.MustCompile(`^[a-z]{2}[a-z-]{0,8}$`)
+	slugPattern     = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+)
+
+type Repository interface {
+	Categories(ctx context.Context, lang string) ([]pg.FAQCategory, error)
+	FindCategory(ctx context.Context, id int64) (*pg.FAQCategory, error)
+	CreateCategory(ctx context.Context, c *pg.FAQCategory) error
+	DeleteCategory(ctx context.Context, id int64) (bool, error)
+	Articles(ctx context.Context, categoryID int64) ([]pg.FAQArticle, error)
+	Pages(ctx context.Context, lang string) ([]pg.FAQArticle, error)
+	Search(ctx context.Context, lang, query string, limit int) ([]pg.FAQArticle, error)
+	FindArticle(ctx context.Context, id int64) (*pg.FAQArticle, error)
+	CreateArticle(ctx context.Context, a *pg.FAQArticle) error
+	SavePage(ctx context.Context, a *pg.FAQArticle) error
+	UpdateArticle(ctx context.Context, id int64, title, body string) (bool, error)
+	DeleteArticle(ctx context.Context, id int64) (bool, error)
+}
+
+type Service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Categories returns the categories of lang, or of DefaultLanguage when lang
+// has none.
+func (s *Service) Categories(ctx context.Context, lang string) ([]pg.FAQCategory, error) {
+	categories, err := s.repo.Categories(ctx, lang)
+	if err != nil || len(categories) > 0 || lang == DefaultLanguage {
+		return categories, err
+	}
+	return s.repo.Categories(ctx, DefaultLanguage)
+}
+
+func (s *Service) Category(ctx context.Context, id int64) (*pg.FAQCategory, error) {
+	return s.repo.FindCategory(ctx, id)
+}
+
+func (s *Service) Articles(ctx context.Context, categoryID int64) ([]pg.FAQArticle, error) {
+	return s.repo.Articles(ctx, categoryID)
+}
+
+func (s *Service) Article(ctx context.Context, id int64) (*pg.FAQArticle, error) {
+	return s.repo.FindArticle(ctx, id)
+}
+
+// Search looks the query up in the articles of the language the user browses,
+// see Categories.
+func (s *Service) Search(ctx context.Context, lang, query string) ([]pg.FAQArticle, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, nil
+	}
+	categories, err := s.Categories(ctx, lang)
+	if err != nil || len(categories) == 0 {
+		return nil, err
+	}
+	return s.repo.Search(ctx, categories[0].Language, query, searchLimit)
+}
+
+// Pages returns the info pages of lang completed with the DefaultLanguage
+// pages it lacks, ordered by slug.
+func (s *Service) Pages(ctx context.Context, lang string) ([]pg.FAQArticle, error) {
+	pages, err := s.repo.Pages(ctx, lang)
+	if err != nil || lang == DefaultLanguage {
+		return pages, err
+	}
+	fallback, err := s.repo.Pages(ctx, DefaultLanguage)
+	if err != nil {
+		return nil, err
+	}
+	have := make(map[string]bool, len(pages))
+	for _, p := range pages {
+		have[*p.Slug] = true
+	}
+	for _, p := range fallback {
+		if !have[*p.Slug] {
+			pages = append(pages, p)
+		}
+	}
+	sort.Slice(pages, func(i, j int) bool { return *pages[i].Slug < *pages[j].Slug })
+	return pages, nil
+}
+
+// Paginate returns the items of the zero based page, the page clamped to the
+// existing ones and the number of pages.
+func Paginate[T any](items []T, page int) ([]T, int, int) {
+	pages := (len(items) + PageSize - 1) / PageSize
+	if pages == 0 {
+		return nil, 0, 0
+	}
+	page = min(max(page, 0), pages-1)
+	return items[page*PageSize : min((page+1)*PageSize, len(items))], page, pages
+}
+
+// ArticleRequest is a parsed /faq_add or /faq_edit command, ID is the category
+// or the article.
+type ArticleRequest struct {
+	ID    int64
+	Title string
+	Body  string
+}
+
+// PageRequest is a parsed /faq_page command.
+type PageRequest struct {
+	Slug     string
+	Language string
+	Title    string
+	Body     string
+}
+
+// ParseCategory parses "<lang> <title>".
+func ParseCategory(args string) (lang, title string, err error) {
+	lang, title, _ = strings.Cut(strings.TrimSpace(args), " ")
+	title = strings.TrimSpace(title)
+	if !languagePattern.MatchString(lang) || title == "" {
+		return "", "", ErrFormat
+	}
+	if utf8.RuneCountInString(title) > maxTitle {
+		return "", "", ErrTooLong
+	}
+	return lang, title, nil
+}
+
+// ParseArticle parses "<id> <title>" followed by the article text on the next
+// lines.
+func ParseArticle(args string) (ArticleRequest, error) {
+	head, body := splitBody(args)
+	idField, title, _ := strings.Cut(head, " ")
+	id, err := strconv.ParseInt(idField, 10, 64)
+	if err != nil || id <= 0 {
+		return ArticleRequest{}, ErrFormat
+	}
+	req := ArticleRequest{ID: id, Title: strings.TrimSpace(title), Body: body}
+	return req, checkText(req.Title, req.Body)
+}
+
+// ParsePage parses "<slug> <lang> <title>" followed by the page text on the
+// next lines.
+func ParsePage(args string) (PageRequest, error) {
+	head, body := splitBody(args)
+	fields := strings.SplitN(head, " ", 3)
+	if len(fields) != 3 || !slugPattern.MatchString(fields[0]) || !languagePattern.MatchString(fields[1]) {
+		return PageRequest{}, ErrFormat
+	}
+	req := PageRequest{Slug: fields[0], Language: fields[1], Title: strings.TrimSpace(fields[2]), Body: body}
+	return req, checkText(req.Title, req.Body)
+}
+
+func splitBody(args string) (string, string) {
+	head, body, _ := strings.Cut(strings.TrimSpace(args), "\n")
+	return strings.TrimSpace(head), strings.TrimSpace(body)
+}
+
+func checkText(title, body string) error {
+	if title == "" || body == "" {
+		return ErrFormat
+	}
+	if utf8.RuneCountInString(title) > maxTitle || utf8.RuneCountInString(body) > maxBody {
+		return ErrTooLong
+	}
+	return nil
+}
+
+func (s *Service) AddCategory(ctx context.Context, lang, title string) (*pg.FAQCategory, error) {
+	c := &pg.FAQCategory{Language: lang, Title: title}
+	if err := s.repo.CreateCategory(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// AddArticle adds an article to the category req.ID in the category language.
+func (s *Service) AddArticle(ctx context.Context, req ArticleRequest) (*pg.FAQArticle, error) {
+	category, err := s.repo.FindCategory(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if category == nil {
+		return nil, ErrNotFound
+	}
+	a := &pg.FAQArticle{CategoryID: &category.ID, Language: category.Language, Title: req.Title, Body: req.Body}
+	if err := s.repo.CreateArticle(ctx, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// EditArticle replaces the title and text of the article or page req.ID.
+func (s *Service) EditArticle(ctx context.Context, req ArticleRequest) error {
+	ok, err := s.repo.UpdateArticle(ctx, req.ID, req.Title, req.Body)
+	if err == nil && !ok {
+		err = ErrNotFound
+	}
+	return err
+}
+
+// SavePage creates or replaces the info page req.Slug of req.Language.
+func (s *Service) SavePage(ctx context.Context, req PageRequest) (*pg.FAQArticle, error) {
+	a := &pg.FAQArticle{Language: req.Language, Slug: &req.Slug, Title: req.Title, Body: req.Body}
+	if err := s.repo.SavePage(ctx, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (s *Service) DeleteArticle(ctx context.Context, id int64) error {
+	ok, err := s.repo.DeleteArticle(ctx, id)
+	if err == nil && !ok {
+		err = ErrNotFound
+	}
+	return err
+}
+
+// DeleteCategory removes the category together with its articles.
+func (s *Service) DeleteCategory(ctx context.Context, id int64) error {
+	ok, err := s.repo.DeleteCategory(ctx, id)
+	if err == nil && !ok {
+		err = ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,221 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/utils"
+)
+
+const maxReason = 500
+
+// Audit actions.
+const (
+	ActionBlock   = "block"
+	ActionBan     = "ban"
+	ActionUnblock = "unblock"
+	ActionExpire  = "block_expired"
+)
+
+var (
+	ErrFormat           = errors.New("expected telegram id, optional duration and reason")
+	ErrReason           = errors.New("reason is too long")
+	ErrCustomerNotFound = errors.New("customer not found")
+	ErrAdminTarget      = errors.New("admins can't be blocked")
+	ErrNotBlocked       = errors.New("customer is not blocked")
+	ErrPanelEnable      = errors.New("block lifted but the panel user is still disabled")
+)
+
+type BlockRepository interface {
+	Find(ctx context.Context, telegramID int64) (*pg.CustomerBlock, error)
+	Block(ctx context.Context, telegramID int64, reason string, until *time.Time, panel bool) (bool, error)
+	Unblock(ctx context.Context, telegramID int64) error
+	MarkNotified(ctx context.Context, telegramID int64) (bool, error)
+}
+
+type AuditRepository interface {
+	Create(ctx context.Context, a *pg.AdminAudit) error
+}
+
+// Panel switches Remnawave users on and off, see remnawave.Client.
+type Panel interface {
+	SetUserEnabled(ctx context.Context, telegramId int64, enabled bool) error
+}
+
+// BlockRequest is a parsed /block or /ban command.
+type BlockRequest struct {
+	TelegramID int64
+	// Duration of the block, zero blocks until /unblock.
+	Duration time.Duration
+	Reason   string
+}
+
+// ParseBlock parses "<telegram_id> [duration] [reason]" where duration is a
+// number of minutes, hours or days such as 30m, 12h or 7d.
+func ParseBlock(args string) (BlockRequest, error) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return BlockRequest{}, ErrFormat
+	}
+	id, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || id <= 0 {
+		return BlockRequest{}, ErrFormat
+	}
+	req := BlockRequest{TelegramID: id}
+	fields = fields[1:]
+	if len(fields) > 0 {
+		if d, ok := parseDuration(fields[0]); ok {
+			req.Duration = d
+			fields = fields[1:]
+		}
+	}
+	req.Reason = strings.Join(fields, " ")
+	if utf8.RuneCountInString(req.Reason) > maxReason {
+		return BlockRequest{}, ErrReason
+	}
+	return req, nil
+}
+
+func parseDuration(s string) (time.Duration, bool) {
+	if len(s) < 2 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	switch s[len(s)-1] {
+	case 'm':
+		return time.Duration(n) * time.Minute, true
+	case 'h':
+		return time.Duration(n) * time.Hour, true
+	case 'd':
+		return time.Duration(n) * 24 * time.Hour, true
+	}
+	return 0, false
+}
+
+type Service struct {
+	blocks BlockRepository
+	audit  AuditRepository
+	panel  Panel
+	now    func() time.Time
+}
+
+func NewService(blocks BlockRepository, audit AuditRepository, panel Panel) *Service {
+	return &Service{
+		blocks: blocks,
+		audit:  audit,
+		panel:  panel,
+		now:    time.Now,
+	}
+}
+
+// Block stops the bot from serving the user. With disablePanel the user's
+// Remnawave account is disabled as well until the block is lifted.
+func (s *Service) Block(ctx context.Context, adminID int64, req BlockRequest, disablePanel bool) (*pg.CustomerBlock, error) {
+	if config.IsAdmin(req.TelegramID) {
+		return nil, ErrAdminTarget
+	}
+	var until *time.Time
+	if req.Duration > 0 {
+		t := s.now().Add(req.Duration)
+		until = &t
+	}
+	found, err := s.blocks.Block(ctx, req.TelegramID, req.Reason, until, disablePanel)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrCustomerNotFound
+	}
+	// The bot block stays when the panel call fails, /ban can be repeated.
+	if disablePanel {
+		if err := s.panel.SetUserEnabled(ctx, req.TelegramID, false); err != nil {
+			return nil, err
+		}
+	}
+
+	action := ActionBlock
+	if disablePanel {
+		action = ActionBan
+	}
+	details := req.Reason
+	if until != nil {
+		details = fmt.Sprintf("until %s; %s", until.UTC().Format(time.RFC3339), req.Reason)
+	}
+	s.record(ctx, adminID, action, req.TelegramID, details)
+	return &pg.CustomerBlock{TelegramID: req.TelegramID, Reason: req.Reason, BlockedAt: s.now(), Until: until, Panel: disablePanel}, nil
+}
+
+// Unblock lifts the block and re-enables the Remnawave user disabled by it.
+func (s *Service) Unblock(ctx context.Context, adminID, telegramID int64) error {
+	block, err := s.blocks.Find(ctx, telegramID)
+	if err != nil {
+		return err
+	}
+	if block == nil {
+		return ErrNotBlocked
+	}
+	return s.lift(ctx, adminID, ActionUnblock, block)
+}
+
+// Check returns the active block of the user or nil. notify is true exactly
+// once per block, for the update that should answer with the notice. A
+// temporary block that is over is lifted here.
+func (s *Service) Check(ctx context.Context, telegramID int64) (block *pg.CustomerBlock, notify bool, err error) {
+	block, err = s.blocks.Find(ctx, telegramID)
+	if err != nil || block == nil {
+		return nil, false, err
+	}
+	if block.Expired(s.now()) {
+		// A failed panel call is already logged, the bot block is gone either way.
+		if err := s.lift(ctx, 0, ActionExpire, block); err != nil && !errors.Is(err, ErrPanelEnable) {
+			return nil, false, err
+		}
+		return nil, false, nil
+	}
+	if block.Notified {
+		return block, false, nil
+	}
+	notify, err = s.blocks.MarkNotified(ctx, telegramID)
+	if err != nil {
+		return nil, false, err
+	}
+	return block, notify, nil
+}
+
+// lift removes the block before touching the panel, so a panel outage can't
+// keep the user locked out of the bot. A failed re-enable is logged and
+// returned; the Remnawave user then has to be enabled by hand.
+func (s *Service) lift(ctx context.Context, adminID int64, action string, block *pg.CustomerBlock) error {
+	if err := s.blocks.Unblock(ctx, block.TelegramID); err != nil {
+		return err
+	}
+	s.record(ctx, adminID, action, block.TelegramID, "")
+	if !block.Panel {
+		return nil
+	}
+	if err := s.panel.SetUserEnabled(ctx, block.TelegramID, true); err != nil {
+		slog.Error("re-enable panel user after block", "action", action, "user", utils.MaskHalfInt64(block.TelegramID), "err", err)
+		return fmt.Errorf("%w: %v", ErrPanelEnable, err)
+	}
+	return nil
+}
+
+// record writes the audit entry. A failed write is logged and does not undo the action.
+func (s *Service) record(ctx context.Context, adminID int64, action string, targetID int64, details string) {
+	slog.Info("admin action", "action", action, "admin", adminID, "target", utils.MaskHalfInt64(targetID))
+	err := s.audit.Create(ctx, &pg.AdminAudit{AdminID: adminID, Action: action, TargetID: targetID, Details: details})
+	if err != nil {
+		slog.Error("write admin audit", "action", action, "err", err)
+	}
+}
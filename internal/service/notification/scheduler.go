@@ -4,6 +4,8 @@ import (
 	"context"
 	"github.com/robfig/cron/v3"
 	"log/slog"
+
+	"remnawave-tg-shop-bot/internal/observability"
 )
 
 type subscriptionNotifier interface {
@@ -14,7 +16,9 @@ func RegisterSubscriptionCron(c *cron.Cron, svc subscriptionNotifier) error {
 	_, err := c.AddFunc("@daily", func() {
 		if err := svc.SendSubscriptionNotifications(context.Background()); err != nil {
 			slog.Error("send subscription notifications", "err", err)
+			return
 		}
+		observability.CronSucceeded("subscription_notifications")
 	})
 	return err
 }
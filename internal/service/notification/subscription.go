@@ -9,18 +9,25 @@ import (
 	"remnawave-tg-shop-bot/internal/adapter/telegram/handler"
 	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
 	"remnawave-tg-shop-bot/internal/pkg/translation"
+	"remnawave-tg-shop-bot/internal/repository/pg"
 	custrepo "remnawave-tg-shop-bot/internal/service/customer"
 	"time"
 )
 
+// ExpiringSubscriptions finds the extra subscriptions of the customers, see pg.SubscriptionRepository.
+type ExpiringSubscriptions interface {
+	FindExpiringExtra(ctx context.Context, start, end time.Time) ([]pg.ExpiringSubscription, error)
+}
+
 type SubscriptionService struct {
 	customerRepository custrepo.Repository
+	subscriptions      ExpiringSubscriptions
 	telegramBot        *bot.Bot
 	tm                 *translation.Manager
 }
 
-func NewSubscriptionService(customerRepository custrepo.Repository, telegramBot *bot.Bot, tm *translation.Manager) *SubscriptionService {
-	return &SubscriptionService{customerRepository: customerRepository, telegramBot: telegramBot, tm: tm}
+func NewSubscriptionService(customerRepository custrepo.Repository, subscriptions ExpiringSubscriptions, telegramBot *bot.Bot, tm *translation.Manager) *SubscriptionService {
+	return &SubscriptionService{customerRepository: customerRepository, subscriptions: subscriptions, telegramBot: telegramBot, tm: tm}
 }
 
 func (s *SubscriptionService) SendSubscriptionNotifications(ctx context.Context) error {
@@ -50,6 +57,43 @@ func (s *SubscriptionService) SendSubscriptionNotifications(ctx context.Context)
 			"days_until_expiration", daysUntilExpiration)
 	}
 
+	return s.sendExtraSubscriptionNotifications(ctx, now)
+}
+
+// sendExtraSubscriptionNotifications reminds the customers of their expiring
+// extra subscriptions, each one by its number in the subscription list.
+func (s *SubscriptionService) sendExtraSubscriptionNotifications(ctx context.Context, now time.Time) error {
+	subs, err := s.subscriptions.FindExpiringExtra(ctx, now, now.AddDate(0, 0, 3))
+	if err != nil {
+		return fmt.Errorf("failed to get expiring extra subscriptions: %w", err)
+	}
+	slog.Info(fmt.Sprintf("Found %d expiring extra subscriptions", len(subs)))
+
+	for _, sub := range subs {
+		_, err := s.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: sub.TelegramID,
+			Text: s.tm.Format(sub.Language, "subscription_expiring_extra", translation.Args{
+				"number": sub.Number,
+				"expire": sub.ExpireAt.Format("02.01.2006"),
+			}),
+			ParseMode: models.ParseModeHTML,
+			ReplyMarkup: models.InlineKeyboardMarkup{
+				InlineKeyboard: [][]models.InlineKeyboardButton{
+					{
+						{
+							Text:         s.tm.GetText(sub.Language, "renew_subscription_button"),
+							CallbackData: fmt.Sprintf("%s?sub=%d", handler.CallbackBuy, sub.ID),
+						},
+					},
+				},
+			},
+		})
+		if err != nil {
+			slog.Error("Failed to send notification", "subscription_id", sub.ID, "error", err)
+			continue
+		}
+		slog.Info("Notification sent successfully", "subscription_id", sub.ID)
+	}
 	return nil
 }
 
@@ -76,10 +120,7 @@ func (s *SubscriptionService) getDaysUntilExpiration(now time.Time, expireAt tim
 func (s *SubscriptionService) sendNotification(ctx context.Context, customer domaincustomer.Customer) error {
 	expireDate := customer.ExpireAt.Format("02.01.2006")
 
-	messageText := fmt.Sprintf(
-		s.tm.GetText(customer.Language, "subscription_expiring"),
-		expireDate,
-	)
+	messageText := s.tm.Format(customer.Language, "subscription_expiring", translation.Args{"expire": expireDate})
 
 	_, err := s.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:    customer.TelegramID,
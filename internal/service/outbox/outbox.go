@@ -0,0 +1,218 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	tg "remnawave-tg-shop-bot/internal/adapter/telegram/messenger"
+	"remnawave-tg-shop-bot/internal/observability"
+	"remnawave-tg-shop-bot/internal/repository/pg"
+)
+
+const (
+	pollInterval = 5 * time.Second
+	lease        = time.Minute
+	batchSize    = 50
+	// sendInterval keeps the dispatcher under the Telegram limit of 30 messages a second.
+	sendInterval = 40 * time.Millisecond
+
+	maxAttempts   = 8
+	retryDelay    = 10 * time.Second
+	maxRetryDelay = 30 * time.Minute
+)
+
+// Outcomes of the outbox messages metric.
+const (
+	messageSent        = "sent"
+	messageRetried     = "retried"
+	messageThrottled   = "throttled"
+	messageFailed      = "failed"
+	messageUnreachable = "unreachable"
+)
+
+type Repository interface {
+	Enqueue(ctx context.Context, msg *pg.OutboxMessage) error
+	Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]pg.OutboxMessage, error)
+	MarkSent(ctx context.Context, id int64) error
+	Retry(ctx context.Context, id int64, runAt time.Time, lastError string) error
+	Postpone(ctx context.Context, chatID int64, runAt time.Time) error
+	Fail(ctx context.Context, id int64, lastError string) error
+	MarkUnreachable(ctx context.Context, chatID int64, lastError string) error
+}
+
+// NewMessage converts the text message parameters into an outbox message. Only
+// inline keyboards are supported as the reply markup.
+func NewMessage(params *bot.SendMessageParams) (*pg.OutboxMessage, error) {
+	chatID, ok := params.ChatID.(int64)
+	if !ok {
+		return nil, fmt.Errorf("outbox message chat id must be int64, got %T", params.ChatID)
+	}
+	msg := &pg.OutboxMessage{ChatID: chatID, Text: params.Text, ParseMode: string(params.ParseMode)}
+	if params.ReplyMarkup != nil {
+		markup, err := json.Marshal(params.ReplyMarkup)
+		if err != nil {
+			return nil, fmt.Errorf("encode reply markup: %w", err)
+		}
+		msg.ReplyMarkup = markup
+	}
+	return msg, nil
+}
+
+// Dispatcher delivers the outbox messages, one at a time per chat and in the
+// order they were written. Failed sends are retried with a growing delay,
+// Telegram asking to slow down postpones the chat, and a blocked bot or a
+// missing chat marks the customer unreachable.
+type Dispatcher struct {
+	repo      Repository
+	messenger tg.Messenger
+	now       func() time.Time
+	wake      chan struct{}
+}
+
+func NewDispatcher(repo Repository, messenger tg.Messenger) *Dispatcher {
+	return &Dispatcher{repo: repo, messenger: messenger, now: time.Now, wake: make(chan struct{}, 1)}
+}
+
+// Send stores the message for delivery and wakes the dispatcher up.
+func (d *Dispatcher) Send(ctx context.Context, params *bot.SendMessageParams) error {
+	msg, err := NewMessage(params)
+	if err != nil {
+		return err
+	}
+	if err := d.repo.Enqueue(ctx, msg); err != nil {
+		return err
+	}
+	d.Notify()
+	return nil
+}
+
+// Notify wakes the dispatcher up without waiting for the next poll.
+func (d *Dispatcher) Notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers the messages until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		if err := d.Dispatch(ctx); err != nil {
+			slog.Error("dispatch outbox messages", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// Dispatch delivers the due messages. Failed messages are rescheduled, the
+// returned error is about claiming them.
+func (d *Dispatcher) Dispatch(ctx context.Context) error {
+	for {
+		msgs, err := d.repo.Claim(ctx, d.now(), lease, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(msgs) == 0 {
+			return nil
+		}
+		for i := range msgs {
+			if i > 0 && !sleep(ctx, sendInterval) {
+				return ctx.Err()
+			}
+			d.deliver(ctx, &msgs[i])
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, msg *pg.OutboxMessage) {
+	params := &bot.SendMessageParams{ChatID: msg.ChatID, Text: msg.Text, ParseMode: models.ParseMode(msg.ParseMode)}
+	if len(msg.ReplyMarkup) > 0 {
+		var markup models.InlineKeyboardMarkup
+		if err := json.Unmarshal(msg.ReplyMarkup, &markup); err != nil {
+			d.fail(ctx, msg, err)
+			return
+		}
+		params.ReplyMarkup = markup
+	}
+
+	_, err := d.messenger.SendMessage(ctx, params)
+	var tooMany *bot.TooManyRequestsError
+	switch {
+	case err == nil:
+		if err := d.repo.MarkSent(ctx, msg.ID); err != nil {
+			slog.ErrorContext(ctx, "mark outbox message sent", "id", msg.ID, "err", err)
+		}
+		observability.OutboxMessages.WithLabelValues(messageSent).Inc()
+	case errors.As(err, &tooMany):
+		runAt := d.now().Add(time.Duration(tooMany.RetryAfter) * time.Second)
+		if err := d.repo.Postpone(ctx, msg.ChatID, runAt); err != nil {
+			slog.ErrorContext(ctx, "postpone outbox messages", "chat", msg.ChatID, "err", err)
+		}
+		observability.OutboxMessages.WithLabelValues(messageThrottled).Inc()
+	case unreachable(err):
+		slog.WarnContext(ctx, "chat is unreachable", "chat", msg.ChatID, "err", err)
+		if err := d.repo.MarkUnreachable(ctx, msg.ChatID, err.Error()); err != nil {
+			slog.ErrorContext(ctx, "mark chat unreachable", "chat", msg.ChatID, "err", err)
+		}
+		observability.OutboxMessages.WithLabelValues(messageUnreachable).Inc()
+	case errors.Is(err, bot.ErrorBadRequest) || msg.Attempts+1 >= maxAttempts:
+		d.fail(ctx, msg, err)
+	default:
+		runAt := d.now().Add(retryAfter(msg.Attempts + 1))
+		slog.WarnContext(ctx, "send outbox message", "id", msg.ID, "attempts", msg.Attempts+1, "retry_at", runAt, "err", err)
+		if err := d.repo.Retry(ctx, msg.ID, runAt, err.Error()); err != nil {
+			slog.ErrorContext(ctx, "reschedule outbox message", "id", msg.ID, "err", err)
+		}
+		observability.OutboxMessages.WithLabelValues(messageRetried).Inc()
+	}
+}
+
+func (d *Dispatcher) fail(ctx context.Context, msg *pg.OutboxMessage, cause error) {
+	slog.ErrorContext(ctx, "outbox message failed", "id", msg.ID, "chat", msg.ChatID, "err", cause)
+	if err := d.repo.Fail(ctx, msg.ID, cause.Error()); err != nil {
+		slog.ErrorContext(ctx, "mark outbox message failed", "id", msg.ID, "err", err)
+	}
+	observability.OutboxMessages.WithLabelValues(messageFailed).Inc()
+}
+
+// unreachable reports whether Telegram won't deliver any message to the chat:
+// the bot was blocked, the user deactivated or the chat doesn't exist.
+func unreachable(err error) bool {
+	return errors.Is(err, bot.ErrorForbidden) ||
+		errors.Is(err, bot.ErrorBadRequest) && strings.Contains(strings.ToLower(err.Error()), "chat not found")
+}
+
+// retryAfter doubles the delay with every attempt up to maxRetryDelay.
+func retryAfter(attempts int) time.Duration {
+	d := retryDelay
+	for i := 1; i < attempts && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	return min(d, maxRetryDelay)
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
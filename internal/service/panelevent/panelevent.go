@@ -0,0 +1,214 @@
+package panelevent
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+
+	"remnawave-tg-shop-bot/internal/adapter/remnawave"
+	"remnawave-tg-shop-bot/internal/adapter/telegram/handler"
+	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
+	"remnawave-tg-shop-bot/internal/observability"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	custrepo "remnawave-tg-shop-bot/internal/service/customer"
+)
+
+// Outcomes of the panel events metric.
+const (
+	eventApplied = "applied"
+	eventIgnored = "ignored"
+	eventFailed  = "failed"
+)
+
+// userEvents are the panel events that carry the current state of a user.
+var userEvents = map[string]bool{
+	remnawave.EventUserCreated:      true,
+	remnawave.EventUserModified:     true,
+	remnawave.EventUserDeleted:      true,
+	remnawave.EventUserRevoked:      true,
+	remnawave.EventUserDisabled:     true,
+	remnawave.EventUserEnabled:      true,
+	remnawave.EventUserLimited:      true,
+	remnawave.EventUserExpired:      true,
+	remnawave.EventUserTrafficReset: true,
+}
+
+// cutOffEvents only report that the subscription stopped working. One that
+// carries an expiry before the stored one was sent before a renewal.
+var cutOffEvents = map[string]bool{
+	remnawave.EventUserLimited: true,
+	remnawave.EventUserExpired: true,
+}
+
+// Sender delivers the notifications, see outbox.Dispatcher.
+type Sender interface {
+	Send(ctx context.Context, params *bot.SendMessageParams) error
+}
+
+// Subscriptions stores the panel users of the customers, see pg.SubscriptionRepository.
+type Subscriptions interface {
+	FindByPanelUUID(ctx context.Context, panelUUID uuid.UUID) (*pg.Subscription, error)
+	FindByPanelUsername(ctx context.Context, username string) (*pg.Subscription, error)
+	SetPanelUser(ctx context.Context, id int64, panelUUID uuid.UUID, expireAt time.Time, link string) error
+}
+
+// Blocks finds the moderation blocks of the customers, see pg.CustomerBlockRepository.
+type Blocks interface {
+	Find(ctx context.Context, telegramID int64) (*pg.CustomerBlock, error)
+}
+
+// Service keeps the customers in step with the changes made on the panel and
+// tells them when the panel cut their subscription off.
+type Service struct {
+	customers     custrepo.Repository
+	subscriptions Subscriptions
+	blocks        Blocks
+	sender        Sender
+	translation   *translation.Manager
+	now           func() time.Time
+}
+
+func NewService(customers custrepo.Repository, subscriptions Subscriptions, blocks Blocks, sender Sender, tm *translation.Manager) *Service {
+	return &Service{customers: customers, subscriptions: subscriptions, blocks: blocks, sender: sender, translation: tm, now: time.Now}
+}
+
+// Handle applies the panel event to the customer of the user. Events about
+// other entities or users without a customer are ignored.
+func (s *Service) Handle(ctx context.Context, event *remnawave.WebhookEvent) error {
+	ctx, span := observability.StartSpan(ctx, "panel.event", attribute.String("panel.event", event.Event))
+	outcome, err := s.handle(ctx, event)
+	observability.EndSpan(span, err)
+
+	label := event.Event
+	if !userEvents[label] {
+		label = "other"
+	}
+	observability.PanelEvents.WithLabelValues(label, outcome).Inc()
+	return err
+}
+
+func (s *Service) handle(ctx context.Context, event *remnawave.WebhookEvent) (string, error) {
+	if !userEvents[event.Event] || event.Data.TelegramID == nil {
+		return eventIgnored, nil
+	}
+	customer, err := s.customers.FindByTelegramId(ctx, *event.Data.TelegramID)
+	if err != nil {
+		return eventFailed, err
+	}
+	if customer == nil {
+		return eventIgnored, nil
+	}
+
+	extra := domaincustomer.IsSubscriptionUsername(customer.TelegramID, event.Data.Username)
+	sub, err := s.subscription(ctx, &event.Data, extra)
+	if err != nil {
+		return eventFailed, err
+	}
+	if extra && sub == nil {
+		// not a subscription of the bot, never copy it to the customer
+		return eventIgnored, nil
+	}
+	stored := customer.ExpireAt
+	if sub != nil && !sub.Primary() {
+		stored = sub.ExpireAt
+	}
+	if cutOffEvents[event.Event] && stored != nil && event.Data.ExpireAt.Before(*stored) {
+		// delivered late or again, don't undo the renewal
+		return eventIgnored, nil
+	}
+	if sub != nil && !sub.Primary() {
+		// extra subscriptions are kept apart from the customer, the panel may
+		// report a new one before its provisioning job recorded the UUID
+		if event.Event != remnawave.EventUserDeleted {
+			panelUUID, err := uuid.Parse(event.Data.UUID)
+			if err != nil && sub.PanelUUID != nil {
+				panelUUID, err = *sub.PanelUUID, nil
+			}
+			if err != nil {
+				return eventIgnored, nil
+			}
+			link := event.Data.SubscriptionURL
+			if link == "" && sub.SubscriptionLink != nil {
+				link = *sub.SubscriptionLink
+			}
+			if err := s.subscriptions.SetPanelUser(ctx, sub.ID, panelUUID, event.Data.ExpireAt, link); err != nil {
+				return eventFailed, err
+			}
+		}
+		if err := s.notify(ctx, customer, event.Event); err != nil {
+			return eventFailed, err
+		}
+		return eventApplied, nil
+	}
+
+	updates := map[string]interface{}{"missing_from_panel_at": nil}
+	if event.Event == remnawave.EventUserDeleted {
+		updates["missing_from_panel_at"] = s.now()
+	} else {
+		updates["expire_at"] = event.Data.ExpireAt
+		if event.Data.SubscriptionURL != "" {
+			updates["subscription_link"] = event.Data.SubscriptionURL
+		}
+	}
+	if err := s.customers.UpdateFields(ctx, customer.ID, updates); err != nil {
+		return eventFailed, err
+	}
+
+	if err := s.notify(ctx, customer, event.Event); err != nil {
+		return eventFailed, err
+	}
+	return eventApplied, nil
+}
+
+// subscription returns the subscription of the panel user, nil when the bot
+// knows the user neither by UUID nor, for an extra subscription, by username.
+func (s *Service) subscription(ctx context.Context, user *remnawave.WebhookUser, extra bool) (*pg.Subscription, error) {
+	if id, err := uuid.Parse(user.UUID); err == nil {
+		sub, err := s.subscriptions.FindByPanelUUID(ctx, id)
+		if err != nil || sub != nil {
+			return sub, err
+		}
+	}
+	if !extra {
+		return nil, nil
+	}
+	return s.subscriptions.FindByPanelUsername(ctx, user.Username)
+}
+
+// notify tells the customer that the subscription stopped working, with a
+// button to renew it. A blocked customer isn't told, /ban disables the panel
+// users itself.
+func (s *Service) notify(ctx context.Context, customer *domaincustomer.Customer, event string) error {
+	lang := customer.Language
+	var text string
+	switch event {
+	case remnawave.EventUserLimited:
+		text = s.translation.GetText(lang, "panel_traffic_limited")
+	case remnawave.EventUserExpired:
+		text = s.translation.GetText(lang, "panel_subscription_expired")
+	case remnawave.EventUserDisabled:
+		text = s.translation.GetText(lang, "panel_user_disabled")
+	default:
+		return nil
+	}
+	block, err := s.blocks.Find(ctx, customer.TelegramID)
+	if err != nil {
+		return err
+	}
+	if block != nil && !block.Expired(s.now()) {
+		return nil
+	}
+	return s.sender.Send(ctx, &bot.SendMessageParams{
+		ChatID:    customer.TelegramID,
+		ParseMode: models.ParseModeHTML,
+		Text:      text,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: s.translation.GetText(lang, "renew_subscription_button"), CallbackData: handler.CallbackBuy}},
+		}},
+	})
+}
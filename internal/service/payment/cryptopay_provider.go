@@ -27,6 +27,8 @@ func (p CryptoPayProvider) Type() domainpurchase.InvoiceType { return domainpurc
 
 func (p CryptoPayProvider) Enabled() bool { return config.IsCryptoPayEnabled() }
 
+func (p CryptoPayProvider) Ping(ctx context.Context) error { return p.client.Ping(ctx) }
+
 func (p CryptoPayProvider) CreateInvoice(ctx context.Context, amount int, months int, customer *domaincustomer.Customer) (string, int64, error) {
 	purchaseID, err := p.repo.Create(ctx, &domainpurchase.Purchase{
 		InvoiceType: domainpurchase.InvoiceTypeCrypto,
@@ -37,11 +39,11 @@ func (p CryptoPayProvider) CreateInvoice(ctx context.Context, amount int, months
 		Month:       months,
 	})
 	if err != nil {
-		slog.Error("Error creating purchase", "err", err)
+		slog.ErrorContext(ctx, "Error creating purchase", "err", err)
 		return "", 0, err
 	}
 
-	invoice, err := p.client.CreateInvoice(&cryptopay.InvoiceRequest{
+	invoice, err := p.client.CreateInvoice(ctx, &cryptopay.InvoiceRequest{
 		CurrencyType:   "fiat",
 		Fiat:           "RUB",
 		Amount:         fmt.Sprintf("%d", amount),
@@ -52,7 +54,7 @@ func (p CryptoPayProvider) CreateInvoice(ctx context.Context, amount int, months
 		PaidBtnUrl:     config.BotURL(),
 	})
 	if err != nil {
-		slog.Error("Error creating invoice", "err", err)
+		slog.ErrorContext(ctx, "Error creating invoice", "err", err)
 		return "", 0, err
 	}
 
@@ -63,7 +65,7 @@ func (p CryptoPayProvider) CreateInvoice(ctx context.Context, amount int, months
 	}
 
 	if err = p.repo.UpdateFields(ctx, purchaseID, updates); err != nil {
-		slog.Error("Error updating purchase", "err", err)
+		slog.ErrorContext(ctx, "Error updating purchase", "err", err)
 		return "", 0, err
 	}
 
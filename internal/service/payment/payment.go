@@ -2,27 +2,40 @@ package payment
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"remnawave-tg-shop-bot/internal/adapter/remnawave"
 	tg "remnawave-tg-shop-bot/internal/adapter/telegram/messenger"
 	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
 	domainpurchase "remnawave-tg-shop-bot/internal/domain/purchase"
+	"remnawave-tg-shop-bot/internal/observability"
 	"remnawave-tg-shop-bot/internal/pkg/cache"
 	"remnawave-tg-shop-bot/internal/pkg/config"
 	"remnawave-tg-shop-bot/internal/pkg/contextkey"
 	"remnawave-tg-shop-bot/internal/pkg/translation"
 	"remnawave-tg-shop-bot/internal/repository/pg"
 	custrepo "remnawave-tg-shop-bot/internal/service/customer"
-	"remnawave-tg-shop-bot/internal/ui"
+	"remnawave-tg-shop-bot/internal/service/outbox"
+	"remnawave-tg-shop-bot/internal/service/promo"
+	"remnawave-tg-shop-bot/internal/service/provisioning"
+	"remnawave-tg-shop-bot/internal/service/referral"
 	"remnawave-tg-shop-bot/utils"
-	"strings"
+	"slices"
 	"time"
 
 	remapi "github.com/Jolymmiles/remnawave-api-go/api"
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
-	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Outcomes of the payment attempts metric.
+const (
+	paymentCreated   = "created"
+	paymentFailed    = "failed"
+	paymentPaid      = "paid"
+	paymentCancelled = "cancelled"
 )
 
 type PaymentService struct {
@@ -32,10 +45,12 @@ type PaymentService struct {
 	messenger                tg.Messenger
 	translation              *translation.Manager
 	providers                map[domainpurchase.InvoiceType]Provider
-	referralRepository       *pg.ReferralRepository
+	referralService          *referral.Service
 	promocodeRepository      *pg.PromocodeRepository
 	promocodeUsageRepository *pg.PromocodeUsageRepository
-	cache                    *cache.Cache
+	cache                    *cache.Cache[int]
+	provisioning             *provisioning.Service
+	outbox                   *outbox.Dispatcher
 }
 
 // EnabledProviders returns slice of active payment providers.
@@ -56,10 +71,12 @@ func NewPaymentService(
 	customerRepository custrepo.Repository,
 	messenger tg.Messenger,
 	providers []Provider,
-	referralRepository *pg.ReferralRepository,
+	referralService *referral.Service,
 	promocodeRepository *pg.PromocodeRepository,
 	promocodeUsageRepository *pg.PromocodeUsageRepository,
-	cache *cache.Cache,
+	cache *cache.Cache[int],
+	provisioning *provisioning.Service,
+	outbox *outbox.Dispatcher,
 ) *PaymentService {
 	provMap := make(map[domainpurchase.InvoiceType]Provider)
 	for _, p := range providers {
@@ -74,14 +91,19 @@ func NewPaymentService(
 		messenger:                messenger,
 		translation:              translation,
 		providers:                provMap,
-		referralRepository:       referralRepository,
+		referralService:          referralService,
 		promocodeRepository:      promocodeRepository,
 		promocodeUsageRepository: promocodeUsageRepository,
 		cache:                    cache,
+		provisioning:             provisioning,
+		outbox:                   outbox,
 	}
 }
 
-func (s PaymentService) ProcessPurchaseById(ctx context.Context, purchaseId int64) error {
+func (s PaymentService) ProcessPurchaseById(ctx context.Context, purchaseId int64) (err error) {
+	ctx, span := observability.StartSpan(ctx, "payment.process_purchase", attribute.Int64("purchase.id", purchaseId))
+	defer func() { observability.EndSpan(span, err) }()
+
 	purchase, err := s.repo.FindById(ctx, purchaseId)
 	if err != nil {
 		return err
@@ -104,84 +126,99 @@ func (s PaymentService) ProcessPurchaseById(ctx context.Context, purchaseId int6
 			MessageID: messageId,
 		})
 		if err != nil {
-			slog.Error("Error deleting message", "err", err)
+			slog.ErrorContext(ctx, "Error deleting message", "err", err)
 		}
 		s.cache.Delete(purchase.ID)
 	}
 
-	err = s.repo.MarkAsPaid(ctx, purchase.ID)
+	// the notice is stored with the credit, so a failed send doesn't fail the
+	// payment and a repeated callback doesn't credit twice
+	notice, err := outbox.NewMessage(&bot.SendMessageParams{
+		ChatID: customer.TelegramID,
+		Text:   s.translation.Format(customer.Language, "balance_topped_up", translation.Args{"amount": int(purchase.Amount)}),
+	})
 	if err != nil {
 		return err
 	}
-
-	newBalance := customer.Balance + purchase.Amount
-	if err := s.customerRepository.UpdateFields(ctx, customer.ID, map[string]interface{}{"balance": newBalance}); err != nil {
-		return err
-	}
-	customer.Balance = newBalance
-
-	_, err = s.messenger.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: customer.TelegramID,
-		Text:   fmt.Sprintf(s.translation.GetText(customer.Language, "balance_topped_up"), int(purchase.Amount)),
-	})
+	paid, err := s.repo.Pay(ctx, purchase.ID, notice)
 	if err != nil {
 		return err
 	}
+	if !paid {
+		slog.InfoContext(ctx, "purchase already processed", "purchase_id", utils.MaskHalfInt64(purchase.ID))
+		return nil
+	}
+	s.outbox.Notify()
+	customer.Balance += purchase.Amount
+	observability.PaymentAttempts.WithLabelValues(string(purchase.InvoiceType), paymentPaid).Inc()
+	observability.Revenue.WithLabelValues(string(purchase.InvoiceType), purchase.Currency).Add(purchase.Amount)
 
-	if referral, err := s.referralRepository.FindByReferee(ctx, customer.TelegramID); err == nil && referral != nil && !referral.BonusGranted {
-		referrer, err := s.customerRepository.FindByTelegramId(ctx, referral.ReferrerID)
-		if err == nil && referrer != nil {
-			bonus := float64(config.GetReferralBonus())
-			newBal := referrer.Balance + bonus
-			if err := s.customerRepository.UpdateFields(ctx, referrer.ID, map[string]interface{}{"balance": newBal}); err == nil {
-				_ = s.referralRepository.MarkBonusGranted(ctx, referral.ID)
-				_, _ = s.messenger.SendMessage(ctx, &bot.SendMessageParams{ChatID: referrer.TelegramID, Text: s.translation.GetText(referrer.Language, "referral_bonus_granted")})
-			}
-		}
+	// Pay queued the rewards, a failed attempt is retried by the referral cron
+	if err := s.referralService.OnPayment(ctx, customer, purchase); err != nil {
+		slog.ErrorContext(ctx, "process referral rewards", "err", err)
 	}
 
-	slog.Info("purchase processed", "purchase_id", utils.MaskHalfInt64(purchase.ID), "type", purchase.InvoiceType, "customer_id", utils.MaskHalfInt64(customer.ID))
+	slog.InfoContext(ctx, "purchase processed", "purchase_id", utils.MaskHalfInt64(purchase.ID), "type", purchase.InvoiceType, "customer_id", utils.MaskHalfInt64(customer.ID))
 
 	return nil
 }
 
-func (s PaymentService) PurchaseFromBalance(ctx context.Context, customer *domaincustomer.Customer, months int) error {
+// PurchaseFromBalance extends the target subscription of the customer by
+// months paid from the balance.
+func (s PaymentService) PurchaseFromBalance(ctx context.Context, customer *domaincustomer.Customer, target provisioning.Target, months int) (err error) {
+	ctx, span := observability.StartSpan(ctx, "payment.purchase_from_balance", attribute.Int("purchase.months", months))
+	defer func() { observability.EndSpan(span, err) }()
+
 	price := config.Price(months)
 	if customer.Balance < float64(price) {
 		_, _ = s.messenger.SendMessage(ctx, &bot.SendMessageParams{ChatID: customer.TelegramID, Text: s.translation.GetText(customer.Language, "insufficient_balance")})
 		return nil
 	}
 
-	user, err := s.remnawaveClient.CreateOrUpdateUser(ctx, customer.TelegramID, config.TrafficLimit(), months*30)
+	subs, err := s.provisioning.Subscriptions(ctx, customer)
 	if err != nil {
 		return err
 	}
-
-	newBalance := customer.Balance - float64(price)
-	updates := map[string]interface{}{
-		"subscription_link": user.SubscriptionUrl,
-		"expire_at":         user.ExpireAt,
-		"balance":           newBalance,
+	switch {
+	case target.New && len(subs) == 0:
+		// the first subscription is the primary one
+		target = provisioning.Target{}
+	case target.New && len(subs) >= config.MaxSubscriptionsPerCustomer():
+		_, _ = s.messenger.SendMessage(ctx, &bot.SendMessageParams{ChatID: customer.TelegramID, Text: s.translation.GetText(customer.Language, "subscription_limit_reached")})
+		return nil
+	case target.SubscriptionID != 0 && !slices.ContainsFunc(subs, func(sub pg.Subscription) bool { return sub.ID == target.SubscriptionID }):
+		return fmt.Errorf("subscription %d doesn't belong to customer %s", target.SubscriptionID, utils.MaskHalfInt64(customer.ID))
 	}
 
-	customer.SubscriptionLink = &user.SubscriptionUrl
-	customer.ExpireAt = &user.ExpireAt
-	customer.Balance = newBalance
-
-	if err := s.customerRepository.UpdateFields(ctx, customer.ID, updates); err != nil {
+	// the subscription is activated by the provisioning worker
+	err = s.provisioning.EnqueueFromBalance(ctx, customer, target, months, float64(price))
+	if errors.Is(err, pg.ErrInsufficientBalance) {
+		_, _ = s.messenger.SendMessage(ctx, &bot.SendMessageParams{ChatID: customer.TelegramID, Text: s.translation.GetText(customer.Language, "insufficient_balance")})
+		return nil
+	}
+	if err != nil {
 		return err
 	}
-
-	_, err = s.messenger.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID:      customer.TelegramID,
-		ParseMode:   models.ParseModeHTML,
-		Text:        s.translation.GetText(customer.Language, "subscription_activated"),
-		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: ui.ConnectKeyboard(customer.Language, "back_button", "start")},
-	})
-	return err
+	// the balance was counted as revenue when it was topped up
+	observability.PaymentAttempts.WithLabelValues("balance", paymentPaid).Inc()
+	return nil
 }
 
 func (s PaymentService) CreatePurchase(ctx context.Context, amount int, months int, customer *domaincustomer.Customer, invoiceType domainpurchase.InvoiceType) (url string, purchaseId int64, err error) {
+	ctx, span := observability.StartSpan(ctx, "payment.create_purchase",
+		attribute.String("purchase.provider", string(invoiceType)), attribute.Int("purchase.months", months))
+	defer func() { observability.EndSpan(span, err) }()
+
+	url, purchaseId, err = s.createPurchase(ctx, amount, months, customer, invoiceType)
+	outcome := paymentCreated
+	if err != nil || url == "" {
+		outcome = paymentFailed
+	}
+	observability.PaymentAttempts.WithLabelValues(string(invoiceType), outcome).Inc()
+	return url, purchaseId, err
+}
+
+func (s PaymentService) createPurchase(ctx context.Context, amount int, months int, customer *domaincustomer.Customer, invoiceType domainpurchase.InvoiceType) (url string, purchaseId int64, err error) {
 	if customer == nil {
 		return "", 0, fmt.Errorf("customer is nil")
 	}
@@ -213,7 +250,7 @@ func (s PaymentService) createTelegramInvoice(ctx context.Context, amount int, m
 		Month:       months,
 	})
 	if err != nil {
-		slog.Error("Error creating purchase", "err", err)
+		slog.ErrorContext(ctx, "Error creating purchase", "err", err)
 		return "", 0, nil
 	}
 
@@ -231,7 +268,7 @@ func (s PaymentService) createTelegramInvoice(ctx context.Context, amount int, m
 	})
 
 	if err != nil {
-		slog.Error("Error creating stars invoice", "err", err)
+		slog.ErrorContext(ctx, "Error creating stars invoice", "err", err)
 		return "", 0, err
 	}
 
@@ -241,48 +278,19 @@ func (s PaymentService) createTelegramInvoice(ctx context.Context, amount int, m
 
 	err = s.repo.UpdateFields(ctx, purchaseId, updates)
 	if err != nil {
-		slog.Error("Error updating purchase", "err", err)
+		slog.ErrorContext(ctx, "Error updating purchase", "err", err)
 		return "", 0, err
 	}
 
 	return invoiceUrl, purchaseId, nil
 }
 
-func (s PaymentService) ActivateTrial(ctx context.Context, telegramId int64) (string, error) {
-	if config.TrialDays() == 0 {
-		return "", nil
-	}
-	customer, err := s.customerRepository.FindByTelegramId(ctx, telegramId)
-	if err != nil {
-		slog.Error("Error finding customer", "err", err)
-		return "", err
-	}
-	if customer == nil {
-		return "", fmt.Errorf("customer %d not found", telegramId)
-	}
-	user, err := s.remnawaveClient.CreateOrUpdateUser(ctx, telegramId, config.TrialTrafficLimit(), config.TrialDays())
-	if err != nil {
-		slog.Error("Error creating user", "err", err)
-		return "", err
-	}
-
-	customerFilesToUpdate := map[string]interface{}{
-		"subscription_link": user.GetSubscriptionUrl(),
-		"expire_at":         user.GetExpireAt(),
-	}
-
-	err = s.customerRepository.UpdateFields(ctx, customer.ID, customerFilesToUpdate)
-	if err != nil {
-		return "", err
-	}
-
-	return user.GetSubscriptionUrl(), nil
-
-}
-
-func (s PaymentService) CancelPayment(purchaseId int64) error {
+func (s PaymentService) CancelPayment(purchaseId int64) (err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
+	ctx, span := observability.StartSpan(ctx, "payment.cancel", attribute.Int64("purchase.id", purchaseId))
+	defer func() { observability.EndSpan(span, err) }()
+
 	purchase, err := s.repo.FindById(ctx, purchaseId)
 	if err != nil {
 		return err
@@ -299,6 +307,7 @@ func (s PaymentService) CancelPayment(purchaseId int64) error {
 	if err != nil {
 		return err
 	}
+	observability.PaymentAttempts.WithLabelValues(string(purchase.InvoiceType), paymentCancelled).Inc()
 
 	return nil
 }
@@ -307,6 +316,11 @@ func (s PaymentService) GetUser(ctx context.Context, telegramId int64) (*remapi.
 	return s.remnawaveClient.GetUserByTelegramID(ctx, telegramId)
 }
 
+// Subscriptions lists the subscriptions of the customer, the primary one first.
+func (s PaymentService) Subscriptions(ctx context.Context, customer *domaincustomer.Customer) ([]pg.Subscription, error) {
+	return s.provisioning.Subscriptions(ctx, customer)
+}
+
 func (s PaymentService) GetUserDailyUsage(ctx context.Context, uuid string, start, end time.Time) (float64, error) {
 	return s.remnawaveClient.GetUserDailyUsage(ctx, uuid, start, end)
 }
@@ -316,24 +330,14 @@ func (s PaymentService) CreatePromocode(ctx context.Context, customer *domaincus
 		if customer.Balance < float64(cost) {
 			return "", fmt.Errorf("insufficient balance")
 		}
-		newBalance := customer.Balance - float64(cost)
-		if err := s.customerRepository.UpdateFields(ctx, customer.ID, map[string]interface{}{"balance": newBalance}); err != nil {
+		newBalance, err := s.customerRepository.SpendBalance(ctx, customer.ID, float64(cost))
+		if err != nil {
 			return "", err
 		}
 		customer.Balance = newBalance
 	}
 
-	tmpCode := uuid.New().String()
-	tmpCode = strings.ReplaceAll(tmpCode, "-", "")
-	var code string
-	for i, r := range tmpCode {
-		if i%5 == 0 {
-			code = fmt.Sprintf("%s-", code)
-		}
-		code = fmt.Sprintf("%s%c", code, r)
-	}
-
-	code = code[1:24]
+	code := promo.GenerateCode("")
 
 	_, err := s.promocodeRepository.Create(ctx, &pg.Promocode{
 		Code:      code,
@@ -357,27 +361,10 @@ func (s PaymentService) ApplyPromocode(ctx context.Context, customer *domaincust
 		return fmt.Errorf("invalid promocode")
 	}
 
-	user, err := s.remnawaveClient.CreateOrUpdateUser(ctx, customer.TelegramID, config.TrafficLimit(), promo.Months*30)
-	if err != nil {
-		return err
-	}
-
-	updates := map[string]interface{}{
-		"subscription_link": user.SubscriptionUrl,
-		"expire_at":         user.ExpireAt,
-	}
-
-	if err := s.customerRepository.UpdateFields(ctx, customer.ID, updates); err != nil {
-		return err
-	}
-	customer.SubscriptionLink = &user.SubscriptionUrl
-	customer.ExpireAt = &user.ExpireAt
-
-	if err := s.promocodeRepository.DecrementUses(ctx, promo.ID); err != nil {
-		return err
-	}
-	_ = s.promocodeUsageRepository.Create(ctx, promo.ID, customer.TelegramID)
-	return nil
+	// the promocode days are granted by the provisioning worker
+	return s.provisioning.Enqueue(ctx, customer, promo.Months*30, pg.ProvisioningSourcePromocode, func(ctx context.Context, job *pg.ProvisioningJob) error {
+		return s.promocodeRepository.Redeem(ctx, promo.ID, customer.TelegramID, job)
+	})
 }
 
 func (s PaymentService) SetPromocodeStatus(ctx context.Context, id int64, active bool) error {
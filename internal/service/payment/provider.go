@@ -16,3 +16,9 @@ type Provider interface {
 	// CreateInvoice creates a new purchase and returns payment URL and purchase ID.
 	CreateInvoice(ctx context.Context, amount int, months int, customer *domaincustomer.Customer) (string, int64, error)
 }
+
+// Pinger is implemented by the providers whose API can be probed by the
+// readiness check.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
@@ -0,0 +1,263 @@
+package promo
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"remnawave-tg-shop-bot/internal/repository/pg"
+)
+
+const (
+	// MaxBatchSize limits the number of codes generated in one batch.
+	MaxBatchSize = 1000
+	maxTagLength = 64
+	// codeGroups makes a code 80 random bits, a prefix doesn't shorten it.
+	codeGroups = 4
+)
+
+var (
+	ErrInvalidCount  = fmt.Errorf("count must be between 1 and %d", MaxBatchSize)
+	ErrInvalidMonths = errors.New("months must be 1, 3 or 6")
+	ErrInvalidUses   = errors.New("uses must be positive")
+	ErrInvalidTag    = fmt.Errorf("tag is required and must be at most %d characters", maxTagLength)
+	ErrInvalidPrefix = errors.New("prefix may contain only letters, digits, '_' and '-' (max 16)")
+	ErrInvalidCode   = errors.New("vanity code may contain only letters, digits, '_' and '-' (max 32)")
+	ErrVanityCount   = errors.New("vanity code cannot be combined with count>1 or prefix")
+	ErrBatchNotFound = errors.New("batch not found")
+)
+
+var (
+	prefixRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,16}$`)
+	codeRe   = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+)
+
+// BatchRepository persists promocode batches.
+type BatchRepository interface {
+	Create(ctx context.Context, batch *pg.PromocodeBatch, codes []string) (*pg.PromocodeBatch, error)
+	FindById(ctx context.Context, id int64) (*pg.PromocodeBatch, error)
+	FindRecent(ctx context.Context, limit int) ([]pg.PromocodeBatch, error)
+	FindCodes(ctx context.Context, batchID int64) ([]pg.PromocodeBatchCode, error)
+	Stats(ctx context.Context, batchID int64) (*pg.PromocodeBatchStats, error)
+}
+
+// BatchRequest describes a batch to generate. Code sets a single vanity code
+// and is mutually exclusive with Prefix.
+type BatchRequest struct {
+	Tag       string
+	Prefix    string
+	Code      string
+	Count     int
+	Months    int
+	Uses      int
+	CreatedBy int64
+}
+
+type Service struct {
+	repo BatchRepository
+}
+
+func NewService(repo BatchRepository) *Service {
+	return &Service{repo: repo}
+}
+
+// GenerateCode returns a random code of codeGroups groups of 5 hex digits,
+// optionally starting with prefix.
+func GenerateCode(prefix string) string {
+	raw := make([]byte, codeGroups*5/2)
+	_, _ = rand.Read(raw) // never fails, see crypto/rand.Read
+	digits := hex.EncodeToString(raw)
+	groups := make([]string, 0, codeGroups)
+	for i := 0; i < len(digits); i += 5 {
+		groups = append(groups, digits[i:i+5])
+	}
+	if prefix != "" {
+		return prefix + "-" + strings.Join(groups, "-")
+	}
+	return strings.Join(groups, "-")
+}
+
+// Validate checks the request and normalises its fields.
+func (r *BatchRequest) Validate() error {
+	r.Tag = strings.TrimSpace(r.Tag)
+	r.Prefix = strings.TrimSpace(r.Prefix)
+	r.Code = strings.TrimSpace(r.Code)
+	if r.Code != "" && r.Count == 0 {
+		r.Count = 1
+	}
+	if r.Tag == "" || len(r.Tag) > maxTagLength {
+		return ErrInvalidTag
+	}
+	if r.Count < 1 || r.Count > MaxBatchSize {
+		return ErrInvalidCount
+	}
+	switch r.Months {
+	case 1, 3, 6:
+	default:
+		return ErrInvalidMonths
+	}
+	if r.Uses < 1 {
+		return ErrInvalidUses
+	}
+	if r.Code != "" {
+		if r.Count != 1 || r.Prefix != "" {
+			return ErrVanityCount
+		}
+		if !codeRe.MatchString(r.Code) {
+			return ErrInvalidCode
+		}
+	}
+	if r.Prefix != "" && !prefixRe.MatchString(r.Prefix) {
+		return ErrInvalidPrefix
+	}
+	return nil
+}
+
+// GenerateBatch validates the request and stores the batch with its codes.
+func (s *Service) GenerateBatch(ctx context.Context, req BatchRequest) (*pg.PromocodeBatch, []string, error) {
+	if err := req.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	var codes []string
+	if req.Code != "" {
+		codes = []string{req.Code}
+	} else {
+		seen := make(map[string]struct{}, req.Count)
+		for len(codes) < req.Count {
+			c := GenerateCode(req.Prefix)
+			if _, ok := seen[c]; ok {
+				continue
+			}
+			seen[c] = struct{}{}
+			codes = append(codes, c)
+		}
+	}
+
+	batch := &pg.PromocodeBatch{
+		Tag:         req.Tag,
+		Months:      req.Months,
+		UsesPerCode: req.Uses,
+		CreatedBy:   req.CreatedBy,
+	}
+	if req.Prefix != "" {
+		prefix := req.Prefix
+		batch.Prefix = &prefix
+	}
+
+	batch, err := s.repo.Create(ctx, batch, codes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return batch, codes, nil
+}
+
+func (s *Service) RecentBatches(ctx context.Context, limit int) ([]pg.PromocodeBatch, error) {
+	return s.repo.FindRecent(ctx, limit)
+}
+
+func (s *Service) Batch(ctx context.Context, id int64) (*pg.PromocodeBatch, error) {
+	batch, err := s.repo.FindById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		return nil, ErrBatchNotFound
+	}
+	return batch, nil
+}
+
+func (s *Service) Stats(ctx context.Context, id int64) (*pg.PromocodeBatchStats, error) {
+	return s.repo.Stats(ctx, id)
+}
+
+// ExportCSV renders all codes of the batch as CSV.
+func (s *Service) ExportCSV(ctx context.Context, id int64) ([]byte, error) {
+	batch, err := s.Batch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	codes, err := s.repo.FindCodes(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"code", "tag", "months", "uses_left", "redeemed", "active"})
+	for _, c := range codes {
+		_ = w.Write([]string{
+			c.Code,
+			batch.Tag,
+			strconv.Itoa(c.Months),
+			strconv.Itoa(c.UsesLeft),
+			strconv.Itoa(c.Redeemed),
+			strconv.FormatBool(c.Active),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write csv: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// FormatRevenue renders revenue per currency in a stable order, e.g. "1500 RUB, 20 STARS".
+func FormatRevenue(revenue map[string]float64) string {
+	if len(revenue) == 0 {
+		return "0"
+	}
+	currencies := make([]string, 0, len(revenue))
+	for c := range revenue {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	parts := make([]string, 0, len(currencies))
+	for _, c := range currencies {
+		parts = append(parts, strings.TrimSpace(fmt.Sprintf("%.0f %s", revenue[c], c)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ParseBatchArgs parses "key=value" arguments of the /promo_batch command.
+func ParseBatchArgs(args []string) (BatchRequest, error) {
+	var req BatchRequest
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			return req, fmt.Errorf("invalid argument %q", arg)
+		}
+		var err error
+		switch strings.ToLower(kv[0]) {
+		case "count":
+			req.Count, err = strconv.Atoi(kv[1])
+		case "months":
+			req.Months, err = strconv.Atoi(kv[1])
+		case "uses":
+			req.Uses, err = strconv.Atoi(kv[1])
+		case "tag":
+			req.Tag = kv[1]
+		case "prefix":
+			req.Prefix = kv[1]
+		case "code":
+			req.Code = kv[1]
+		default:
+			return req, fmt.Errorf("unknown argument %q", kv[0])
+		}
+		if err != nil {
+			return req, fmt.Errorf("invalid value for %s: %w", kv[0], err)
+		}
+	}
+	if req.Uses == 0 {
+		req.Uses = 1
+	}
+	return req, nil
+}
@@ -0,0 +1,387 @@
+package provisioning
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	remapi "github.com/Jolymmiles/remnawave-api-go/api"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+
+	"remnawave-tg-shop-bot/internal/adapter/remnawave"
+	tg "remnawave-tg-shop-bot/internal/adapter/telegram/messenger"
+	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
+	"remnawave-tg-shop-bot/internal/observability"
+	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	custrepo "remnawave-tg-shop-bot/internal/service/customer"
+	"remnawave-tg-shop-bot/internal/ui"
+	"remnawave-tg-shop-bot/utils"
+)
+
+const (
+	pollInterval = 15 * time.Second
+	// lease hides a claimed job from other workers, it must outlast the panel
+	// calls of one attempt including their retries.
+	lease     = 5 * time.Minute
+	batchSize = 20
+
+	retryDelay    = 30 * time.Second
+	maxRetryDelay = time.Hour
+)
+
+// Outcomes of the provisioning jobs metric.
+const (
+	jobEnqueued = "enqueued"
+	jobDone     = "done"
+	jobRetried  = "retried"
+	jobDead     = "dead"
+)
+
+type Repository interface {
+	EnqueueFromBalance(ctx context.Context, job *pg.ProvisioningJob, price float64) (float64, error)
+	Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]pg.ProvisioningJob, error)
+	SetTarget(ctx context.Context, id int64, expireAt time.Time) error
+	Complete(ctx context.Context, id int64) error
+	Retry(ctx context.Context, id int64, runAt time.Time, lastError string) error
+	Bury(ctx context.Context, id int64, lastError string) error
+}
+
+// Subscriptions stores the panel users of the customers, see pg.SubscriptionRepository.
+type Subscriptions interface {
+	ListByCustomer(ctx context.Context, customerID int64) ([]pg.Subscription, error)
+	FindByID(ctx context.Context, id int64) (*pg.Subscription, error)
+	SetPanelUser(ctx context.Context, id int64, panelUUID uuid.UUID, expireAt time.Time, link string) error
+}
+
+// Panel applies the jobs, see remnawave.Client.
+type Panel interface {
+	GetSubscriptionUser(ctx context.Context, ref remnawave.SubscriptionRef) (*remapi.UserDto, error)
+	SetSubscriptionExpire(ctx context.Context, ref remnawave.SubscriptionRef, trafficLimit int, expireAt time.Time, inbounds map[uuid.UUID]uuid.UUID) (*remapi.UserDto, error)
+}
+
+// Sender delivers the notifications, see outbox.Dispatcher.
+type Sender interface {
+	Send(ctx context.Context, params *bot.SendMessageParams) error
+}
+
+// Target selects the subscription a purchase extends. The zero Target is the
+// primary subscription, New adds a subscription.
+type Target struct {
+	SubscriptionID int64
+	New            bool
+}
+
+// Service extends panel users for paid subscriptions. The payment and the job
+// are stored in one transaction, then a worker applies the job to the panel
+// until it succeeds or runs out of attempts, and admins are alerted about the
+// latter. The customer sees an "activating" message until then, results that
+// can't replace it go through the notification outbox.
+type Service struct {
+	repo          Repository
+	subscriptions Subscriptions
+	panel         Panel
+	customers     custrepo.Repository
+	messenger     tg.Messenger
+	sender        Sender
+	translation   *translation.Manager
+	maxAttempts   int
+	now           func() time.Time
+	wake          chan struct{}
+}
+
+func NewService(repo Repository, subscriptions Subscriptions, panel Panel, customers custrepo.Repository, messenger tg.Messenger, sender Sender, tm *translation.Manager, maxAttempts int) *Service {
+	return &Service{
+		repo:          repo,
+		subscriptions: subscriptions,
+		panel:         panel,
+		customers:     customers,
+		messenger:     messenger,
+		sender:        sender,
+		translation:   tm,
+		maxAttempts:   maxAttempts,
+		now:           time.Now,
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+// Subscriptions lists the subscriptions of the customer, the primary one
+// first. The primary subscription is taken from the customer, so that it is
+// listed before its first purchase, e.g. after a trial.
+func (s *Service) Subscriptions(ctx context.Context, customer *domaincustomer.Customer) ([]pg.Subscription, error) {
+	list, err := s.subscriptions.ListByCustomer(ctx, customer.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) > 0 && list[0].Primary() {
+		list[0].ExpireAt = customer.ExpireAt
+		list[0].SubscriptionLink = customer.SubscriptionLink
+		return list, nil
+	}
+	if customer.SubscriptionLink == nil {
+		return list, nil
+	}
+	primary := pg.Subscription{CustomerID: customer.ID, ExpireAt: customer.ExpireAt, SubscriptionLink: customer.SubscriptionLink}
+	return append([]pg.Subscription{primary}, list...), nil
+}
+
+// Enqueue tells the customer the subscription is being activated and stores a
+// job extending it by days with store, which takes the payment in the same
+// transaction. When store fails the message is deleted and nothing is queued.
+func (s *Service) Enqueue(ctx context.Context, customer *domaincustomer.Customer, days int, source string, store func(ctx context.Context, job *pg.ProvisioningJob) error) error {
+	job := &pg.ProvisioningJob{CustomerID: customer.ID, TelegramID: customer.TelegramID, Days: days, Source: source}
+
+	msg, err := s.messenger.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: customer.TelegramID,
+		Text:   s.translation.GetText(customer.Language, "provisioning_pending"),
+	})
+	if err != nil {
+		// the worker sends a new message when the job is done
+		slog.WarnContext(ctx, "send provisioning message", "err", err)
+	} else {
+		job.MessageID = &msg.ID
+	}
+
+	if err := store(ctx, job); err != nil {
+		if job.MessageID != nil {
+			_, _ = s.messenger.DeleteMessage(ctx, &bot.DeleteMessageParams{ChatID: customer.TelegramID, MessageID: *job.MessageID})
+		}
+		return err
+	}
+	observability.ProvisioningJobs.WithLabelValues(jobEnqueued).Inc()
+	slog.InfoContext(ctx, "provisioning job enqueued", "job_id", job.ID, "source", source, "days", days, "customer_id", utils.MaskHalfInt64(customer.ID))
+	s.Notify()
+	return nil
+}
+
+// EnqueueFromBalance pays for the plan of the given months of the target
+// subscription from the customer balance. It returns pg.ErrInsufficientBalance
+// when the balance doesn't cover the price.
+func (s *Service) EnqueueFromBalance(ctx context.Context, customer *domaincustomer.Customer, target Target, months int, price float64) error {
+	return s.Enqueue(ctx, customer, months*30, pg.ProvisioningSourceBalance, func(ctx context.Context, job *pg.ProvisioningJob) error {
+		job.PlanMonths = &months
+		if target.SubscriptionID != 0 {
+			job.SubscriptionID = &target.SubscriptionID
+		}
+		job.NewSubscription = target.New
+		balance, err := s.repo.EnqueueFromBalance(ctx, job, price)
+		if err != nil {
+			return err
+		}
+		customer.Balance = balance
+		return nil
+	})
+}
+
+// Notify wakes the worker up without waiting for the next poll.
+func (s *Service) Notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run applies the jobs until ctx is done.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.ProcessDue(ctx); err != nil {
+			slog.Error("process provisioning jobs", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// ProcessDue applies the due jobs. Failed jobs are rescheduled, the returned
+// error is about claiming them.
+func (s *Service) ProcessDue(ctx context.Context) error {
+	for {
+		jobs, err := s.repo.Claim(ctx, s.now(), lease, batchSize)
+		if err != nil {
+			return err
+		}
+		for i := range jobs {
+			s.process(ctx, &jobs[i])
+		}
+		if len(jobs) < batchSize {
+			return nil
+		}
+	}
+}
+
+func (s *Service) process(ctx context.Context, job *pg.ProvisioningJob) {
+	ctx, span := observability.StartSpan(ctx, "provisioning.apply",
+		attribute.Int64("job.id", job.ID), attribute.Int("job.attempt", job.Attempts))
+	customer, err := s.apply(ctx, job)
+	observability.EndSpan(span, err)
+	if err == nil {
+		observability.ProvisioningJobs.WithLabelValues(jobDone).Inc()
+		slog.InfoContext(ctx, "provisioning job done", "job_id", job.ID, "attempts", job.Attempts)
+		return
+	}
+
+	if job.Attempts < s.maxAttempts {
+		runAt := s.now().Add(retryAfter(job.Attempts))
+		slog.WarnContext(ctx, "provisioning job failed", "job_id", job.ID, "attempts", job.Attempts, "retry_at", runAt, "err", err)
+		if rerr := s.repo.Retry(ctx, job.ID, runAt, err.Error()); rerr != nil {
+			slog.ErrorContext(ctx, "reschedule provisioning job", "job_id", job.ID, "err", rerr)
+		}
+		observability.ProvisioningJobs.WithLabelValues(jobRetried).Inc()
+		return
+	}
+
+	slog.ErrorContext(ctx, "provisioning job is dead", "job_id", job.ID, "attempts", job.Attempts, "err", err)
+	if berr := s.repo.Bury(ctx, job.ID, err.Error()); berr != nil {
+		slog.ErrorContext(ctx, "bury provisioning job", "job_id", job.ID, "err", berr)
+		return
+	}
+	observability.ProvisioningJobs.WithLabelValues(jobDead).Inc()
+	s.alertAdmins(ctx, job, err)
+	if customer != nil {
+		s.reply(ctx, job, s.translation.GetText(customer.Language, "provisioning_delayed"), nil)
+	}
+}
+
+// apply sets the target expiration and the inbounds of the plan on the panel
+// user of the subscription and records the expiration. The target is computed
+// once, so repeated attempts are idempotent.
+func (s *Service) apply(ctx context.Context, job *pg.ProvisioningJob) (*domaincustomer.Customer, error) {
+	customer, err := s.customers.FindById(ctx, job.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	if customer == nil {
+		return nil, fmt.Errorf("customer %s not found", utils.MaskHalfInt64(job.CustomerID))
+	}
+
+	// jobs queued before subscriptions extend the primary one
+	var sub *pg.Subscription
+	ref := remnawave.SubscriptionRef{TelegramID: job.TelegramID}
+	if job.SubscriptionID != nil {
+		sub, err = s.subscriptions.FindByID(ctx, *job.SubscriptionID)
+		if err != nil {
+			return customer, err
+		}
+		if sub == nil {
+			return customer, fmt.Errorf("subscription %d not found", *job.SubscriptionID)
+		}
+		ref.UUID = sub.PanelUUID
+		if sub.PanelUsername != nil {
+			ref.Username = *sub.PanelUsername
+		}
+	}
+
+	if job.TargetExpireAt == nil {
+		user, err := s.panel.GetSubscriptionUser(ctx, ref)
+		if err != nil {
+			return customer, err
+		}
+		var current time.Time
+		if user != nil {
+			current = user.ExpireAt
+		}
+		target := remnawave.ExtendExpire(job.Days, current)
+		if err := s.repo.SetTarget(ctx, job.ID, target); err != nil {
+			return customer, err
+		}
+		job.TargetExpireAt = &target
+	}
+
+	var inbounds map[uuid.UUID]uuid.UUID
+	if job.PlanMonths != nil {
+		inbounds = config.PlanInboundUUIDs(*job.PlanMonths)
+	}
+	user, err := s.panel.SetSubscriptionExpire(ctx, ref, config.TrafficLimit(), *job.TargetExpireAt, inbounds)
+	if err != nil {
+		return customer, err
+	}
+	if sub != nil {
+		if err := s.subscriptions.SetPanelUser(ctx, sub.ID, user.UUID, user.ExpireAt, user.SubscriptionUrl); err != nil {
+			return customer, err
+		}
+	}
+	if sub == nil || sub.Primary() {
+		if err := s.customers.UpdateFields(ctx, customer.ID, map[string]interface{}{
+			"subscription_link": user.SubscriptionUrl,
+			"expire_at":         user.ExpireAt,
+		}); err != nil {
+			return customer, err
+		}
+	}
+	if err := s.repo.Complete(ctx, job.ID); err != nil {
+		return customer, err
+	}
+
+	switch job.Source {
+	case pg.ProvisioningSourcePromocode:
+		text := s.translation.Format(customer.Language, "promo_applied", translation.Args{"expire": user.ExpireAt.Format("02.01.2006 15:04")})
+		s.reply(ctx, job, text, nil)
+	case pg.ProvisioningSourceReferral:
+		s.reply(ctx, job, s.translation.Format(customer.Language, "referral_bonus_days", translation.Args{"days": job.Days}), nil)
+	default:
+		s.reply(ctx, job, s.translation.GetText(customer.Language, "subscription_activated"),
+			&models.InlineKeyboardMarkup{InlineKeyboard: ui.ConnectKeyboard(customer.Language, "back_button", "start")})
+	}
+	return customer, nil
+}
+
+// reply replaces the "activating" message of the job with text, or sends a new
+// message through the outbox when there is none or it can't be edited.
+func (s *Service) reply(ctx context.Context, job *pg.ProvisioningJob, text string, markup *models.InlineKeyboardMarkup) {
+	if job.MessageID != nil {
+		params := &bot.EditMessageTextParams{ChatID: job.TelegramID, MessageID: *job.MessageID, ParseMode: models.ParseModeHTML, Text: text}
+		if markup != nil {
+			params.ReplyMarkup = markup
+		}
+		if _, err := s.messenger.EditMessageText(ctx, params); err == nil {
+			return
+		}
+	}
+	params := &bot.SendMessageParams{ChatID: job.TelegramID, ParseMode: models.ParseModeHTML, Text: text}
+	if markup != nil {
+		params.ReplyMarkup = markup
+	}
+	if err := s.sender.Send(ctx, params); err != nil {
+		slog.ErrorContext(ctx, "send provisioning result", "job_id", job.ID, "err", err)
+	}
+}
+
+func (s *Service) alertAdmins(ctx context.Context, job *pg.ProvisioningJob, cause error) {
+	for _, adminID := range config.GetAdminTelegramIds() {
+		lang := ""
+		if admin, err := s.customers.FindByTelegramId(ctx, adminID); err == nil && admin != nil {
+			lang = admin.Language
+		}
+		text := s.translation.Format(lang, "provisioning_dead_admin_notice", translation.Args{
+			"id":       job.ID,
+			"customer": job.TelegramID,
+			"days":     job.Days,
+			"source":   translation.Raw(job.Source),
+			"attempts": job.Attempts,
+			"error":    translation.Raw(cause.Error()),
+		})
+		if err := s.sender.Send(ctx, &bot.SendMessageParams{ChatID: adminID, Text: text}); err != nil {
+			slog.ErrorContext(ctx, "send provisioning alert to admin", "err", err)
+		}
+	}
+}
+
+// retryAfter doubles the delay with every attempt up to maxRetryDelay.
+func retryAfter(attempts int) time.Duration {
+	d := retryDelay
+	for i := 1; i < attempts && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	return min(d, maxRetryDelay)
+}
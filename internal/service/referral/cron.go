@@ -0,0 +1,29 @@
+package referral
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/robfig/cron/v3"
+
+	"remnawave-tg-shop-bot/internal/observability"
+)
+
+type rewardReleaser interface {
+	RetryPayments(ctx context.Context) error
+	ReleaseDue(ctx context.Context) error
+}
+
+func RegisterReleaseCron(c *cron.Cron, svc rewardReleaser) error {
+	_, err := c.AddFunc("@hourly", func() {
+		if err := svc.RetryPayments(context.Background()); err != nil {
+			slog.Error("retry referral payments", "err", err)
+		}
+		if err := svc.ReleaseDue(context.Background()); err != nil {
+			slog.Error("release referral rewards", "err", err)
+			return
+		}
+		observability.CronSucceeded("referral_release")
+	})
+	return err
+}
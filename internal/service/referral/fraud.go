@@ -0,0 +1,147 @@
+package referral
+
+import (
+	"context"
+	"log/slog"
+
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// Decisions taken by the anti-fraud checks.
+const (
+	DecisionAccepted = "accepted"
+	DecisionRejected = "rejected"
+	DecisionDeferred = "deferred"
+	DecisionReview   = "review"
+)
+
+// Reason codes stored with every decision.
+const (
+	ReasonOK                  = "ok"
+	ReasonSelfReferral        = "self_referral"
+	ReasonCircularReferral    = "circular_referral"
+	ReasonReferrerNotFound    = "referrer_not_found"
+	ReasonRefereeExists       = "referee_exists"
+	ReasonPaymentBelowMinimum = "payment_below_minimum"
+	ReasonRefereeTooNew       = "referee_too_new"
+	ReasonDailyCapExceeded    = "daily_cap_exceeded"
+	ReasonAdminApproved       = "admin_approved"
+	ReasonAdminRejected       = "admin_rejected"
+)
+
+// maxChainDepth bounds the walk up the referral chain when looking for cycles.
+const maxChainDepth = 20
+
+const reviewQueueLimit = 20
+
+// Register links a referee to the referrer from a ref_<id> start parameter.
+// Only new customers can be referred; self and circular referrals are rejected.
+// It reports whether the referral was created.
+func (s *Service) Register(ctx context.Context, referrerID, refereeID int64, newCustomer bool) (bool, error) {
+	reason, err := s.checkReferral(ctx, referrerID, refereeID, newCustomer)
+	if err != nil {
+		return false, err
+	}
+	if reason != ReasonOK {
+		s.decide(ctx, referrerID, refereeID, nil, DecisionRejected, reason)
+		return false, nil
+	}
+	if _, err := s.referrals.Create(ctx, referrerID, refereeID); err != nil {
+		return false, err
+	}
+	s.decide(ctx, referrerID, refereeID, nil, DecisionAccepted, ReasonOK)
+	return true, nil
+}
+
+func (s *Service) checkReferral(ctx context.Context, referrerID, refereeID int64, newCustomer bool) (string, error) {
+	if referrerID == refereeID {
+		return ReasonSelfReferral, nil
+	}
+	if !newCustomer {
+		return ReasonRefereeExists, nil
+	}
+	referrer, err := s.customers.FindByTelegramId(ctx, referrerID)
+	if err != nil {
+		return "", err
+	}
+	if referrer == nil {
+		return ReasonReferrerNotFound, nil
+	}
+	existing, err := s.referrals.FindByReferee(ctx, refereeID)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		return ReasonRefereeExists, nil
+	}
+
+	current := referrerID
+	for i := 0; i < maxChainDepth; i++ {
+		parent, err := s.referrals.FindByReferee(ctx, current)
+		if err != nil {
+			return "", err
+		}
+		if parent == nil {
+			break
+		}
+		if parent.ReferrerID == refereeID {
+			return ReasonCircularReferral, nil
+		}
+		current = parent.ReferrerID
+	}
+	return ReasonOK, nil
+}
+
+// ReviewQueue lists referrers whose rewards wait for an admin decision.
+func (s *Service) ReviewQueue(ctx context.Context) ([]pg.ReferralReviewItem, error) {
+	return s.rewards.FindReviewQueue(ctx, reviewQueueLimit)
+}
+
+// ApproveReview holds rewards of the referrer sent to review like any other
+// reward and returns their number. Rewards whose hold period is over are
+// released right away, the others by ReleaseDue.
+func (s *Service) ApproveReview(ctx context.Context, beneficiaryID int64) (int, error) {
+	rewards, err := s.rewards.ResolveReview(ctx, beneficiaryID, pg.ReferralRewardStatusHeld)
+	if err != nil {
+		return 0, err
+	}
+	now := s.now()
+	for _, rw := range rewards {
+		s.decide(ctx, rw.BeneficiaryID, rw.RefereeID, rw.PurchaseID, DecisionAccepted, ReasonAdminApproved)
+		if rw.AvailableAt.After(now) {
+			continue
+		}
+		if err := s.release(ctx, rw); err != nil {
+			slog.Error("release referral reward", "reward_id", rw.ID, "err", err)
+		}
+	}
+	return len(rewards), nil
+}
+
+// RejectReview cancels rewards of the referrer held for review and returns their number.
+func (s *Service) RejectReview(ctx context.Context, beneficiaryID int64) (int, error) {
+	rewards, err := s.rewards.ResolveReview(ctx, beneficiaryID, pg.ReferralRewardStatusRejected)
+	if err != nil {
+		return 0, err
+	}
+	for _, rw := range rewards {
+		s.decide(ctx, rw.BeneficiaryID, rw.RefereeID, rw.PurchaseID, DecisionRejected, ReasonAdminRejected)
+	}
+	return len(rewards), nil
+}
+
+func (s *Service) decide(ctx context.Context, referrerID, refereeID int64, purchaseID *int64, decision, reason string) {
+	slog.Info("referral decision", "decision", decision, "reason", reason,
+		"referrer", utils.MaskHalfInt64(referrerID), "referee", utils.MaskHalfInt64(refereeID))
+	err := s.decisions.Create(ctx, &pg.ReferralDecision{
+		ReferrerID: referrerID,
+		RefereeID:  refereeID,
+		PurchaseID: purchaseID,
+		Decision:   decision,
+		Reason:     reason,
+	})
+	if err != nil {
+		slog.Error("save referral decision", "err", err)
+	}
+}
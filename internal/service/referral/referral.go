@@ -0,0 +1,375 @@
+package referral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/go-telegram/bot"
+
+	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
+	domainpurchase "remnawave-tg-shop-bot/internal/domain/purchase"
+	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	custrepo "remnawave-tg-shop-bot/internal/service/customer"
+	"remnawave-tg-shop-bot/utils"
+)
+
+const releaseBatchSize = 100
+
+// errNotHeld stops queueing the days of a reward released by another run.
+var errNotHeld = errors.New("referral reward is not held")
+
+// paymentRetryDelay leaves the payment to the attempt made when it was paid.
+const paymentRetryDelay = 10 * time.Minute
+
+type ReferralRepository interface {
+	Create(ctx context.Context, referrerID, refereeID int64) (*pg.Referral, error)
+	FindByReferee(ctx context.Context, refereeID int64) (*pg.Referral, error)
+	FindByReferrer(ctx context.Context, referrerID int64) ([]pg.Referral, error)
+	MarkBonusGranted(ctx context.Context, referralID int64) error
+}
+
+type RewardRepository interface {
+	Create(ctx context.Context, reward *pg.ReferralReward) (bool, error)
+	FindDue(ctx context.Context, now time.Time, limit int) ([]pg.ReferralReward, error)
+	ReleaseMoney(ctx context.Context, id int64) (bool, error)
+	ReleaseDays(ctx context.Context, id int64, job *pg.ProvisioningJob) (bool, error)
+	Summary(ctx context.Context, beneficiaryID int64) (*pg.ReferralRewardSummary, error)
+	CountSince(ctx context.Context, beneficiaryID int64, level int, since time.Time) (int, error)
+	FindReviewQueue(ctx context.Context, limit int) ([]pg.ReferralReviewItem, error)
+	ResolveReview(ctx context.Context, beneficiaryID int64, to string) ([]pg.ReferralReward, error)
+}
+
+type DecisionRepository interface {
+	Create(ctx context.Context, d *pg.ReferralDecision) error
+}
+
+// PurchaseRepository counts the payments of the referees and queues their
+// rewards, see pg.PurchaseRepository.
+type PurchaseRepository interface {
+	CountPaidByCustomer(ctx context.Context, customerID, untilID int64) (int, error)
+	FindReferralPending(ctx context.Context, before time.Time, limit int) ([]domainpurchase.Purchase, error)
+	CompleteReferral(ctx context.Context, purchaseID int64) error
+}
+
+// Provisioner queues the days of the rewards for the panel, see provisioning.Service.
+type Provisioner interface {
+	Enqueue(ctx context.Context, customer *domaincustomer.Customer, days int, source string, store func(ctx context.Context, job *pg.ProvisioningJob) error) error
+}
+
+// Sender delivers the notifications, see outbox.Dispatcher.
+type Sender interface {
+	Send(ctx context.Context, params *bot.SendMessageParams) error
+}
+
+// Stats summarises the referral program of a single referrer.
+type Stats struct {
+	Invited  int
+	Rewarded int
+	Rewards  pg.ReferralRewardSummary
+}
+
+type Service struct {
+	rules       Rules
+	referrals   ReferralRepository
+	rewards     RewardRepository
+	decisions   DecisionRepository
+	withdrawals WithdrawalRepository
+	purchases   PurchaseRepository
+	customers   custrepo.Repository
+	provisioner Provisioner
+	sender      Sender
+	translation *translation.Manager
+	now         func() time.Time
+}
+
+func NewService(
+	rules Rules,
+	referrals ReferralRepository,
+	rewards RewardRepository,
+	decisions DecisionRepository,
+	withdrawals WithdrawalRepository,
+	purchases PurchaseRepository,
+	customers custrepo.Repository,
+	provisioner Provisioner,
+	sender Sender,
+	translation *translation.Manager,
+) *Service {
+	return &Service{
+		rules:       rules,
+		referrals:   referrals,
+		rewards:     rewards,
+		decisions:   decisions,
+		withdrawals: withdrawals,
+		purchases:   purchases,
+		customers:   customers,
+		provisioner: provisioner,
+		sender:      sender,
+		translation: translation,
+		now:         time.Now,
+	}
+}
+
+// RulesFromConfig builds rules from REFERRAL_* environment variables.
+func RulesFromConfig() Rules {
+	return Rules{
+		Mode:               RewardMode(config.GetReferralMode()),
+		Bonus:              config.GetReferralBonus(),
+		Percent:            config.GetReferralPercent(),
+		Days:               config.GetReferralDays(),
+		Payments:           config.GetReferralPayments(),
+		RefereeBonus:       config.GetReferralRefereeBonus(),
+		RefereeDays:        config.GetReferralRefereeDays(),
+		SecondLevelPercent: config.GetReferralSecondLevelPercent(),
+		Hold:               time.Duration(config.GetReferralHoldDays()) * 24 * time.Hour,
+		MinPayment:         config.GetReferralMinPayment(),
+		MinRefereeAge:      time.Duration(config.GetReferralMinRefereeAgeHours()) * time.Hour,
+		DailyCap:           config.GetReferralDailyCap(),
+		MinWithdrawal:      config.GetReferralWithdrawalMin(),
+	}
+}
+
+func (s *Service) Rules() Rules {
+	return s.rules
+}
+
+// OnPayment records rewards for a paid purchase of the customer. Rewards are
+// credited right away unless a hold period applies or the referrer is sent to
+// review by the anti-fraud checks. The purchase stays queued when it fails and
+// is retried by RetryPayments.
+func (s *Service) OnPayment(ctx context.Context, customer *domaincustomer.Customer, purchase *domainpurchase.Purchase) error {
+	if err := s.onPayment(ctx, customer, purchase); err != nil {
+		return err
+	}
+	return s.purchases.CompleteReferral(ctx, purchase.ID)
+}
+
+// RetryPayments records the rewards of payments whose first attempt failed.
+func (s *Service) RetryPayments(ctx context.Context) error {
+	pending, err := s.purchases.FindReferralPending(ctx, s.now().Add(-paymentRetryDelay), releaseBatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range pending {
+		purchase := &pending[i]
+		customer, err := s.customers.FindById(ctx, purchase.CustomerID)
+		if err != nil {
+			slog.Error("find referee", "purchase_id", utils.MaskHalfInt64(purchase.ID), "err", err)
+			continue
+		}
+		if customer == nil {
+			if err := s.purchases.CompleteReferral(ctx, purchase.ID); err != nil {
+				slog.Error("complete referral payment", "err", err)
+			}
+			continue
+		}
+		if err := s.OnPayment(ctx, customer, purchase); err != nil {
+			slog.Error("process referral rewards", "purchase_id", utils.MaskHalfInt64(purchase.ID), "err", err)
+		}
+	}
+	return nil
+}
+
+func (s *Service) onPayment(ctx context.Context, customer *domaincustomer.Customer, purchase *domainpurchase.Purchase) error {
+	ref, err := s.referrals.FindByReferee(ctx, customer.TelegramID)
+	if err != nil {
+		return err
+	}
+	if ref == nil {
+		return nil
+	}
+
+	if purchase.Amount < float64(s.rules.MinPayment) {
+		s.decide(ctx, ref.ReferrerID, customer.TelegramID, &purchase.ID, DecisionRejected, ReasonPaymentBelowMinimum)
+		return nil
+	}
+
+	number, err := s.purchases.CountPaidByCustomer(ctx, customer.ID, purchase.ID)
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	availableAt := now.Add(s.rules.Hold)
+	decision, reason := DecisionAccepted, ReasonOK
+	if matureAt := customer.CreatedAt.Add(s.rules.MinRefereeAge); s.rules.MinRefereeAge > 0 && matureAt.After(availableAt) {
+		availableAt = matureAt
+		decision, reason = DecisionDeferred, ReasonRefereeTooNew
+	}
+	firstLevelStatus := pg.ReferralRewardStatusHeld
+	if s.rules.DailyCap > 0 {
+		count, err := s.rewards.CountSince(ctx, ref.ReferrerID, LevelFirst, now.Add(-24*time.Hour))
+		if err != nil {
+			return err
+		}
+		if count >= s.rules.DailyCap {
+			firstLevelStatus = pg.ReferralRewardStatusReview
+			decision, reason = DecisionReview, ReasonDailyCapExceeded
+		}
+	}
+
+	recorded := false
+	for _, rw := range s.rules.Compute(Payment{Amount: purchase.Amount, Number: number}) {
+		beneficiary, err := s.beneficiary(ctx, rw.Level, customer.TelegramID, ref)
+		if err != nil {
+			return err
+		}
+		if beneficiary == 0 {
+			continue
+		}
+
+		record := &pg.ReferralReward{
+			BeneficiaryID: beneficiary,
+			RefereeID:     customer.TelegramID,
+			PurchaseID:    &purchase.ID,
+			Level:         rw.Level,
+			Kind:          string(rw.Kind),
+			Amount:        rw.Amount,
+			Days:          rw.Days,
+			Status:        pg.ReferralRewardStatusHeld,
+			AvailableAt:   availableAt,
+		}
+		if rw.Level == LevelFirst {
+			record.Status = firstLevelStatus
+		}
+		created, err := s.rewards.Create(ctx, record)
+		if err != nil {
+			return err
+		}
+		if !created {
+			continue
+		}
+		recorded = true
+		slog.Info("referral reward recorded", "reward_id", record.ID, "level", rw.Level, "kind", rw.Kind, "status", record.Status,
+			"beneficiary", utils.MaskHalfInt64(beneficiary), "purchase_id", utils.MaskHalfInt64(purchase.ID))
+
+		if rw.Level == LevelFirst && !ref.BonusGranted {
+			if err := s.referrals.MarkBonusGranted(ctx, ref.ID); err != nil {
+				slog.Error("mark referral bonus granted", "err", err)
+			}
+			ref.BonusGranted = true
+		}
+		if record.Status == pg.ReferralRewardStatusHeld && !availableAt.After(now) {
+			if err := s.release(ctx, *record); err != nil {
+				slog.Error("release referral reward", "reward_id", record.ID, "err", err)
+			}
+		}
+	}
+	if recorded {
+		s.decide(ctx, ref.ReferrerID, customer.TelegramID, &purchase.ID, decision, reason)
+	}
+	return nil
+}
+
+func (s *Service) beneficiary(ctx context.Context, level int, refereeID int64, ref *pg.Referral) (int64, error) {
+	switch level {
+	case LevelReferee:
+		return refereeID, nil
+	case LevelFirst:
+		return ref.ReferrerID, nil
+	case LevelSecond:
+		parent, err := s.referrals.FindByReferee(ctx, ref.ReferrerID)
+		if err != nil || parent == nil || parent.ReferrerID == refereeID {
+			return 0, err
+		}
+		return parent.ReferrerID, nil
+	default:
+		return 0, fmt.Errorf("unknown referral level %d", level)
+	}
+}
+
+// ReleaseDue credits rewards whose hold period is over.
+func (s *Service) ReleaseDue(ctx context.Context) error {
+	due, err := s.rewards.FindDue(ctx, s.now(), releaseBatchSize)
+	if err != nil {
+		return err
+	}
+	for _, rw := range due {
+		if err := s.release(ctx, rw); err != nil {
+			slog.Error("release referral reward", "reward_id", rw.ID, "err", err)
+		}
+	}
+	return nil
+}
+
+func (s *Service) release(ctx context.Context, rw pg.ReferralReward) error {
+	switch RewardKind(rw.Kind) {
+	case KindMoney:
+		released, err := s.rewards.ReleaseMoney(ctx, rw.ID)
+		if err != nil || !released {
+			return err
+		}
+	case KindDays:
+		// the provisioning worker extends the subscription and tells the customer
+		return s.releaseDays(ctx, rw)
+	default:
+		return fmt.Errorf("unknown referral reward kind %q", rw.Kind)
+	}
+
+	s.notify(ctx, rw)
+	return nil
+}
+
+// releaseDays queues a provisioning job for the days of the reward. The job is
+// stored with the released reward, so the days are granted exactly once.
+func (s *Service) releaseDays(ctx context.Context, rw pg.ReferralReward) error {
+	customer, err := s.customers.FindByTelegramId(ctx, rw.BeneficiaryID)
+	if err != nil {
+		return err
+	}
+	if customer == nil {
+		return fmt.Errorf("beneficiary %s not found", utils.MaskHalfInt64(rw.BeneficiaryID))
+	}
+	err = s.provisioner.Enqueue(ctx, customer, rw.Days, pg.ProvisioningSourceReferral, func(ctx context.Context, job *pg.ProvisioningJob) error {
+		released, err := s.rewards.ReleaseDays(ctx, rw.ID, job)
+		if err == nil && !released {
+			return errNotHeld
+		}
+		return err
+	})
+	if errors.Is(err, errNotHeld) {
+		return nil
+	}
+	return err
+}
+
+// notify tells the beneficiary about a money reward.
+func (s *Service) notify(ctx context.Context, rw pg.ReferralReward) {
+	customer, err := s.customers.FindByTelegramId(ctx, rw.BeneficiaryID)
+	if err != nil || customer == nil {
+		return
+	}
+	text := s.translation.Format(customer.Language, "referral_bonus_money", translation.Args{"amount": translation.Raw(FormatAmount(rw.Amount))})
+	if err := s.sender.Send(ctx, &bot.SendMessageParams{ChatID: customer.TelegramID, Text: text}); err != nil {
+		slog.Error("send referral reward notification", "err", err)
+	}
+}
+
+// Stats returns invited and rewarded referrals of the referrer with reward totals.
+func (s *Service) Stats(ctx context.Context, referrerID int64) (*Stats, error) {
+	refs, err := s.referrals.FindByReferrer(ctx, referrerID)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := s.rewards.Summary(ctx, referrerID)
+	if err != nil {
+		return nil, err
+	}
+	st := &Stats{Invited: len(refs), Rewards: *summary}
+	for _, r := range refs {
+		if r.BonusGranted {
+			st.Rewarded++
+		}
+	}
+	return st, nil
+}
+
+// FormatAmount renders money without trailing zeros, e.g. 150 or 12.5.
+func FormatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
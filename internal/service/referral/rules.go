@@ -0,0 +1,136 @@
+package referral
+
+import (
+	"math"
+	"time"
+)
+
+// RewardMode defines how the referrer reward is calculated.
+type RewardMode string
+
+const (
+	// ModeFixed pays a fixed amount of money per rewarded payment.
+	ModeFixed RewardMode = "fixed"
+	// ModePercent pays a share of every rewarded payment.
+	ModePercent RewardMode = "percent"
+	// ModeDays extends the referrer subscription instead of paying money.
+	ModeDays RewardMode = "days"
+)
+
+// RewardKind is the unit of a single reward.
+type RewardKind string
+
+const (
+	KindMoney RewardKind = "money"
+	KindDays  RewardKind = "days"
+)
+
+// Reward levels. LevelReferee rewards the paying user, LevelFirst the user who
+// invited them and LevelSecond the one who invited the referrer.
+const (
+	LevelReferee = 0
+	LevelFirst   = 1
+	LevelSecond  = 2
+)
+
+// Rules configure the referral program.
+type Rules struct {
+	Mode    RewardMode
+	Bonus   int // money per payment in ModeFixed
+	Percent int // share of payment in ModePercent
+	Days    int // days per payment in ModeDays
+	// Payments limits rewarded payments of a referee to the first N; 0 rewards every payment.
+	Payments int
+	// RefereeBonus and RefereeDays are granted to the referee on their first payment.
+	RefereeBonus int
+	RefereeDays  int
+	// SecondLevelPercent is the share of the first level reward paid one level up.
+	SecondLevelPercent int
+	// Hold delays rewards before they are credited.
+	Hold time.Duration
+	// MinPayment is the smallest payment that qualifies for rewards.
+	MinPayment int
+	// MinRefereeAge holds rewards until the referee account is at least this old.
+	MinRefereeAge time.Duration
+	// DailyCap sends first level rewards over this many per day to admin review; 0 disables it.
+	DailyCap int
+	// MinWithdrawal is the smallest cash-out of referral earnings; 0 disables withdrawals.
+	MinWithdrawal int
+}
+
+// Payment describes a paid purchase of a referee.
+type Payment struct {
+	Amount float64
+	// Number is the 1-based ordinal of the payment among the referee's paid purchases.
+	Number int
+}
+
+// Reward is a single computed reward before it is assigned to a customer.
+type Reward struct {
+	Level  int
+	Kind   RewardKind
+	Amount float64
+	Days   int
+}
+
+// Compute returns rewards due for the payment. Zero rewards are omitted.
+func (r Rules) Compute(p Payment) []Reward {
+	var rewards []Reward
+
+	if p.Number == 1 {
+		if r.RefereeBonus > 0 {
+			rewards = append(rewards, Reward{Level: LevelReferee, Kind: KindMoney, Amount: float64(r.RefereeBonus)})
+		}
+		if r.RefereeDays > 0 {
+			rewards = append(rewards, Reward{Level: LevelReferee, Kind: KindDays, Days: r.RefereeDays})
+		}
+	}
+
+	if r.Payments > 0 && p.Number > r.Payments {
+		return rewards
+	}
+
+	first := r.firstLevel(p)
+	if first == nil {
+		return rewards
+	}
+	rewards = append(rewards, *first)
+
+	if r.SecondLevelPercent > 0 {
+		second := Reward{Level: LevelSecond, Kind: first.Kind}
+		if first.Kind == KindDays {
+			second.Days = first.Days * r.SecondLevelPercent / 100
+		} else {
+			second.Amount = roundMoney(first.Amount * float64(r.SecondLevelPercent) / 100)
+		}
+		if second.Days > 0 || second.Amount > 0 {
+			rewards = append(rewards, second)
+		}
+	}
+	return rewards
+}
+
+func (r Rules) firstLevel(p Payment) *Reward {
+	switch r.Mode {
+	case ModePercent:
+		amount := roundMoney(p.Amount * float64(r.Percent) / 100)
+		if amount <= 0 {
+			return nil
+		}
+		return &Reward{Level: LevelFirst, Kind: KindMoney, Amount: amount}
+	case ModeDays:
+		if r.Days <= 0 {
+			return nil
+		}
+		return &Reward{Level: LevelFirst, Kind: KindDays, Days: r.Days}
+	default:
+		if r.Bonus <= 0 {
+			return nil
+		}
+		return &Reward{Level: LevelFirst, Kind: KindMoney, Amount: float64(r.Bonus)}
+	}
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,134 @@
+package referral
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-telegram/bot"
+
+	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/utils"
+)
+
+const (
+	maxWithdrawalDetails = 500
+	pendingWithdrawals   = 20
+)
+
+var (
+	ErrWithdrawalDisabled     = errors.New("withdrawals are disabled")
+	ErrWithdrawalFormat       = errors.New("expected amount followed by payout details")
+	ErrWithdrawalBelowMinimum = errors.New("amount is below the minimum withdrawal")
+	ErrWithdrawalDetails      = errors.New("payout details are too long")
+)
+
+type WithdrawalRepository interface {
+	Withdrawable(ctx context.Context, telegramID int64) (float64, error)
+	Create(ctx context.Context, telegramID int64, amount float64, details string) (*pg.ReferralWithdrawal, error)
+	FindHeld(ctx context.Context, limit int) ([]pg.ReferralWithdrawal, error)
+	MarkPaid(ctx context.Context, id, adminID int64) (*pg.ReferralWithdrawal, error)
+	Reject(ctx context.Context, id, adminID int64) (*pg.ReferralWithdrawal, error)
+}
+
+// ParseWithdrawal splits "<amount> <payout details>" sent by the referrer.
+func ParseWithdrawal(text string) (float64, string, error) {
+	text = strings.TrimSpace(text)
+	amountStr, details, _ := strings.Cut(text, " ")
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(amountStr, ",", "."), 64)
+	details = strings.TrimSpace(details)
+	if err != nil || amount <= 0 || details == "" {
+		return 0, "", ErrWithdrawalFormat
+	}
+	if utf8.RuneCountInString(details) > maxWithdrawalDetails {
+		return 0, "", ErrWithdrawalDetails
+	}
+	return amount, details, nil
+}
+
+// Withdrawable returns the part of the balance earned through referrals and not yet withdrawn.
+func (s *Service) Withdrawable(ctx context.Context, telegramID int64) (float64, error) {
+	return s.withdrawals.Withdrawable(ctx, telegramID)
+}
+
+// RequestWithdrawal holds the amount on the referrer balance until an admin
+// pays it out or rejects it. Admins are notified about the request.
+func (s *Service) RequestWithdrawal(ctx context.Context, telegramID int64, amount float64, details string) (*pg.ReferralWithdrawal, error) {
+	if s.rules.MinWithdrawal <= 0 {
+		return nil, ErrWithdrawalDisabled
+	}
+	if amount < float64(s.rules.MinWithdrawal) {
+		return nil, ErrWithdrawalBelowMinimum
+	}
+	w, err := s.withdrawals.Create(ctx, telegramID, amount, details)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("referral withdrawal requested", "withdrawal_id", w.ID, "customer", utils.MaskHalfInt64(telegramID), "amount", amount)
+
+	for _, adminID := range config.GetAdminTelegramIds() {
+		lang := ""
+		if admin, err := s.customers.FindByTelegramId(ctx, adminID); err == nil && admin != nil {
+			lang = admin.Language
+		}
+		text := s.translation.Format(lang, "referral_withdrawal_admin_notice", translation.Args{
+			"id":       w.ID,
+			"customer": telegramID,
+			"amount":   translation.Raw(FormatAmount(w.Amount)),
+			"details":  translation.Raw(w.Details),
+		})
+		if err := s.sender.Send(ctx, &bot.SendMessageParams{ChatID: adminID, Text: text}); err != nil {
+			slog.Error("send withdrawal notice to admin", "err", err)
+		}
+	}
+	return w, nil
+}
+
+// PendingWithdrawals lists withdrawals waiting for payout, oldest first.
+func (s *Service) PendingWithdrawals(ctx context.Context) ([]pg.ReferralWithdrawal, error) {
+	return s.withdrawals.FindHeld(ctx, pendingWithdrawals)
+}
+
+// ApproveWithdrawal records that the admin paid the withdrawal out. It returns
+// nil when the withdrawal was already processed.
+func (s *Service) ApproveWithdrawal(ctx context.Context, id, adminID int64) (*pg.ReferralWithdrawal, error) {
+	w, err := s.withdrawals.MarkPaid(ctx, id, adminID)
+	if err != nil || w == nil {
+		return nil, err
+	}
+	slog.Info("referral withdrawal paid", "withdrawal_id", w.ID, "admin", adminID)
+	s.notifyWithdrawal(ctx, w, true)
+	return w, nil
+}
+
+// RejectWithdrawal returns the held amount to the referrer balance. It returns
+// nil when the withdrawal was already processed.
+func (s *Service) RejectWithdrawal(ctx context.Context, id, adminID int64) (*pg.ReferralWithdrawal, error) {
+	w, err := s.withdrawals.Reject(ctx, id, adminID)
+	if err != nil || w == nil {
+		return nil, err
+	}
+	slog.Info("referral withdrawal rejected", "withdrawal_id", w.ID, "admin", adminID)
+	s.notifyWithdrawal(ctx, w, false)
+	return w, nil
+}
+
+func (s *Service) notifyWithdrawal(ctx context.Context, w *pg.ReferralWithdrawal, paid bool) {
+	customer, err := s.customers.FindByTelegramId(ctx, w.CustomerID)
+	if err != nil || customer == nil {
+		return
+	}
+	args := translation.Args{"id": w.ID, "amount": translation.Raw(FormatAmount(w.Amount))}
+	text := s.translation.Format(customer.Language, "referral_withdrawal_rejected", args)
+	if paid {
+		text = s.translation.Format(customer.Language, "referral_withdrawal_paid", args)
+	}
+	if err := s.sender.Send(ctx, &bot.SendMessageParams{ChatID: customer.TelegramID, Text: text}); err != nil {
+		slog.Error("send withdrawal notification", "err", err)
+	}
+}
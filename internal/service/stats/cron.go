@@ -0,0 +1,43 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/robfig/cron/v3"
+
+	"remnawave-tg-shop-bot/internal/observability"
+)
+
+type dailyReporter interface {
+	SendDailyReport(ctx context.Context) error
+}
+
+type gaugeUpdater interface {
+	UpdateSubscriptionGauges(ctx context.Context) error
+}
+
+// RegisterReportCron sends the daily report at hour o'clock UTC.
+func RegisterReportCron(c *cron.Cron, svc dailyReporter, hour int) error {
+	_, err := c.AddFunc(fmt.Sprintf("0 %d * * *", hour), func() {
+		if err := svc.SendDailyReport(context.Background()); err != nil {
+			slog.Error("send daily stats report", "err", err)
+			return
+		}
+		observability.CronSucceeded("stats_report")
+	})
+	return err
+}
+
+// RegisterGaugesCron refreshes the subscription gauges every 5 minutes.
+func RegisterGaugesCron(c *cron.Cron, svc gaugeUpdater) error {
+	_, err := c.AddFunc("@every 5m", func() {
+		if err := svc.UpdateSubscriptionGauges(context.Background()); err != nil {
+			slog.Error("update subscription gauges", "err", err)
+			return
+		}
+		observability.CronSucceeded("subscription_gauges")
+	})
+	return err
+}
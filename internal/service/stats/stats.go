@@ -0,0 +1,258 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	tg "remnawave-tg-shop-bot/internal/adapter/telegram/messenger"
+	"remnawave-tg-shop-bot/internal/observability"
+	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	custrepo "remnawave-tg-shop-bot/internal/service/customer"
+)
+
+// Period selects the time range of a report, ending at the moment it is built.
+type Period string
+
+const (
+	PeriodDay   Period = "day"
+	PeriodWeek  Period = "week"
+	PeriodMonth Period = "month"
+	PeriodAll   Period = "all"
+)
+
+// Periods lists the periods offered on the statistics screen.
+var Periods = []Period{PeriodDay, PeriodWeek, PeriodMonth, PeriodAll}
+
+func ParsePeriod(s string) (Period, bool) {
+	for _, p := range Periods {
+		if string(p) == s {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// Range returns [from, to) of the period. The day starts at midnight UTC,
+// the week and the month are the last 7 and 30 days.
+func (p Period) Range(now time.Time) (time.Time, time.Time) {
+	now = now.UTC()
+	switch p {
+	case PeriodDay:
+		return now.Truncate(24 * time.Hour), now
+	case PeriodWeek:
+		return now.AddDate(0, 0, -7), now
+	case PeriodMonth:
+		return now.AddDate(0, 0, -30), now
+	default:
+		return time.Unix(0, 0).UTC(), now
+	}
+}
+
+type Repository interface {
+	Totals(ctx context.Context, from, to time.Time) (*pg.StatsTotals, error)
+	Revenue(ctx context.Context, from, to time.Time) ([]pg.RevenueRow, error)
+	Subscriptions(ctx context.Context, now, until time.Time) (active, expiring int, err error)
+}
+
+// Report is the statistics of [From, To).
+type Report struct {
+	pg.StatsTotals
+	Period  Period
+	From    time.Time
+	To      time.Time
+	Revenue []pg.RevenueRow
+}
+
+// Conversion is the share of the period trials converted to paid, in percent.
+func (r *Report) Conversion() float64 {
+	if r.Trials == 0 {
+		return 0
+	}
+	return float64(r.ConvertedTrials) * 100 / float64(r.Trials)
+}
+
+// Revenue sums paid purchases of one group in one currency. Amounts in
+// different currencies are never added up.
+type Revenue struct {
+	Provider string
+	Months   int
+	Currency string
+	Count    int
+	Amount   float64
+}
+
+// ByCurrency sums the revenue per currency.
+func (r *Report) ByCurrency() []Revenue {
+	return groupRevenue(r.Revenue, func(row pg.RevenueRow) Revenue { return Revenue{Currency: row.Currency} })
+}
+
+// ByProvider sums the revenue per payment provider and currency.
+func (r *Report) ByProvider() []Revenue {
+	return groupRevenue(r.Revenue, func(row pg.RevenueRow) Revenue {
+		return Revenue{Provider: row.Provider, Currency: row.Currency}
+	})
+}
+
+// ByPlan sums the revenue per subscription length and currency.
+func (r *Report) ByPlan() []Revenue {
+	return groupRevenue(r.Revenue, func(row pg.RevenueRow) Revenue { return Revenue{Months: row.Months, Currency: row.Currency} })
+}
+
+func groupRevenue(rows []pg.RevenueRow, key func(pg.RevenueRow) Revenue) []Revenue {
+	sums := make(map[Revenue]*Revenue)
+	var out []*Revenue
+	for _, row := range rows {
+		k := key(row)
+		sum, ok := sums[k]
+		if !ok {
+			sum = &Revenue{Provider: k.Provider, Months: k.Months, Currency: k.Currency}
+			sums[k] = sum
+			out = append(out, sum)
+		}
+		sum.Count += row.Count
+		sum.Amount += row.Amount
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Provider != out[j].Provider {
+			return out[i].Provider < out[j].Provider
+		}
+		if out[i].Months != out[j].Months {
+			return out[i].Months < out[j].Months
+		}
+		return out[i].Currency < out[j].Currency
+	})
+	revenue := make([]Revenue, len(out))
+	for i, r := range out {
+		revenue[i] = *r
+	}
+	return revenue
+}
+
+type Service struct {
+	repo        Repository
+	customers   custrepo.Repository
+	messenger   tg.Messenger
+	translation *translation.Manager
+	now         func() time.Time
+}
+
+func NewService(repo Repository, customers custrepo.Repository, messenger tg.Messenger, translation *translation.Manager) *Service {
+	return &Service{repo: repo, customers: customers, messenger: messenger, translation: translation, now: time.Now}
+}
+
+// Report builds the statistics of the period ending now.
+func (s *Service) Report(ctx context.Context, period Period) (*Report, error) {
+	from, to := period.Range(s.now())
+	return s.build(ctx, period, from, to)
+}
+
+func (s *Service) build(ctx context.Context, period Period, from, to time.Time) (*Report, error) {
+	totals, err := s.repo.Totals(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	revenue, err := s.repo.Revenue(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &Report{StatsTotals: *totals, Period: period, From: from, To: to, Revenue: revenue}, nil
+}
+
+// UpdateSubscriptionGauges refreshes the active and expiring subscription
+// gauges. Expiring are the ones the expiration notice goes out for.
+func (s *Service) UpdateSubscriptionGauges(ctx context.Context) error {
+	now := s.now()
+	active, expiring, err := s.repo.Subscriptions(ctx, now, now.AddDate(0, 0, 3))
+	if err != nil {
+		return err
+	}
+	observability.Subscriptions.WithLabelValues("active").Set(float64(active))
+	observability.Subscriptions.WithLabelValues("expiring").Set(float64(expiring))
+	return nil
+}
+
+// SendDailyReport sends the statistics of the previous UTC day to every admin
+// in their language.
+func (s *Service) SendDailyReport(ctx context.Context) error {
+	to := s.now().UTC().Truncate(24 * time.Hour)
+	report, err := s.build(ctx, PeriodDay, to.AddDate(0, 0, -1), to)
+	if err != nil {
+		return err
+	}
+	for _, adminID := range config.GetAdminTelegramIds() {
+		lang := ""
+		if admin, err := s.customers.FindByTelegramId(ctx, adminID); err == nil && admin != nil {
+			lang = admin.Language
+		}
+		date := report.From.Format("02.01.2006")
+		text := s.translation.Format(lang, "stats_daily_title", translation.Args{"date": date}) + "\n\n" + s.Format(lang, report)
+		_, err := s.messenger.SendMessage(ctx, &bot.SendMessageParams{ChatID: adminID, Text: text, ParseMode: models.ParseModeHTML})
+		if err != nil {
+			slog.Error("send daily stats report", "err", err)
+		}
+	}
+	return nil
+}
+
+// Format renders the report body as HTML.
+func (s *Service) Format(lang string, r *Report) string {
+	tm := s.translation
+	var revenue strings.Builder
+	if len(r.Revenue) == 0 {
+		revenue.WriteString(tm.GetText(lang, "stats_no_revenue"))
+	} else {
+		revenue.WriteString(tm.GetText(lang, "stats_by_currency"))
+		for _, rev := range r.ByCurrency() {
+			revenue.WriteString("\n" + s.revenueLine(lang, "", rev))
+		}
+		revenue.WriteString("\n\n" + tm.GetText(lang, "stats_by_provider"))
+		for _, rev := range r.ByProvider() {
+			revenue.WriteString("\n" + s.revenueLine(lang, rev.Provider, rev))
+		}
+		revenue.WriteString("\n\n" + tm.GetText(lang, "stats_by_plan"))
+		for _, rev := range r.ByPlan() {
+			plan := tm.Format(lang, "stats_plan", translation.Args{"months": rev.Months})
+			revenue.WriteString("\n" + s.revenueLine(lang, plan, rev))
+		}
+	}
+
+	return tm.Format(lang, "stats_report", translation.Args{
+		"customers":    r.NewCustomers,
+		"trials":       r.Trials,
+		"converted":    r.ConvertedTrials,
+		"conversion":   fmt.Sprintf("%.1f", r.Conversion()),
+		"active":       r.ActiveSubscriptions,
+		"revenue":      translation.Raw(revenue.String()),
+		"referrals":    r.NewReferrals,
+		"reward_money": formatAmount(r.RewardMoney),
+		"reward_days":  r.RewardDays,
+		"withdrawals":  r.WithdrawalsPaid,
+		"withdrawn":    formatAmount(r.WithdrawnMoney),
+		"promos":       r.PromoRedemptions,
+	})
+}
+
+func (s *Service) revenueLine(lang, group string, rev Revenue) string {
+	args := translation.Args{"amount": formatAmount(rev.Amount), "currency": rev.Currency, "count": rev.Count}
+	if group == "" {
+		return s.translation.Format(lang, "stats_revenue_line", args)
+	}
+	args["group"] = group
+	return s.translation.Format(lang, "stats_revenue_group_line", args)
+}
+
+// formatAmount rounds away the float noise of summed amounts.
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
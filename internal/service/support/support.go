@@ -0,0 +1,157 @@
+package support
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"remnawave-tg-shop-bot/internal/repository/pg"
+)
+
+const (
+	// lastPurchases is the number of purchases listed in a topic header.
+	lastPurchases = 3
+	maxCannedText = 4000
+)
+
+var (
+	ErrFormat   = errors.New("expected canned response name and text")
+	ErrNotFound = errors.New("canned response not found")
+)
+
+var cannedName = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+type Repository interface {
+	FindByCustomer(ctx context.Context, customerID int64) (*pg.SupportTicket, error)
+	FindByThread(ctx context.Context, threadID int) (*pg.SupportTicket, error)
+	Create(ctx context.Context, t *pg.SupportTicket) error
+	SetThread(ctx context.Context, id int64, threadID int) error
+	SetStatus(ctx context.Context, id int64, status string) (bool, error)
+	CannedResponses(ctx context.Context) ([]pg.CannedResponse, error)
+	FindCannedResponse(ctx context.Context, name string) (*pg.CannedResponse, error)
+	SaveCannedResponse(ctx context.Context, name, text string) error
+	DeleteCannedResponse(ctx context.Context, name string) (bool, error)
+}
+
+type CustomerRepository interface {
+	FindByTelegramId(ctx context.Context, telegramId int64) (*pg.Customer, error)
+}
+
+type PurchaseRepository interface {
+	FindLastPaidByCustomer(ctx context.Context, customerID int64, limit int) ([]pg.Purchase, error)
+}
+
+type Service struct {
+	tickets   Repository
+	customers CustomerRepository
+	purchases PurchaseRepository
+}
+
+func NewService(tickets Repository, customers CustomerRepository, purchases PurchaseRepository) *Service {
+	return &Service{tickets: tickets, customers: customers, purchases: purchases}
+}
+
+// Summary is the customer context shown in the header of a ticket topic.
+type Summary struct {
+	Customer  *pg.Customer
+	Purchases []pg.Purchase
+}
+
+// Ticket returns the ticket of the customer, nil if they never contacted support.
+func (s *Service) Ticket(ctx context.Context, telegramID int64) (*pg.SupportTicket, error) {
+	return s.tickets.FindByCustomer(ctx, telegramID)
+}
+
+// TicketByThread returns the ticket of the support group topic, nil for other topics.
+func (s *Service) TicketByThread(ctx context.Context, threadID int) (*pg.SupportTicket, error) {
+	return s.tickets.FindByThread(ctx, threadID)
+}
+
+// Create opens the first ticket of the customer in the topic threadID.
+func (s *Service) Create(ctx context.Context, telegramID int64, threadID int) (*pg.SupportTicket, error) {
+	t := &pg.SupportTicket{CustomerID: telegramID, ThreadID: threadID, Status: pg.SupportTicketOpen}
+	if err := s.tickets.Create(ctx, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// MoveThread binds the ticket to a new topic.
+func (s *Service) MoveThread(ctx context.Context, t *pg.SupportTicket, threadID int) error {
+	if err := s.tickets.SetThread(ctx, t.ID, threadID); err != nil {
+		return err
+	}
+	t.ThreadID = threadID
+	return nil
+}
+
+// Reopen opens a closed ticket. It returns false when the ticket was open.
+func (s *Service) Reopen(ctx context.Context, t *pg.SupportTicket) (bool, error) {
+	return s.setStatus(ctx, t, pg.SupportTicketOpen)
+}
+
+// Close closes an open ticket. It returns false when the ticket was closed.
+func (s *Service) Close(ctx context.Context, t *pg.SupportTicket) (bool, error) {
+	return s.setStatus(ctx, t, pg.SupportTicketClosed)
+}
+
+func (s *Service) setStatus(ctx context.Context, t *pg.SupportTicket, status string) (bool, error) {
+	changed, err := s.tickets.SetStatus(ctx, t.ID, status)
+	if err != nil {
+		return false, err
+	}
+	t.Status = status
+	return changed, nil
+}
+
+// Summary collects the customer context for a topic header.
+func (s *Service) Summary(ctx context.Context, telegramID int64) (*Summary, error) {
+	customer, err := s.customers.FindByTelegramId(ctx, telegramID)
+	if err != nil || customer == nil {
+		return nil, err
+	}
+	purchases, err := s.purchases.FindLastPaidByCustomer(ctx, customer.ID, lastPurchases)
+	if err != nil {
+		return nil, err
+	}
+	return &Summary{Customer: customer, Purchases: purchases}, nil
+}
+
+// ParseCanned parses "<name> <text>", the text may span several lines.
+func ParseCanned(args string) (string, string, error) {
+	args = strings.TrimSpace(args)
+	name, text := args, ""
+	if i := strings.IndexAny(args, " \n"); i >= 0 {
+		name, text = args[:i], strings.TrimSpace(args[i+1:])
+	}
+	if !cannedName.MatchString(name) || text == "" || utf8.RuneCountInString(text) > maxCannedText {
+		return "", "", ErrFormat
+	}
+	return name, text, nil
+}
+
+func (s *Service) CannedResponses(ctx context.Context) ([]pg.CannedResponse, error) {
+	return s.tickets.CannedResponses(ctx)
+}
+
+func (s *Service) CannedResponse(ctx context.Context, name string) (*pg.CannedResponse, error) {
+	c, err := s.tickets.FindCannedResponse(ctx, strings.TrimSpace(name))
+	if err == nil && c == nil {
+		err = ErrNotFound
+	}
+	return c, err
+}
+
+func (s *Service) SaveCannedResponse(ctx context.Context, name, text string) error {
+	return s.tickets.SaveCannedResponse(ctx, name, text)
+}
+
+func (s *Service) DeleteCannedResponse(ctx context.Context, name string) error {
+	ok, err := s.tickets.DeleteCannedResponse(ctx, strings.TrimSpace(name))
+	if err == nil && !ok {
+		err = ErrNotFound
+	}
+	return err
+}
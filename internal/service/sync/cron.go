@@ -0,0 +1,26 @@
+package sync
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/robfig/cron/v3"
+
+	"remnawave-tg-shop-bot/internal/observability"
+)
+
+type syncer interface {
+	Sync(ctx context.Context, dryRun bool) (*Report, error)
+}
+
+// RegisterCron applies the panel sync on the cron schedule spec.
+func RegisterCron(c *cron.Cron, svc syncer, spec string) error {
+	_, err := c.AddFunc(spec, func() {
+		if _, err := svc.Sync(context.Background(), false); err != nil {
+			slog.Error("sync users with panel", "err", err)
+			return
+		}
+		observability.CronSucceeded("panel_sync")
+	})
+	return err
+}
@@ -2,102 +2,192 @@ package sync
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"slices"
+	gosync "sync"
+
+	remapi "github.com/Jolymmiles/remnawave-api-go/api"
+
 	"remnawave-tg-shop-bot/internal/adapter/remnawave"
 	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
 	custrepo "remnawave-tg-shop-bot/internal/service/customer"
-	"time"
 )
 
+const (
+	// sampleSize is the number of telegram ids listed for each kind of change.
+	sampleSize = 5
+	// batchSize keeps the batch queries under the Postgres parameter limit.
+	batchSize = 1000
+)
+
+var (
+	ErrRunning = errors.New("sync is already running")
+	// ErrNoUsers is returned when the panel has no users with a telegram id,
+	// which more likely means a misconfigured panel than no customers.
+	ErrNoUsers = errors.New("no users found in remnawave")
+)
+
+// Panel lists the panel users, see remnawave.Client.
+type Panel interface {
+	GetUsers(ctx context.Context) (*[]remapi.UserDto, error)
+}
+
+// Diff counts the customers of one kind of change and lists the telegram ids
+// of the first few.
+type Diff struct {
+	Count  int
+	Sample []int64
+}
+
+func (d *Diff) add(telegramID int64) {
+	d.Count++
+	if len(d.Sample) < sampleSize {
+		d.Sample = append(d.Sample, telegramID)
+	}
+}
+
+// Report describes the changes of a sync. Orphaned are the customers with a
+// subscription but no panel user.
+type Report struct {
+	DryRun   bool
+	Created  Diff
+	Updated  Diff
+	Orphaned Diff
+}
+
+// SyncService copies the subscriptions of the panel users to the customers.
+// It never deletes customers or touches their balance and language: customers
+// missing from the panel are only marked.
 type SyncService struct {
-	client             *remnawave.Client
+	client             Panel
 	customerRepository custrepo.Repository
+	running            gosync.Mutex
 }
 
-func NewSyncService(client *remnawave.Client, customerRepository custrepo.Repository) *SyncService {
+func NewSyncService(client Panel, customerRepository custrepo.Repository) *SyncService {
 	return &SyncService{
 		client: client, customerRepository: customerRepository,
 	}
 }
 
-func (s SyncService) Sync() {
-	slog.Info("Starting sync")
-	ctx := context.Background()
-	var telegramIDs []int64
-	telegramIDsSet := make(map[int64]int64)
-	var mappedUsers []domaincustomer.Customer
+// Sync compares the panel users with the customers and applies the changes
+// unless dryRun is set. It returns ErrRunning when another sync is in progress.
+func (s *SyncService) Sync(ctx context.Context, dryRun bool) (*Report, error) {
+	if !s.running.TryLock() {
+		return nil, ErrRunning
+	}
+	defer s.running.Unlock()
+
+	slog.Info("Starting sync", "dry_run", dryRun)
 	users, err := s.client.GetUsers(ctx)
 	if err != nil {
-		slog.Error("Error while getting users from remnawave")
-		return
-	}
-	if users == nil || len(*users) == 0 {
-		slog.Error("No users found in remnawave")
-		return
+		return nil, err
 	}
 
-	for _, user := range *users {
-		if user.TelegramId.Null {
-			continue
+	var order []int64
+	byTelegramID := make(map[int64][]remapi.UserDto)
+	if users != nil {
+		for _, user := range *users {
+			if user.TelegramId.Null {
+				continue
+			}
+			telegramID := int64(user.TelegramId.Value)
+			if _, exists := byTelegramID[telegramID]; !exists {
+				order = append(order, telegramID)
+			}
+			byTelegramID[telegramID] = append(byTelegramID[telegramID], user)
 		}
-		if _, exists := telegramIDsSet[int64(user.TelegramId.Value)]; exists {
+	}
+	var telegramIDs []int64
+	var panelUsers []domaincustomer.Customer
+	for _, telegramID := range order {
+		// the same user the bot renews, extra subscriptions are not kept on the customer
+		user := remnawave.PrimaryUser(telegramID, byTelegramID[telegramID])
+		if user == nil {
 			continue
 		}
-
-		telegramIDsSet[int64(user.TelegramId.Value)] = int64(user.TelegramId.Value)
-
-		telegramIDs = append(telegramIDs, int64(user.TelegramId.Value))
-
-		mappedUsers = append(mappedUsers, domaincustomer.Customer{
-			TelegramID:       int64(user.TelegramId.Value),
+		telegramIDs = append(telegramIDs, telegramID)
+		panelUsers = append(panelUsers, domaincustomer.Customer{
+			TelegramID:       telegramID,
 			ExpireAt:         &user.ExpireAt,
 			SubscriptionLink: &user.SubscriptionUrl,
-			Balance:          0,
 		})
 	}
-
-	existingCustomers, err := s.customerRepository.FindByTelegramIds(ctx, telegramIDs)
-	if err != nil {
-		slog.Error("Error while searching users by telegram ids")
-		return
-	}
-	existingMap := make(map[int64]domaincustomer.Customer)
-	for _, cust := range existingCustomers {
-		existingMap[cust.TelegramID] = cust
+	if len(panelUsers) == 0 {
+		return nil, ErrNoUsers
 	}
 
-	var toCreate []domaincustomer.Customer
-	var toUpdate []domaincustomer.Customer
+	existing := make(map[int64]domaincustomer.Customer, len(telegramIDs))
+	for ids := range slices.Chunk(telegramIDs, batchSize) {
+		customers, err := s.customerRepository.FindByTelegramIds(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, cust := range customers {
+			existing[cust.TelegramID] = cust
+		}
+	}
 
-	for _, cust := range mappedUsers {
-		if _, found := existingMap[cust.TelegramID]; found {
-			cust.CreatedAt = time.Now()
-			toUpdate = append(toUpdate, cust)
-		} else {
-			toCreate = append(toCreate, cust)
+	report := &Report{DryRun: dryRun}
+	var toCreate, toUpdate []domaincustomer.Customer
+	for _, user := range panelUsers {
+		cust, found := existing[user.TelegramID]
+		switch {
+		case !found:
+			toCreate = append(toCreate, user)
+			report.Created.add(user.TelegramID)
+		case changed(&cust, &user):
+			toUpdate = append(toUpdate, user)
+			report.Updated.add(user.TelegramID)
 		}
 	}
 
-	err = s.customerRepository.DeleteByNotInTelegramIds(ctx, telegramIDs)
+	orphans, err := s.customerRepository.FindMissingFromPanel(ctx, telegramIDs)
 	if err != nil {
-		slog.Error("Error while deleting users")
+		return nil, err
+	}
+	for _, telegramID := range orphans {
+		report.Orphaned.add(telegramID)
 	}
-	slog.Info("Deleted clients which not exist in panel")
 
-	if len(toCreate) > 0 {
-		if err := s.customerRepository.CreateBatch(ctx, toCreate); err != nil {
-			slog.Error("Error while creating users")
-		} else {
-			slog.Info("Created clients", "count", len(toCreate))
+	if !dryRun {
+		if err := s.apply(ctx, toCreate, toUpdate, orphans); err != nil {
+			return nil, err
 		}
 	}
+	slog.Info("Synchronization completed", "dry_run", dryRun,
+		"created", report.Created.Count, "updated", report.Updated.Count, "orphaned", report.Orphaned.Count)
+	return report, nil
+}
 
-	if len(toUpdate) > 0 {
-		if err := s.customerRepository.UpdateBatch(ctx, toUpdate); err != nil {
-			slog.Error("Error while updating users")
-		} else {
-			slog.Info("Updated clients", "count", len(toUpdate))
+func (s *SyncService) apply(ctx context.Context, toCreate, toUpdate []domaincustomer.Customer, orphans []int64) error {
+	for batch := range slices.Chunk(toCreate, batchSize) {
+		if err := s.customerRepository.CreateBatch(ctx, batch); err != nil {
+			return err
+		}
+	}
+	for batch := range slices.Chunk(toUpdate, batchSize) {
+		if err := s.customerRepository.UpdateBatch(ctx, batch); err != nil {
+			return err
+		}
+	}
+	for batch := range slices.Chunk(orphans, batchSize) {
+		if err := s.customerRepository.MarkMissingFromPanel(ctx, batch); err != nil {
+			return err
 		}
 	}
-	slog.Info("Synchronization completed")
+	return nil
+}
+
+// changed reports whether the customer differs from the panel user or has to
+// lose its missing mark.
+func changed(cust, user *domaincustomer.Customer) bool {
+	if cust.MissingFromPanelAt != nil {
+		return true
+	}
+	if cust.ExpireAt == nil || !cust.ExpireAt.Equal(*user.ExpireAt) {
+		return true
+	}
+	return cust.SubscriptionLink == nil || *cust.SubscriptionLink != *user.SubscriptionLink
 }
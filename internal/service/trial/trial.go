@@ -0,0 +1,173 @@
+package trial
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	remapi "github.com/Jolymmiles/remnawave-api-go/api"
+	"github.com/google/uuid"
+
+	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
+	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	custrepo "remnawave-tg-shop-bot/internal/service/customer"
+	"remnawave-tg-shop-bot/utils"
+)
+
+var (
+	ErrDisabled        = errors.New("trial is disabled")
+	ErrUsed            = errors.New("trial already used")
+	ErrSubscribed      = errors.New("customer already has a subscription")
+	ErrAccountTooNew   = errors.New("account is too new for a trial")
+	ErrChannelRequired = errors.New("channel membership required")
+	ErrPanelUserExists = errors.New("user already exists in remnawave")
+)
+
+type UsageRepository interface {
+	Claim(ctx context.Context, telegramID int64, variant string) (bool, error)
+	FindByTelegramId(ctx context.Context, telegramID int64) (*pg.TrialUsage, error)
+	Release(ctx context.Context, telegramID int64) error
+}
+
+// Panel creates trial users, see remnawave.Client.
+type Panel interface {
+	GetUserByTelegramID(ctx context.Context, telegramId int64) (*remapi.UserDto, error)
+	CreateOrUpdateUserWithInbounds(ctx context.Context, telegramId int64, trafficLimit int, days int, inbounds map[uuid.UUID]uuid.UUID) (*remapi.UserDto, error)
+}
+
+// MembershipChecker verifies channel membership, see messenger.BotMessenger.
+type MembershipChecker interface {
+	IsChatMember(ctx context.Context, chat string, userID int64) (bool, error)
+}
+
+// Rules describe the trial offered by the deployment and who may take it.
+type Rules struct {
+	Variant      string
+	Days         int
+	TrafficLimit int // bytes
+	Inbounds     map[uuid.UUID]uuid.UUID
+	// MinAccountAge is how long the customer must exist in the bot before a trial.
+	MinAccountAge time.Duration
+	// RequiredChannel is a chat the user must be a member of, empty disables the check.
+	RequiredChannel string
+	// NewPanelUsersOnly denies a trial to users already present in Remnawave.
+	NewPanelUsersOnly bool
+}
+
+func RulesFromConfig() Rules {
+	return Rules{
+		Variant:           config.TrialVariant(),
+		Days:              config.TrialDays(),
+		TrafficLimit:      config.TrialTrafficLimit(),
+		Inbounds:          config.TrialInboundUUIDs(),
+		MinAccountAge:     time.Duration(config.TrialMinAccountAgeHours()) * time.Hour,
+		RequiredChannel:   config.TrialRequiredChannel(),
+		NewPanelUsersOnly: config.IsTrialNewPanelUsersOnly(),
+	}
+}
+
+type Service struct {
+	rules     Rules
+	usage     UsageRepository
+	customers custrepo.Repository
+	panel     Panel
+	members   MembershipChecker
+	now       func() time.Time
+}
+
+func NewService(rules Rules, usage UsageRepository, customers custrepo.Repository, panel Panel, members MembershipChecker) *Service {
+	return &Service{
+		rules:     rules,
+		usage:     usage,
+		customers: customers,
+		panel:     panel,
+		members:   members,
+		now:       time.Now,
+	}
+}
+
+func (s *Service) Rules() Rules {
+	return s.rules
+}
+
+// Used reports whether the user already took a trial, even under a deleted customer row.
+func (s *Service) Used(ctx context.Context, telegramID int64) (bool, error) {
+	usage, err := s.usage.FindByTelegramId(ctx, telegramID)
+	return usage != nil, err
+}
+
+// Check returns nil when the customer may activate a trial, otherwise the
+// reason as one of the package errors.
+func (s *Service) Check(ctx context.Context, customer *domaincustomer.Customer) error {
+	if s.rules.Days <= 0 {
+		return ErrDisabled
+	}
+	used, err := s.Used(ctx, customer.TelegramID)
+	if err != nil {
+		return err
+	}
+	if used {
+		return ErrUsed
+	}
+	// the trial would overwrite the traffic limit and inbounds of a paid user
+	if customer.SubscriptionLink != nil || (customer.ExpireAt != nil && customer.ExpireAt.After(s.now())) {
+		return ErrSubscribed
+	}
+	if s.rules.MinAccountAge > 0 && s.now().Sub(customer.CreatedAt) < s.rules.MinAccountAge {
+		return ErrAccountTooNew
+	}
+	if s.rules.NewPanelUsersOnly {
+		user, err := s.panel.GetUserByTelegramID(ctx, customer.TelegramID)
+		if err != nil {
+			return err
+		}
+		if user != nil {
+			return ErrPanelUserExists
+		}
+	}
+	if s.rules.RequiredChannel != "" {
+		member, err := s.members.IsChatMember(ctx, s.rules.RequiredChannel, customer.TelegramID)
+		if err != nil {
+			return err
+		}
+		if !member {
+			return ErrChannelRequired
+		}
+	}
+	return nil
+}
+
+// Activate checks eligibility, records the trial and creates the panel user.
+// The record is removed again when the panel call fails.
+func (s *Service) Activate(ctx context.Context, customer *domaincustomer.Customer) (string, error) {
+	if err := s.Check(ctx, customer); err != nil {
+		return "", err
+	}
+	claimed, err := s.usage.Claim(ctx, customer.TelegramID, s.rules.Variant)
+	if err != nil {
+		return "", err
+	}
+	if !claimed {
+		return "", ErrUsed
+	}
+
+	user, err := s.panel.CreateOrUpdateUserWithInbounds(ctx, customer.TelegramID, s.rules.TrafficLimit, s.rules.Days, s.rules.Inbounds)
+	if err != nil {
+		if rerr := s.usage.Release(ctx, customer.TelegramID); rerr != nil {
+			slog.Error("release trial usage", "err", rerr)
+		}
+		return "", err
+	}
+
+	err = s.customers.UpdateFields(ctx, customer.ID, map[string]interface{}{
+		"subscription_link": user.GetSubscriptionUrl(),
+		"expire_at":         user.GetExpireAt(),
+	})
+	if err != nil {
+		return "", err
+	}
+	slog.Info("trial activated", "telegramId", utils.MaskHalfInt64(customer.TelegramID), "variant", s.rules.Variant)
+	return user.GetSubscriptionUrl(), nil
+}
@@ -41,7 +41,10 @@ func TestHeaderTransport(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	c := NewClient(srv.URL, "token", "local")
+	c, err := NewClient(srv.URL, "token", "local")
+	if err != nil {
+		t.Fatal(err)
+	}
 	if err := c.Ping(context.Background()); err != nil {
 		t.Fatalf("ping: %v", err)
 	}
@@ -12,11 +12,17 @@ type StubCustomerRepo struct {
 	Ctx context.Context
 	// CustomerByTelegramID is returned from FindByTelegramId when set.
 	CustomerByTelegramID *domaincustomer.Customer
-	Calls                int
+	// CustomerByID is returned from FindById when set.
+	CustomerByID *domaincustomer.Customer
+	Calls        int
+	// Updates records the arguments of UpdateFields.
+	Updates []map[string]interface{}
+	// Spent records the amounts of SpendBalance.
+	Spent []float64
 }
 
 func (s *StubCustomerRepo) FindById(ctx context.Context, id int64) (*domaincustomer.Customer, error) {
-	return nil, nil
+	return s.CustomerByID, nil
 }
 
 func (s *StubCustomerRepo) FindByTelegramId(ctx context.Context, telegramId int64) (*domaincustomer.Customer, error) {
@@ -33,22 +39,32 @@ func (s *StubCustomerRepo) Create(ctx context.Context, c *domaincustomer.Custome
 }
 
 func (s *StubCustomerRepo) UpdateFields(ctx context.Context, id int64, updates map[string]interface{}) error {
+	s.Updates = append(s.Updates, updates)
 	return nil
 }
 
+func (s *StubCustomerRepo) SpendBalance(ctx context.Context, id int64, amount float64) (float64, error) {
+	s.Spent = append(s.Spent, amount)
+	return 0, nil
+}
+
 func (s *StubCustomerRepo) FindByTelegramIds(ctx context.Context, telegramIDs []int64) ([]domaincustomer.Customer, error) {
 	return nil, nil
 }
 
-func (s *StubCustomerRepo) DeleteByNotInTelegramIds(ctx context.Context, telegramIDs []int64) error {
+func (s *StubCustomerRepo) CreateBatch(ctx context.Context, customers []domaincustomer.Customer) error {
 	return nil
 }
 
-func (s *StubCustomerRepo) CreateBatch(ctx context.Context, customers []domaincustomer.Customer) error {
+func (s *StubCustomerRepo) UpdateBatch(ctx context.Context, customers []domaincustomer.Customer) error {
 	return nil
 }
 
-func (s *StubCustomerRepo) UpdateBatch(ctx context.Context, customers []domaincustomer.Customer) error {
+func (s *StubCustomerRepo) FindMissingFromPanel(ctx context.Context, telegramIDs []int64) ([]int64, error) {
+	return nil, nil
+}
+
+func (s *StubCustomerRepo) MarkMissingFromPanel(ctx context.Context, telegramIDs []int64) error {
 	return nil
 }
 
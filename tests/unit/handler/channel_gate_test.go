@@ -0,0 +1,145 @@
+package handler_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	handlerpkg "remnawave-tg-shop-bot/internal/adapter/telegram/handler"
+	"remnawave-tg-shop-bot/internal/pkg/config"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	"remnawave-tg-shop-bot/tests/testutils"
+)
+
+// fakeTelegramAPI answers Bot API calls and reports the channel membership status.
+type fakeTelegramAPI struct {
+	mu     sync.Mutex
+	status string
+	calls  []string
+}
+
+func (f *fakeTelegramAPI) Do(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	method := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+	f.calls = append(f.calls, method)
+
+	body := `{"ok":true,"result":{"message_id":1}}`
+	switch method {
+	case "getChatMember":
+		body = `{"ok":true,"result":{"status":"` + f.status + `","user":{"id":5}}}`
+	case "answerCallbackQuery":
+		body = `{"ok":true,"result":true}`
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func (f *fakeTelegramAPI) count(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, c := range f.calls {
+		if c == method {
+			n++
+		}
+	}
+	return n
+}
+
+// initHandlerConfig loads the config from the required variables plus env.
+func initHandlerConfig(t *testing.T, env map[string]string) {
+	t.Helper()
+	t.Setenv("DISABLE_ENV_FILE", "true")
+	t.Setenv("ADMIN_TELEGRAM_IDS", "1")
+	t.Setenv("TELEGRAM_TOKEN", "token")
+	t.Setenv("TRIAL_TRAFFIC_LIMIT", "1")
+	t.Setenv("TRIAL_DAYS", "1")
+	t.Setenv("PRICE_1", "10")
+	t.Setenv("PRICE_3", "30")
+	t.Setenv("PRICE_6", "50")
+	t.Setenv("REMNAWAVE_URL", "http://example.com")
+	t.Setenv("REMNAWAVE_TOKEN", "tok")
+	t.Setenv("DATABASE_URL", "db")
+	t.Setenv("TRAFFIC_LIMIT", "100")
+	t.Setenv("CRYPTO_PAY_ENABLED", "false")
+	t.Setenv("TELEGRAM_STARS_ENABLED", "false")
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	config.InitConfig()
+	t.Cleanup(func() {
+		for k := range env {
+			t.Setenv(k, "")
+		}
+		config.InitConfig()
+	})
+}
+
+func callbackUpdate(data string) *models.Update {
+	return &models.Update{CallbackQuery: &models.CallbackQuery{
+		ID:   "q",
+		From: models.User{ID: 5, LanguageCode: "en"},
+		Data: data,
+		Message: models.MaybeInaccessibleMessage{
+			Type:    models.MaybeInaccessibleMessageTypeMessage,
+			Message: &models.Message{ID: 7, Chat: models.Chat{ID: 5}},
+		},
+	}}
+}
+
+func TestChannelGateResumesActionAfterJoin(t *testing.T) {
+	initHandlerConfig(t, map[string]string{
+		"CHANNEL_URL":  "https://t.me/news",
+		"CHANNEL_ID":   "@news",
+		"CHANNEL_GATE": "all",
+	})
+	trans := translation.GetInstance()
+	if err := trans.InitDefaultTranslations(); err != nil {
+		t.Fatal(err)
+	}
+
+	api := &fakeTelegramAPI{status: "left"}
+	b, err := bot.New("token", bot.WithHTTPClient(time.Second, api), bot.WithSkipGetMe(), bot.WithNotAsyncHandlers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handlerpkg.NewHandler(nil, nil, trans, &testutils.StubCustomerRepo{}, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	var resumed int
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handlerpkg.CallbackBuy, bot.MatchTypePrefix,
+		func(ctx context.Context, b *bot.Bot, update *models.Update) { resumed++ }, h.ChannelGateMiddleware)
+
+	ctx := context.Background()
+	b.ProcessUpdate(ctx, callbackUpdate(handlerpkg.CallbackBuy))
+	if resumed != 0 || api.count("editMessageText") != 1 {
+		t.Fatalf("non-member must see the gate: resumed %d, calls %v", resumed, api.calls)
+	}
+
+	// still not a member: the check only shows an alert
+	h.ChannelCheckCallbackHandler(ctx, b, callbackUpdate(handlerpkg.CallbackChannelCheck))
+	if resumed != 0 || api.count("answerCallbackQuery") != 1 {
+		t.Fatalf("check before joining: resumed %d, calls %v", resumed, api.calls)
+	}
+
+	api.status = "member"
+	h.ChannelCheckCallbackHandler(ctx, b, callbackUpdate(handlerpkg.CallbackChannelCheck))
+	if resumed != 1 || api.count("answerCallbackQuery") != 2 {
+		t.Fatalf("original action not resumed or check not answered after join, calls %v", api.calls)
+	}
+	// the membership of the resumed action comes from the cache
+	if n := api.count("getChatMember"); n != 3 {
+		t.Fatalf("expected 3 getChatMember calls, got %d", n)
+	}
+
+	b.ProcessUpdate(ctx, callbackUpdate(handlerpkg.CallbackBuy))
+	if resumed != 2 || api.count("getChatMember") != 3 {
+		t.Fatalf("cached member must pass without API call: resumed %d, calls %v", resumed, api.calls)
+	}
+}
@@ -59,7 +59,7 @@ func TestKeysCallbackHandler_DecodeBase64(t *testing.T) {
 		t.Fatalf("new bot: %v", err)
 	}
 
-	h := handlerpkg.NewHandler(nil, nil, trans, repo, nil, nil, nil, nil, nil)
+	h := handlerpkg.NewHandler(nil, nil, trans, repo, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 
 	upd := &models.Update{CallbackQuery: &models.CallbackQuery{From: models.User{ID: 1, LanguageCode: "en"}, Message: models.MaybeInaccessibleMessage{InaccessibleMessage: &models.InaccessibleMessage{Chat: models.Chat{ID: 1}, MessageID: 1}}}}
 
@@ -0,0 +1,81 @@
+package handler_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	handlerpkg "remnawave-tg-shop-bot/internal/adapter/telegram/handler"
+	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
+	"remnawave-tg-shop-bot/internal/pkg/contextkey"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	"remnawave-tg-shop-bot/tests/testutils"
+)
+
+func TestChosenLanguageIsNotOverwritten(t *testing.T) {
+	trans := translation.GetInstance()
+	if err := trans.InitDefaultTranslations(); err != nil {
+		t.Fatal(err)
+	}
+	repo := &testutils.StubCustomerRepo{CustomerByTelegramID: &domaincustomer.Customer{ID: 1, TelegramID: 5, Language: "ru", LanguageChosen: true}}
+	h := handlerpkg.NewHandler(nil, nil, trans, repo, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	var lang string
+	next := h.CreateCustomerIfNotExistMiddleware(func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		lang, _ = contextkey.LanguageFromContext(ctx)
+	})
+	next(context.Background(), nil, callbackUpdate(handlerpkg.CallbackStart))
+
+	if lang != "ru" {
+		t.Fatalf("expected chosen language ru, got %q", lang)
+	}
+	if len(repo.Updates) != 0 {
+		t.Fatalf("chosen language must not be overwritten: %v", repo.Updates)
+	}
+}
+
+func TestReturningCustomerIsReachable(t *testing.T) {
+	blockedAt := time.Now().Add(-time.Hour)
+	repo := &testutils.StubCustomerRepo{CustomerByTelegramID: &domaincustomer.Customer{ID: 1, TelegramID: 5, Language: "ru", LanguageChosen: true, UnreachableAt: &blockedAt}}
+	h := handlerpkg.NewHandler(nil, nil, translation.GetInstance(), repo, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	next := h.CreateCustomerIfNotExistMiddleware(func(ctx context.Context, b *bot.Bot, update *models.Update) {})
+	next(context.Background(), nil, callbackUpdate(handlerpkg.CallbackStart))
+
+	if len(repo.Updates) != 1 {
+		t.Fatalf("expected one update, got %v", repo.Updates)
+	}
+	if v, ok := repo.Updates[0]["unreachable_at"]; !ok || v != nil {
+		t.Fatalf("unreachable mark not cleared: %v", repo.Updates)
+	}
+}
+
+func TestLanguageCallbackStoresChoice(t *testing.T) {
+	trans := translation.GetInstance()
+	if err := trans.InitDefaultTranslations(); err != nil {
+		t.Fatal(err)
+	}
+	api := &fakeTelegramAPI{}
+	b, err := bot.New("token", bot.WithHTTPClient(time.Second, api), bot.WithSkipGetMe())
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &testutils.StubCustomerRepo{}
+	h := handlerpkg.NewHandler(nil, nil, trans, repo, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	h.LanguageCallbackHandler(context.Background(), b, callbackUpdate(handlerpkg.CallbackLanguage+":ru"))
+	if len(repo.Updates) != 1 || repo.Updates[0]["language"] != "ru" || repo.Updates[0]["language_chosen"] != true {
+		t.Fatalf("unexpected updates %v", repo.Updates)
+	}
+
+	h.LanguageCallbackHandler(context.Background(), b, callbackUpdate(handlerpkg.CallbackLanguage+":auto"))
+	if len(repo.Updates) != 2 || repo.Updates[1]["language"] != "en" || repo.Updates[1]["language_chosen"] != false {
+		t.Fatalf("auto must restore the Telegram language: %v", repo.Updates)
+	}
+	if n := api.count("editMessageText"); n != 2 {
+		t.Fatalf("expected the menu to be redrawn twice, got %d", n)
+	}
+}
@@ -15,6 +15,7 @@ import (
 	domainpurchase "remnawave-tg-shop-bot/internal/domain/purchase"
 	"remnawave-tg-shop-bot/internal/pkg/cache"
 	"remnawave-tg-shop-bot/internal/pkg/translation"
+	"remnawave-tg-shop-bot/internal/repository/pg"
 	"remnawave-tg-shop-bot/internal/service/payment"
 	"remnawave-tg-shop-bot/tests/testutils"
 )
@@ -40,7 +41,9 @@ func (s *stubPurchaseRepo) UpdateFields(ctx context.Context, id int64, updates m
 	s.ctxUpdate = ctx
 	return nil
 }
-func (s *stubPurchaseRepo) MarkAsPaid(ctx context.Context, purchaseID int64) error { return nil }
+func (s *stubPurchaseRepo) Pay(ctx context.Context, purchaseID int64, notice *pg.OutboxMessage) (bool, error) {
+	return true, nil
+}
 
 type stubMessenger struct{ ctx context.Context }
 
@@ -52,6 +55,10 @@ func (m *stubMessenger) DeleteMessage(ctx context.Context, params *bot.DeleteMes
 	m.ctx = ctx
 	return true, nil
 }
+func (m *stubMessenger) EditMessageText(ctx context.Context, params *bot.EditMessageTextParams) (*models.Message, error) {
+	m.ctx = ctx
+	return &models.Message{}, nil
+}
 func (m *stubMessenger) CreateInvoiceLink(ctx context.Context, params *bot.CreateInvoiceLinkParams) (string, error) {
 	m.ctx = ctx
 	return "link", nil
@@ -69,12 +76,12 @@ func TestPaymentCallbackHandler_ContextPropagation(t *testing.T) {
 	custRepo := &testutils.StubCustomerRepo{}
 	purchRepo := &stubPurchaseRepo{}
 	messenger := &stubMessenger{}
-	cache := cache.NewCache(context.Background(), time.Minute)
+	cache := cache.NewCache[int](context.Background(), time.Minute)
 	defer cache.Close()
 	trans := translation.GetInstance()
-	paySvc := payment.NewPaymentService(trans, purchRepo, nil, custRepo, messenger, nil, nil, nil, nil, cache)
+	paySvc := payment.NewPaymentService(trans, purchRepo, nil, custRepo, messenger, nil, nil, nil, nil, cache, nil, nil)
 
-	h := handlerpkg.NewHandler(nil, paySvc, trans, custRepo, nil, nil, nil, nil, cache)
+	h := handlerpkg.NewHandler(nil, paySvc, trans, custRepo, nil, nil, nil, nil, cache, nil, nil, nil, nil, nil, nil, nil)
 
 	b, err := bot.New("token", bot.WithHTTPClient(time.Second, &httpClient{}), bot.WithSkipGetMe())
 	if err != nil {
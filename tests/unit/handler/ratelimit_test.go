@@ -0,0 +1,55 @@
+package handler_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	handlerpkg "remnawave-tg-shop-bot/internal/adapter/telegram/handler"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	"remnawave-tg-shop-bot/tests/testutils"
+)
+
+func TestRateLimitThrottlesCallbacks(t *testing.T) {
+	initHandlerConfig(t, map[string]string{
+		"RATE_LIMIT_CHEAP_PER_MINUTE":     "1",
+		"RATE_LIMIT_CHEAP_BURST":          "5",
+		"RATE_LIMIT_EXPENSIVE_PER_MINUTE": "1",
+		"RATE_LIMIT_EXPENSIVE_BURST":      "2",
+	})
+	trans := translation.GetInstance()
+	if err := trans.InitDefaultTranslations(); err != nil {
+		t.Fatal(err)
+	}
+
+	api := &fakeTelegramAPI{}
+	b, err := bot.New("token", bot.WithHTTPClient(time.Second, api), bot.WithSkipGetMe(), bot.WithNotAsyncHandlers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handlerpkg.NewHandler(nil, nil, trans, &testutils.StubCustomerRepo{}, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	var cheap, expensive int
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handlerpkg.CallbackReferral, bot.MatchTypePrefix,
+		func(ctx context.Context, b *bot.Bot, update *models.Update) { cheap++ }, h.RateLimit(handlerpkg.BudgetCheap))
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handlerpkg.CallbackKeys, bot.MatchTypePrefix,
+		func(ctx context.Context, b *bot.Bot, update *models.Update) { expensive++ }, h.RateLimit(handlerpkg.BudgetExpensive))
+
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		b.ProcessUpdate(ctx, callbackUpdate(handlerpkg.CallbackKeys))
+		b.ProcessUpdate(ctx, callbackUpdate(handlerpkg.CallbackReferral))
+	}
+	if expensive != 2 {
+		t.Fatalf("expensive budget must allow 2 updates, got %d", expensive)
+	}
+	if cheap != 4 {
+		t.Fatalf("cheap budget is separate and must allow all 4 updates, got %d", cheap)
+	}
+	if n := api.count("answerCallbackQuery"); n != 2 {
+		t.Fatalf("throttled callbacks must get a toast, got %d answers", n)
+	}
+}
@@ -0,0 +1,35 @@
+package handler_test
+
+import (
+	"testing"
+
+	"github.com/go-telegram/bot/models"
+
+	handlerpkg "remnawave-tg-shop-bot/internal/adapter/telegram/handler"
+)
+
+func TestUpdateLabel(t *testing.T) {
+	callback := func(data string) *models.Update {
+		return &models.Update{CallbackQuery: &models.CallbackQuery{Data: data}}
+	}
+	message := func(text string) *models.Update {
+		return &models.Update{Message: &models.Message{Text: text, Chat: models.Chat{ID: 1}}}
+	}
+	cases := []struct {
+		update *models.Update
+		want   string
+	}{
+		{callback("faq:c:3:0"), "callback:faq"},
+		{callback("topup_method?amount=100"), "callback:topup_method"},
+		{callback("made_up_by_client"), "callback:other"},
+		{message("/start@shop_bot ref_1"), "command:start"},
+		{message("/unknown"), "command:other"},
+		{message("hello"), "message"},
+		{&models.Update{PreCheckoutQuery: &models.PreCheckoutQuery{}}, "pre_checkout"},
+	}
+	for _, c := range cases {
+		if got := handlerpkg.UpdateLabel(c.update); got != c.want {
+			t.Errorf("got %q, want %q", got, c.want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package observability_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"remnawave-tg-shop-bot/internal/observability"
+)
+
+func TestDashboardIsUpToDate(t *testing.T) {
+	generated, err := observability.Dashboard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed, err := os.ReadFile("../../../docs/grafana-dashboard.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(generated, committed) {
+		t.Fatal("docs/grafana-dashboard.json is outdated, run go generate ./internal/observability")
+	}
+
+	var d struct {
+		Panels []struct {
+			Description string `json:"description"`
+			Targets     []struct {
+				Expr string `json:"expr"`
+			} `json:"targets"`
+		} `json:"panels"`
+	}
+	if err := json.Unmarshal(generated, &d); err != nil {
+		t.Fatal(err)
+	}
+	charted := map[string]bool{}
+	for _, p := range d.Panels {
+		if len(p.Targets) == 0 || p.Targets[0].Expr == "" {
+			t.Errorf("panel %s has no query", p.Description)
+		}
+		charted[p.Description] = true
+	}
+	for _, def := range observability.Definitions {
+		if !charted[def.Name] {
+			t.Errorf("metric %s has no panel", def.Name)
+		}
+	}
+}
+
+func TestObserveDependency(t *testing.T) {
+	errorsBefore := testutil.ToFloat64(observability.DependencyErrors.WithLabelValues("test", "op"))
+	observability.ObserveDependency("test", "op", time.Now(), nil)
+	observability.ObserveDependency("test", "op", time.Now(), errors.New("timeout"))
+
+	if got := testutil.ToFloat64(observability.DependencyErrors.WithLabelValues("test", "op")) - errorsBefore; got != 1 {
+		t.Errorf("errors counted %v, want 1", got)
+	}
+	if n := testutil.CollectAndCount(observability.DependencyDuration, "bot_dependency_request_duration_seconds"); n == 0 {
+		t.Error("duration not observed")
+	}
+}
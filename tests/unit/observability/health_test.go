@@ -0,0 +1,70 @@
+package observability_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/observability"
+)
+
+func readyz(t *testing.T, h *observability.Health) (int, observability.HealthReport) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ReadyHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var report observability.HealthReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, report
+}
+
+func TestReadinessReport(t *testing.T) {
+	var calls atomic.Int32
+	paymentErr := errors.New("bad token")
+	h := observability.NewHealth(time.Minute)
+	h.Register(
+		observability.HealthCheck{Name: "postgres", Check: func(context.Context) error { calls.Add(1); return nil }},
+		observability.HealthCheck{Name: "payment:crypto", Check: func(context.Context) error { return paymentErr }, Optional: true},
+	)
+
+	code, report := readyz(t, h)
+	if code != http.StatusOK || report.Status != observability.StatusDegraded {
+		t.Fatalf("optional failure: %d %+v", code, report)
+	}
+	if c := report.Components["payment:crypto"]; c.Status != observability.StatusFail || c.Error != "bad token" {
+		t.Errorf("payment component %+v", c)
+	}
+	if report.Components["postgres"].Status != observability.StatusOK {
+		t.Errorf("postgres component %+v", report.Components["postgres"])
+	}
+
+	readyz(t, h)
+	if calls.Load() != 1 {
+		t.Errorf("checks ran %d times within ttl", calls.Load())
+	}
+
+	h.Register(observability.HealthCheck{Name: "remnawave", Check: func(context.Context) error { return errors.New("timeout") }})
+	code, report = readyz(t, h)
+	if code != http.StatusServiceUnavailable || report.Status != observability.StatusFail {
+		t.Errorf("required failure: %d %+v", code, report)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("registering a check didn't reset the cache")
+	}
+}
+
+func TestLivenessSkipsChecks(t *testing.T) {
+	h := observability.NewHealth(0)
+	h.Register(observability.HealthCheck{Name: "postgres", Check: func(context.Context) error { return errors.New("down") }})
+	rec := httptest.NewRecorder()
+	h.LiveHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("liveness %d", rec.Code)
+	}
+}
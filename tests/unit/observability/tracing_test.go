@@ -0,0 +1,72 @@
+package observability_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"remnawave-tg-shop-bot/internal/observability"
+)
+
+func TestTracingTransport(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	ctx, parent := observability.StartSpan(context.Background(), "parent")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/users/42", nil)
+	client := &http.Client{Transport: observability.Transport("remnawave", http.DefaultTransport)}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	parent.End()
+
+	if req.Header.Get("traceparent") != "" {
+		t.Error("transport modified the request")
+	}
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("ended %d spans", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "remnawave GET" || span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("span %q with parent %v", span.Name(), span.Parent().SpanID())
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("status %v for 502", span.Status())
+	}
+	if !strings.Contains(traceparent, span.SpanContext().TraceID().String()) {
+		t.Errorf("traceparent %q not propagated", traceparent)
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(observability.NewLogHandler(slog.NewTextHandler(&buf, nil)))
+	logger.InfoContext(ctx, "traced")
+	logger.Info("untraced")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if !strings.Contains(lines[0], "trace_id="+parent.SpanContext().TraceID().String()) {
+		t.Errorf("trace id missing: %s", lines[0])
+	}
+	if strings.Contains(lines[1], "trace_id") {
+		t.Errorf("trace id without span: %s", lines[1])
+	}
+}
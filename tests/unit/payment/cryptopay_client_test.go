@@ -1,6 +1,7 @@
 package cryptopay_test
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -39,7 +40,7 @@ func TestClientCreateInvoice(t *testing.T) {
 	defer srv.Close()
 
 	c := cryptopay.NewCryptoPayClient(srv.URL, "tok")
-	inv, err := c.CreateInvoice(&cryptopay.InvoiceRequest{Amount: "10"})
+	inv, err := c.CreateInvoice(context.Background(), &cryptopay.InvoiceRequest{Amount: "10"})
 	if err != nil {
 		t.Fatalf("CreateInvoice: %v", err)
 	}
@@ -68,7 +69,7 @@ func TestClientGetInvoices(t *testing.T) {
 	defer srv.Close()
 
 	c := cryptopay.NewCryptoPayClient(srv.URL, "tok")
-	invs, err := c.GetInvoices("paid", "", "", "", 0, 0)
+	invs, err := c.GetInvoices(context.Background(), "paid", "", "", "", 0, 0)
 	if err != nil {
 		t.Fatalf("GetInvoices: %v", err)
 	}
@@ -0,0 +1,74 @@
+package breaker_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/pkg/breaker"
+)
+
+func TestBreakerOpensAndProbes(t *testing.T) {
+	now := time.Unix(0, 0)
+	var changes []bool
+	b := breaker.New(2, time.Minute).
+		WithClock(func() time.Time { return now }).
+		OnChange(func(open bool) { changes = append(changes, open) })
+
+	b.Failure()
+	if err := b.Allow(); err != nil {
+		t.Fatalf("opened after one failure: %v", err)
+	}
+	b.Failure()
+	if err := b.Allow(); !errors.Is(err, breaker.ErrOpen) {
+		t.Fatalf("not open after threshold: %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe not allowed after cooldown: %v", err)
+	}
+	if err := b.Allow(); err == nil {
+		t.Fatal("second call allowed while probing")
+	}
+	b.Failure()
+	if err := b.Allow(); err == nil {
+		t.Fatal("failed probe didn't reopen the breaker")
+	}
+
+	now = now.Add(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe not allowed: %v", err)
+	}
+	b.Success()
+	if b.Open() || b.Allow() != nil {
+		t.Fatal("successful probe didn't close the breaker")
+	}
+	if len(changes) != 2 || !changes[0] || changes[1] {
+		t.Errorf("state changes %v", changes)
+	}
+}
+
+func TestBreakerCancelReleasesProbe(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := breaker.New(1, time.Second).WithClock(func() time.Time { return now })
+	b.Failure()
+	now = now.Add(time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatal(err)
+	}
+	b.Cancel()
+	if err := b.Allow(); err != nil {
+		t.Fatalf("cancelled probe blocks the next one: %v", err)
+	}
+}
+
+func TestDisabledBreaker(t *testing.T) {
+	b := breaker.New(0, time.Minute)
+	for range 10 {
+		b.Failure()
+	}
+	if err := b.Allow(); err != nil {
+		t.Fatalf("disabled breaker opened: %v", err)
+	}
+}
@@ -9,7 +9,7 @@ import (
 )
 
 func TestCacheTTL(t *testing.T) {
-	c := cache.NewCache(context.Background(), 10*time.Millisecond)
+	c := cache.NewCache[int](context.Background(), 10*time.Millisecond)
 	defer c.Close()
 
 	c.Set(1, 42)
@@ -23,7 +23,7 @@ func TestCacheTTL(t *testing.T) {
 }
 
 func TestCacheDelete(t *testing.T) {
-	c := cache.NewCache(context.Background(), time.Minute)
+	c := cache.NewCache[int](context.Background(), time.Minute)
 	defer c.Close()
 
 	c.Set(2, 7)
@@ -34,7 +34,7 @@ func TestCacheDelete(t *testing.T) {
 }
 
 func TestCacheClose(t *testing.T) {
-	c := cache.NewCache(context.Background(), time.Millisecond)
+	c := cache.NewCache[int](context.Background(), time.Millisecond)
 	c.Close()
 	// second call should not panic
 	c.Close()
@@ -0,0 +1,42 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/pkg/ratelimit"
+)
+
+func TestLimiterBurstAndRefill(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := ratelimit.New(60, 3).WithClock(func() time.Time { return now })
+
+	for i := 0; i < 3; i++ {
+		if !l.Allow(1) {
+			t.Fatalf("call %d within burst throttled", i)
+		}
+	}
+	if l.Allow(1) {
+		t.Fatalf("call over burst allowed")
+	}
+	if !l.Allow(2) {
+		t.Fatalf("other key must have its own bucket")
+	}
+
+	now = now.Add(time.Second)
+	if !l.Allow(1) {
+		t.Fatalf("token not refilled after a second")
+	}
+	if l.Allow(1) {
+		t.Fatalf("only one token refills per second")
+	}
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	l := ratelimit.New(0, 0)
+	for i := 0; i < 100; i++ {
+		if !l.Allow(1) {
+			t.Fatalf("disabled limiter throttled")
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package faq_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/internal/service/faq"
+)
+
+type stubRepo struct {
+	categories []pg.FAQCategory
+	articles   []pg.FAQArticle
+	searched   string
+}
+
+func (s *stubRepo) Categories(ctx context.Context, lang string) ([]pg.FAQCategory, error) {
+	var out []pg.FAQCategory
+	for _, c := range s.categories {
+		if c.Language == lang {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (s *stubRepo) FindCategory(ctx context.Context, id int64) (*pg.FAQCategory, error) {
+	for _, c := range s.categories {
+		if c.ID == id {
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *stubRepo) CreateCategory(ctx context.Context, c *pg.FAQCategory) error {
+	c.ID = int64(len(s.categories) + 1)
+	s.categories = append(s.categories, *c)
+	return nil
+}
+
+func (s *stubRepo) DeleteCategory(ctx context.Context, id int64) (bool, error) { return false, nil }
+
+func (s *stubRepo) Articles(ctx context.Context, categoryID int64) ([]pg.FAQArticle, error) {
+	return nil, nil
+}
+
+func (s *stubRepo) Pages(ctx context.Context, lang string) ([]pg.FAQArticle, error) {
+	var out []pg.FAQArticle
+	for _, a := range s.articles {
+		if a.Slug != nil && a.Language == lang {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func (s *stubRepo) Search(ctx context.Context, lang, query string, limit int) ([]pg.FAQArticle, error) {
+	s.searched = lang
+	return nil, nil
+}
+
+func (s *stubRepo) FindArticle(ctx context.Context, id int64) (*pg.FAQArticle, error) {
+	return nil, nil
+}
+
+func (s *stubRepo) CreateArticle(ctx context.Context, a *pg.FAQArticle) error {
+	a.ID = int64(len(s.articles) + 1)
+	s.articles = append(s.articles, *a)
+	return nil
+}
+
+func (s *stubRepo) SavePage(ctx context.Context, a *pg.FAQArticle) error {
+	return s.CreateArticle(ctx, a)
+}
+
+func (s *stubRepo) UpdateArticle(ctx context.Context, id int64, title, body string) (bool, error) {
+	return false, nil
+}
+
+func (s *stubRepo) DeleteArticle(ctx context.Context, id int64) (bool, error) { return false, nil }
+
+func slug(s string) *string { return &s }
+
+func TestParseArticle(t *testing.T) {
+	req, err := faq.ParseArticle(" 3 How to connect?\nOpen the app.\n\nThen import the link.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.ID != 3 || req.Title != "How to connect?" || req.Body != "Open the app.\n\nThen import the link." {
+		t.Fatalf("unexpected request %+v", req)
+	}
+
+	for _, args := range []string{"", "x Title\nbody", "3 Title", "3\nbody", "-1 Title\nbody"} {
+		if _, err := faq.ParseArticle(args); !errors.Is(err, faq.ErrFormat) {
+			t.Errorf("%q: got %v, want ErrFormat", args, err)
+		}
+	}
+	if _, err := faq.ParseArticle("3 Title\n" + strings.Repeat("a", 3501)); !errors.Is(err, faq.ErrTooLong) {
+		t.Errorf("long body: got %v", err)
+	}
+}
+
+func TestParsePageAndCategory(t *testing.T) {
+	req, err := faq.ParsePage("tos ru Условия\nТекст")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Slug != "tos" || req.Language != "ru" || req.Title != "Условия" || req.Body != "Текст" {
+		t.Fatalf("unexpected page %+v", req)
+	}
+	if _, err := faq.ParsePage("Terms of service\nText"); !errors.Is(err, faq.ErrFormat) {
+		t.Errorf("missing slug: got %v", err)
+	}
+
+	lang, title, err := faq.ParseCategory("en  Payments ")
+	if err != nil || lang != "en" || title != "Payments" {
+		t.Fatalf("got %q %q %v", lang, title, err)
+	}
+	if _, _, err := faq.ParseCategory("English Payments"); !errors.Is(err, faq.ErrFormat) {
+		t.Errorf("bad language: got %v", err)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	items := make([]int, 2*faq.PageSize+1)
+	for i := range items {
+		items[i] = i
+	}
+	page, n, pages := faq.Paginate(items, 2)
+	if n != 2 || pages != 3 || len(page) != 1 || page[0] != 2*faq.PageSize {
+		t.Fatalf("last page: %v %d %d", page, n, pages)
+	}
+	if _, n, _ := faq.Paginate(items, 10); n != 2 {
+		t.Errorf("page past the end not clamped: %d", n)
+	}
+	if page, _, pages := faq.Paginate([]int{}, 0); page != nil || pages != 0 {
+		t.Errorf("empty: %v %d", page, pages)
+	}
+}
+
+func TestLanguageFallback(t *testing.T) {
+	repo := &stubRepo{
+		categories: []pg.FAQCategory{{ID: 1, Language: "en", Title: "Payments"}, {ID: 2, Language: "ru", Title: "Оплата"}},
+		articles: []pg.FAQArticle{
+			{ID: 1, Language: "en", Slug: slug("tos"), Title: "Terms"},
+			{ID: 2, Language: "en", Slug: slug("privacy"), Title: "Privacy"},
+			{ID: 3, Language: "ru", Slug: slug("tos"), Title: "Условия"},
+		},
+	}
+	svc := faq.NewService(repo)
+	ctx := context.Background()
+
+	categories, _ := svc.Categories(ctx, "de")
+	if len(categories) != 1 || categories[0].Language != "en" {
+		t.Fatalf("de categories %+v, want the English ones", categories)
+	}
+	_, _ = svc.Search(ctx, "de", "pay")
+	if repo.searched != "en" {
+		t.Errorf("search language %q, want en", repo.searched)
+	}
+	_, _ = svc.Search(ctx, "ru", "оплата")
+	if repo.searched != "ru" {
+		t.Errorf("search language %q, want ru", repo.searched)
+	}
+
+	pages, _ := svc.Pages(ctx, "ru")
+	var titles []string
+	for _, p := range pages {
+		titles = append(titles, p.Title)
+	}
+	if strings.Join(titles, ",") != "Privacy,Условия" {
+		t.Errorf("ru pages %v", titles)
+	}
+}
+
+func TestAddArticleUsesCategoryLanguage(t *testing.T) {
+	repo := &stubRepo{categories: []pg.FAQCategory{{ID: 2, Language: "ru", Title: "Оплата"}}}
+	svc := faq.NewService(repo)
+
+	a, err := svc.AddArticle(context.Background(), faq.ArticleRequest{ID: 2, Title: "Как оплатить?", Body: "Картой"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Language != "ru" || a.CategoryID == nil || *a.CategoryID != 2 {
+		t.Errorf("unexpected article %+v", a)
+	}
+	if _, err := svc.AddArticle(context.Background(), faq.ArticleRequest{ID: 9, Title: "x", Body: "y"}); !errors.Is(err, faq.ErrNotFound) {
+		t.Errorf("unknown category: got %v", err)
+	}
+}
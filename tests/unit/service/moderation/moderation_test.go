@@ -0,0 +1,173 @@
+package moderation_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/internal/service/moderation"
+)
+
+type stubBlocks struct {
+	blocks map[int64]*pg.CustomerBlock
+	// customers that exist in the bot
+	customers map[int64]bool
+}
+
+func newStubBlocks(ids ...int64) *stubBlocks {
+	s := &stubBlocks{blocks: map[int64]*pg.CustomerBlock{}, customers: map[int64]bool{}}
+	for _, id := range ids {
+		s.customers[id] = true
+	}
+	return s
+}
+
+func (s *stubBlocks) Find(ctx context.Context, telegramID int64) (*pg.CustomerBlock, error) {
+	if b, ok := s.blocks[telegramID]; ok {
+		c := *b
+		return &c, nil
+	}
+	return nil, nil
+}
+
+func (s *stubBlocks) Block(ctx context.Context, telegramID int64, reason string, until *time.Time, panel bool) (bool, error) {
+	if !s.customers[telegramID] {
+		return false, nil
+	}
+	s.blocks[telegramID] = &pg.CustomerBlock{TelegramID: telegramID, Reason: reason, Until: until, Panel: panel}
+	return true, nil
+}
+
+func (s *stubBlocks) Unblock(ctx context.Context, telegramID int64) error {
+	delete(s.blocks, telegramID)
+	return nil
+}
+
+func (s *stubBlocks) MarkNotified(ctx context.Context, telegramID int64) (bool, error) {
+	b, ok := s.blocks[telegramID]
+	if !ok || b.Notified {
+		return false, nil
+	}
+	b.Notified = true
+	return true, nil
+}
+
+type stubAudit struct{ actions []string }
+
+func (s *stubAudit) Create(ctx context.Context, a *pg.AdminAudit) error {
+	s.actions = append(s.actions, a.Action)
+	return nil
+}
+
+type stubPanel struct {
+	enabled map[int64]bool
+	err     error
+}
+
+func (p *stubPanel) SetUserEnabled(ctx context.Context, telegramId int64, enabled bool) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.enabled[telegramId] = enabled
+	return nil
+}
+
+func TestParseBlock(t *testing.T) {
+	req, err := moderation.ParseBlock("42 7d spam in support")
+	if err != nil || req.TelegramID != 42 || req.Duration != 7*24*time.Hour || req.Reason != "spam in support" {
+		t.Fatalf("unexpected %+v %v", req, err)
+	}
+	req, err = moderation.ParseBlock("42 chargeback")
+	if err != nil || req.Duration != 0 || req.Reason != "chargeback" {
+		t.Fatalf("unexpected %+v %v", req, err)
+	}
+	for _, bad := range []string{"", "abc", "-1 1d"} {
+		if _, err := moderation.ParseBlock(bad); !errors.Is(err, moderation.ErrFormat) {
+			t.Fatalf("%q: expected ErrFormat, got %v", bad, err)
+		}
+	}
+}
+
+func TestBanDisablesPanelAndNotifiesOnce(t *testing.T) {
+	blocks, audit, panel := newStubBlocks(42), &stubAudit{}, &stubPanel{enabled: map[int64]bool{}}
+	svc := moderation.NewService(blocks, audit, panel)
+	ctx := context.Background()
+
+	if _, err := svc.Block(ctx, 1, moderation.BlockRequest{TelegramID: 42, Reason: "fraud"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if enabled, ok := panel.enabled[42]; !ok || enabled {
+		t.Fatalf("panel user must be disabled")
+	}
+
+	block, notify, err := svc.Check(ctx, 42)
+	if err != nil || block == nil || !notify {
+		t.Fatalf("first update must be notified: %+v %v %v", block, notify, err)
+	}
+	block, notify, _ = svc.Check(ctx, 42)
+	if block == nil || notify {
+		t.Fatalf("second update must be dropped silently")
+	}
+
+	if err := svc.Unblock(ctx, 1, 42); err != nil {
+		t.Fatal(err)
+	}
+	if !panel.enabled[42] {
+		t.Fatalf("panel user must be enabled after unblock")
+	}
+	if block, _, _ := svc.Check(ctx, 42); block != nil {
+		t.Fatalf("user still blocked")
+	}
+	if len(audit.actions) != 2 || audit.actions[0] != moderation.ActionBan || audit.actions[1] != moderation.ActionUnblock {
+		t.Fatalf("unexpected audit %v", audit.actions)
+	}
+	if err := svc.Unblock(ctx, 1, 42); !errors.Is(err, moderation.ErrNotBlocked) {
+		t.Fatalf("expected ErrNotBlocked, got %v", err)
+	}
+}
+
+func TestExpiredBlockIsLifted(t *testing.T) {
+	blocks, audit, panel := newStubBlocks(42), &stubAudit{}, &stubPanel{enabled: map[int64]bool{}}
+	past := time.Now().Add(-time.Minute)
+	blocks.blocks[42] = &pg.CustomerBlock{TelegramID: 42, Until: &past, Panel: true}
+	svc := moderation.NewService(blocks, audit, panel)
+
+	block, notify, err := svc.Check(context.Background(), 42)
+	if err != nil || block != nil || notify {
+		t.Fatalf("expired block must not apply: %+v %v %v", block, notify, err)
+	}
+	if !panel.enabled[42] || len(audit.actions) != 1 || audit.actions[0] != moderation.ActionExpire {
+		t.Fatalf("expired ban must be lifted: panel %v audit %v", panel.enabled, audit.actions)
+	}
+}
+
+func TestExpiredBanIsLiftedWhenPanelFails(t *testing.T) {
+	blocks, audit := newStubBlocks(42), &stubAudit{}
+	panel := &stubPanel{enabled: map[int64]bool{}, err: errors.New("panel down")}
+	past := time.Now().Add(-time.Minute)
+	blocks.blocks[42] = &pg.CustomerBlock{TelegramID: 42, Until: &past, Panel: true}
+	svc := moderation.NewService(blocks, audit, panel)
+
+	block, _, err := svc.Check(context.Background(), 42)
+	if err != nil || block != nil {
+		t.Fatalf("expired ban must not apply while the panel is down: %+v %v", block, err)
+	}
+	if _, ok := blocks.blocks[42]; ok {
+		t.Fatal("block not removed")
+	}
+
+	blocks.blocks[42] = &pg.CustomerBlock{TelegramID: 42, Panel: true}
+	if err := svc.Unblock(context.Background(), 1, 42); !errors.Is(err, moderation.ErrPanelEnable) {
+		t.Fatalf("expected ErrPanelEnable, got %v", err)
+	}
+}
+
+func TestBlockUnknownCustomer(t *testing.T) {
+	svc := moderation.NewService(newStubBlocks(), &stubAudit{}, &stubPanel{enabled: map[int64]bool{}})
+	_, err := svc.Block(context.Background(), 1, moderation.BlockRequest{TelegramID: 42}, false)
+	if !errors.Is(err, moderation.ErrCustomerNotFound) {
+		t.Fatalf("expected ErrCustomerNotFound, got %v", err)
+	}
+}
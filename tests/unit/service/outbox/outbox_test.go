@@ -0,0 +1,145 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/internal/service/outbox"
+)
+
+type stubOutbox struct {
+	due         []pg.OutboxMessage
+	sent        []int64
+	retried     map[int64]time.Time
+	postponed   map[int64]time.Time
+	failed      []int64
+	unreachable []int64
+}
+
+func newStubOutbox(due ...pg.OutboxMessage) *stubOutbox {
+	return &stubOutbox{due: due, retried: map[int64]time.Time{}, postponed: map[int64]time.Time{}}
+}
+
+func (s *stubOutbox) Enqueue(ctx context.Context, msg *pg.OutboxMessage) error {
+	msg.ID = int64(len(s.due) + 1)
+	s.due = append(s.due, *msg)
+	return nil
+}
+func (s *stubOutbox) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]pg.OutboxMessage, error) {
+	due := s.due
+	s.due = nil
+	return due, nil
+}
+func (s *stubOutbox) MarkSent(ctx context.Context, id int64) error {
+	s.sent = append(s.sent, id)
+	return nil
+}
+func (s *stubOutbox) Retry(ctx context.Context, id int64, runAt time.Time, lastError string) error {
+	s.retried[id] = runAt
+	return nil
+}
+func (s *stubOutbox) Postpone(ctx context.Context, chatID int64, runAt time.Time) error {
+	s.postponed[chatID] = runAt
+	return nil
+}
+func (s *stubOutbox) Fail(ctx context.Context, id int64, lastError string) error {
+	s.failed = append(s.failed, id)
+	return nil
+}
+func (s *stubOutbox) MarkUnreachable(ctx context.Context, chatID int64, lastError string) error {
+	s.unreachable = append(s.unreachable, chatID)
+	return nil
+}
+
+// stubMessenger fails the sends to the chats in errs.
+type stubMessenger struct {
+	errs   map[int64]error
+	params []*bot.SendMessageParams
+}
+
+func (m *stubMessenger) SendMessage(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+	m.params = append(m.params, params)
+	if err := m.errs[params.ChatID.(int64)]; err != nil {
+		return nil, err
+	}
+	return &models.Message{}, nil
+}
+func (m *stubMessenger) DeleteMessage(ctx context.Context, params *bot.DeleteMessageParams) (bool, error) {
+	return true, nil
+}
+func (m *stubMessenger) EditMessageText(ctx context.Context, params *bot.EditMessageTextParams) (*models.Message, error) {
+	return &models.Message{}, nil
+}
+func (m *stubMessenger) CreateInvoiceLink(ctx context.Context, params *bot.CreateInvoiceLinkParams) (string, error) {
+	return "", nil
+}
+
+func TestSendKeepsKeyboard(t *testing.T) {
+	repo, msg := newStubOutbox(), &stubMessenger{}
+	d := outbox.NewDispatcher(repo, msg)
+	keyboard := models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{{{Text: "Buy", CallbackData: "buy"}}}}
+
+	err := d.Send(context.Background(), &bot.SendMessageParams{ChatID: int64(1), Text: "<b>hi</b>", ParseMode: models.ParseModeHTML, ReplyMarkup: keyboard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Dispatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.sent) != 1 || len(msg.params) != 1 {
+		t.Fatalf("sent %v", repo.sent)
+	}
+	got := msg.params[0]
+	markup, ok := got.ReplyMarkup.(models.InlineKeyboardMarkup)
+	if got.ParseMode != models.ParseModeHTML || !ok || markup.InlineKeyboard[0][0].CallbackData != "buy" {
+		t.Errorf("params %+v", got)
+	}
+}
+
+func TestDispatchFailures(t *testing.T) {
+	repo := newStubOutbox(
+		pg.OutboxMessage{ID: 1, ChatID: 10},
+		pg.OutboxMessage{ID: 2, ChatID: 20},
+		pg.OutboxMessage{ID: 3, ChatID: 30},
+		pg.OutboxMessage{ID: 4, ChatID: 40},
+		pg.OutboxMessage{ID: 5, ChatID: 50},
+		pg.OutboxMessage{ID: 6, ChatID: 60, Attempts: 7},
+	)
+	network := errors.New("connection reset")
+	msg := &stubMessenger{errs: map[int64]error{
+		10: &bot.TooManyRequestsError{Message: "too many requests", RetryAfter: 5},
+		20: fmt.Errorf("%w, Forbidden: bot was blocked by the user", bot.ErrorForbidden),
+		30: fmt.Errorf("%w, Bad Request: chat not found", bot.ErrorBadRequest),
+		40: fmt.Errorf("%w, Bad Request: can't parse entities", bot.ErrorBadRequest),
+		50: network,
+		60: network,
+	}}
+	d := outbox.NewDispatcher(repo, msg)
+	start := time.Now()
+
+	if err := d.Dispatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if at, ok := repo.postponed[10]; !ok || at.Sub(start) < 5*time.Second {
+		t.Errorf("throttled chat postponed until %v", at)
+	}
+	if len(repo.unreachable) != 2 || repo.unreachable[0] != 20 || repo.unreachable[1] != 30 {
+		t.Errorf("unreachable chats %v", repo.unreachable)
+	}
+	if len(repo.failed) != 2 || repo.failed[0] != 4 || repo.failed[1] != 6 {
+		t.Errorf("failed messages %v", repo.failed)
+	}
+	if len(repo.retried) != 1 || repo.retried[5].Before(start) {
+		t.Errorf("retried messages %v", repo.retried)
+	}
+	if len(repo.sent) != 0 {
+		t.Errorf("sent %v", repo.sent)
+	}
+}
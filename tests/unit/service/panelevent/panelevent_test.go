@@ -0,0 +1,282 @@
+package panelevent_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/google/uuid"
+
+	"remnawave-tg-shop-bot/internal/adapter/remnawave"
+	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/internal/service/panelevent"
+	"remnawave-tg-shop-bot/tests/testutils"
+)
+
+const secret = "webhook-secret"
+
+var extraUUID = uuid.MustParse("2f1c7a52-8f0e-4b8e-9d53-5d7c6a1e0b11")
+
+type stubSender struct {
+	sent []*bot.SendMessageParams
+}
+
+func (s *stubSender) Send(ctx context.Context, params *bot.SendMessageParams) error {
+	s.sent = append(s.sent, params)
+	return nil
+}
+
+type stubSubscriptions struct {
+	byUUID     map[uuid.UUID]*pg.Subscription
+	byUsername map[string]*pg.Subscription
+	set        map[int64]time.Time
+}
+
+func (s *stubSubscriptions) FindByPanelUUID(ctx context.Context, panelUUID uuid.UUID) (*pg.Subscription, error) {
+	return s.byUUID[panelUUID], nil
+}
+func (s *stubSubscriptions) FindByPanelUsername(ctx context.Context, username string) (*pg.Subscription, error) {
+	return s.byUsername[username], nil
+}
+func (s *stubSubscriptions) SetPanelUser(ctx context.Context, id int64, panelUUID uuid.UUID, expireAt time.Time, link string) error {
+	s.set[id] = expireAt
+	return nil
+}
+
+type stubBlocks struct{ blocks map[int64]*pg.CustomerBlock }
+
+func (s *stubBlocks) Find(ctx context.Context, telegramID int64) (*pg.CustomerBlock, error) {
+	return s.blocks[telegramID], nil
+}
+
+func sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newHandler(t *testing.T) (http.Handler, *testutils.StubCustomerRepo, *stubSender) {
+	t.Helper()
+	h, customers, sender, _ := newHandlerWithSubscriptions(t)
+	return h, customers, sender
+}
+
+func newHandlerWithSubscriptions(t *testing.T) (http.Handler, *testutils.StubCustomerRepo, *stubSender, *stubSubscriptions) {
+	t.Helper()
+	tm := translation.GetInstance()
+	if err := tm.InitDefaultTranslations(); err != nil {
+		t.Fatal(err)
+	}
+	customers := &testutils.StubCustomerRepo{CustomerByTelegramID: &domaincustomer.Customer{ID: 3, TelegramID: 77, Language: "en"}}
+	sender := &stubSender{}
+	username := domaincustomer.SubscriptionUsername(77, 5)
+	pending := domaincustomer.SubscriptionUsername(77, 6)
+	subs := &stubSubscriptions{
+		byUUID:     map[uuid.UUID]*pg.Subscription{extraUUID: {ID: 5, CustomerID: 3, PanelUUID: &extraUUID, PanelUsername: &username}},
+		byUsername: map[string]*pg.Subscription{pending: {ID: 6, CustomerID: 3, PanelUsername: &pending}},
+		set:        make(map[int64]time.Time),
+	}
+	svc := panelevent.NewService(customers, subs, &stubBlocks{}, sender, tm)
+	return remnawave.WebhookHandler(secret, svc.Handle), customers, sender, subs
+}
+
+func post(h http.Handler, body []byte, signature string) int {
+	req := httptest.NewRequest(http.MethodPost, "/remnawave/webhook", bytes.NewReader(body))
+	if signature != "" {
+		req.Header.Set(remnawave.SignatureHeader, signature)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+// stamped adds the current send time to the webhook body.
+func stamped(body string) []byte {
+	return []byte(`{"timestamp":"` + time.Now().UTC().Format(time.RFC3339) + `",` + body[1:])
+}
+
+func TestWebhookRejectsBadSignature(t *testing.T) {
+	h, customers, _ := newHandler(t)
+	body := stamped(`{"event":"user.expired","data":{"telegramId":77,"expireAt":"2030-01-01T00:00:00.000Z"}}`)
+
+	if code := post(h, body, ""); code != http.StatusUnauthorized {
+		t.Errorf("unsigned webhook: status %d", code)
+	}
+	if code := post(h, body, sign([]byte("other body"))); code != http.StatusUnauthorized {
+		t.Errorf("badly signed webhook: status %d", code)
+	}
+	if len(customers.Updates) != 0 {
+		t.Errorf("customer updated by a rejected webhook")
+	}
+}
+
+func TestWebhookExpiredUpdatesAndNotifies(t *testing.T) {
+	h, customers, sender := newHandler(t)
+	body := stamped(`{"event":"user.expired","data":{"uuid":"u","telegramId":77,"status":"EXPIRED",` +
+		`"expireAt":"2030-01-01T00:00:00.000Z","subscriptionUrl":"https://sub/77"}}`)
+
+	if code := post(h, body, sign(body)); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if len(customers.Updates) != 1 {
+		t.Fatalf("updates %v", customers.Updates)
+	}
+	update := customers.Updates[0]
+	if at, ok := update["expire_at"].(time.Time); !ok || !at.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expire_at %v", update["expire_at"])
+	}
+	if update["subscription_link"] != "https://sub/77" {
+		t.Errorf("subscription_link %v", update["subscription_link"])
+	}
+	if len(sender.sent) != 1 || sender.sent[0].ChatID != int64(77) || sender.sent[0].ReplyMarkup == nil {
+		t.Fatalf("expiry notice not sent: %+v", sender.sent)
+	}
+}
+
+func TestWebhookModifiedOnlyUpdates(t *testing.T) {
+	h, customers, sender := newHandler(t)
+	body := stamped(`{"event":"user.modified","data":{"telegramId":77,"expireAt":"2031-05-01T00:00:00.000Z"}}`)
+
+	if code := post(h, body, sign(body)); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if len(customers.Updates) != 1 {
+		t.Fatalf("updates %v", customers.Updates)
+	}
+	if _, ok := customers.Updates[0]["subscription_link"]; ok {
+		t.Errorf("empty subscription link overwrote the stored one")
+	}
+	if len(sender.sent) != 0 {
+		t.Errorf("unexpected notice: %+v", sender.sent)
+	}
+}
+
+func TestWebhookIgnoresOtherEvents(t *testing.T) {
+	h, customers, sender := newHandler(t)
+	body := stamped(`{"event":"node.connection_lost","data":{"uuid":"n"}}`)
+
+	if code := post(h, body, sign(body)); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if len(customers.Updates) != 0 || len(sender.sent) != 0 {
+		t.Errorf("node event applied: updates %v sent %v", customers.Updates, sender.sent)
+	}
+}
+
+func TestWebhookUpdatesExtraSubscription(t *testing.T) {
+	h, customers, sender, subs := newHandlerWithSubscriptions(t)
+	body := stamped(`{"event":"user.expired","data":{"uuid":"` + extraUUID.String() + `","telegramId":77,` +
+		`"expireAt":"2030-01-01T00:00:00.000Z","subscriptionUrl":"https://sub/77_sub5"}}`)
+
+	if code := post(h, body, sign(body)); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if at, ok := subs.set[5]; !ok || !at.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("subscription not updated: %v", subs.set)
+	}
+	if len(customers.Updates) != 0 {
+		t.Errorf("extra subscription copied to the customer: %v", customers.Updates)
+	}
+	if len(sender.sent) != 1 {
+		t.Errorf("expiry notice not sent: %+v", sender.sent)
+	}
+}
+
+func TestWebhookExtraSubscriptionBeforeProvisioningRecordedIt(t *testing.T) {
+	h, customers, _, subs := newHandlerWithSubscriptions(t)
+	body := stamped(`{"event":"user.created","data":{"uuid":"` + uuid.NewString() + `","username":"77_sub6","telegramId":77,` +
+		`"expireAt":"2030-01-01T00:00:00.000Z","subscriptionUrl":"https://sub/77_sub6"}}`)
+
+	if code := post(h, body, sign(body)); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if _, ok := subs.set[6]; !ok {
+		t.Errorf("subscription not found by username: %v", subs.set)
+	}
+	if len(customers.Updates) != 0 {
+		t.Errorf("extra subscription copied to the customer: %v", customers.Updates)
+	}
+
+	unknown := stamped(`{"event":"user.modified","data":{"uuid":"` + uuid.NewString() + `","username":"77_sub9","telegramId":77,` +
+		`"expireAt":"2030-01-01T00:00:00.000Z"}}`)
+	if code := post(h, unknown, sign(unknown)); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if len(customers.Updates) != 0 {
+		t.Errorf("unknown extra subscription copied to the customer: %v", customers.Updates)
+	}
+}
+
+func TestWebhookIgnoresExpiryBeforeRenewal(t *testing.T) {
+	h, customers, sender := newHandler(t)
+	renewed := time.Date(2030, 2, 1, 0, 0, 0, 0, time.UTC)
+	customers.CustomerByTelegramID.ExpireAt = &renewed
+	body := stamped(`{"event":"user.expired","data":{"uuid":"u","telegramId":77,"expireAt":"2030-01-01T00:00:00.000Z"}}`)
+
+	if code := post(h, body, sign(body)); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if len(customers.Updates) != 0 || len(sender.sent) != 0 {
+		t.Errorf("stale event applied: updates %v sent %v", customers.Updates, sender.sent)
+	}
+}
+
+func TestWebhookRejectsStaleTimestamp(t *testing.T) {
+	h, customers, _ := newHandler(t)
+	old := time.Now().Add(-time.Hour)
+
+	body := []byte(`{"event":"user.modified","timestamp":"` + old.Format(time.RFC3339) + `","data":{"telegramId":77,"expireAt":"2031-05-01T00:00:00.000Z"}}`)
+	if code := post(h, body, sign(body)); code != http.StatusUnauthorized {
+		t.Errorf("replayed body: status %d", code)
+	}
+
+	body = []byte(`{"event":"user.modified","data":{"telegramId":77,"expireAt":"2031-05-01T00:00:00.000Z"}}`)
+	if code := post(h, body, sign(body)); code != http.StatusUnauthorized {
+		t.Errorf("webhook without timestamp: status %d", code)
+	}
+	if len(customers.Updates) != 0 {
+		t.Errorf("customer updated by a replayed webhook")
+	}
+
+	body = stamped(`{"event":"user.modified","data":{"telegramId":77,"expireAt":"2031-05-01T00:00:00.000Z"}}`)
+	if code := post(h, body, sign(body)); code != http.StatusOK {
+		t.Errorf("fresh webhook: status %d", code)
+	}
+}
+
+func TestDisabledNoticeSkippedForBlockedCustomer(t *testing.T) {
+	tm := translation.GetInstance()
+	if err := tm.InitDefaultTranslations(); err != nil {
+		t.Fatal(err)
+	}
+	customers := &testutils.StubCustomerRepo{CustomerByTelegramID: &domaincustomer.Customer{ID: 3, TelegramID: 77, Language: "en"}}
+	sender := &stubSender{}
+	blocks := &stubBlocks{blocks: map[int64]*pg.CustomerBlock{77: {TelegramID: 77, Panel: true}}}
+	subs := &stubSubscriptions{set: make(map[int64]time.Time)}
+	h := remnawave.WebhookHandler(secret, panelevent.NewService(customers, subs, blocks, sender, tm).Handle)
+	body := stamped(`{"event":"user.disabled","data":{"uuid":"u","telegramId":77,"expireAt":"2030-01-01T00:00:00.000Z"}}`)
+
+	if code := post(h, body, sign(body)); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if len(sender.sent) != 0 {
+		t.Errorf("banned customer told about the disabled subscription: %+v", sender.sent)
+	}
+
+	delete(blocks.blocks, 77)
+	if code := post(h, body, sign(body)); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if len(sender.sent) != 1 {
+		t.Errorf("disabled notice not sent: %+v", sender.sent)
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
 	domainpurchase "remnawave-tg-shop-bot/internal/domain/purchase"
+	"remnawave-tg-shop-bot/internal/repository/pg"
 	"remnawave-tg-shop-bot/internal/service/payment"
 )
 
@@ -34,12 +35,14 @@ func (stubRepo) FindById(ctx context.Context, id int64) (*domainpurchase.Purchas
 func (stubRepo) UpdateFields(ctx context.Context, id int64, m map[string]interface{}) error {
 	return nil
 }
-func (stubRepo) MarkAsPaid(ctx context.Context, id int64) error { return nil }
+func (stubRepo) Pay(ctx context.Context, id int64, notice *pg.OutboxMessage) (bool, error) {
+	return true, nil
+}
 
 func TestEnabledProviders(t *testing.T) {
 	p1 := &stubProvider{typ: domainpurchase.InvoiceTypeCrypto, enabled: true}
 	p2 := &stubProvider{typ: domainpurchase.InvoiceTypeTribute, enabled: false}
-	svc := payment.NewPaymentService(nil, nil, nil, nil, nil, []payment.Provider{p1, p2}, nil, nil, nil, nil)
+	svc := payment.NewPaymentService(nil, nil, nil, nil, nil, []payment.Provider{p1, p2}, nil, nil, nil, nil, nil, nil)
 	res := svc.EnabledProviders()
 	if len(res) != 1 || res[0] != p1 {
 		t.Fatalf("expected only enabled provider")
@@ -47,7 +50,7 @@ func TestEnabledProviders(t *testing.T) {
 }
 
 func TestCreatePurchaseUnknownType(t *testing.T) {
-	svc := payment.NewPaymentService(nil, stubRepo{}, nil, nil, nil, nil, nil, nil, nil, nil)
+	svc := payment.NewPaymentService(nil, stubRepo{}, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	c := &domaincustomer.Customer{ID: 1}
 	if _, _, err := svc.CreatePurchase(context.Background(), 10, 1, c, domainpurchase.InvoiceTypeCrypto); err == nil {
 		t.Fatal("expected error")
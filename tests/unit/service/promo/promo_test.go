@@ -0,0 +1,171 @@
+package promo_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/internal/service/promo"
+)
+
+type stubBatchRepo struct {
+	created *pg.PromocodeBatch
+	codes   []string
+	stored  []pg.PromocodeBatchCode
+	err     error
+}
+
+func (s *stubBatchRepo) Create(ctx context.Context, b *pg.PromocodeBatch, codes []string) (*pg.PromocodeBatch, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	b.ID = 7
+	b.Size = len(codes)
+	s.created = b
+	s.codes = codes
+	return b, nil
+}
+func (s *stubBatchRepo) FindById(ctx context.Context, id int64) (*pg.PromocodeBatch, error) {
+	if s.created == nil || s.created.ID != id {
+		return nil, nil
+	}
+	return s.created, nil
+}
+func (s *stubBatchRepo) FindRecent(ctx context.Context, limit int) ([]pg.PromocodeBatch, error) {
+	return nil, nil
+}
+func (s *stubBatchRepo) FindCodes(ctx context.Context, id int64) ([]pg.PromocodeBatchCode, error) {
+	return s.stored, nil
+}
+func (s *stubBatchRepo) Stats(ctx context.Context, id int64) (*pg.PromocodeBatchStats, error) {
+	return &pg.PromocodeBatchStats{}, nil
+}
+
+func TestGenerateBatchUniqueCodesWithPrefix(t *testing.T) {
+	repo := &stubBatchRepo{}
+	svc := promo.NewService(repo)
+	batch, codes, err := svc.GenerateBatch(context.Background(), promo.BatchRequest{
+		Tag: "partner", Prefix: "BLOG", Count: 50, Months: 3, Uses: 2, CreatedBy: 1,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if batch.ID != 7 || len(codes) != 50 || len(repo.codes) != 50 {
+		t.Fatalf("unexpected batch %+v with %d codes", batch, len(codes))
+	}
+	if batch.Prefix == nil || *batch.Prefix != "BLOG" || batch.UsesPerCode != 2 || batch.Months != 3 {
+		t.Fatalf("batch fields not set: %+v", batch)
+	}
+	seen := map[string]bool{}
+	for _, c := range codes {
+		if !strings.HasPrefix(c, "BLOG-") {
+			t.Fatalf("code %q missing prefix", c)
+		}
+		if groups := strings.Split(strings.TrimPrefix(c, "BLOG-"), "-"); len(groups) != 4 {
+			t.Fatalf("code %q has %d random groups, want 4", c, len(groups))
+		}
+		if seen[c] {
+			t.Fatalf("duplicate code %q", c)
+		}
+		seen[c] = true
+	}
+}
+
+func TestGenerateBatchVanityCode(t *testing.T) {
+	repo := &stubBatchRepo{}
+	svc := promo.NewService(repo)
+	_, codes, err := svc.GenerateBatch(context.Background(), promo.BatchRequest{
+		Tag: "stream", Code: "HEDGEHOG2025", Months: 1, Uses: 100,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(codes) != 1 || codes[0] != "HEDGEHOG2025" {
+		t.Fatalf("unexpected codes %v", codes)
+	}
+}
+
+func TestGenerateBatchValidation(t *testing.T) {
+	cases := []struct {
+		name string
+		req  promo.BatchRequest
+		err  error
+	}{
+		{"no tag", promo.BatchRequest{Count: 1, Months: 1, Uses: 1}, promo.ErrInvalidTag},
+		{"too many", promo.BatchRequest{Tag: "t", Count: promo.MaxBatchSize + 1, Months: 1, Uses: 1}, promo.ErrInvalidCount},
+		{"bad months", promo.BatchRequest{Tag: "t", Count: 1, Months: 2, Uses: 1}, promo.ErrInvalidMonths},
+		{"no uses", promo.BatchRequest{Tag: "t", Count: 1, Months: 1}, promo.ErrInvalidUses},
+		{"vanity count", promo.BatchRequest{Tag: "t", Code: "X", Count: 2, Months: 1, Uses: 1}, promo.ErrVanityCount},
+		{"bad prefix", promo.BatchRequest{Tag: "t", Prefix: "a b", Count: 1, Months: 1, Uses: 1}, promo.ErrInvalidPrefix},
+	}
+	for _, tc := range cases {
+		repo := &stubBatchRepo{}
+		_, _, err := promo.NewService(repo).GenerateBatch(context.Background(), tc.req)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.err, err)
+		}
+		if repo.created != nil {
+			t.Errorf("%s: batch must not be stored", tc.name)
+		}
+	}
+}
+
+func TestGenerateBatchRepoError(t *testing.T) {
+	repo := &stubBatchRepo{err: pg.ErrPromocodeExists}
+	_, _, err := promo.NewService(repo).GenerateBatch(context.Background(), promo.BatchRequest{
+		Tag: "t", Code: "TAKEN", Months: 1, Uses: 1,
+	})
+	if !errors.Is(err, pg.ErrPromocodeExists) {
+		t.Fatalf("expected ErrPromocodeExists, got %v", err)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	repo := &stubBatchRepo{
+		created: &pg.PromocodeBatch{ID: 3, Tag: "partner"},
+		stored: []pg.PromocodeBatchCode{
+			{Code: "A-1", Months: 1, UsesLeft: 0, Redeemed: 1, Active: true},
+			{Code: "A-2", Months: 1, UsesLeft: 1, Redeemed: 0, Active: false},
+		},
+	}
+	data, err := promo.NewService(repo).ExportCSV(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "code,tag,months,uses_left,redeemed,active\nA-1,partner,1,0,1,true\nA-2,partner,1,1,0,false\n"
+	if string(data) != want {
+		t.Fatalf("unexpected csv:\n%s", data)
+	}
+
+	if _, err := promo.NewService(repo).ExportCSV(context.Background(), 99); !errors.Is(err, promo.ErrBatchNotFound) {
+		t.Fatalf("expected ErrBatchNotFound, got %v", err)
+	}
+}
+
+func TestParseBatchArgs(t *testing.T) {
+	req, err := promo.ParseBatchArgs([]string{"count=10", "months=3", "tag=blog", "prefix=BLOG"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Count != 10 || req.Months != 3 || req.Tag != "blog" || req.Prefix != "BLOG" || req.Uses != 1 {
+		t.Fatalf("unexpected request %+v", req)
+	}
+	if _, err := promo.ParseBatchArgs([]string{"count=x"}); err == nil {
+		t.Fatal("expected error for non-numeric count")
+	}
+	if _, err := promo.ParseBatchArgs([]string{"foo=1"}); err == nil {
+		t.Fatal("expected error for unknown argument")
+	}
+}
+
+func TestFormatRevenue(t *testing.T) {
+	if got := promo.FormatRevenue(nil); got != "0" {
+		t.Fatalf("unexpected empty revenue %q", got)
+	}
+	got := promo.FormatRevenue(map[string]float64{"RUB": 1500, "STARS": 20})
+	if got != "1500 RUB, 20 STARS" {
+		t.Fatalf("unexpected revenue %q", got)
+	}
+}
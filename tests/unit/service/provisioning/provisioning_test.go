@@ -0,0 +1,319 @@
+package provisioning_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	remapi "github.com/Jolymmiles/remnawave-api-go/api"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/google/uuid"
+
+	"remnawave-tg-shop-bot/internal/adapter/remnawave"
+	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/internal/service/provisioning"
+	"remnawave-tg-shop-bot/tests/testutils"
+)
+
+type stubJobs struct {
+	due      []pg.ProvisioningJob
+	target   *time.Time
+	done     bool
+	retryAt  time.Time
+	buried   string
+	enqueued *pg.ProvisioningJob
+}
+
+func (s *stubJobs) EnqueueFromBalance(ctx context.Context, job *pg.ProvisioningJob, price float64) (float64, error) {
+	if price > 100 {
+		return 0, pg.ErrInsufficientBalance
+	}
+	job.ID = 1
+	s.enqueued = job
+	return 100 - price, nil
+}
+func (s *stubJobs) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]pg.ProvisioningJob, error) {
+	due := s.due
+	s.due = nil
+	for i := range due {
+		due[i].Attempts++
+	}
+	return due, nil
+}
+func (s *stubJobs) SetTarget(ctx context.Context, id int64, expireAt time.Time) error {
+	s.target = &expireAt
+	return nil
+}
+func (s *stubJobs) Complete(ctx context.Context, id int64) error {
+	s.done = true
+	return nil
+}
+func (s *stubJobs) Retry(ctx context.Context, id int64, runAt time.Time, lastError string) error {
+	s.retryAt = runAt
+	return nil
+}
+func (s *stubJobs) Bury(ctx context.Context, id int64, lastError string) error {
+	s.buried = lastError
+	return nil
+}
+
+type stubSubscriptions struct {
+	list []pg.Subscription
+	set  map[int64]time.Time
+}
+
+func (s *stubSubscriptions) ListByCustomer(ctx context.Context, customerID int64) ([]pg.Subscription, error) {
+	return s.list, nil
+}
+func (s *stubSubscriptions) FindByID(ctx context.Context, id int64) (*pg.Subscription, error) {
+	for i := range s.list {
+		if s.list[i].ID == id {
+			return &s.list[i], nil
+		}
+	}
+	return nil, nil
+}
+func (s *stubSubscriptions) SetPanelUser(ctx context.Context, id int64, panelUUID uuid.UUID, expireAt time.Time, link string) error {
+	if s.set == nil {
+		s.set = make(map[int64]time.Time)
+	}
+	s.set[id] = expireAt
+	return nil
+}
+
+type stubPanel struct {
+	current  *remapi.UserDto
+	err      error
+	set      []time.Time
+	refs     []remnawave.SubscriptionRef
+	inbounds []map[uuid.UUID]uuid.UUID
+}
+
+func (p *stubPanel) GetSubscriptionUser(ctx context.Context, ref remnawave.SubscriptionRef) (*remapi.UserDto, error) {
+	return p.current, nil
+}
+func (p *stubPanel) SetSubscriptionExpire(ctx context.Context, ref remnawave.SubscriptionRef, trafficLimit int, expireAt time.Time, inbounds map[uuid.UUID]uuid.UUID) (*remapi.UserDto, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	p.set = append(p.set, expireAt)
+	p.refs = append(p.refs, ref)
+	p.inbounds = append(p.inbounds, inbounds)
+	return &remapi.UserDto{SubscriptionUrl: "https://sub", ExpireAt: expireAt}, nil
+}
+
+type stubMessenger struct {
+	sent    []string
+	edited  []string
+	queued  []string
+	deleted int
+}
+
+func (m *stubMessenger) Send(ctx context.Context, params *bot.SendMessageParams) error {
+	m.queued = append(m.queued, params.Text)
+	return nil
+}
+
+func (m *stubMessenger) SendMessage(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+	m.sent = append(m.sent, params.Text)
+	return &models.Message{ID: 42}, nil
+}
+func (m *stubMessenger) DeleteMessage(ctx context.Context, params *bot.DeleteMessageParams) (bool, error) {
+	m.deleted++
+	return true, nil
+}
+func (m *stubMessenger) EditMessageText(ctx context.Context, params *bot.EditMessageTextParams) (*models.Message, error) {
+	m.edited = append(m.edited, params.Text)
+	return &models.Message{}, nil
+}
+func (m *stubMessenger) CreateInvoiceLink(ctx context.Context, params *bot.CreateInvoiceLinkParams) (string, error) {
+	return "", nil
+}
+
+func newTranslations(t *testing.T) *translation.Manager {
+	t.Helper()
+	tm := translation.GetInstance()
+	if err := tm.InitDefaultTranslations(); err != nil {
+		t.Fatal(err)
+	}
+	return tm
+}
+
+func newService(t *testing.T, jobs *stubJobs, panel *stubPanel, msg *stubMessenger, maxAttempts int) *provisioning.Service {
+	customers := &testutils.StubCustomerRepo{CustomerByID: &domaincustomer.Customer{ID: 1, TelegramID: 10, Language: "en"}}
+	return provisioning.NewService(jobs, &stubSubscriptions{}, panel, customers, msg, msg, newTranslations(t), maxAttempts)
+}
+
+func TestEnqueueFromBalance(t *testing.T) {
+	jobs, msg := &stubJobs{}, &stubMessenger{}
+	svc := newService(t, jobs, &stubPanel{}, msg, 3)
+	customer := &domaincustomer.Customer{ID: 1, TelegramID: 10, Language: "en", Balance: 100}
+
+	if err := svc.EnqueueFromBalance(context.Background(), customer, provisioning.Target{New: true}, 1, 80); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if jobs.enqueued == nil || jobs.enqueued.Days != 30 || jobs.enqueued.Source != pg.ProvisioningSourceBalance {
+		t.Fatalf("job %+v", jobs.enqueued)
+	}
+	if jobs.enqueued.PlanMonths == nil || *jobs.enqueued.PlanMonths != 1 {
+		t.Errorf("plan of the job %v", jobs.enqueued.PlanMonths)
+	}
+	if !jobs.enqueued.NewSubscription || jobs.enqueued.SubscriptionID != nil {
+		t.Errorf("job doesn't add a subscription: %+v", jobs.enqueued)
+	}
+	if jobs.enqueued.MessageID == nil || *jobs.enqueued.MessageID != 42 {
+		t.Errorf("job doesn't reference the activating message")
+	}
+	if customer.Balance != 20 {
+		t.Errorf("balance %v", customer.Balance)
+	}
+
+	err := svc.EnqueueFromBalance(context.Background(), customer, provisioning.Target{}, 1, 500)
+	if !errors.Is(err, pg.ErrInsufficientBalance) {
+		t.Fatalf("expected insufficient balance, got %v", err)
+	}
+	if msg.deleted != 1 {
+		t.Errorf("activating message of the failed payment not deleted")
+	}
+}
+
+func TestProcessDueExtendsOnce(t *testing.T) {
+	current := time.Now().Add(10 * 24 * time.Hour).UTC()
+	jobs := &stubJobs{due: []pg.ProvisioningJob{{ID: 1, CustomerID: 1, TelegramID: 10, Days: 30, Source: pg.ProvisioningSourceBalance, MessageID: new(int)}}}
+	panel := &stubPanel{current: &remapi.UserDto{ExpireAt: current}}
+	msg := &stubMessenger{}
+	svc := newService(t, jobs, panel, msg, 3)
+
+	if err := svc.ProcessDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := current.AddDate(0, 0, 30)
+	if jobs.target == nil || !jobs.target.Equal(want) {
+		t.Fatalf("target %v, want %v", jobs.target, want)
+	}
+	if len(panel.set) != 1 || !panel.set[0].Equal(want) || !jobs.done {
+		t.Fatalf("panel set %v, done %v", panel.set, jobs.done)
+	}
+	if len(msg.edited) != 1 || len(msg.sent) != 0 || len(msg.queued) != 0 {
+		t.Errorf("activating message not replaced: edited %v sent %v queued %v", msg.edited, msg.sent, msg.queued)
+	}
+}
+
+func TestProcessDueRetriesWithFixedTarget(t *testing.T) {
+	target := time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC)
+	jobs := &stubJobs{}
+	panel := &stubPanel{err: errors.New("panel unavailable")}
+	msg := &stubMessenger{}
+	svc := newService(t, jobs, panel, msg, 2)
+
+	jobs.due = []pg.ProvisioningJob{{ID: 1, CustomerID: 1, TelegramID: 10, Days: 30, Source: pg.ProvisioningSourcePromocode, TargetExpireAt: &target}}
+	if err := svc.ProcessDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if jobs.target != nil {
+		t.Errorf("fixed target recomputed")
+	}
+	if time.Until(jobs.retryAt) < 20*time.Second || jobs.buried != "" {
+		t.Fatalf("first failure: retry at %v, buried %q", jobs.retryAt, jobs.buried)
+	}
+
+	jobs.due = []pg.ProvisioningJob{{ID: 1, CustomerID: 1, TelegramID: 10, Days: 30, Source: pg.ProvisioningSourcePromocode, TargetExpireAt: &target, Attempts: 1}}
+	if err := svc.ProcessDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if jobs.buried != "panel unavailable" {
+		t.Fatalf("job not buried after the last attempt: %q", jobs.buried)
+	}
+	if len(msg.queued) != 1 || !strings.Contains(msg.queued[0], "longer than usual") {
+		t.Errorf("customer not told about the delay: %v", msg.queued)
+	}
+
+	panel.err = nil
+	jobs.due = []pg.ProvisioningJob{{ID: 2, CustomerID: 1, TelegramID: 10, Days: 30, Source: pg.ProvisioningSourcePromocode, TargetExpireAt: &target}}
+	if err := svc.ProcessDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(panel.set) != 1 || !panel.set[0].Equal(target) {
+		t.Errorf("panel set %v, want %v", panel.set, target)
+	}
+	if !strings.Contains(msg.queued[len(msg.queued)-1], "01.01.2031") {
+		t.Errorf("promocode result %q", msg.queued[len(msg.queued)-1])
+	}
+}
+
+func TestProcessDueKeepsExtraSubscriptionApart(t *testing.T) {
+	username := domaincustomer.SubscriptionUsername(10, 7)
+	subID := int64(7)
+	subs := &stubSubscriptions{list: []pg.Subscription{{ID: 7, CustomerID: 1, PanelUsername: &username}}}
+	jobs := &stubJobs{due: []pg.ProvisioningJob{{ID: 1, CustomerID: 1, TelegramID: 10, SubscriptionID: &subID, Days: 30, Source: pg.ProvisioningSourceBalance}}}
+	panel := &stubPanel{}
+	customers := &testutils.StubCustomerRepo{CustomerByID: &domaincustomer.Customer{ID: 1, TelegramID: 10, Language: "en"}}
+	svc := provisioning.NewService(jobs, subs, panel, customers, &stubMessenger{}, &stubMessenger{}, newTranslations(t), 3)
+
+	if err := svc.ProcessDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(panel.refs) != 1 || panel.refs[0].Username != username || panel.refs[0].TelegramID != 10 {
+		t.Fatalf("panel refs %+v", panel.refs)
+	}
+	if _, ok := subs.set[7]; !ok || !jobs.done {
+		t.Fatalf("subscription not recorded: %v, done %v", subs.set, jobs.done)
+	}
+	if len(customers.Updates) != 0 {
+		t.Errorf("extra subscription copied to the customer: %v", customers.Updates)
+	}
+}
+
+func TestSubscriptionsListPrimaryFromCustomer(t *testing.T) {
+	link := "https://sub/primary"
+	expireAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	username := domaincustomer.SubscriptionUsername(10, 7)
+	subs := &stubSubscriptions{list: []pg.Subscription{{ID: 7, CustomerID: 1, PanelUsername: &username}}}
+	svc := provisioning.NewService(&stubJobs{}, subs, &stubPanel{}, &testutils.StubCustomerRepo{}, &stubMessenger{}, &stubMessenger{}, newTranslations(t), 3)
+
+	list, err := svc.Subscriptions(context.Background(), &domaincustomer.Customer{ID: 1, TelegramID: 10, ExpireAt: &expireAt, SubscriptionLink: &link})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || !list[0].Primary() || list[0].ID != 0 || *list[0].SubscriptionLink != link || list[1].ID != 7 {
+		t.Fatalf("subscriptions %+v", list)
+	}
+}
+
+func TestProcessDueSetsInboundsOfPlanOnly(t *testing.T) {
+	months := 3
+	jobs := &stubJobs{due: []pg.ProvisioningJob{
+		{ID: 1, CustomerID: 1, TelegramID: 10, Days: 90, PlanMonths: &months, Source: pg.ProvisioningSourceBalance},
+	}}
+	panel := &stubPanel{}
+	svc := newService(t, jobs, panel, &stubMessenger{}, 3)
+	if err := svc.ProcessDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs.due = []pg.ProvisioningJob{{ID: 2, CustomerID: 1, TelegramID: 10, Days: 90, Source: pg.ProvisioningSourcePromocode}}
+	if err := svc.ProcessDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(panel.inbounds) != 2 || panel.inbounds[0] == nil || panel.inbounds[1] != nil {
+		t.Fatalf("inbounds %v, want the plan's then none", panel.inbounds)
+	}
+}
+
+func TestProcessDueAnnouncesReferralDays(t *testing.T) {
+	jobs := &stubJobs{due: []pg.ProvisioningJob{{ID: 1, CustomerID: 1, TelegramID: 10, Days: 7, Source: pg.ProvisioningSourceReferral}}}
+	msg := &stubMessenger{}
+	svc := newService(t, jobs, &stubPanel{}, msg, 3)
+
+	if err := svc.ProcessDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !jobs.done || len(msg.queued) != 1 || !strings.Contains(msg.queued[0], "+7 days") {
+		t.Fatalf("referral result: done %v, queued %v", jobs.done, msg.queued)
+	}
+}
@@ -0,0 +1,175 @@
+package referral_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
+	domainpurchase "remnawave-tg-shop-bot/internal/domain/purchase"
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/internal/service/referral"
+	"remnawave-tg-shop-bot/tests/testutils"
+)
+
+func TestRegisterRejectsFraud(t *testing.T) {
+	cases := []struct {
+		name        string
+		referrer    int64
+		referee     int64
+		newCustomer bool
+		reason      string
+	}{
+		{"self", 40, 40, true, referral.ReasonSelfReferral},
+		{"existing customer", 20, 40, false, referral.ReasonRefereeExists},
+		{"already referred", 10, 30, true, referral.ReasonRefereeExists},
+		// 10 invited 20 who invited 30, so 30 can't invite 10
+		{"circular", 30, 10, true, referral.ReasonCircularReferral},
+		{"ok", 30, 40, true, referral.ReasonOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			refs := chain()
+			decisions := &stubDecisions{}
+			svc := referral.NewService(referral.Rules{}, refs, &stubRewards{}, decisions, nil, stubPurchases{}, &testutils.StubCustomerRepo{}, nil, &stubSender{}, newTranslations(t))
+
+			created, err := svc.Register(context.Background(), tc.referrer, tc.referee, tc.newCustomer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if created != (tc.reason == referral.ReasonOK) {
+				t.Fatalf("created = %v for reason %s", created, tc.reason)
+			}
+			if got := decisions.reasons(); !reflect.DeepEqual(got, []string{tc.reason}) {
+				t.Fatalf("logged reasons %v, want %s", got, tc.reason)
+			}
+		})
+	}
+}
+
+func TestOnPaymentBelowMinimum(t *testing.T) {
+	rewards := &stubRewards{}
+	decisions := &stubDecisions{}
+	rules := referral.Rules{Mode: referral.ModeFixed, Bonus: 150, Payments: 1, MinPayment: 300}
+	svc := referral.NewService(rules, chain(), rewards, decisions, nil, stubPurchases{count: 1}, &testutils.StubCustomerRepo{}, nil, &stubSender{}, newTranslations(t))
+
+	if err := svc.OnPayment(context.Background(), &domaincustomer.Customer{TelegramID: 30}, &domainpurchase.Purchase{ID: 1, Amount: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rewards.stored) != 0 {
+		t.Fatalf("expected no rewards, got %d", len(rewards.stored))
+	}
+	if got := decisions.logged; len(got) != 1 || got[0].Decision != referral.DecisionRejected || got[0].Reason != referral.ReasonPaymentBelowMinimum {
+		t.Fatalf("unexpected decisions %+v", got)
+	}
+}
+
+func TestOnPaymentDefersRewardsOfNewReferee(t *testing.T) {
+	rewards := &stubRewards{}
+	decisions := &stubDecisions{}
+	rules := referral.Rules{Mode: referral.ModeFixed, Bonus: 150, Payments: 1, MinRefereeAge: 72 * time.Hour}
+	svc := referral.NewService(rules, chain(), rewards, decisions, nil, stubPurchases{count: 1}, &testutils.StubCustomerRepo{}, nil, &stubSender{}, newTranslations(t))
+
+	createdAt := time.Now().Add(-time.Hour)
+	if err := svc.OnPayment(context.Background(), &domaincustomer.Customer{TelegramID: 30, CreatedAt: createdAt}, &domainpurchase.Purchase{ID: 2, Amount: 299}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rewards.stored) != 1 || rewards.stored[0].Status != pg.ReferralRewardStatusHeld {
+		t.Fatalf("expected a held reward, got %+v", rewards.stored)
+	}
+	if !rewards.stored[0].AvailableAt.Equal(createdAt.Add(72 * time.Hour)) {
+		t.Fatalf("reward available at %s", rewards.stored[0].AvailableAt)
+	}
+	if got := decisions.reasons(); !reflect.DeepEqual(got, []string{referral.ReasonRefereeTooNew}) {
+		t.Fatalf("unexpected reasons %v", got)
+	}
+}
+
+func TestDailyCapSendsRewardsToReview(t *testing.T) {
+	refs := &stubReferrals{byReferee: map[int64]*pg.Referral{
+		31: {ID: 1, ReferrerID: 20, RefereeID: 31},
+		32: {ID: 2, ReferrerID: 20, RefereeID: 32},
+		33: {ID: 3, ReferrerID: 20, RefereeID: 33},
+	}}
+	rewards := &stubRewards{}
+	decisions := &stubDecisions{}
+	rules := referral.Rules{Mode: referral.ModeFixed, Bonus: 100, Payments: 1, DailyCap: 1}
+	svc := referral.NewService(rules, refs, rewards, decisions, nil, stubPurchases{count: 1}, &testutils.StubCustomerRepo{}, nil, &stubSender{}, newTranslations(t))
+
+	for i, referee := range []int64{31, 32, 33} {
+		purchase := &domainpurchase.Purchase{ID: int64(i + 1), Amount: 299}
+		if err := svc.OnPayment(context.Background(), &domaincustomer.Customer{TelegramID: referee}, purchase); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{pg.ReferralRewardStatusReleased, pg.ReferralRewardStatusReview, pg.ReferralRewardStatusReview}
+	for i, r := range rewards.stored {
+		if r.Status != want[i] {
+			t.Fatalf("reward %d: status %s, want %s", i, r.Status, want[i])
+		}
+	}
+
+	queue, err := svc.ReviewQueue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue) != 1 || queue[0].BeneficiaryID != 20 || queue[0].Rewards != 2 {
+		t.Fatalf("unexpected review queue %+v", queue)
+	}
+
+	rewards.stored[2].Status = pg.ReferralRewardStatusReview
+	approved, err := svc.ApproveReview(context.Background(), 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approved != 2 || len(rewards.released) != 3 {
+		t.Fatalf("approved %d, released %v", approved, rewards.released)
+	}
+	if n, _ := svc.RejectReview(context.Background(), 20); n != 0 {
+		t.Fatalf("nothing left to reject, got %d", n)
+	}
+	got := decisions.reasons()
+	if got[len(got)-1] != referral.ReasonAdminApproved || got[1] != referral.ReasonDailyCapExceeded {
+		t.Fatalf("unexpected reasons %v", got)
+	}
+}
+
+func TestApproveReviewKeepsHoldPeriod(t *testing.T) {
+	availableAt := time.Now().Add(7 * 24 * time.Hour)
+	rewards := &stubRewards{stored: []*pg.ReferralReward{
+		{ID: 1, BeneficiaryID: 20, RefereeID: 30, PurchaseID: new(int64), Level: referral.LevelFirst, Kind: "money", Amount: 100,
+			Status: pg.ReferralRewardStatusReview, AvailableAt: availableAt},
+	}}
+	svc := referral.NewService(referral.Rules{}, chain(), rewards, &stubDecisions{}, nil, stubPurchases{}, &testutils.StubCustomerRepo{}, nil, &stubSender{}, newTranslations(t))
+
+	approved, err := svc.ApproveReview(context.Background(), 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approved != 1 || rewards.stored[0].Status != pg.ReferralRewardStatusHeld || len(rewards.released) != 0 {
+		t.Fatalf("approved reward released before its hold period: %+v", rewards.stored[0])
+	}
+	if !rewards.stored[0].AvailableAt.Equal(availableAt) {
+		t.Fatalf("hold period changed to %v", rewards.stored[0].AvailableAt)
+	}
+}
+
+func TestRejectReview(t *testing.T) {
+	rewards := &stubRewards{stored: []*pg.ReferralReward{
+		{ID: 1, BeneficiaryID: 20, RefereeID: 30, PurchaseID: new(int64), Level: referral.LevelFirst, Kind: "money", Amount: 100, Status: pg.ReferralRewardStatusReview},
+	}}
+	decisions := &stubDecisions{}
+	svc := referral.NewService(referral.Rules{}, chain(), rewards, decisions, nil, stubPurchases{}, &testutils.StubCustomerRepo{}, nil, &stubSender{}, newTranslations(t))
+
+	rejected, err := svc.RejectReview(context.Background(), 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rejected != 1 || rewards.stored[0].Status != pg.ReferralRewardStatusRejected || len(rewards.released) != 0 {
+		t.Fatalf("reward not rejected: %+v", rewards.stored[0])
+	}
+	if got := decisions.reasons(); !reflect.DeepEqual(got, []string{referral.ReasonAdminRejected}) {
+		t.Fatalf("unexpected reasons %v", got)
+	}
+}
@@ -0,0 +1,353 @@
+package referral_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+
+	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
+	domainpurchase "remnawave-tg-shop-bot/internal/domain/purchase"
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/internal/service/referral"
+	"remnawave-tg-shop-bot/tests/testutils"
+)
+
+type stubReferrals struct {
+	byReferee map[int64]*pg.Referral
+	granted   []int64
+}
+
+func (s *stubReferrals) Create(ctx context.Context, referrerID, refereeID int64) (*pg.Referral, error) {
+	if s.byReferee == nil {
+		s.byReferee = map[int64]*pg.Referral{}
+	}
+	r := &pg.Referral{ID: int64(len(s.byReferee) + 1), ReferrerID: referrerID, RefereeID: refereeID}
+	s.byReferee[refereeID] = r
+	return r, nil
+}
+func (s *stubReferrals) FindByReferee(ctx context.Context, refereeID int64) (*pg.Referral, error) {
+	return s.byReferee[refereeID], nil
+}
+func (s *stubReferrals) FindByReferrer(ctx context.Context, referrerID int64) ([]pg.Referral, error) {
+	var list []pg.Referral
+	for _, r := range s.byReferee {
+		if r.ReferrerID == referrerID {
+			list = append(list, *r)
+		}
+	}
+	return list, nil
+}
+func (s *stubReferrals) MarkBonusGranted(ctx context.Context, referralID int64) error {
+	s.granted = append(s.granted, referralID)
+	return nil
+}
+
+type stubRewards struct {
+	stored   []*pg.ReferralReward
+	released []int64
+}
+
+func (s *stubRewards) Create(ctx context.Context, r *pg.ReferralReward) (bool, error) {
+	for _, e := range s.stored {
+		if *e.PurchaseID == *r.PurchaseID && e.Level == r.Level && e.Kind == r.Kind {
+			return false, nil
+		}
+	}
+	r.ID = int64(len(s.stored) + 1)
+	if r.Status == "" {
+		r.Status = pg.ReferralRewardStatusHeld
+	}
+	s.stored = append(s.stored, r)
+	return true, nil
+}
+func (s *stubRewards) FindDue(ctx context.Context, now time.Time, limit int) ([]pg.ReferralReward, error) {
+	var list []pg.ReferralReward
+	for _, r := range s.stored {
+		if r.Status == pg.ReferralRewardStatusHeld && !r.AvailableAt.After(now) {
+			list = append(list, *r)
+		}
+	}
+	return list, nil
+}
+func (s *stubRewards) ReleaseMoney(ctx context.Context, id int64) (bool, error) {
+	return s.SetStatus(ctx, id, pg.ReferralRewardStatusHeld, pg.ReferralRewardStatusReleased)
+}
+func (s *stubRewards) ReleaseDays(ctx context.Context, id int64, job *pg.ProvisioningJob) (bool, error) {
+	return s.SetStatus(ctx, id, pg.ReferralRewardStatusHeld, pg.ReferralRewardStatusReleased)
+}
+func (s *stubRewards) SetStatus(ctx context.Context, id int64, from, to string) (bool, error) {
+	for _, r := range s.stored {
+		if r.ID == id && r.Status == from {
+			r.Status = to
+			if to == pg.ReferralRewardStatusReleased {
+				s.released = append(s.released, id)
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+func (s *stubRewards) Summary(ctx context.Context, beneficiaryID int64) (*pg.ReferralRewardSummary, error) {
+	var sum pg.ReferralRewardSummary
+	for _, r := range s.stored {
+		if r.BeneficiaryID != beneficiaryID {
+			continue
+		}
+		switch {
+		case r.Kind == "money" && r.Status == pg.ReferralRewardStatusReleased:
+			sum.ReleasedMoney += r.Amount
+		case r.Kind == "money":
+			sum.HeldMoney += r.Amount
+		case r.Status == pg.ReferralRewardStatusReleased:
+			sum.ReleasedDays += r.Days
+		default:
+			sum.HeldDays += r.Days
+		}
+	}
+	return &sum, nil
+}
+
+func (s *stubRewards) CountSince(ctx context.Context, beneficiaryID int64, level int, since time.Time) (int, error) {
+	count := 0
+	for _, r := range s.stored {
+		if r.BeneficiaryID == beneficiaryID && r.Level == level && r.Status != pg.ReferralRewardStatusRejected {
+			count++
+		}
+	}
+	return count, nil
+}
+func (s *stubRewards) FindReviewQueue(ctx context.Context, limit int) ([]pg.ReferralReviewItem, error) {
+	byID := map[int64]*pg.ReferralReviewItem{}
+	var list []pg.ReferralReviewItem
+	for _, r := range s.stored {
+		if r.Status != pg.ReferralRewardStatusReview {
+			continue
+		}
+		if byID[r.BeneficiaryID] == nil {
+			list = append(list, pg.ReferralReviewItem{BeneficiaryID: r.BeneficiaryID})
+			byID[r.BeneficiaryID] = &list[len(list)-1]
+		}
+		byID[r.BeneficiaryID].Rewards++
+	}
+	return list, nil
+}
+func (s *stubRewards) ResolveReview(ctx context.Context, beneficiaryID int64, to string) ([]pg.ReferralReward, error) {
+	var list []pg.ReferralReward
+	for _, r := range s.stored {
+		if r.BeneficiaryID == beneficiaryID && r.Status == pg.ReferralRewardStatusReview {
+			r.Status = to
+			list = append(list, *r)
+		}
+	}
+	return list, nil
+}
+
+type stubDecisions struct{ logged []pg.ReferralDecision }
+
+func (s *stubDecisions) Create(ctx context.Context, d *pg.ReferralDecision) error {
+	s.logged = append(s.logged, *d)
+	return nil
+}
+
+func (s *stubDecisions) reasons() []string {
+	var list []string
+	for _, d := range s.logged {
+		list = append(list, d.Reason)
+	}
+	return list
+}
+
+type stubPurchases struct {
+	count     int
+	pending   []domainpurchase.Purchase
+	completed *[]int64
+}
+
+func (s stubPurchases) CountPaidByCustomer(ctx context.Context, customerID, untilID int64) (int, error) {
+	return s.count, nil
+}
+func (s stubPurchases) FindReferralPending(ctx context.Context, before time.Time, limit int) ([]domainpurchase.Purchase, error) {
+	return s.pending, nil
+}
+func (s stubPurchases) CompleteReferral(ctx context.Context, purchaseID int64) error {
+	if s.completed != nil {
+		*s.completed = append(*s.completed, purchaseID)
+	}
+	return nil
+}
+
+type stubProvisioner struct {
+	jobs []*pg.ProvisioningJob
+	err  error
+}
+
+func (s *stubProvisioner) Enqueue(ctx context.Context, customer *domaincustomer.Customer, days int, source string, store func(ctx context.Context, job *pg.ProvisioningJob) error) error {
+	if s.err != nil {
+		return s.err
+	}
+	job := &pg.ProvisioningJob{CustomerID: customer.ID, TelegramID: customer.TelegramID, Days: days, Source: source}
+	if err := store(ctx, job); err != nil {
+		return err
+	}
+	s.jobs = append(s.jobs, job)
+	return nil
+}
+
+type stubSender struct{ sent []string }
+
+func (s *stubSender) Send(ctx context.Context, params *bot.SendMessageParams) error {
+	s.sent = append(s.sent, params.Text)
+	return nil
+}
+
+func newTranslations(t *testing.T) *translation.Manager {
+	t.Helper()
+	tm := translation.GetInstance()
+	if err := tm.InitDefaultTranslations(); err != nil {
+		t.Fatal(err)
+	}
+	return tm
+}
+
+// referee 30 was invited by 20, who was invited by 10.
+func chain() *stubReferrals {
+	return &stubReferrals{byReferee: map[int64]*pg.Referral{
+		30: {ID: 2, ReferrerID: 20, RefereeID: 30},
+		20: {ID: 1, ReferrerID: 10, RefereeID: 20},
+	}}
+}
+
+func TestOnPaymentCreditsAllLevels(t *testing.T) {
+	refs := chain()
+	rewards := &stubRewards{}
+	msg := &stubSender{}
+	rules := referral.Rules{Mode: referral.ModePercent, Percent: 10, RefereeBonus: 50, SecondLevelPercent: 50}
+	svc := referral.NewService(rules, refs, rewards, &stubDecisions{}, nil, stubPurchases{count: 1}, &testutils.StubCustomerRepo{}, nil, msg, newTranslations(t))
+
+	customer := &domaincustomer.Customer{ID: 3, TelegramID: 30}
+	purchase := &domainpurchase.Purchase{ID: 100, Amount: 1000}
+	if err := svc.OnPayment(context.Background(), customer, purchase); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[int64]float64{30: 50, 20: 100, 10: 50}
+	if len(rewards.stored) != len(want) {
+		t.Fatalf("expected %d rewards, got %d", len(want), len(rewards.stored))
+	}
+	for _, r := range rewards.stored {
+		if want[r.BeneficiaryID] != r.Amount || r.Status != pg.ReferralRewardStatusReleased {
+			t.Errorf("unexpected reward %+v", r)
+		}
+	}
+	if len(refs.granted) != 1 || refs.granted[0] != 2 {
+		t.Errorf("expected referral 2 marked as granted, got %v", refs.granted)
+	}
+	if len(msg.sent) != 3 {
+		t.Errorf("expected 3 notifications, got %d", len(msg.sent))
+	}
+
+	// processing the same purchase again must not duplicate rewards
+	if err := svc.OnPayment(context.Background(), customer, purchase); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rewards.stored) != 3 || len(rewards.released) != 3 {
+		t.Fatalf("duplicate rewards recorded: %d stored, %d released", len(rewards.stored), len(rewards.released))
+	}
+}
+
+func TestOnPaymentWithoutReferral(t *testing.T) {
+	rewards := &stubRewards{}
+	svc := referral.NewService(referral.Rules{Bonus: 150}, &stubReferrals{}, rewards, &stubDecisions{}, nil, stubPurchases{count: 1}, &testutils.StubCustomerRepo{}, nil, &stubSender{}, newTranslations(t))
+	if err := svc.OnPayment(context.Background(), &domaincustomer.Customer{TelegramID: 1}, &domainpurchase.Purchase{ID: 1, Amount: 100}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rewards.stored) != 0 {
+		t.Fatalf("expected no rewards, got %d", len(rewards.stored))
+	}
+}
+
+func TestHoldAndReleaseDue(t *testing.T) {
+	rewards := &stubRewards{}
+	rules := referral.Rules{Mode: referral.ModeFixed, Bonus: 150, Payments: 1, Hold: 24 * time.Hour}
+	svc := referral.NewService(rules, chain(), rewards, &stubDecisions{}, nil, stubPurchases{count: 1}, &testutils.StubCustomerRepo{}, nil, &stubSender{}, newTranslations(t))
+
+	if err := svc.OnPayment(context.Background(), &domaincustomer.Customer{TelegramID: 30}, &domainpurchase.Purchase{ID: 5, Amount: 299}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rewards.stored) != 1 || rewards.stored[0].Status != pg.ReferralRewardStatusHeld {
+		t.Fatalf("expected a held reward, got %+v", rewards.stored)
+	}
+	stats, err := svc.Stats(context.Background(), 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Invited != 1 || stats.Rewards.HeldMoney != 150 || stats.Rewards.ReleasedMoney != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	if err := svc.ReleaseDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(rewards.released) != 0 {
+		t.Fatal("reward released before hold expired")
+	}
+
+	rewards.stored[0].AvailableAt = time.Now().Add(-time.Minute)
+	if err := svc.ReleaseDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(rewards.released) != 1 {
+		t.Fatal("reward not released after hold expired")
+	}
+}
+
+func TestDaysRewardQueuedOnce(t *testing.T) {
+	rewards := &stubRewards{}
+	prov := &stubProvisioner{err: errors.New("database down")}
+	customers := &testutils.StubCustomerRepo{CustomerByTelegramID: &domaincustomer.Customer{ID: 2, TelegramID: 20}}
+	rules := referral.Rules{Mode: referral.ModeDays, Days: 7, Payments: 1}
+	svc := referral.NewService(rules, chain(), rewards, &stubDecisions{}, nil, stubPurchases{count: 1}, customers, prov, &stubSender{}, newTranslations(t))
+
+	if err := svc.OnPayment(context.Background(), &domaincustomer.Customer{TelegramID: 30}, &domainpurchase.Purchase{ID: 9, Amount: 299}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rewards.stored[0].Status != pg.ReferralRewardStatusHeld {
+		t.Fatalf("reward must stay held when the job can't be queued, got %s", rewards.stored[0].Status)
+	}
+
+	prov.err = nil
+	for range 2 {
+		if err := svc.ReleaseDue(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rewards.stored[0].Status != pg.ReferralRewardStatusReleased || len(prov.jobs) != 1 {
+		t.Fatalf("days reward not queued once: status %s, jobs %v", rewards.stored[0].Status, prov.jobs)
+	}
+	if job := prov.jobs[0]; job.Days != 7 || job.Source != pg.ProvisioningSourceReferral || job.CustomerID != 2 {
+		t.Fatalf("unexpected job %+v", job)
+	}
+}
+
+func TestRetryPaymentsRecordsQueuedRewards(t *testing.T) {
+	rewards := &stubRewards{}
+	var completed []int64
+	purchases := stubPurchases{count: 1, pending: []domainpurchase.Purchase{{ID: 100, CustomerID: 3, Amount: 1000}}, completed: &completed}
+	customers := &testutils.StubCustomerRepo{CustomerByID: &domaincustomer.Customer{ID: 3, TelegramID: 30}}
+	rules := referral.Rules{Mode: referral.ModeFixed, Bonus: 100, Payments: 1}
+	svc := referral.NewService(rules, chain(), rewards, &stubDecisions{}, nil, purchases, customers, nil, &stubSender{}, newTranslations(t))
+
+	if err := svc.RetryPayments(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(rewards.stored) != 1 || rewards.stored[0].BeneficiaryID != 20 {
+		t.Fatalf("rewards of the queued payment not recorded: %+v", rewards.stored)
+	}
+	if len(completed) != 1 || completed[0] != 100 {
+		t.Fatalf("payment not removed from the queue: %v", completed)
+	}
+}
@@ -0,0 +1,77 @@
+package referral_test
+
+import (
+	"reflect"
+	"testing"
+
+	"remnawave-tg-shop-bot/internal/service/referral"
+)
+
+func TestComputeFixedFirstPaymentOnly(t *testing.T) {
+	rules := referral.Rules{Mode: referral.ModeFixed, Bonus: 150, Payments: 1}
+
+	got := rules.Compute(referral.Payment{Amount: 500, Number: 1})
+	want := []referral.Reward{{Level: referral.LevelFirst, Kind: referral.KindMoney, Amount: 150}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("first payment: got %+v, want %+v", got, want)
+	}
+	if got := rules.Compute(referral.Payment{Amount: 500, Number: 2}); len(got) != 0 {
+		t.Fatalf("second payment must not be rewarded, got %+v", got)
+	}
+}
+
+func TestComputePercentEveryPayment(t *testing.T) {
+	rules := referral.Rules{Mode: referral.ModePercent, Percent: 10, Payments: 0}
+	for n := 1; n <= 5; n++ {
+		got := rules.Compute(referral.Payment{Amount: 299, Number: n})
+		if len(got) != 1 || got[0].Amount != 29.9 || got[0].Kind != referral.KindMoney {
+			t.Fatalf("payment %d: unexpected rewards %+v", n, got)
+		}
+	}
+}
+
+func TestComputePercentFirstN(t *testing.T) {
+	rules := referral.Rules{Mode: referral.ModePercent, Percent: 20, Payments: 3}
+	if got := rules.Compute(referral.Payment{Amount: 100, Number: 3}); len(got) != 1 || got[0].Amount != 20 {
+		t.Fatalf("third payment: unexpected rewards %+v", got)
+	}
+	if got := rules.Compute(referral.Payment{Amount: 100, Number: 4}); len(got) != 0 {
+		t.Fatalf("fourth payment must not be rewarded, got %+v", got)
+	}
+}
+
+func TestComputeDaysWithRefereeAndSecondLevel(t *testing.T) {
+	rules := referral.Rules{
+		Mode:               referral.ModeDays,
+		Days:               14,
+		Payments:           1,
+		RefereeBonus:       100,
+		RefereeDays:        3,
+		SecondLevelPercent: 50,
+	}
+	got := rules.Compute(referral.Payment{Amount: 299, Number: 1})
+	want := []referral.Reward{
+		{Level: referral.LevelReferee, Kind: referral.KindMoney, Amount: 100},
+		{Level: referral.LevelReferee, Kind: referral.KindDays, Days: 3},
+		{Level: referral.LevelFirst, Kind: referral.KindDays, Days: 14},
+		{Level: referral.LevelSecond, Kind: referral.KindDays, Days: 7},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestComputeSecondLevelMoney(t *testing.T) {
+	rules := referral.Rules{Mode: referral.ModePercent, Percent: 10, SecondLevelPercent: 25}
+	got := rules.Compute(referral.Payment{Amount: 1000, Number: 7})
+	if len(got) != 2 || got[1].Level != referral.LevelSecond || got[1].Amount != 25 {
+		t.Fatalf("unexpected rewards %+v", got)
+	}
+}
+
+func TestComputeDisabled(t *testing.T) {
+	rules := referral.Rules{Mode: referral.ModeFixed, Bonus: 0, Payments: 1, SecondLevelPercent: 50}
+	if got := rules.Compute(referral.Payment{Amount: 100, Number: 1}); len(got) != 0 {
+		t.Fatalf("expected no rewards, got %+v", got)
+	}
+}
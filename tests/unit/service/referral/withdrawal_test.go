@@ -0,0 +1,111 @@
+package referral_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/internal/service/referral"
+	"remnawave-tg-shop-bot/tests/testutils"
+)
+
+type stubWithdrawals struct {
+	available float64
+	stored    []*pg.ReferralWithdrawal
+}
+
+func (s *stubWithdrawals) Withdrawable(ctx context.Context, telegramID int64) (float64, error) {
+	return s.available, nil
+}
+func (s *stubWithdrawals) Create(ctx context.Context, telegramID int64, amount float64, details string) (*pg.ReferralWithdrawal, error) {
+	if amount > s.available {
+		return nil, pg.ErrInsufficientFunds
+	}
+	s.available -= amount
+	w := &pg.ReferralWithdrawal{ID: int64(len(s.stored) + 1), CustomerID: telegramID, Amount: amount, Details: details,
+		Status: pg.ReferralWithdrawalStatusHeld, CreatedAt: time.Now()}
+	s.stored = append(s.stored, w)
+	return w, nil
+}
+func (s *stubWithdrawals) FindHeld(ctx context.Context, limit int) ([]pg.ReferralWithdrawal, error) {
+	var list []pg.ReferralWithdrawal
+	for _, w := range s.stored {
+		if w.Status == pg.ReferralWithdrawalStatusHeld {
+			list = append(list, *w)
+		}
+	}
+	return list, nil
+}
+func (s *stubWithdrawals) MarkPaid(ctx context.Context, id, adminID int64) (*pg.ReferralWithdrawal, error) {
+	return s.process(id, pg.ReferralWithdrawalStatusPaid), nil
+}
+func (s *stubWithdrawals) Reject(ctx context.Context, id, adminID int64) (*pg.ReferralWithdrawal, error) {
+	w := s.process(id, pg.ReferralWithdrawalStatusRejected)
+	if w != nil {
+		s.available += w.Amount
+	}
+	return w, nil
+}
+func (s *stubWithdrawals) process(id int64, status string) *pg.ReferralWithdrawal {
+	for _, w := range s.stored {
+		if w.ID == id && w.Status == pg.ReferralWithdrawalStatusHeld {
+			w.Status = status
+			return w
+		}
+	}
+	return nil
+}
+
+func TestParseWithdrawal(t *testing.T) {
+	amount, details, err := referral.ParseWithdrawal("  1500,50 card 2200 0000 ")
+	if err != nil || amount != 1500.5 || details != "card 2200 0000" {
+		t.Fatalf("got %v %q %v", amount, details, err)
+	}
+	for _, text := range []string{"", "1500", "abc card", "-5 card", "0 card"} {
+		if _, _, err := referral.ParseWithdrawal(text); !errors.Is(err, referral.ErrWithdrawalFormat) {
+			t.Errorf("%q: expected format error, got %v", text, err)
+		}
+	}
+}
+
+func TestRequestWithdrawal(t *testing.T) {
+	withdrawals := &stubWithdrawals{available: 1000}
+	msg := &stubSender{}
+	rules := referral.Rules{MinWithdrawal: 500}
+	svc := referral.NewService(rules, chain(), &stubRewards{}, &stubDecisions{}, withdrawals, stubPurchases{}, &testutils.StubCustomerRepo{}, nil, msg, newTranslations(t))
+	ctx := context.Background()
+
+	if _, err := svc.RequestWithdrawal(ctx, 20, 100, "card"); !errors.Is(err, referral.ErrWithdrawalBelowMinimum) {
+		t.Fatalf("expected below minimum, got %v", err)
+	}
+	if _, err := svc.RequestWithdrawal(ctx, 20, 1500, "card"); !errors.Is(err, pg.ErrInsufficientFunds) {
+		t.Fatalf("expected insufficient funds, got %v", err)
+	}
+	w, err := svc.RequestWithdrawal(ctx, 20, 700, "card")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if withdrawals.available != 300 {
+		t.Fatalf("amount not held, available %v", withdrawals.available)
+	}
+
+	rejected, err := svc.RejectWithdrawal(ctx, w.ID, 1)
+	if err != nil || rejected == nil {
+		t.Fatalf("reject: %v %v", rejected, err)
+	}
+	if withdrawals.available != 1000 || len(msg.sent) != 1 {
+		t.Fatalf("amount not returned or user not notified: available %v, sent %d", withdrawals.available, len(msg.sent))
+	}
+	if again, err := svc.ApproveWithdrawal(ctx, w.ID, 1); err != nil || again != nil {
+		t.Fatalf("processed withdrawal must not be paid, got %v %v", again, err)
+	}
+}
+
+func TestRequestWithdrawalDisabled(t *testing.T) {
+	svc := referral.NewService(referral.Rules{}, chain(), &stubRewards{}, &stubDecisions{}, &stubWithdrawals{available: 1000}, stubPurchases{}, &testutils.StubCustomerRepo{}, nil, &stubSender{}, newTranslations(t))
+	if _, err := svc.RequestWithdrawal(context.Background(), 20, 700, "card"); !errors.Is(err, referral.ErrWithdrawalDisabled) {
+		t.Fatalf("expected disabled, got %v", err)
+	}
+}
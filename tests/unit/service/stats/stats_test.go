@@ -0,0 +1,103 @@
+package stats_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/internal/service/stats"
+)
+
+type stubRepo struct {
+	from, to time.Time
+	totals   pg.StatsTotals
+	revenue  []pg.RevenueRow
+}
+
+func (s *stubRepo) Totals(ctx context.Context, from, to time.Time) (*pg.StatsTotals, error) {
+	s.from, s.to = from, to
+	t := s.totals
+	return &t, nil
+}
+
+func (s *stubRepo) Revenue(ctx context.Context, from, to time.Time) ([]pg.RevenueRow, error) {
+	return s.revenue, nil
+}
+
+func (s *stubRepo) Subscriptions(ctx context.Context, now, until time.Time) (int, int, error) {
+	return 10, 2, nil
+}
+
+func TestPeriodRange(t *testing.T) {
+	now := time.Date(2026, 3, 15, 13, 45, 0, 0, time.FixedZone("MSK", 3*3600))
+
+	from, to := stats.PeriodDay.Range(now)
+	if !from.Equal(time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)) || !to.Equal(now) {
+		t.Errorf("day: %v — %v", from, to)
+	}
+	from, _ = stats.PeriodWeek.Range(now)
+	if !from.Equal(now.AddDate(0, 0, -7)) {
+		t.Errorf("week starts at %v", from)
+	}
+	from, _ = stats.PeriodAll.Range(now)
+	if from.Year() != 1970 {
+		t.Errorf("all time starts at %v", from)
+	}
+	if _, ok := stats.ParsePeriod("year"); ok {
+		t.Error("unknown period parsed")
+	}
+}
+
+func TestRevenueGroups(t *testing.T) {
+	r := &stats.Report{Revenue: []pg.RevenueRow{
+		{Provider: "crypto", Currency: "USD", Months: 1, Count: 2, Amount: 4.2},
+		{Provider: "crypto", Currency: "USD", Months: 3, Count: 1, Amount: 10},
+		{Provider: "telegram", Currency: "STARS", Months: 1, Count: 3, Amount: 300},
+		{Provider: "tribute", Currency: "USD", Months: 1, Count: 1, Amount: 2.1},
+	}}
+
+	byCurrency := r.ByCurrency()
+	if len(byCurrency) != 2 || byCurrency[0].Currency != "STARS" || byCurrency[1].Count != 4 {
+		t.Fatalf("by currency %+v", byCurrency)
+	}
+	byProvider := r.ByProvider()
+	if len(byProvider) != 3 || byProvider[0].Provider != "crypto" || byProvider[0].Amount != 14.2 {
+		t.Errorf("by provider %+v", byProvider)
+	}
+	byPlan := r.ByPlan()
+	if len(byPlan) != 3 || byPlan[0].Months != 1 || byPlan[0].Currency != "STARS" || byPlan[1].Count != 3 {
+		t.Errorf("by plan %+v", byPlan)
+	}
+}
+
+func TestFormatReport(t *testing.T) {
+	tm := translation.NewManager("en")
+	if err := tm.InitTranslations("../../../../translations"); err != nil {
+		t.Fatal(err)
+	}
+	repo := &stubRepo{
+		totals: pg.StatsTotals{NewCustomers: 5, Trials: 4, ConvertedTrials: 1, RewardMoney: 0.1 + 0.2},
+		revenue: []pg.RevenueRow{
+			{Provider: "crypto", Currency: "USD", Months: 3, Count: 2, Amount: 20},
+		},
+	}
+	svc := stats.NewService(repo, nil, nil, tm)
+
+	report, err := svc.Report(context.Background(), stats.PeriodWeek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.To.Sub(repo.from) != 7*24*time.Hour || !report.To.Equal(repo.to) {
+		t.Errorf("queried %v — %v", repo.from, repo.to)
+	}
+
+	text := svc.Format("en", report)
+	for _, want := range []string{"New customers: 5", "Trial → paid: 1 (25.0%)", "• crypto: 20 USD — 2 payments", "• 3 months: 20 USD", "Rewards released: 0.3 to balance"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("report misses %q:\n%s", want, text)
+		}
+	}
+}
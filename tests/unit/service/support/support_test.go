@@ -0,0 +1,152 @@
+package support_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/internal/service/support"
+)
+
+type stubRepo struct {
+	tickets map[int64]*pg.SupportTicket
+	canned  map[string]string
+}
+
+func newStubRepo() *stubRepo {
+	return &stubRepo{tickets: map[int64]*pg.SupportTicket{}, canned: map[string]string{}}
+}
+
+func (s *stubRepo) FindByCustomer(ctx context.Context, customerID int64) (*pg.SupportTicket, error) {
+	for _, t := range s.tickets {
+		if t.CustomerID == customerID {
+			c := *t
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *stubRepo) FindByThread(ctx context.Context, threadID int) (*pg.SupportTicket, error) {
+	for _, t := range s.tickets {
+		if t.ThreadID == threadID {
+			c := *t
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *stubRepo) Create(ctx context.Context, t *pg.SupportTicket) error {
+	t.ID = int64(len(s.tickets) + 1)
+	c := *t
+	s.tickets[t.ID] = &c
+	return nil
+}
+
+func (s *stubRepo) SetThread(ctx context.Context, id int64, threadID int) error {
+	s.tickets[id].ThreadID = threadID
+	return nil
+}
+
+func (s *stubRepo) SetStatus(ctx context.Context, id int64, status string) (bool, error) {
+	t := s.tickets[id]
+	if t.Status == status {
+		return false, nil
+	}
+	t.Status = status
+	return true, nil
+}
+
+func (s *stubRepo) CannedResponses(ctx context.Context) ([]pg.CannedResponse, error) {
+	return nil, nil
+}
+
+func (s *stubRepo) FindCannedResponse(ctx context.Context, name string) (*pg.CannedResponse, error) {
+	text, ok := s.canned[name]
+	if !ok {
+		return nil, nil
+	}
+	return &pg.CannedResponse{Name: name, Text: text}, nil
+}
+
+func (s *stubRepo) SaveCannedResponse(ctx context.Context, name, text string) error {
+	s.canned[name] = text
+	return nil
+}
+
+func (s *stubRepo) DeleteCannedResponse(ctx context.Context, name string) (bool, error) {
+	_, ok := s.canned[name]
+	delete(s.canned, name)
+	return ok, nil
+}
+
+func TestParseCanned(t *testing.T) {
+	name, text, err := support.ParseCanned(" refund_1 Money is returned\nwithin 3 days ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "refund_1" || text != "Money is returned\nwithin 3 days" {
+		t.Fatalf("got %q %q", name, text)
+	}
+
+	for _, args := range []string{"", "refund", "Refund text", "name\n", strings.Repeat("a", 33) + " text"} {
+		if _, _, err := support.ParseCanned(args); !errors.Is(err, support.ErrFormat) {
+			t.Errorf("%q: got %v, want ErrFormat", args, err)
+		}
+	}
+	if _, _, err := support.ParseCanned("long " + strings.Repeat("a", 4001)); !errors.Is(err, support.ErrFormat) {
+		t.Errorf("long text: got %v", err)
+	}
+}
+
+func TestTicketStatus(t *testing.T) {
+	repo := newStubRepo()
+	svc := support.NewService(repo, nil, nil)
+	ctx := context.Background()
+
+	ticket, err := svc.Create(ctx, 42, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := svc.Reopen(ctx, ticket); changed {
+		t.Error("reopening an open ticket reported a change")
+	}
+	if changed, _ := svc.Close(ctx, ticket); !changed || ticket.Status != pg.SupportTicketClosed {
+		t.Errorf("close: changed=%v status=%q", changed, ticket.Status)
+	}
+	if changed, _ := svc.Close(ctx, ticket); changed {
+		t.Error("closing twice reported a change")
+	}
+
+	if err := svc.MoveThread(ctx, ticket, 9); err != nil {
+		t.Fatal(err)
+	}
+	moved, _ := svc.TicketByThread(ctx, 9)
+	if moved == nil || moved.CustomerID != 42 {
+		t.Fatalf("ticket not found in the new topic: %+v", moved)
+	}
+	if old, _ := svc.TicketByThread(ctx, 7); old != nil {
+		t.Errorf("ticket still bound to the old topic: %+v", old)
+	}
+}
+
+func TestCannedResponseNotFound(t *testing.T) {
+	svc := support.NewService(newStubRepo(), nil, nil)
+	ctx := context.Background()
+
+	if _, err := svc.CannedResponse(ctx, "missing"); !errors.Is(err, support.ErrNotFound) {
+		t.Errorf("find: got %v", err)
+	}
+	if err := svc.DeleteCannedResponse(ctx, "missing"); !errors.Is(err, support.ErrNotFound) {
+		t.Errorf("delete: got %v", err)
+	}
+	if err := svc.SaveCannedResponse(ctx, "hello", "Hi!"); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := svc.CannedResponse(ctx, " hello "); err != nil || c.Text != "Hi!" {
+		t.Errorf("got %+v %v", c, err)
+	}
+}
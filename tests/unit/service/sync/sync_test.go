@@ -0,0 +1,148 @@
+package sync_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	remapi "github.com/Jolymmiles/remnawave-api-go/api"
+
+	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
+	syncsvc "remnawave-tg-shop-bot/internal/service/sync"
+	"remnawave-tg-shop-bot/tests/testutils"
+)
+
+type stubPanel struct {
+	users []remapi.UserDto
+}
+
+func (p *stubPanel) GetUsers(ctx context.Context) (*[]remapi.UserDto, error) {
+	return &p.users, nil
+}
+
+type stubCustomers struct {
+	testutils.StubCustomerRepo
+	existing []domaincustomer.Customer
+	missing  []int64
+	created  []domaincustomer.Customer
+	updated  []domaincustomer.Customer
+	marked   []int64
+}
+
+func (s *stubCustomers) FindByTelegramIds(ctx context.Context, telegramIDs []int64) ([]domaincustomer.Customer, error) {
+	return s.existing, nil
+}
+func (s *stubCustomers) FindMissingFromPanel(ctx context.Context, telegramIDs []int64) ([]int64, error) {
+	return s.missing, nil
+}
+func (s *stubCustomers) CreateBatch(ctx context.Context, customers []domaincustomer.Customer) error {
+	s.created = append(s.created, customers...)
+	return nil
+}
+func (s *stubCustomers) UpdateBatch(ctx context.Context, customers []domaincustomer.Customer) error {
+	s.updated = append(s.updated, customers...)
+	return nil
+}
+func (s *stubCustomers) MarkMissingFromPanel(ctx context.Context, telegramIDs []int64) error {
+	s.marked = append(s.marked, telegramIDs...)
+	return nil
+}
+
+func panelUser(telegramID int, expireAt time.Time, link string) remapi.UserDto {
+	return remapi.UserDto{TelegramId: remapi.NewNilInt(telegramID), ExpireAt: expireAt, SubscriptionUrl: link}
+}
+
+func newFixture() (*stubPanel, *stubCustomers) {
+	expireAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	link := "https://sub/2"
+	missingAt := time.Now()
+	panel := &stubPanel{users: []remapi.UserDto{
+		panelUser(1, expireAt, "https://sub/1"),
+		panelUser(2, expireAt, link),
+		panelUser(3, expireAt, "https://sub/3"),
+		panelUser(3, expireAt, "https://sub/3"),
+		{TelegramId: remapi.NilInt{Null: true}},
+	}}
+	customers := &stubCustomers{
+		existing: []domaincustomer.Customer{
+			// unchanged
+			{TelegramID: 2, ExpireAt: &expireAt, SubscriptionLink: &link, Balance: 50},
+			// back in the panel
+			{TelegramID: 3, ExpireAt: &expireAt, SubscriptionLink: &link, MissingFromPanelAt: &missingAt},
+		},
+		missing: []int64{7, 8},
+	}
+	return panel, customers
+}
+
+func TestSyncDryRunChangesNothing(t *testing.T) {
+	panel, customers := newFixture()
+	svc := syncsvc.NewSyncService(panel, customers)
+
+	report, err := svc.Sync(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.DryRun || report.Created.Count != 1 || report.Updated.Count != 1 || report.Orphaned.Count != 2 {
+		t.Fatalf("report %+v", report)
+	}
+	if len(customers.created)+len(customers.updated)+len(customers.marked) != 0 {
+		t.Errorf("dry run wrote: created %v updated %v marked %v", customers.created, customers.updated, customers.marked)
+	}
+}
+
+func TestSyncAppliesOnlyChanges(t *testing.T) {
+	panel, customers := newFixture()
+	svc := syncsvc.NewSyncService(panel, customers)
+
+	report, err := svc.Sync(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(customers.created) != 1 || customers.created[0].TelegramID != 1 {
+		t.Errorf("created %v", customers.created)
+	}
+	if len(customers.updated) != 1 || customers.updated[0].TelegramID != 3 {
+		t.Errorf("updated %v", customers.updated)
+	}
+	if len(customers.marked) != 2 {
+		t.Errorf("orphans not marked: %v", customers.marked)
+	}
+	if len(report.Orphaned.Sample) != 2 || report.Orphaned.Sample[0] != 7 {
+		t.Errorf("orphan sample %v", report.Orphaned.Sample)
+	}
+}
+
+func TestSyncRefusesEmptyPanel(t *testing.T) {
+	_, customers := newFixture()
+	svc := syncsvc.NewSyncService(&stubPanel{}, customers)
+
+	if _, err := svc.Sync(context.Background(), false); !errors.Is(err, syncsvc.ErrNoUsers) {
+		t.Fatalf("expected ErrNoUsers, got %v", err)
+	}
+	if len(customers.marked) != 0 {
+		t.Errorf("customers marked missing after an empty panel response")
+	}
+}
+
+func TestSyncKeepsThePrimaryUser(t *testing.T) {
+	expireAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	older := panelUser(4, expireAt, "https://sub/old")
+	older.Username = "friend"
+	older.CreatedAt = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	named := panelUser(4, expireAt.AddDate(1, 0, 0), "https://sub/4")
+	named.Username = "shop_4"
+	named.CreatedAt = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	extra := panelUser(4, expireAt, "https://sub/4_sub9")
+	extra.Username = domaincustomer.SubscriptionUsername(4, 9)
+	customers := &stubCustomers{}
+	svc := syncsvc.NewSyncService(&stubPanel{users: []remapi.UserDto{extra, older, named}}, customers)
+
+	if _, err := svc.Sync(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+	if len(customers.created) != 1 || *customers.created[0].SubscriptionLink != "https://sub/4" {
+		t.Errorf("created %+v", customers.created)
+	}
+}
@@ -0,0 +1,124 @@
+package trial_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	remapi "github.com/Jolymmiles/remnawave-api-go/api"
+	"github.com/google/uuid"
+
+	domaincustomer "remnawave-tg-shop-bot/internal/domain/customer"
+	"remnawave-tg-shop-bot/internal/repository/pg"
+	"remnawave-tg-shop-bot/internal/service/trial"
+	"remnawave-tg-shop-bot/tests/testutils"
+)
+
+type stubUsage struct{ used map[int64]string }
+
+func (s *stubUsage) Claim(ctx context.Context, telegramID int64, variant string) (bool, error) {
+	if _, ok := s.used[telegramID]; ok {
+		return false, nil
+	}
+	s.used[telegramID] = variant
+	return true, nil
+}
+func (s *stubUsage) FindByTelegramId(ctx context.Context, telegramID int64) (*pg.TrialUsage, error) {
+	variant, ok := s.used[telegramID]
+	if !ok {
+		return nil, nil
+	}
+	return &pg.TrialUsage{TelegramID: telegramID, Variant: variant}, nil
+}
+func (s *stubUsage) Release(ctx context.Context, telegramID int64) error {
+	delete(s.used, telegramID)
+	return nil
+}
+
+type stubPanel struct {
+	existing *remapi.UserDto
+	err      error
+	inbounds map[uuid.UUID]uuid.UUID
+	days     int
+}
+
+func (p *stubPanel) GetUserByTelegramID(ctx context.Context, telegramId int64) (*remapi.UserDto, error) {
+	return p.existing, nil
+}
+func (p *stubPanel) CreateOrUpdateUserWithInbounds(ctx context.Context, telegramId int64, trafficLimit int, days int, inbounds map[uuid.UUID]uuid.UUID) (*remapi.UserDto, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	p.inbounds, p.days = inbounds, days
+	return &remapi.UserDto{SubscriptionUrl: "https://sub", ExpireAt: time.Now().AddDate(0, 0, days)}, nil
+}
+
+type stubMembers struct{ member bool }
+
+func (m stubMembers) IsChatMember(ctx context.Context, chat string, userID int64) (bool, error) {
+	return m.member, nil
+}
+
+func TestActivateRecordsUsageOnce(t *testing.T) {
+	inbound := uuid.New()
+	usage := &stubUsage{used: map[int64]string{}}
+	panel := &stubPanel{}
+	rules := trial.Rules{Variant: "promo", Days: 3, Inbounds: map[uuid.UUID]uuid.UUID{inbound: inbound}}
+	svc := trial.NewService(rules, usage, &testutils.StubCustomerRepo{}, panel, stubMembers{})
+	customer := &domaincustomer.Customer{ID: 1, TelegramID: 10}
+
+	link, err := svc.Activate(context.Background(), customer)
+	if err != nil || link != "https://sub" {
+		t.Fatalf("activate: %q %v", link, err)
+	}
+	if usage.used[10] != "promo" || panel.days != 3 || len(panel.inbounds) != 1 {
+		t.Fatalf("unexpected usage %v or panel call %+v", usage.used, panel)
+	}
+	// a recreated customer row must not get a second trial
+	if _, err := svc.Activate(context.Background(), &domaincustomer.Customer{ID: 2, TelegramID: 10}); !errors.Is(err, trial.ErrUsed) {
+		t.Fatalf("expected ErrUsed, got %v", err)
+	}
+}
+
+func TestActivateReleasesUsageOnPanelError(t *testing.T) {
+	usage := &stubUsage{used: map[int64]string{}}
+	svc := trial.NewService(trial.Rules{Days: 3}, usage, &testutils.StubCustomerRepo{}, &stubPanel{err: errors.New("panel down")}, stubMembers{})
+
+	if _, err := svc.Activate(context.Background(), &domaincustomer.Customer{TelegramID: 10}); err == nil {
+		t.Fatal("expected error")
+	}
+	if len(usage.used) != 0 {
+		t.Fatal("trial usage must be released when the panel fails")
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	now := time.Now()
+	link, future := "https://sub", now.Add(time.Hour)
+	cases := []struct {
+		name     string
+		rules    trial.Rules
+		panel    *stubPanel
+		member   bool
+		customer domaincustomer.Customer
+		want     error
+	}{
+		{"disabled", trial.Rules{}, &stubPanel{}, true, domaincustomer.Customer{}, trial.ErrDisabled},
+		{"too new", trial.Rules{Days: 1, MinAccountAge: 24 * time.Hour}, &stubPanel{}, true, domaincustomer.Customer{CreatedAt: now.Add(-time.Hour)}, trial.ErrAccountTooNew},
+		{"old enough", trial.Rules{Days: 1, MinAccountAge: 24 * time.Hour}, &stubPanel{}, true, domaincustomer.Customer{CreatedAt: now.Add(-48 * time.Hour)}, nil},
+		{"panel user", trial.Rules{Days: 1, NewPanelUsersOnly: true}, &stubPanel{existing: &remapi.UserDto{}}, true, domaincustomer.Customer{}, trial.ErrPanelUserExists},
+		{"not a member", trial.Rules{Days: 1, RequiredChannel: "@channel"}, &stubPanel{}, false, domaincustomer.Customer{}, trial.ErrChannelRequired},
+		{"member", trial.Rules{Days: 1, RequiredChannel: "@channel"}, &stubPanel{}, true, domaincustomer.Customer{}, nil},
+		{"paid subscription", trial.Rules{Days: 1}, &stubPanel{}, true, domaincustomer.Customer{SubscriptionLink: &link}, trial.ErrSubscribed},
+		{"active subscription", trial.Rules{Days: 1}, &stubPanel{}, true, domaincustomer.Customer{ExpireAt: &future}, trial.ErrSubscribed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := trial.NewService(tc.rules, &stubUsage{used: map[int64]string{}}, &testutils.StubCustomerRepo{}, tc.panel, stubMembers{member: tc.member})
+			if err := svc.Check(context.Background(), &tc.customer); !errors.Is(err, tc.want) {
+				t.Fatalf("got %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
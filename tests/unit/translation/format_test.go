@@ -0,0 +1,134 @@
+package translation
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+)
+
+func TestPluralCategory(t *testing.T) {
+	cases := []struct {
+		lang string
+		n    int64
+		want string
+	}{
+		{"ru", 1, translation.PluralOne},
+		{"ru", 21, translation.PluralOne},
+		{"ru", 11, translation.PluralMany},
+		{"ru", 2, translation.PluralFew},
+		{"ru", 24, translation.PluralFew},
+		{"ru", 12, translation.PluralMany},
+		{"ru", 5, translation.PluralMany},
+		{"ru", 0, translation.PluralMany},
+		{"en", 1, translation.PluralOne},
+		{"en", 0, translation.PluralOther},
+		{"en", 21, translation.PluralOther},
+	}
+	for _, c := range cases {
+		if got := translation.PluralCategory(c.lang, c.n); got != c.want {
+			t.Errorf("%s %d: got %s, want %s", c.lang, c.n, got, c.want)
+		}
+	}
+}
+
+func TestFormatPluralsAndEscaping(t *testing.T) {
+	tm := translation.GetInstance()
+	if err := tm.InitDefaultTranslations(); err != nil {
+		t.Fatal(err)
+	}
+	for n, want := range map[int]string{1: "1 день", 3: "3 дня", 5: "5 дней", 21: "21 день"} {
+		if got := tm.Format("ru", "referral_amount_days", translation.Args{"days": n}); got != want {
+			t.Errorf("ru %d: got %q, want %q", n, got, want)
+		}
+	}
+	if got := tm.Format("en", "referral_amount_days", translation.Args{"days": 1}); got != "1 day" {
+		t.Errorf("en 1: got %q", got)
+	}
+	// unknown languages use the default translation and its plural rules
+	if got := tm.Format("de", "referral_amount_days", translation.Args{"days": 2}); got != "2 days" {
+		t.Errorf("fallback: got %q", got)
+	}
+
+	dir := t.TempDir()
+	writeFile(t, dir, "en.yml", "greet: 'Hi, <b>{name}</b>'\n")
+	m := translation.NewManager("en")
+	if err := m.InitTranslations(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Format("en", "greet", translation.Args{"name": "<script>"}); got != "Hi, <b>&lt;script&gt;</b>" {
+		t.Errorf("value not escaped: %q", got)
+	}
+	if got := m.Format("en", "greet", translation.Args{"name": translation.Raw("<i>x</i>")}); got != "Hi, <b><i>x</i></b>" {
+		t.Errorf("raw value escaped: %q", got)
+	}
+	// a default template that can't be rendered falls back to the key
+	if got := m.Format("en", "greet", nil); got != "greet" {
+		t.Errorf("missing value: %q", got)
+	}
+}
+
+func TestFormatFallsBackToDefaultLanguage(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "en.yml", "balance: 'Balance: {balance}'\n")
+	writeFile(t, dir, "ru.yml", "balance: 'Баланс: %d'\n")
+	writeFile(t, dir, "de.yml", "balance: 'Guthaben: {amount}'\n")
+	m := translation.NewManager("en")
+	if err := m.InitTranslations(dir); err != nil {
+		t.Fatal(err)
+	}
+	args := translation.Args{"balance": 100}
+	for _, lang := range []string{"ru", "de"} {
+		if got := m.Format(lang, "balance", args); got != "Balance: 100" {
+			t.Errorf("%s: got %q", lang, got)
+		}
+	}
+}
+
+func TestValidateDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "en.yml", strings.Join([]string{
+		"days: '{days, plural, one {# day} other {# days}}'",
+		"balance: 'Balance: %d'",
+		"greet: 'Hi {name}'",
+		"only_en: 'x'",
+	}, "\n"))
+	writeFile(t, dir, "ru.yml", strings.Join([]string{
+		"days: '{days, plural, one {# день} few {# дня}}'",
+		"balance: 'Баланс: %s'",
+		"greet: 'Привет {user}'",
+		"only_ru: 'x'",
+	}, "\n"))
+
+	issues, err := translation.ValidateDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, i := range issues {
+		got = append(got, i.Key+": "+strings.SplitN(i.Problem, " ", 2)[0])
+	}
+	want := []string{"balance: format", "days: plural", "greet: placeholders", "only_en: missing", "only_ru: extra"}
+	if strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Fatalf("issues %v, want %v", issues, want)
+	}
+}
+
+func TestShippedTranslationsAreValid(t *testing.T) {
+	issues, err := translation.ValidateDir("../../../translations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range issues {
+		t.Error(i)
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
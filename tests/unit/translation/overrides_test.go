@@ -0,0 +1,65 @@
+package translation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"remnawave-tg-shop-bot/internal/pkg/translation"
+)
+
+func TestOverridesMergeAndReload(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "en.yml", "support_button: 'Get support'\n")
+	writeFile(t, dir, "de.yml", "support_button: 'Hilfe'\n")
+	writeFile(t, dir, "notes.txt", "ignored")
+
+	m := translation.NewManager("en")
+	if err := m.InitWithOverrides(dir); err != nil {
+		t.Fatal(err)
+	}
+	defaults := translation.NewManager("en")
+	if err := defaults.InitDefaultTranslations(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := m.GetText("en", "support_button"); got != "Get support" {
+		t.Errorf("override not applied: %q", got)
+	}
+	// keys missing from the override keep the embedded text
+	if got, want := m.GetText("en", "rate_limited"), defaults.GetText("en", "rate_limited"); got != want {
+		t.Errorf("embedded key lost: %q, want %q", got, want)
+	}
+	if got, want := m.GetText("ru", "support_button"), defaults.GetText("ru", "support_button"); got != want {
+		t.Errorf("ru changed: %q, want %q", got, want)
+	}
+	if !m.Has("de") || m.GetText("de", "support_button") != "Hilfe" {
+		t.Errorf("new language not added: %v", m.Languages())
+	}
+	if got, want := m.GetText("de", "rate_limited"), defaults.GetText("en", "rate_limited"); got != want {
+		t.Errorf("new language fallback: %q, want %q", got, want)
+	}
+
+	writeFile(t, dir, "en.yml", "support_button: 'Help'\n")
+	if err := os.Remove(filepath.Join(dir, "de.yml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetText("en", "support_button"); got != "Help" {
+		t.Errorf("reload not applied: %q", got)
+	}
+	if m.Has("de") {
+		t.Error("removed language still loaded")
+	}
+
+	// a broken file keeps the previous texts in use
+	writeFile(t, dir, "en.yml", "support_button: [")
+	if err := m.Reload(); err == nil {
+		t.Fatal("expected parse error")
+	}
+	if got := m.GetText("en", "support_button"); got != "Help" {
+		t.Errorf("failed reload replaced texts: %q", got)
+	}
+}
@@ -60,7 +60,7 @@ func collectUsedKeys(t *testing.T) map[string]struct{} {
 		if err != nil {
 			return err
 		}
-		re := regexp.MustCompile(`GetText\([^,]+,\s*"([^"]+)"`)
+		re := regexp.MustCompile(`(?:GetText|Format)\([^,]+,\s*"([^"]+)"`)
 		for _, m := range re.FindAllStringSubmatch(string(b), -1) {
 			keys[m[1]] = struct{}{}
 		}